@@ -0,0 +1,223 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/28 21:55
+ */
+package controller
+
+import (
+    "time"
+    "pet/protocol"
+    "pet/model"
+    "pet/utils"
+    "third/go-local"
+)
+
+// 分页获取活动列表，可按日期、展馆筛选
+func GetActivityListByPage(args *protocol.ActivityListArgs, reply *protocol.ActivityListReply) error {
+    utils.Logger.Info("[cmd:activity_list_by_page] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+
+    var day time.Time
+    if args.Day != "" {
+        var err error
+        day, err = time.ParseInLocation(utils.DATE_FORMAT, args.Day, time.Local)
+        if nil != err {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "日期格式错误")
+            utils.Logger.Error("GetActivityListByPage failed, param err: %s \n", err.Error())
+            return err
+        }
+    }
+
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+
+    activity_model := new(model.Activity)
+    activity_list, total_num, err := activity_model.GetActivityListByPage(edition_id, day, args.Hall, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    reply.ActivityList = make([]protocol.ActivityInfoJson, len(activity_list))
+    // format data
+    for i := range activity_list {
+        model.CopyActivityData(&activity_list[i], &reply.ActivityList[i])
+    }
+    reply.TotalNum = total_num
+
+    return nil
+}
+
+// 活动详情
+func GetActivityDetail(args *protocol.ActivityDetailArgs, reply *protocol.ActivityDetailReply) error {
+    utils.Logger.Info("[cmd:activity_detail] args: %+v", args)
+
+    var err error
+    if args.Id <= 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "活动id错误")
+        utils.Logger.Error("GetActivityDetail failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    activity_model := new(model.Activity)
+    found, err := activity_model.GetActivityById(args.Id)
+    if nil != err {
+        return err
+    }
+    if !found || !activity_model.Visible() {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "活动不存在")
+        utils.Logger.Error("GetActivityDetail failed, id: %d, err: %s", args.Id, err.Error())
+        return err
+    }
+
+    model.CopyActivityData(activity_model, &reply.Activity)
+    return nil
+}
+
+// 获取可报名的活动，活动开始后或已取消不能报名、取消报名
+func getOpenActivity(activity_id int64) (*model.Activity, error) {
+    var err error
+    if activity_id <= 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "活动id错误")
+        return nil, err
+    }
+
+    activity_model := new(model.Activity)
+    found, err := activity_model.GetActivityById(activity_id)
+    if nil != err {
+        return nil, err
+    }
+    if !found || !activity_model.Visible() {
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "活动不存在")
+    }
+    if !activity_model.SignupOpen(time.Now()) {
+        return nil, utils.NewInternalErrorByStr(utils.ActivityClosedErrCode, "活动已开始或已取消")
+    }
+    return activity_model, nil
+}
+
+// 报名活动，名额已满时进入候补
+func SignupActivity(args *protocol.SignupActivityArgs, reply *protocol.SignupActivityReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:signup_activity] args: %+v", args)
+
+    _, err := getOpenActivity(args.ActivityId)
+    if nil != err {
+        utils.Logger.Error("SignupActivity failed, activity_id: %d, err: %v", args.ActivityId, err)
+        return err
+    }
+
+    signup, err := model.SignupActivity(args.ActivityId, args.UserId)
+    if nil != err {
+        return err
+    }
+
+    reply.Status = signup.Status
+    if signup.Status == model.SIGNUP_STATUS_WAITING {
+        reply.WaitingPosition, err = signup.WaitingPosition()
+        if nil != err {
+            return err
+        }
+    }
+    return nil
+}
+
+// 取消报名，释放的名额由候补第一位递补并通知
+func CancelActivitySignup(args *protocol.CancelActivitySignupArgs, reply *protocol.CancelActivitySignupReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:cancel_activity_signup] args: %+v", args)
+
+    activity_model, err := getOpenActivity(args.ActivityId)
+    if nil != err {
+        utils.Logger.Error("CancelActivitySignup failed, activity_id: %d, err: %v", args.ActivityId, err)
+        return err
+    }
+
+    signup := new(model.ActivitySignup)
+    found, err := signup.GetSignup(args.ActivityId, args.UserId)
+    if nil != err {
+        return err
+    }
+    if !found || signup.Status == model.SIGNUP_STATUS_CANCELLED {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "没有报名该活动")
+        utils.Logger.Error("CancelActivitySignup failed, activity_id: %d, user_id: %d, err: %s",
+            args.ActivityId, args.UserId, err.Error())
+        return err
+    }
+
+    promoted, err := model.CancelActivitySignup(signup)
+    if nil != err {
+        return err
+    }
+    if nil != promoted {
+        utils.Logger.Info("activity signup promoted, activity_id: %d, user_id: %d", promoted.ActivityId, promoted.UserId)
+        go notifyActivityPromoted(activity_model, promoted.UserId)
+    }
+    return nil
+}
+
+// 通知候补用户递补成功
+func notifyActivityPromoted(activity *model.Activity, user_id int64) {
+    start_time := activity.StartTime.Format("2006-01-02 15:04")
+    NotifyUser(user_id, &Notification{
+        Purpose:    utils.SMS_PURPOSE_ACTIVITY_PROMOTED,
+        Weixin:     map[string]string{
+            "first":    "您候补的活动已报名成功",
+            "keyword1": activity.Title,
+            "keyword2": start_time,
+            "remark":   "请准时参加，如不能参加请提前取消报名",
+        },
+        Sms:        map[string]string{"title": activity.Title, "start_time": start_time},
+    })
+}
+
+// 我的报名列表
+func GetMySignupList(args *protocol.MySignupListArgs, reply *protocol.MySignupListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_signup_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+
+    signup_list, total_num, err := model.GetUserSignupListByPage(args.UserId, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+
+    reply.SignupList = make([]protocol.ActivitySignupJson, len(signup_list))
+    for i := range signup_list {
+        activity_model := new(model.Activity)
+        _, err = activity_model.GetActivityById(signup_list[i].ActivityId)
+        if nil != err {
+            return err
+        }
+        model.CopyActivityData(activity_model, &reply.SignupList[i].Activity)
+        reply.SignupList[i].Status = signup_list[i].Status
+        if signup_list[i].Status == model.SIGNUP_STATUS_WAITING {
+            reply.SignupList[i].WaitingPosition, err = signup_list[i].WaitingPosition()
+            if nil != err {
+                return err
+            }
+        }
+    }
+    reply.TotalNum = total_num
+
+    return nil
+}
@@ -22,8 +22,13 @@ func GetArticleListByPage(args *protocol.ArticleListArgs, reply *protocol.Articl
         args.PageSize = 10
     }
 
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+
     article_model := new(model.Article)
-    article_list, total_num, err := article_model.GetArticleListByPage(args.Type, args.PageNum, args.PageSize)
+    article_list, total_num, err := article_model.GetArticleListByPage(edition_id, args.Type, args.PageNum, args.PageSize)
     if nil != err {
         return err
     }
@@ -22,8 +22,13 @@ func GetBannerListByPage(args *protocol.BannerListArgs, reply *protocol.BannerLi
         args.PageSize = 10
     }
 
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+
     banner_model := new(model.Banner)
-    banner_list, total_num, err := banner_model.GetBannerListByPage(args.Type, args.PageNum, args.PageSize)
+    banner_list, total_num, err := banner_model.GetBannerListByPage(edition_id, args.Type, args.PageNum, args.PageSize)
     if nil != err {
         return err
     }
@@ -13,3 +13,7 @@ func InitCachePool(redis_conf *utils.RedisConfig) (err error) {
     g_cache, err = utils.InitRedisPool(redis_conf)
     return err
 }
+
+func GetCache() *utils.Cache {
+    return g_cache
+}
@@ -0,0 +1,44 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/24 15:05
+ */
+package controller
+
+import (
+    "bytes"
+    "encoding/base64"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+    "github.com/dchest/captcha"
+)
+
+const (
+    CAPTCHA_EXPIRE = 10 * 60    // 图形验证码10分钟失效
+)
+
+// 图形验证码保存到redis，多实例共享
+func InitCaptcha() {
+    captcha.SetCustomStore(utils.NewCaptchaStore(g_cache, "captcha:", CAPTCHA_EXPIRE))
+}
+
+// 获取图形验证码
+func NewCaptcha(args *protocol.NewCaptchaArgs, reply *protocol.NewCaptchaReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:new_captcha] args: %+v", args)
+
+    id := captcha.NewLen(4)
+    var buf bytes.Buffer
+    err := captcha.WriteImage(&buf, id, captcha.StdWidth, captcha.StdHeight)
+    if nil != err {
+        utils.Logger.Error("write captcha image err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+
+    reply.CaptchaId = id
+    reply.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+    return nil
+}
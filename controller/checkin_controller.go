@@ -0,0 +1,168 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/6 15:50
+ */
+package controller
+
+import (
+    "fmt"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    CHECKIN_GATE_MAX_LEN    = 32
+    CHECKIN_BATCH_MAX       = 500   // 离线同步每次最多记录数
+    CHECKIN_CLOCK_SKEW      = 5 * 60    // 扫码设备时间误差，单位秒
+)
+
+/**
+ * 校验门票二维码并入场
+ *
+ * checkin_time 为入场时间，离线同步时为扫码时间，有效期按入场时间判断
+ * 闸口设备配置了届次时只能入场该届的门票，否则门票所属届次需要已发布，
+ * editions 缓存届次是否已发布，批量同步时共用
+ */
+func checkinTicket(payload, gate_id string, gate_edition_id int64, checkin_time time.Time, operator_id int64,
+    editions map[int64]bool) (*model.Ticket, error) {
+    claims, err := utils.TicketSign.Verify(payload)
+    if nil != err {
+        return nil, utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票二维码无效")
+    }
+    if gate_edition_id != 0 && claims.EditionId != gate_edition_id {
+        return nil, utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "非本届展会门票")
+    }
+    active, cached := editions[claims.EditionId]
+    if !cached {
+        _, active, err = getActiveEdition(claims.EditionId)
+        if nil != err {
+            return nil, err
+        }
+        editions[claims.EditionId] = active
+    }
+    if !active {
+        return nil, utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票所属展会未发布")
+    }
+    if !claims.ValidAt(checkin_time) {
+        return nil, utils.NewInternalErrorByStr(utils.TicketExpiredErrCode, "门票不在有效期内")
+    }
+
+    ticket := new(model.Ticket)
+    found, err := ticket.GetTicketByNo(claims.TicketNo)
+    if nil != err {
+        return nil, err
+    }
+    if !found || ticket.Status != model.TICKET_STATUS_VALID {
+        return nil, utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票不存在或已作废")
+    }
+
+    ok, err := ticket.Checkin(gate_id, checkin_time, operator_id)
+    if nil != err {
+        return nil, err
+    }
+    if !ok {
+        // 错误信息中不能有英文冒号
+        desc := fmt.Sprintf("门票已于%s在%s闸口入场", ticket.CheckinTime.Format("01月02日15时04分"), ticket.CheckinGate)
+        return ticket, utils.NewInternalErrorByStr(utils.TicketCheckedInErrCode, desc)
+    }
+    return ticket, nil
+}
+
+func checkinGateValid(gate_id string) bool {
+    return gate_id != "" && len(gate_id) <= CHECKIN_GATE_MAX_LEN
+}
+
+// 扫码入场
+func TicketCheckin(args *protocol.TicketCheckinArgs, reply *protocol.TicketCheckinReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:ticket_checkin] args: %+v", args)
+
+    var err error
+    if args.Payload == "" || !checkinGateValid(args.GateId) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "二维码或闸口不能为空")
+        utils.Logger.Error("TicketCheckin failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    ticket, err := checkinTicket(args.Payload, args.GateId, args.EditionId, time.Now(), args.OperatorId, make(map[int64]bool))
+    if nil != err {
+        utils.Logger.Error("TicketCheckin failed, gate_id: %s, operator_id: %d, err: %v", args.GateId, args.OperatorId, err)
+        return err
+    }
+
+    reply.Checkin.TicketNo = ticket.TicketNo
+    reply.Checkin.EditionId = ticket.EditionId
+    reply.Checkin.CheckinGate = ticket.CheckinGate
+    reply.Checkin.CheckinTime = ticket.CheckinTime.Format(utils.TIME_FORMAT)
+
+    user_model := new(model.User)
+    if err = user_model.GetUserById(ticket.UserId); nil == err {
+        reply.Checkin.UserName = user_model.Name
+    }
+    return nil
+}
+
+/**
+ * 离线入场记录批量同步
+ *
+ * 每条记录单独处理，互不影响，已在其他闸口入场的返回门票已入场
+ */
+func CheckinBatchSync(args *protocol.CheckinBatchSyncArgs, reply *protocol.CheckinBatchSyncReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:checkin_batch_sync] operator_id: %d, records: %d", args.OperatorId, len(args.Records))
+
+    var err error
+    if len(args.Records) == 0 || len(args.Records) > CHECKIN_BATCH_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每次同步1到%d条记录", CHECKIN_BATCH_MAX))
+        utils.Logger.Error("CheckinBatchSync failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    now := time.Now()
+    editions := make(map[int64]bool)
+    reply.Results = make([]protocol.CheckinResultJson, len(args.Records))
+    for i := range args.Records {
+        record := &args.Records[i]
+        result := &reply.Results[i]
+        result.Index = i
+
+        checkin_time := time.Unix(record.CheckinTime, 0)
+        if record.CheckinTime <= 0 || checkin_time.After(now.Add(CHECKIN_CLOCK_SKEW*time.Second)) {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "扫码时间错误")
+        } else if record.Payload == "" || !checkinGateValid(record.GateId) {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "二维码或闸口不能为空")
+        } else {
+            var ticket *model.Ticket
+            ticket, err = checkinTicket(record.Payload, record.GateId, record.EditionId, checkin_time, args.OperatorId, editions)
+            if nil != ticket {
+                result.TicketNo = ticket.TicketNo
+            }
+        }
+
+        if nil == err {
+            result.Code = 200
+            reply.SuccessNum++
+            continue
+        }
+        reply.FailNum++
+        is_user_err, code, info := utils.IsUserErr(err)
+        if !is_user_err {
+            code, info = int(utils.InternalErrorCode), "系统错误"
+        }
+        result.Code = code
+        result.Desc = info
+        utils.Logger.Warning("CheckinBatchSync record failed, index: %d, gate_id: %s, err: %v", i, record.GateId, err)
+    }
+
+    utils.Logger.Info("CheckinBatchSync finished, operator_id: %d, success: %d, fail: %d",
+        args.OperatorId, reply.SuccessNum, reply.FailNum)
+    return nil
+}
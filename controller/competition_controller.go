@@ -0,0 +1,413 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/13 15:00
+ */
+package controller
+
+import (
+    "encoding/json"
+    "strings"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    COMPETITION_CRITERIA_MAX    = 10    // 每个组别最多评分项
+    COMPETITION_JUDGE_MAX       = 20    // 每个组别最多裁判
+    COMPETITION_SCORE_MAX       = 100   // 评分项满分上限
+)
+
+// 获取组别，不存在时返回错误
+func getCompetitionClass(class_id int64) (*model.CompetitionClass, error) {
+    if class_id <= 0 {
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "组别id错误")
+    }
+    class := new(model.CompetitionClass)
+    found, err := class.GetCompetitionClassById(class_id)
+    if nil != err {
+        return nil, err
+    }
+    if !found {
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "比赛组别不存在")
+    }
+    return class, nil
+}
+
+// 组别信息，包括评分项
+func copyCompetitionClass(class *model.CompetitionClass, dst *protocol.CompetitionClassJson) error {
+    criteria, err := model.GetCompetitionCriterionList(class.Id)
+    if nil != err {
+        return err
+    }
+    utils.DumpStruct(dst, class)
+    dst.Criteria = make([]protocol.CompetitionCriterionJson, len(criteria))
+    for i := range criteria {
+        utils.DumpStruct(&dst.Criteria[i], &criteria[i])
+    }
+    return nil
+}
+
+// 是否为组别的裁判
+func isCompetitionJudge(class_id, judge_id int64) (bool, error) {
+    judge_ids, err := model.GetCompetitionJudgeIds(class_id)
+    if nil != err {
+        return false, err
+    }
+    for _, id := range judge_ids {
+        if id == judge_id {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// 组别列表，可按活动筛选
+func GetCompetitionClassList(args *protocol.CompetitionClassListArgs, reply *protocol.CompetitionClassListReply) error {
+    utils.Logger.Info("[cmd:competition_class_list] args: %+v", args)
+
+    class_list, err := model.GetCompetitionClassList(args.ActivityId)
+    if nil != err {
+        return err
+    }
+    reply.ClassList = make([]protocol.CompetitionClassJson, len(class_list))
+    for i := range class_list {
+        err = copyCompetitionClass(&class_list[i], &reply.ClassList[i])
+        if nil != err {
+            return err
+        }
+    }
+    return nil
+}
+
+// 比赛成绩，比赛结束后公布
+func GetCompetitionResult(args *protocol.CompetitionResultArgs, reply *protocol.CompetitionResultReply) error {
+    utils.Logger.Info("[cmd:competition_result] args: %+v", args)
+
+    class, err := getCompetitionClass(args.ClassId)
+    if nil != err {
+        utils.Logger.Error("GetCompetitionResult failed, class_id: %d, err: %v", args.ClassId, err)
+        return err
+    }
+    if class.Status != model.COMPETITION_STATUS_CLOSED {
+        return utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "比赛成绩尚未公布")
+    }
+    err = copyCompetitionClass(class, &reply.Class)
+    if nil != err {
+        return err
+    }
+
+    entry_list, err := model.GetCompetitionEntryList(class.Id)
+    if nil != err {
+        return err
+    }
+    user_ids := make([]int64, len(entry_list))
+    for i := range entry_list {
+        user_ids[i] = entry_list[i].UserId
+    }
+    user_list, err := model.GetUserListByIds(user_ids)
+    if nil != err {
+        return err
+    }
+    owner_names := make(map[int64]string)
+    for _, user := range user_list {
+        owner_names[user.UserId] = user.Name
+    }
+
+    reply.ResultList = make([]protocol.CompetitionResultJson, len(entry_list))
+    for i := range entry_list {
+        entry := &entry_list[i]
+        result := &reply.ResultList[i]
+        result.Ranking = entry.Ranking
+        result.EntryId = entry.Id
+        result.PetName = entry.PetName
+        result.TotalScore = entry.TotalScore
+        json.Unmarshal([]byte(entry.CriterionScores), &result.CriterionScores)
+        result.OwnerName = owner_names[entry.UserId]
+    }
+    return nil
+}
+
+// 宠物报名比赛，退赛后可以重新报名
+func EnterCompetition(args *protocol.EnterCompetitionArgs, reply *protocol.EnterCompetitionReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:enter_competition] args: %+v", args)
+
+    class, err := getCompetitionClass(args.ClassId)
+    if nil != err {
+        utils.Logger.Error("EnterCompetition failed, class_id: %d, err: %v", args.ClassId, err)
+        return err
+    }
+    if args.PetId <= 0 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物id错误")
+    }
+
+    entry, err := model.EnterCompetitionClass(class.Id, args.PetId, args.UserId)
+    if nil != err {
+        utils.Logger.Error("EnterCompetition failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.PetId, err)
+        return err
+    }
+
+    utils.DumpStruct(&reply.Entry, entry)
+    return nil
+}
+
+// 退赛，只能在报名阶段
+func WithdrawCompetition(args *protocol.WithdrawCompetitionArgs, reply *protocol.WithdrawCompetitionReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:withdraw_competition] args: %+v", args)
+
+    class, err := getCompetitionClass(args.ClassId)
+    if nil != err {
+        utils.Logger.Error("WithdrawCompetition failed, class_id: %d, err: %v", args.ClassId, err)
+        return err
+    }
+    return model.WithdrawCompetitionClass(class.Id, args.PetId, args.UserId)
+}
+
+// 我的参赛记录
+func GetMyCompetitionEntryList(args *protocol.MyCompetitionEntryListArgs, reply *protocol.MyCompetitionEntryListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_competition_entry_list] args: %+v", args)
+
+    entry_list, err := model.GetUserCompetitionEntryList(args.UserId)
+    if nil != err {
+        return err
+    }
+    reply.EntryList = make([]protocol.CompetitionEntryJson, len(entry_list))
+    for i := range entry_list {
+        utils.DumpStruct(&reply.EntryList[i], &entry_list[i])
+    }
+    return nil
+}
+
+// 裁判获取组别的参赛记录和自己的评分
+func GetJudgeEntryList(args *protocol.JudgeEntryListArgs, reply *protocol.JudgeEntryListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:judge_entry_list] args: %+v", args)
+
+    class, err := getCompetitionClass(args.ClassId)
+    if nil != err {
+        utils.Logger.Error("GetJudgeEntryList failed, class_id: %d, err: %v", args.ClassId, err)
+        return err
+    }
+    ok, err := isCompetitionJudge(class.Id, args.JudgeId)
+    if nil != err {
+        return err
+    }
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "不是该组别的裁判")
+    }
+    err = copyCompetitionClass(class, &reply.Class)
+    if nil != err {
+        return err
+    }
+
+    entry_list, err := model.GetCompetitionEntryList(class.Id)
+    if nil != err {
+        return err
+    }
+    score_list, err := model.GetJudgeCompetitionScoreList(class.Id, args.JudgeId)
+    if nil != err {
+        return err
+    }
+    scores := make(map[int64][]protocol.CompetitionScoreJson)
+    for _, score := range score_list {
+        scores[score.EntryId] = append(scores[score.EntryId], protocol.CompetitionScoreJson{CriterionId: score.CriterionId, Score: score.Score})
+    }
+
+    reply.EntryList = make([]protocol.JudgeEntryJson, len(entry_list))
+    for i := range entry_list {
+        utils.DumpStruct(&reply.EntryList[i].Entry, &entry_list[i])
+        reply.EntryList[i].Scores = scores[entry_list[i].Id]
+        if nil == reply.EntryList[i].Scores {
+            reply.EntryList[i].Scores = []protocol.CompetitionScoreJson{}
+        }
+    }
+    return nil
+}
+
+/**
+ * 裁判提交评分，需要一次提交所有评分项，比赛结束前可以重新提交覆盖
+ *
+ * 比赛结束后评分不能修改
+ */
+func SubmitCompetitionScore(args *protocol.SubmitCompetitionScoreArgs, reply *protocol.SubmitCompetitionScoreReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:submit_competition_score] args: %+v", args)
+
+    entry := new(model.CompetitionEntry)
+    found, err := entry.GetCompetitionEntryById(args.EntryId)
+    if nil != err {
+        return err
+    }
+    if !found || entry.Status != model.ENTRY_STATUS_ENTERED {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "参赛记录不存在")
+    }
+    ok, err := isCompetitionJudge(entry.ClassId, args.JudgeId)
+    if nil != err {
+        return err
+    }
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "不是该组别的裁判")
+    }
+
+    criteria, err := model.GetCompetitionCriterionList(entry.ClassId)
+    if nil != err {
+        return err
+    }
+    max_scores := make(map[int64]int, len(criteria))
+    for _, criterion := range criteria {
+        max_scores[criterion.Id] = criterion.MaxScore
+    }
+    score_list := make([]model.CompetitionScore, 0, len(args.Scores))
+    for _, score := range args.Scores {
+        max_score, ok := max_scores[score.CriterionId]
+        if !ok || score.Score < 0 || score.Score > max_score {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "评分项或分数错误")
+            utils.Logger.Error("SubmitCompetitionScore failed, entry_id: %d, score: %+v", args.EntryId, score)
+            return err
+        }
+        // 每个评分项只能提交一次
+        delete(max_scores, score.CriterionId)
+        score_list = append(score_list, model.CompetitionScore{
+            EntryId:        entry.Id,
+            JudgeId:        args.JudgeId,
+            CriterionId:    score.CriterionId,
+            Score:          score.Score,
+        })
+    }
+    if len(max_scores) > 0 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "需要提交所有评分项")
+    }
+
+    ok, err = model.SubmitCompetitionScores(entry.ClassId, score_list)
+    if nil != err {
+        return err
+    }
+    if !ok {
+        err = utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "比赛不在评分阶段，评分不能提交或修改")
+        utils.Logger.Error("SubmitCompetitionScore failed, class_id: %d, judge_id: %d, err: %v", entry.ClassId, args.JudgeId, err)
+        return err
+    }
+    return nil
+}
+
+// 创建比赛组别，裁判需要有裁判角色
+func CreateCompetitionClass(args *protocol.CreateCompetitionClassArgs, reply *protocol.CreateCompetitionClassReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:create_competition_class] args: %+v", args)
+
+    var err error
+    class := new(model.CompetitionClass)
+    class.ActivityId = args.ActivityId
+    class.Name = strings.TrimSpace(args.Name)
+    class.Species = args.Species
+    class.Description = args.Description
+    if class.Name == "" || utf8.RuneCountInString(class.Name) > 64 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "组别名称不能为空且不能超过64个字")
+    } else if _, ok := model.PetSpeciesNames[class.Species]; class.Species != 0 && !ok {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物种类错误")
+    } else if len(args.Criteria) == 0 || len(args.Criteria) > COMPETITION_CRITERIA_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "评分项数量错误")
+    } else if len(args.JudgeIds) == 0 || len(args.JudgeIds) > COMPETITION_JUDGE_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "裁判数量错误")
+    }
+    if nil != err {
+        utils.Logger.Error("CreateCompetitionClass failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    activity_model := new(model.Activity)
+    found, err := activity_model.GetActivityById(args.ActivityId)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "活动不存在")
+    }
+
+    criteria := make([]model.CompetitionCriterion, len(args.Criteria))
+    for i, criterion := range args.Criteria {
+        name := strings.TrimSpace(criterion.Name)
+        if name == "" || utf8.RuneCountInString(name) > 32 || criterion.Weight <= 0 || criterion.Weight > 100 ||
+            criterion.MaxScore <= 0 || criterion.MaxScore > COMPETITION_SCORE_MAX {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "评分项名称、权重或满分错误")
+            utils.Logger.Error("CreateCompetitionClass failed, criterion: %+v", criterion)
+            return err
+        }
+        criteria[i].Name = name
+        criteria[i].Weight = criterion.Weight
+        criteria[i].MaxScore = criterion.MaxScore
+    }
+
+    judge_set := make(map[int64]bool)
+    for _, judge_id := range args.JudgeIds {
+        user_model := new(model.User)
+        if err = user_model.GetUserById(judge_id); nil != err {
+            return err
+        }
+        if judge_set[judge_id] || user_model.UserId == 0 || !user_model.HasRole(model.USER_ROLE_JUDGE) {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "裁判重复或不是裁判账号")
+            utils.Logger.Error("CreateCompetitionClass failed, judge_id: %d, err: %v", judge_id, err)
+            return err
+        }
+        judge_set[judge_id] = true
+    }
+
+    err = model.CreateCompetitionClass(class, criteria, args.JudgeIds)
+    if nil != err {
+        return err
+    }
+    utils.Logger.Info("competition class created, id: %d, operator_id: %d", class.Id, args.OperatorId)
+    return copyCompetitionClass(class, &reply.Class)
+}
+
+// 推进组别状态，报名中 => 评分中 => 已结束，结束时计算名次
+func UpdateCompetitionStatus(args *protocol.UpdateCompetitionStatusArgs, reply *protocol.UpdateCompetitionStatusReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:update_competition_status] args: %+v", args)
+
+    class, err := getCompetitionClass(args.ClassId)
+    if nil != err {
+        utils.Logger.Error("UpdateCompetitionStatus failed, class_id: %d, err: %v", args.ClassId, err)
+        return err
+    }
+
+    var ok bool
+    switch args.Status {
+    case model.COMPETITION_STATUS_JUDGING:
+        ok, err = class.UpdateStatus(model.COMPETITION_STATUS_OPEN, model.COMPETITION_STATUS_JUDGING)
+    case model.COMPETITION_STATUS_CLOSED:
+        ok, err = model.CloseCompetitionClass(class.Id)
+    default:
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "状态错误")
+    }
+    if nil != err {
+        return err
+    }
+    if !ok {
+        err = utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "组别当前状态不能修改为该状态")
+        utils.Logger.Error("UpdateCompetitionStatus failed, class_id: %d, status: %d, err: %v", args.ClassId, args.Status, err)
+        return err
+    }
+    utils.Logger.Info("competition class status updated, id: %d, status: %d, operator_id: %d", class.Id, args.Status, args.OperatorId)
+    return nil
+}
@@ -0,0 +1,211 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/17 21:10
+ */
+package controller
+
+import (
+    "net"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    EDITION_HOST_CACHE_EXPIRE   = 300   // 域名对应届次的缓存时间，秒
+)
+
+// 域名对应的届次，没有绑定时缓存0
+func editionHostKey(host string) string {
+    return "edition_host:" + host
+}
+
+// 请求域名，去掉端口并转小写
+func normalizeHost(host string) string {
+    host = strings.ToLower(strings.TrimSpace(host))
+    if name, _, err := net.SplitHostPort(host); nil == err {
+        host = name
+    }
+    return host
+}
+
+// 当前届次
+func CurrentEditionId() int64 {
+    return utils.Config.EditionSetting.CurrentId
+}
+
+// 域名绑定的届次，没有绑定时返回0
+func hostEditionId(host string) (int64, error) {
+    host = normalizeHost(host)
+    if host == "" {
+        return 0, nil
+    }
+
+    key := editionHostKey(host)
+    res, err := g_cache.Get(key)
+    if nil == err && res != nil {
+        edition_id, err := strconv.ParseInt(string(res), 10, 64)
+        if nil == err {
+            return edition_id, nil
+        }
+    }
+
+    edition := new(model.Edition)
+    found, err := edition.GetEditionByHost(host)
+    if nil != err {
+        return 0, err
+    }
+    var edition_id int64
+    if found {
+        edition_id = edition.Id
+    }
+    g_cache.Set(key, edition_id, EDITION_HOST_CACHE_EXPIRE)
+    return edition_id, nil
+}
+
+/**
+ * 列表接口使用的届次
+ *
+ * 优先使用参数指定的届次，其次按请求域名确定，都没有时使用当前届次
+ * 当前届次也没有配置时返回0，不按届次过滤
+ */
+func resolveEditionId(edition_id int64, host string) (int64, error) {
+    if edition_id > 0 {
+        return edition_id, nil
+    }
+    edition_id, err := hostEditionId(host)
+    if nil != err {
+        return 0, err
+    }
+    if edition_id == 0 {
+        edition_id = CurrentEditionId()
+    }
+    return edition_id, nil
+}
+
+// 已发布的届次列表
+func GetEditionList(args *protocol.EditionListArgs, reply *protocol.EditionListReply) error {
+    utils.Logger.Info("[cmd:edition_list] args: %+v", args)
+
+    edition_list, err := model.GetEditionList()
+    if nil != err {
+        return err
+    }
+    reply.EditionList = make([]protocol.EditionInfoJson, len(edition_list))
+    for i := range edition_list {
+        model.CopyEditionData(&edition_list[i], &reply.EditionList[i])
+    }
+    reply.CurrentId = CurrentEditionId()
+    return nil
+}
+
+// 当前届次，按请求域名确定，没有绑定时为默认届次
+func GetCurrentEdition(args *protocol.CurrentEditionArgs, reply *protocol.CurrentEditionReply) error {
+    utils.Logger.Info("[cmd:current_edition] args: %+v", args)
+
+    edition_id, err := resolveEditionId(0, args.Host)
+    if nil != err {
+        return err
+    }
+
+    edition := new(model.Edition)
+    found := false
+    if edition_id != 0 {
+        found, err = edition.GetEditionById(edition_id)
+        if nil != err {
+            return err
+        }
+    }
+    if !found {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "没有当前届次")
+        utils.Logger.Error("GetCurrentEdition failed, host: %s, edition_id: %d", args.Host, edition_id)
+        return err
+    }
+    model.CopyEditionData(edition, &reply.Edition)
+    return nil
+}
+
+// 新建、修改届次，id为0时新建
+func SaveEdition(args *protocol.SaveEditionArgs, reply *protocol.SaveEditionReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:save_edition] args: %+v", args)
+
+    var err error
+    args.Name = strings.TrimSpace(args.Name)
+    args.Host = normalizeHost(args.Host)
+    if args.Name == "" || utf8.RuneCountInString(args.Name) > 64 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "届次名称不能为空且不能超过64个字")
+    } else if utf8.RuneCountInString(args.City) > 32 || len(args.Host) > 128 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "城市或域名过长")
+    } else if args.Status != model.EDITION_STATUS_DRAFT && args.Status != model.EDITION_STATUS_PUBLISHED {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "届次状态错误")
+    }
+    if nil != err {
+        utils.Logger.Error("SaveEdition failed, param err: %s \n", err.Error())
+        return err
+    }
+    start_date, err1 := parseOptionalDate(args.StartDate)
+    end_date, err2 := parseOptionalDate(args.EndDate)
+    if nil != err1 || nil != err2 || (!start_date.IsZero() && !end_date.IsZero() && end_date.Before(start_date)) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "开展、闭展日期错误")
+        utils.Logger.Error("SaveEdition failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    if args.Host != "" && args.Status == model.EDITION_STATUS_PUBLISHED {
+        other := new(model.Edition)
+        found, err := other.GetEditionByHost(args.Host)
+        if nil != err {
+            return err
+        }
+        if found && other.Id != args.Id {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "域名已绑定其他届次")
+            utils.Logger.Error("SaveEdition failed, host: %s, edition_id: %d", args.Host, other.Id)
+            return err
+        }
+    }
+
+    edition := new(model.Edition)
+    old_host := ""
+    if args.Id != 0 {
+        found, err := edition.GetEditionById(args.Id)
+        if nil != err {
+            return err
+        }
+        if !found {
+            err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "届次不存在")
+            utils.Logger.Error("SaveEdition failed, id: %d, err: %s", args.Id, err.Error())
+            return err
+        }
+        old_host = edition.Host
+    }
+    edition.Name = args.Name
+    edition.City = strings.TrimSpace(args.City)
+    edition.Year = args.Year
+    edition.Host = args.Host
+    edition.StartDate = start_date
+    edition.EndDate = end_date
+    edition.Status = args.Status
+    err = edition.Save()
+    if nil != err {
+        return err
+    }
+
+    // 域名或状态变化后清除缓存
+    for _, host := range []string{old_host, edition.Host} {
+        if host != "" {
+            g_cache.Del(editionHostKey(host))
+        }
+    }
+    utils.Logger.Info("save edition, operator_id: %d, edition_id: %d, host: %s", args.OperatorId, edition.Id, edition.Host)
+
+    model.CopyEditionData(edition, &reply.Edition)
+    return nil
+}
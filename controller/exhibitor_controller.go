@@ -0,0 +1,321 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/8 21:20
+ */
+package controller
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    EXHIBITOR_IMPORT_MAX_SIZE   = 5 * 1024 * 1024   // 导入文件最大5M
+    EXHIBITOR_IMPORT_MAX_ROWS   = 5000
+)
+
+// 导入表格的表头，支持中文和英文，英文不区分大小写
+var exhibitorImportColumns = map[string]string{
+    "公司名称":     "name",
+    "展商名称":     "name",
+    "name":         "name",
+    "logo":         "logo",
+    "公司介绍":     "description",
+    "简介":         "description",
+    "description":  "description",
+    "展馆":         "hall",
+    "hall":         "hall",
+    "展位号":       "booth",
+    "booth":        "booth",
+    "产品类别":     "categories",
+    "categories":   "categories",
+    "联系人":       "contact_name",
+    "contact_name": "contact_name",
+    "联系电话":     "contact_phone",
+    "contact_phone": "contact_phone",
+    "邮箱":         "contact_email",
+    "contact_email": "contact_email",
+    "网站":         "website",
+    "website":      "website",
+}
+
+// 产品类别之间的分隔符
+var categorySeparator = regexp.MustCompile(`[,，、;；/|]`)
+
+// 所有产品类别，id => 名称
+func exhibitorCategoryMap() (map[int64]string, error) {
+    category_list, err := model.GetExhibitorCategoryList()
+    if nil != err {
+        return nil, err
+    }
+    categories := make(map[int64]string, len(category_list))
+    for _, category := range category_list {
+        categories[category.Id] = category.Name
+    }
+    return categories, nil
+}
+
+// 产品类别列表
+func GetExhibitorCategoryList(args *protocol.ExhibitorCategoryListArgs, reply *protocol.ExhibitorCategoryListReply) error {
+    utils.Logger.Info("[cmd:exhibitor_category_list] args: %+v", args)
+
+    category_list, err := model.GetExhibitorCategoryList()
+    if nil != err {
+        return err
+    }
+    reply.CategoryList = make([]protocol.ExhibitorCategoryJson, len(category_list))
+    for i := range category_list {
+        reply.CategoryList[i].Id = category_list[i].Id
+        reply.CategoryList[i].Name = category_list[i].Name
+    }
+    return nil
+}
+
+// 分页获取展商列表，可按产品类别、展馆筛选，按公司名称或展位号搜索
+func GetExhibitorListByPage(args *protocol.ExhibitorListArgs, reply *protocol.ExhibitorListReply) error {
+    utils.Logger.Info("[cmd:exhibitor_list_by_page] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+    args.Keyword = strings.TrimSpace(args.Keyword)
+
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+
+    exhibitor_model := new(model.Exhibitor)
+    exhibitor_list, total_num, err := exhibitor_model.GetExhibitorListByPage(edition_id, args.CategoryId, args.Hall, args.Keyword, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    categories, err := exhibitorCategoryMap()
+    if nil != err {
+        return err
+    }
+    reply.ExhibitorList = make([]protocol.ExhibitorInfoJson, len(exhibitor_list))
+    // format data
+    for i := range exhibitor_list {
+        model.CopyExhibitorData(&exhibitor_list[i], &reply.ExhibitorList[i], categories)
+    }
+    reply.TotalNum = total_num
+
+    return nil
+}
+
+// 展商详情
+func GetExhibitorDetail(args *protocol.ExhibitorDetailArgs, reply *protocol.ExhibitorDetailReply) error {
+    utils.Logger.Info("[cmd:exhibitor_detail] args: %+v", args)
+
+    var err error
+    if args.Id <= 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "展商id错误")
+        utils.Logger.Error("GetExhibitorDetail failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    exhibitor_model := new(model.Exhibitor)
+    found, err := exhibitor_model.GetExhibitorById(args.Id)
+    if nil != err {
+        return err
+    }
+    if !found {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "展商不存在")
+        utils.Logger.Error("GetExhibitorDetail failed, id: %d, err: %s", args.Id, err.Error())
+        return err
+    }
+
+    categories, err := exhibitorCategoryMap()
+    if nil != err {
+        return err
+    }
+    model.CopyExhibitorData(exhibitor_model, &reply.Exhibitor, categories)
+    return nil
+}
+
+// 产品类别名称转换为id，不存在的类别自动创建，category_ids 为已有类别，名称 => id
+func importCategoryIds(value string, category_ids map[string]int64) ([]int64, error) {
+    var id_list []int64
+    for _, name := range categorySeparator.Split(value, -1) {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        if utf8.RuneCountInString(name) > 32 {
+            return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "产品类别名称不能超过32个字")
+        }
+        id, ok := category_ids[name]
+        if !ok {
+            category := &model.ExhibitorCategory{Name: name, Sort: len(category_ids) + 1}
+            if err := category.Create(); nil != err {
+                return nil, err
+            }
+            id = category.Id
+            category_ids[name] = id
+            utils.Logger.Info("import exhibitor, create category, id: %d, name: %s", id, name)
+        }
+        id_list = append(id_list, id)
+    }
+    return id_list, nil
+}
+
+/**
+ * 导入一行展商数据，按同一届的公司名称新增或更新，返回是否新增
+ *
+ * 更新时表格中为空的字段不修改
+ */
+func importExhibitorRow(edition_id int64, values map[string]string, category_ids map[string]int64) (bool, error) {
+    name := values["name"]
+    if name == "" {
+        return false, utils.NewInternalErrorByStr(utils.ParameterErrCode, "公司名称不能为空")
+    }
+    if utf8.RuneCountInString(name) > 128 {
+        return false, utils.NewInternalErrorByStr(utils.ParameterErrCode, "公司名称不能超过128个字")
+    }
+    if utf8.RuneCountInString(values["hall"]) > 32 || utf8.RuneCountInString(values["booth"]) > 32 {
+        return false, utils.NewInternalErrorByStr(utils.ParameterErrCode, "展馆、展位号不能超过32个字")
+    }
+    if values["contact_email"] != "" && !utils.EmailValid(values["contact_email"]) {
+        return false, utils.NewInternalErrorByStr(utils.ParameterErrCode, "邮箱格式错误")
+    }
+
+    exhibitor := new(model.Exhibitor)
+    found, err := exhibitor.GetExhibitorByName(edition_id, name)
+    if nil != err {
+        return false, err
+    }
+    exhibitor.EditionId = edition_id
+    exhibitor.Name = name
+
+    fields := map[string]*string{
+        "logo":             &exhibitor.Logo,
+        "description":      &exhibitor.Description,
+        "hall":             &exhibitor.Hall,
+        "booth":            &exhibitor.Booth,
+        "contact_name":     &exhibitor.ContactName,
+        "contact_phone":    &exhibitor.ContactPhone,
+        "contact_email":    &exhibitor.ContactEmail,
+        "website":          &exhibitor.Website,
+    }
+    for column, field := range fields {
+        if values[column] != "" {
+            *field = values[column]
+        }
+    }
+    if values["categories"] != "" {
+        id_list, err := importCategoryIds(values["categories"], category_ids)
+        if nil != err {
+            return false, err
+        }
+        exhibitor.SetCategoryIdList(id_list)
+    }
+
+    if found {
+        return false, exhibitor.Save()
+    }
+    return true, exhibitor.Create()
+}
+
+/**
+ * 从表格批量导入展商，支持csv和xlsx，第一行为表头
+ *
+ * 每行单独导入，失败的行返回行号和原因
+ */
+func ImportExhibitor(args *protocol.ImportExhibitorArgs, reply *protocol.ImportExhibitorReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:import_exhibitor] operator_id: %d, edition_id: %d, filename: %s, size: %d",
+        args.OperatorId, args.EditionId, args.Filename, len(args.Data))
+
+    if args.EditionId == 0 {
+        args.EditionId = CurrentEditionId()
+    } else {
+        found, err := new(model.Edition).GetEditionById(args.EditionId)
+        if nil != err {
+            return err
+        }
+        if !found {
+            return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "届次不存在")
+        }
+    }
+
+    rows, err := utils.ReadSheetRows(args.Filename, args.Data)
+    if nil != err {
+        return err
+    }
+    if len(rows) < 2 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "表格没有数据")
+    }
+    if len(rows) > EXHIBITOR_IMPORT_MAX_ROWS+1 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每次最多导入%d行", EXHIBITOR_IMPORT_MAX_ROWS))
+    }
+
+    // 表头，列下标 => 字段
+    header := make(map[int]string)
+    for i, title := range rows[0] {
+        if column, ok := exhibitorImportColumns[strings.ToLower(title)]; ok {
+            header[i] = column
+        }
+    }
+    has_name := false
+    for _, column := range header {
+        has_name = has_name || column == "name"
+    }
+    if !has_name {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "表头缺少公司名称")
+    }
+
+    category_list, err := model.GetExhibitorCategoryList()
+    if nil != err {
+        return err
+    }
+    category_ids := make(map[string]int64, len(category_list))
+    for _, category := range category_list {
+        category_ids[category.Name] = category.Id
+    }
+
+    reply.Errors = []protocol.ImportErrorJson{}
+    for i, row := range rows[1:] {
+        values := make(map[string]string)
+        empty := true
+        for j, value := range row {
+            if column, ok := header[j]; ok && value != "" {
+                values[column] = value
+                empty = false
+            }
+        }
+        if empty {
+            continue
+        }
+
+        created, err := importExhibitorRow(args.EditionId, values, category_ids)
+        if nil != err {
+            _, _, info := utils.IsUserErr(err)
+            reply.FailNum++
+            reply.Errors = append(reply.Errors, protocol.ImportErrorJson{Row: i + 2, Desc: info})
+            utils.Logger.Warning("import exhibitor row failed, row: %d, name: %s, err: %v", i+2, values["name"], err)
+            continue
+        }
+        if created {
+            reply.CreatedNum++
+        } else {
+            reply.UpdatedNum++
+        }
+    }
+
+    utils.Logger.Info("ImportExhibitor finished, operator_id: %d, created: %d, updated: %d, fail: %d",
+        args.OperatorId, reply.CreatedNum, reply.UpdatedNum, reply.FailNum)
+    return nil
+}
@@ -0,0 +1,308 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/16 21:00
+ */
+package controller
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    FAVORITE_COUNT_EXPIRE       = 10 * 60       // 收藏数缓存过期时间，秒，并发时写回的旧值最多保留这么久
+    FAVORITE_COUNT_BATCH_MAX    = 100
+)
+
+// 收藏数缓存，每个对象一个key，各自过期
+func favoriteCountKey(target_type int, target_id int64) string {
+    return fmt.Sprintf("favorite_count:%d:%d", target_type, target_id)
+}
+
+/**
+ * 批量获取收藏数，target_id => 收藏数
+ *
+ * 优先读缓存，缓存中没有的从数据库统计后写回缓存，缓存不可用时直接读数据库
+ */
+func getFavoriteCounts(target_type int, target_ids []int64) (map[int64]int, error) {
+    counts := make(map[int64]int, len(target_ids))
+    if len(target_ids) == 0 {
+        return counts, nil
+    }
+
+    keys := make([]interface{}, len(target_ids))
+    for i, target_id := range target_ids {
+        keys[i] = favoriteCountKey(target_type, target_id)
+    }
+
+    missing := target_ids
+    values, err := g_cache.MGetValue(keys)
+    if nil != err {
+        utils.Logger.Error("get favorite counts from cache err, target_type: %d, err: %v", target_type, err)
+    } else if len(values) == len(target_ids) {
+        missing = make([]int64, 0)
+        for i, value := range values {
+            bytes, ok := value.([]byte)
+            if !ok {
+                missing = append(missing, target_ids[i])
+                continue
+            }
+            num, err := strconv.Atoi(string(bytes))
+            if nil != err {
+                missing = append(missing, target_ids[i])
+                continue
+            }
+            counts[target_ids[i]] = num
+        }
+    }
+    if len(missing) == 0 {
+        return counts, nil
+    }
+
+    count_list, err := model.GetFavoriteCounts(target_type, missing)
+    if nil != err {
+        return nil, err
+    }
+    model.MergeFavoriteCounts(counts, missing, count_list)
+
+    // 没有被收藏的也写入缓存，避免重复统计
+    for _, target_id := range missing {
+        key := favoriteCountKey(target_type, target_id)
+        if err = g_cache.Set(key, counts[target_id], FAVORITE_COUNT_EXPIRE); nil != err {
+            utils.Logger.Error("set favorite count cache err, key: %s, err: %v", key, err)
+        }
+    }
+    return counts, nil
+}
+
+// 收藏、取消收藏后删除缓存的收藏数，下次读取时从数据库重新统计
+func clearFavoriteCount(target_type int, target_id int64) {
+    key := favoriteCountKey(target_type, target_id)
+    err := g_cache.Del(key)
+    if nil != err {
+        utils.Logger.Error("clear favorite count cache err, key: %s, err: %v", key, err)
+    }
+}
+
+// 收藏对象是否存在，草稿状态的活动不能收藏
+func favoriteTargetExists(target_type int, target_id int64) (bool, error) {
+    switch target_type {
+    case model.FAVORITE_TYPE_EXHIBITOR:
+        return new(model.Exhibitor).GetExhibitorById(target_id)
+    case model.FAVORITE_TYPE_ACTIVITY:
+        activity := new(model.Activity)
+        found, err := activity.GetActivityById(target_id)
+        return found && activity.Visible(), err
+    case model.FAVORITE_TYPE_ARTICLE:
+        return new(model.Article).GetArticleById(target_id)
+    }
+    return false, nil
+}
+
+func checkFavoriteArgs(args *protocol.FavoriteArgs) error {
+    if !model.FavoriteTypeValid(args.TargetType) {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "收藏类型错误")
+    }
+    if args.TargetId <= 0 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "收藏对象id错误")
+    }
+    return nil
+}
+
+// 收藏，重复收藏不报错
+func AddFavorite(args *protocol.FavoriteArgs, reply *protocol.FavoriteReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:add_favorite] args: %+v", args)
+
+    err := checkFavoriteArgs(args)
+    if nil != err {
+        utils.Logger.Error("AddFavorite failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    found, err := favoriteTargetExists(args.TargetType, args.TargetId)
+    if nil != err {
+        return err
+    }
+    if !found {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "收藏对象不存在")
+        utils.Logger.Error("AddFavorite failed, target_type: %d, target_id: %d, err: %s", args.TargetType, args.TargetId, err.Error())
+        return err
+    }
+
+    added, err := model.AddFavorite(args.UserId, args.TargetType, args.TargetId)
+    if nil != err {
+        return err
+    }
+    if added {
+        clearFavoriteCount(args.TargetType, args.TargetId)
+    }
+
+    counts, err := getFavoriteCounts(args.TargetType, []int64{args.TargetId})
+    if nil != err {
+        return err
+    }
+    reply.IsFavorite = true
+    reply.FavoriteNum = counts[args.TargetId]
+    return nil
+}
+
+// 取消收藏，未收藏时不报错
+func RemoveFavorite(args *protocol.FavoriteArgs, reply *protocol.FavoriteReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:remove_favorite] args: %+v", args)
+
+    err := checkFavoriteArgs(args)
+    if nil != err {
+        utils.Logger.Error("RemoveFavorite failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    removed, err := model.RemoveFavorite(args.UserId, args.TargetType, args.TargetId)
+    if nil != err {
+        return err
+    }
+    if removed {
+        clearFavoriteCount(args.TargetType, args.TargetId)
+    }
+
+    counts, err := getFavoriteCounts(args.TargetType, []int64{args.TargetId})
+    if nil != err {
+        return err
+    }
+    reply.IsFavorite = false
+    reply.FavoriteNum = counts[args.TargetId]
+    return nil
+}
+
+/**
+ * 我的收藏，按类型分组，每组按收藏时间倒序
+ *
+ * 已删除的展商、文章和未发布的活动不返回
+ */
+func GetMyFavoriteList(args *protocol.MyFavoriteListArgs, reply *protocol.MyFavoriteListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_favorite_list] args: %+v", args)
+
+    favorite_list, err := model.GetUserFavoriteList(args.UserId)
+    if nil != err {
+        return err
+    }
+    target_ids := make(map[int][]int64)
+    for _, favorite := range favorite_list {
+        target_ids[favorite.TargetType] = append(target_ids[favorite.TargetType], favorite.TargetId)
+    }
+
+    reply.ExhibitorList = make([]protocol.ExhibitorInfoJson, 0)
+    exhibitor_list, err := model.GetExhibitorListByIds(target_ids[model.FAVORITE_TYPE_EXHIBITOR])
+    if nil != err {
+        return err
+    }
+    if len(exhibitor_list) > 0 {
+        categories, err := exhibitorCategoryMap()
+        if nil != err {
+            return err
+        }
+        exhibitors := make(map[int64]*model.Exhibitor, len(exhibitor_list))
+        for i := range exhibitor_list {
+            exhibitors[exhibitor_list[i].Id] = &exhibitor_list[i]
+        }
+        for _, id := range target_ids[model.FAVORITE_TYPE_EXHIBITOR] {
+            if exhibitor, ok := exhibitors[id]; ok {
+                var info protocol.ExhibitorInfoJson
+                model.CopyExhibitorData(exhibitor, &info, categories)
+                reply.ExhibitorList = append(reply.ExhibitorList, info)
+            }
+        }
+    }
+
+    reply.ActivityList = make([]protocol.ActivityInfoJson, 0)
+    activity_list, err := model.GetActivityListByIds(target_ids[model.FAVORITE_TYPE_ACTIVITY])
+    if nil != err {
+        return err
+    }
+    activities := make(map[int64]*model.Activity, len(activity_list))
+    for i := range activity_list {
+        activities[activity_list[i].Id] = &activity_list[i]
+    }
+    for _, id := range target_ids[model.FAVORITE_TYPE_ACTIVITY] {
+        if activity, ok := activities[id]; ok {
+            var info protocol.ActivityInfoJson
+            model.CopyActivityData(activity, &info)
+            reply.ActivityList = append(reply.ActivityList, info)
+        }
+    }
+
+    reply.ArticleList = make([]protocol.ArticleInfoJson, 0)
+    article_list, err := model.GetArticleListByIds(target_ids[model.FAVORITE_TYPE_ARTICLE])
+    if nil != err {
+        return err
+    }
+    articles := make(map[int64]*model.Article, len(article_list))
+    for i := range article_list {
+        articles[article_list[i].Id] = &article_list[i]
+    }
+    for _, id := range target_ids[model.FAVORITE_TYPE_ARTICLE] {
+        if article, ok := articles[id]; ok {
+            var info protocol.ArticleInfoJson
+            utils.DumpStruct(&info, article)
+            reply.ArticleList = append(reply.ArticleList, info)
+        }
+    }
+    return nil
+}
+
+// 批量获取收藏数
+func GetFavoriteCountList(args *protocol.FavoriteCountListArgs, reply *protocol.FavoriteCountListReply) error {
+    utils.Logger.Info("[cmd:favorite_count_list] args: %+v", args)
+
+    var err error
+    if !model.FavoriteTypeValid(args.TargetType) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "收藏类型错误")
+        utils.Logger.Error("GetFavoriteCountList failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    var target_ids []int64
+    for _, value := range strings.Split(args.TargetIds, ",") {
+        value = strings.TrimSpace(value)
+        if value == "" {
+            continue
+        }
+        target_id, err := strconv.ParseInt(value, 10, 64)
+        if nil != err || target_id <= 0 {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "收藏对象id错误")
+            utils.Logger.Error("GetFavoriteCountList failed, target_ids: %s, err: %s", args.TargetIds, err.Error())
+            return err
+        }
+        target_ids = append(target_ids, target_id)
+    }
+    if len(target_ids) > FAVORITE_COUNT_BATCH_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每次最多查询%d个", FAVORITE_COUNT_BATCH_MAX))
+        utils.Logger.Error("GetFavoriteCountList failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    counts, err := getFavoriteCounts(args.TargetType, target_ids)
+    if nil != err {
+        return err
+    }
+    reply.CountList = make([]protocol.FavoriteCountJson, len(target_ids))
+    for i, target_id := range target_ids {
+        reply.CountList[i].TargetId = target_id
+        reply.CountList[i].FavoriteNum = counts[target_id]
+    }
+    return nil
+}
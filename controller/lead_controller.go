@@ -0,0 +1,491 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/21 21:10
+ */
+package controller
+
+import (
+    "fmt"
+    "time"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    LEAD_NOTES_MAX_LEN      = 500       // 备注最多字数
+    LEAD_RATING_MAX         = 5
+    LEAD_EXPORT_MAX_ROWS    = 20000     // 每次导出最多行数
+)
+
+func boolToInt(value bool) int {
+    if value {
+        return 1
+    }
+    return 0
+}
+
+/**
+ * 展商看到的潜在客户
+ *
+ * 观众没有同意查看联系方式时，只返回备注、评级和扫码时间
+ */
+func copyLeadData(from *model.ExhibitorLead, visitor *model.User, dst *protocol.LeadInfoJson) {
+    dst.Id = from.Id
+    dst.ExhibitorId = from.ExhibitorId
+    dst.Notes = from.Notes
+    dst.Rating = from.Rating
+    dst.ConsentContact = from.ConsentContact == 1
+    dst.ConsentMarketing = from.ConsentMarketing == 1
+    dst.ScanNum = from.ScanNum
+    dst.FirstScanTime = formatOrderTime(from.CreateTime)
+    dst.LastScanTime = formatOrderTime(from.LastScanTime)
+    if dst.ConsentContact && nil != visitor {
+        dst.Name = visitor.Name
+        dst.Phone = visitor.Phone
+        dst.Email = visitor.Email
+    }
+}
+
+// 潜在客户对应的观众，user_id => 用户
+func leadVisitorMap(lead_list []model.ExhibitorLead) (map[int64]*model.User, error) {
+    user_ids := make([]int64, 0, len(lead_list))
+    for _, lead := range lead_list {
+        if lead.ConsentContact == 1 {
+            user_ids = append(user_ids, lead.UserId)
+        }
+    }
+    user_list, err := model.GetUserListByIds(user_ids)
+    if nil != err {
+        return nil, err
+    }
+    user_map := make(map[int64]*model.User, len(user_list))
+    for i := range user_list {
+        user_map[user_list[i].UserId] = &user_list[i]
+    }
+    return user_map, nil
+}
+
+// 校验当前用户是展商的工作人员，只能操作所属展商的潜在客户
+func checkLeadStaff(exhibitor_id, user_id int64) (*model.Exhibitor, error) {
+    if exhibitor_id <= 0 {
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "展商不能为空")
+    }
+    is_staff, err := model.IsExhibitorStaff(exhibitor_id, user_id)
+    if nil != err {
+        return nil, err
+    }
+    if !is_staff {
+        utils.Logger.Warning("not exhibitor staff, exhibitor_id: %d, user_id: %d", exhibitor_id, user_id)
+        return nil, utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "不是该展商的工作人员")
+    }
+
+    exhibitor := new(model.Exhibitor)
+    found, err := exhibitor.GetExhibitorById(exhibitor_id)
+    if nil != err {
+        return nil, err
+    }
+    if !found {
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "展商不存在")
+    }
+    return exhibitor, nil
+}
+
+func checkLeadNotes(notes string, rating int) error {
+    if utf8.RuneCountInString(notes) > LEAD_NOTES_MAX_LEN {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("备注不能超过%d个字", LEAD_NOTES_MAX_LEN))
+    }
+    if rating < 0 || rating > LEAD_RATING_MAX {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("评级为0到%d，0为未评级", LEAD_RATING_MAX))
+    }
+    return nil
+}
+
+// 添加展商工作人员，需要已注册
+func AddExhibitorStaff(args *protocol.AddExhibitorStaffArgs, reply *protocol.AddExhibitorStaffReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:add_exhibitor_staff] args: %+v", args)
+
+    var err error
+    if args.ExhibitorId <= 0 || args.Phone == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "展商和电话号码不能为空")
+        utils.Logger.Error("AddExhibitorStaff failed, param err: %s \n", err.Error())
+        return err
+    }
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("AddExhibitorStaff failed, normalize phone err: %v", err)
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
+    }
+
+    exhibitor := new(model.Exhibitor)
+    found, err := exhibitor.GetExhibitorById(args.ExhibitorId)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "展商不存在")
+    }
+
+    err, exist, user_info := model.CheckPhoneExist(args.Phone)
+    if nil != err {
+        return err
+    }
+    if !exist {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "该电话号码未注册")
+    }
+
+    if err = model.AddExhibitorStaff(args.ExhibitorId, user_info.UserId, args.OperatorId); nil != err {
+        return err
+    }
+    utils.Logger.Info("add exhibitor staff, exhibitor_id: %d, user_id: %d, operator_id: %d",
+        args.ExhibitorId, user_info.UserId, args.OperatorId)
+
+    reply.Staff.UserId = user_info.UserId
+    reply.Staff.Name = user_info.Name
+    reply.Staff.Phone = user_info.Phone
+    reply.Staff.CreateTime = time.Now().Format(utils.TIME_FORMAT)
+    return nil
+}
+
+// 移除展商工作人员，已记录的潜在客户保留在展商下
+func RemoveExhibitorStaff(args *protocol.RemoveExhibitorStaffArgs, reply *protocol.RemoveExhibitorStaffReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:remove_exhibitor_staff] args: %+v", args)
+
+    if args.ExhibitorId <= 0 || args.UserId <= 0 {
+        err := utils.NewInternalErrorByStr(utils.ParameterErrCode, "展商和用户不能为空")
+        utils.Logger.Error("RemoveExhibitorStaff failed, param err: %s \n", err.Error())
+        return err
+    }
+    return model.RemoveExhibitorStaff(args.ExhibitorId, args.UserId)
+}
+
+// 展商工作人员列表
+func GetExhibitorStaffList(args *protocol.ExhibitorStaffListArgs, reply *protocol.ExhibitorStaffListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:exhibitor_staff_list] args: %+v", args)
+
+    staff_list, err := model.GetExhibitorStaffList(args.ExhibitorId)
+    if nil != err {
+        return err
+    }
+    user_ids := make([]int64, len(staff_list))
+    for i, staff := range staff_list {
+        user_ids[i] = staff.UserId
+    }
+    user_list, err := model.GetUserListByIds(user_ids)
+    if nil != err {
+        return err
+    }
+    user_map := make(map[int64]*model.User, len(user_list))
+    for i := range user_list {
+        user_map[user_list[i].UserId] = &user_list[i]
+    }
+
+    reply.StaffList = make([]protocol.ExhibitorStaffJson, len(staff_list))
+    for i, staff := range staff_list {
+        reply.StaffList[i].UserId = staff.UserId
+        reply.StaffList[i].CreateTime = formatOrderTime(staff.CreateTime)
+        if user_info, ok := user_map[staff.UserId]; ok {
+            reply.StaffList[i].Name = user_info.Name
+            reply.StaffList[i].Phone = user_info.Phone
+        }
+    }
+    return nil
+}
+
+// 当前用户作为工作人员所属的展商
+func GetStaffExhibitorList(args *protocol.StaffExhibitorListArgs, reply *protocol.StaffExhibitorListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:staff_exhibitor_list] args: %+v", args)
+
+    exhibitor_ids, err := model.GetStaffExhibitorIds(args.UserId)
+    if nil != err {
+        return err
+    }
+    exhibitor_list, err := model.GetExhibitorListByIds(exhibitor_ids)
+    if nil != err {
+        return err
+    }
+    reply.ExhibitorList = make([]protocol.StaffExhibitorJson, len(exhibitor_list))
+    for i, exhibitor := range exhibitor_list {
+        reply.ExhibitorList[i].Id = exhibitor.Id
+        reply.ExhibitorList[i].EditionId = exhibitor.EditionId
+        reply.ExhibitorList[i].Name = exhibitor.Name
+        reply.ExhibitorList[i].Booth = exhibitor.Booth
+    }
+    return nil
+}
+
+/**
+ * 展位扫观众门票记录潜在客户
+ *
+ * 授权需要观众当面确认，不传时视为不同意；同一观众重复扫码更新记录，授权以最后一次为准
+ */
+func ScanLead(args *protocol.ScanLeadArgs, reply *protocol.ScanLeadReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:scan_lead] user_id: %d, exhibitor_id: %d, rating: %d, consent_contact: %v, consent_marketing: %v",
+        args.UserId, args.ExhibitorId, args.Rating, args.ConsentContact, args.ConsentMarketing)
+
+    var err error
+    if args.Payload == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "二维码不能为空")
+        utils.Logger.Error("ScanLead failed, param err: %s \n", err.Error())
+        return err
+    }
+    if err = checkLeadNotes(args.Notes, args.Rating); nil != err {
+        return err
+    }
+    exhibitor, err := checkLeadStaff(args.ExhibitorId, args.UserId)
+    if nil != err {
+        return err
+    }
+
+    claims, err := utils.TicketSign.Verify(args.Payload)
+    if nil != err {
+        return utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票二维码无效")
+    }
+    if claims.EditionId != exhibitor.EditionId {
+        return utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "非本届展会门票")
+    }
+    ticket := new(model.Ticket)
+    found, err := ticket.GetTicketByNo(claims.TicketNo)
+    if nil != err {
+        return err
+    }
+    if !found || ticket.Status != model.TICKET_STATUS_VALID {
+        return utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票不存在或已作废")
+    }
+
+    lead := &model.ExhibitorLead{
+        ExhibitorId:        exhibitor.Id,
+        EditionId:          exhibitor.EditionId,
+        UserId:             ticket.UserId,
+        TicketNo:           ticket.TicketNo,
+        ScannedBy:          args.UserId,
+        Notes:              args.Notes,
+        Rating:             args.Rating,
+        ConsentContact:     boolToInt(args.ConsentContact),
+        ConsentMarketing:   boolToInt(args.ConsentMarketing),
+    }
+    if err = model.SaveLeadScan(lead); nil != err {
+        return err
+    }
+    utils.Logger.Info("scan lead, exhibitor_id: %d, visitor_id: %d, lead_id: %d, scan_num: %d",
+        lead.ExhibitorId, lead.UserId, lead.Id, lead.ScanNum)
+
+    visitor := new(model.User)
+    if err = visitor.GetUserById(lead.UserId); nil != err {
+        return err
+    }
+    copyLeadData(lead, visitor, &reply.Lead)
+    return nil
+}
+
+// 修改潜在客户的备注和评级
+func UpdateLead(args *protocol.UpdateLeadArgs, reply *protocol.UpdateLeadReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:update_lead] user_id: %d, exhibitor_id: %d, lead_id: %d, rating: %d",
+        args.UserId, args.ExhibitorId, args.LeadId, args.Rating)
+
+    err := checkLeadNotes(args.Notes, args.Rating)
+    if nil != err {
+        return err
+    }
+    if _, err = checkLeadStaff(args.ExhibitorId, args.UserId); nil != err {
+        return err
+    }
+
+    lead := new(model.ExhibitorLead)
+    found, err := lead.GetExhibitorLead(args.ExhibitorId, args.LeadId)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "潜在客户不存在")
+    }
+    if err = lead.UpdateNotes(args.Notes, args.Rating); nil != err {
+        return err
+    }
+
+    visitor := new(model.User)
+    if err = visitor.GetUserById(lead.UserId); nil != err {
+        return err
+    }
+    copyLeadData(lead, visitor, &reply.Lead)
+    return nil
+}
+
+// 展商的潜在客户列表
+func GetLeadList(args *protocol.LeadListArgs, reply *protocol.LeadListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:lead_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 20
+    }
+
+    _, err := checkLeadStaff(args.ExhibitorId, args.UserId)
+    if nil != err {
+        return err
+    }
+    lead_list, total_num, err := model.GetExhibitorLeadListByPage(args.ExhibitorId, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    user_map, err := leadVisitorMap(lead_list)
+    if nil != err {
+        return err
+    }
+    reply.LeadList = make([]protocol.LeadInfoJson, len(lead_list))
+    for i := range lead_list {
+        copyLeadData(&lead_list[i], user_map[lead_list[i].UserId], &reply.LeadList[i])
+    }
+    reply.TotalNum = total_num
+    return nil
+}
+
+/**
+ * 导出展商的潜在客户，返回csv
+ *
+ * 和列表一样，观众没有同意的不导出联系方式
+ */
+func ExportLead(args *protocol.ExportLeadArgs, reply *protocol.ExportLeadReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:export_lead] args: %+v", args)
+
+    _, err := checkLeadStaff(args.ExhibitorId, args.UserId)
+    if nil != err {
+        return err
+    }
+    lead_list, err := model.GetExhibitorLeadList(args.ExhibitorId, LEAD_EXPORT_MAX_ROWS)
+    if nil != err {
+        return err
+    }
+    user_map, err := leadVisitorMap(lead_list)
+    if nil != err {
+        return err
+    }
+
+    rows := make([][]string, 0, len(lead_list)+1)
+    rows = append(rows, []string{"编号", "姓名", "电话", "邮箱", "评级", "备注", "同意联系", "同意推广", "扫码次数", "首次扫码时间", "最后扫码时间"})
+    yes_no := map[bool]string{true: "是", false: "否"}
+    for i := range lead_list {
+        var info protocol.LeadInfoJson
+        copyLeadData(&lead_list[i], user_map[lead_list[i].UserId], &info)
+        rows = append(rows, []string{
+            fmt.Sprintf("%d", info.Id), info.Name, info.Phone, info.Email, fmt.Sprintf("%d", info.Rating), info.Notes,
+            yes_no[info.ConsentContact], yes_no[info.ConsentMarketing], fmt.Sprintf("%d", info.ScanNum),
+            info.FirstScanTime, info.LastScanTime,
+        })
+    }
+
+    reply.Csv, err = utils.WriteCsvRows(rows)
+    if nil != err {
+        utils.Logger.Error("export lead write csv err, exhibitor_id: %d, err: %v", args.ExhibitorId, err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    reply.Filename = fmt.Sprintf("leads_%d_%s.csv", args.ExhibitorId, time.Now().Format("20060102"))
+    utils.Logger.Info("export lead, exhibitor_id: %d, user_id: %d, rows: %d", args.ExhibitorId, args.UserId, len(lead_list))
+    return nil
+}
+
+// 观众查看被哪些展商记录及授权情况
+func GetMyLeadList(args *protocol.MyLeadListArgs, reply *protocol.MyLeadListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_lead_list] args: %+v", args)
+
+    lead_list, err := model.GetUserLeadList(args.UserId)
+    if nil != err {
+        return err
+    }
+    exhibitor_ids := make([]int64, len(lead_list))
+    for i, lead := range lead_list {
+        exhibitor_ids[i] = lead.ExhibitorId
+    }
+    exhibitor_list, err := model.GetExhibitorListByIds(exhibitor_ids)
+    if nil != err {
+        return err
+    }
+    exhibitor_map := make(map[int64]*model.Exhibitor, len(exhibitor_list))
+    for i := range exhibitor_list {
+        exhibitor_map[exhibitor_list[i].Id] = &exhibitor_list[i]
+    }
+
+    reply.LeadList = make([]protocol.MyLeadJson, len(lead_list))
+    for i, lead := range lead_list {
+        dst := &reply.LeadList[i]
+        dst.Id = lead.Id
+        dst.ExhibitorId = lead.ExhibitorId
+        dst.ConsentContact = lead.ConsentContact == 1
+        dst.ConsentMarketing = lead.ConsentMarketing == 1
+        dst.LastScanTime = formatOrderTime(lead.LastScanTime)
+        if exhibitor, ok := exhibitor_map[lead.ExhibitorId]; ok {
+            dst.ExhibitorName = exhibitor.Name
+            dst.Booth = exhibitor.Booth
+        }
+    }
+    return nil
+}
+
+// 观众撤回对展商的授权，撤回后展商不能再查看和导出联系方式，再次扫码也不能重新授权
+func RevokeLeadConsent(args *protocol.RevokeLeadConsentArgs, reply *protocol.RevokeLeadConsentReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:revoke_lead_consent] args: %+v", args)
+
+    ok, err := model.RevokeLeadConsent(args.LeadId, args.UserId)
+    if nil != err {
+        return err
+    }
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "记录不存在")
+    }
+    utils.Logger.Info("revoke lead consent, lead_id: %d, user_id: %d", args.LeadId, args.UserId)
+    return nil
+}
+
+// 观众自己重新授权，撤回授权后只能由观众在这里重新授权
+func GrantLeadConsent(args *protocol.GrantLeadConsentArgs, reply *protocol.GrantLeadConsentReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:grant_lead_consent] args: %+v", args)
+
+    ok, err := model.GrantLeadConsent(args.LeadId, args.UserId, boolToInt(args.ConsentContact), boolToInt(args.ConsentMarketing))
+    if nil != err {
+        return err
+    }
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "记录不存在")
+    }
+    utils.Logger.Info("grant lead consent, lead_id: %d, user_id: %d, contact: %v, marketing: %v",
+        args.LeadId, args.UserId, args.ConsentContact, args.ConsentMarketing)
+    return nil
+}
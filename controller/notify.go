@@ -0,0 +1,61 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/30 17:40
+ */
+package controller
+
+import (
+    "pet/model"
+    "pet/utils"
+)
+
+// 微信模板消息发送，微信服务初始化时设置
+var WeixinTemplateSender func(openid, template_id, url string, data map[string]string) error
+
+// 用户通知，微信和短信的模板参数不同
+type Notification struct {
+    Purpose     string                  // 通知用途，对应微信模板和短信模板
+    Url         string                  // 微信模板消息跳转地址
+    Weixin      map[string]string       // 微信模板数据，如 first, keyword1, remark
+    Sms         map[string]string       // 短信模板参数
+}
+
+/**
+ * 通知用户
+ *
+ * 用户关注了公众号且配置了微信模板时发送微信模板消息，发送失败或无法发送时使用短信
+ */
+func NotifyUser(user_id int64, notification *Notification) error {
+    user_model := new(model.User)
+    err := user_model.GetUserById(user_id)
+    if nil != err {
+        return err
+    }
+    if user_model.UserId == 0 {
+        utils.Logger.Warning("notify user not found, user_id: %d, purpose: %s", user_id, notification.Purpose)
+        return nil
+    }
+
+    template_id := utils.Config.WeixinTemplates[notification.Purpose]
+    if user_model.Openid != "" && template_id != "" && WeixinTemplateSender != nil {
+        err = WeixinTemplateSender(user_model.Openid, template_id, notification.Url, notification.Weixin)
+        if nil == err {
+            utils.Logger.Info("notify user by weixin, user_id: %d, purpose: %s", user_id, notification.Purpose)
+            return nil
+        }
+        utils.Logger.Error("notify user by weixin failed, user_id: %d, purpose: %s, err: %v", user_id, notification.Purpose, err)
+    }
+
+    if user_model.Phone == "" {
+        utils.Logger.Warning("notify user failed, no openid or phone, user_id: %d, purpose: %s", user_id, notification.Purpose)
+        return nil
+    }
+    err = utils.SendSms(user_model.Phone, notification.Purpose, notification.Sms)
+    if nil != err {
+        utils.Logger.Error("notify user by sms failed, user_id: %d, purpose: %s, err: %v", user_id, notification.Purpose, err)
+        return err
+    }
+    utils.Logger.Info("notify user by sms, user_id: %d, purpose: %s", user_id, notification.Purpose)
+    return nil
+}
@@ -0,0 +1,541 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 22:40
+ */
+package controller
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    ORDER_ITEM_MAX              = 10    // 每单最多商品种类
+    ORDER_QUANTITY_MAX          = 10    // 每种商品最多购买数量
+    CLOSE_EXPIRED_ORDER_BATCH   = 100
+)
+
+// 订单号，下单时间加随机数
+func newOrderNo() (string, error) {
+    random, err := utils.RandomHex(4)
+    if nil != err {
+        return "", err
+    }
+    return time.Now().Format("20060102150405") + strings.ToUpper(random), nil
+}
+
+func formatOrderTime(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.Format(utils.TIME_FORMAT)
+}
+
+func copyOrderData(from *model.Order, items []model.OrderItem, dst *protocol.OrderInfoJson) {
+    dst.OrderNo = from.OrderNo
+    dst.EditionId = from.EditionId
+    dst.TotalFee = from.TotalFee
+    dst.RefundFee = from.RefundFee
+    dst.Status = from.Status
+    dst.PaidTime = formatOrderTime(from.PaidTime)
+    dst.ExpireTime = formatOrderTime(from.ExpireTime)
+    dst.CreateTime = formatOrderTime(from.CreateTime)
+    dst.Items = make([]protocol.OrderItemJson, len(items))
+    for i := range items {
+        dst.Items[i].ProductId = items[i].ProductId
+        dst.Items[i].ProductName = items[i].ProductName
+        dst.Items[i].Price = items[i].Price
+        dst.Items[i].Quantity = items[i].Quantity
+    }
+}
+
+// 获取用户自己的订单，不是自己的订单按不存在处理
+func getUserOrder(user_id int64, order_no string) (*model.Order, error) {
+    if order_no == "" {
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "订单号不能为空")
+    }
+    order := new(model.Order)
+    found, err := order.GetOrderByNo(order_no)
+    if nil != err {
+        return nil, err
+    }
+    if !found || order.UserId != user_id {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "订单不存在")
+        utils.Logger.Error("get user order failed, user_id: %d, order_no: %s", user_id, order_no)
+        return nil, err
+    }
+    return order, nil
+}
+
+// 未配置支付时不能下单、支付和退款
+func checkPayEnabled() error {
+    if utils.PayClient == nil {
+        err := utils.NewInternalErrorByStr(utils.PayDisabledErrCode, "支付未配置")
+        utils.Logger.Error("pay gateway not configured")
+        return err
+    }
+    return nil
+}
+
+/**
+ * 关闭未支付的订单，先关闭支付平台的订单，避免关闭后用户仍能支付
+ *
+ * 返回是否本次关闭，订单已支付时返回false
+ */
+func closeOrder(order *model.Order) (bool, error) {
+    if order.PrepayId != "" {
+        if err := checkPayEnabled(); nil != err {
+            return false, err
+        }
+        err := utils.PayClient.CloseOrder(order.OrderNo)
+        if nil != err {
+            utils.Logger.Error("close pay order failed, order_no: %s, err: %v", order.OrderNo, err)
+            return false, utils.NewInternalError(utils.PayErrCode, err)
+        }
+    }
+    closed, err := model.CloseOrder(order)
+    if nil != err {
+        return false, err
+    }
+    if closed {
+        utils.Logger.Info("close order, order_no: %s, user_id: %d", order.OrderNo, order.UserId)
+    }
+    return closed, nil
+}
+
+/**
+ * 发放订单的门票，每张门票对应订单明细和序号
+ *
+ * 已发放的跳过，支付通知重复到达时不会重复发放
+ */
+func issueOrderTickets(order *model.Order) error {
+    items, err := model.GetOrderItemList(order.Id)
+    if nil != err {
+        return err
+    }
+    issued, err := model.GetOrderTicketList(order.Id)
+    if nil != err {
+        return err
+    }
+    exists := make(map[string]bool, len(issued))
+    for _, ticket := range issued {
+        exists[fmt.Sprintf("%d_%d", ticket.OrderItemId, ticket.Seq)] = true
+    }
+
+    for _, item := range items {
+        product := new(model.TicketProduct)
+        found, err := product.GetTicketProductById(item.ProductId)
+        if nil != err {
+            return err
+        }
+        valid_from, valid_to := product.ValidFrom, product.ValidTo
+        if !found || valid_from.IsZero() || valid_to.IsZero() {
+            valid_from, valid_to, err = editionTicketValidTime(order.EditionId, order.PaidTime)
+            if nil != err {
+                utils.Logger.Error("ticket valid time config err: %v", err)
+                return utils.NewInternalError(utils.InternalErrorCode, err)
+            }
+        }
+
+        for seq := 1; seq <= item.Quantity; seq++ {
+            if exists[fmt.Sprintf("%d_%d", item.Id, seq)] {
+                continue
+            }
+            ticket_no, err := utils.RandomHex(8)
+            if nil != err {
+                return utils.NewInternalError(utils.InternalErrorCode, err)
+            }
+            ticket := new(model.Ticket)
+            ticket.TicketNo = strings.ToUpper(ticket_no)
+            ticket.UserId = order.UserId
+            ticket.EditionId = order.EditionId
+            ticket.ProductId = item.ProductId
+            ticket.OrderId = order.Id
+            ticket.OrderItemId = item.Id
+            ticket.Seq = seq
+            ticket.Status = model.TICKET_STATUS_VALID
+            ticket.ValidFrom = valid_from
+            ticket.ValidTo = valid_to
+            if err = ticket.Create(); nil != err {
+                return err
+            }
+            utils.Logger.Info("issue order ticket, order_no: %s, ticket_no: %s", order.OrderNo, ticket.TicketNo)
+        }
+    }
+    return nil
+}
+
+// 在售门票
+func GetTicketProductList(args *protocol.TicketProductListArgs, reply *protocol.TicketProductListReply) error {
+    utils.Logger.Info("[cmd:ticket_product_list] args: %+v", args)
+
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+    product_list, err := model.GetTicketProductList(edition_id)
+    if nil != err {
+        return err
+    }
+    reply.ProductList = make([]protocol.TicketProductJson, len(product_list))
+    for i, product := range product_list {
+        dst := &reply.ProductList[i]
+        dst.Id = product.Id
+        dst.EditionId = product.EditionId
+        dst.Name = product.Name
+        dst.Description = product.Description
+        dst.Price = product.Price
+        dst.Remain = -1
+        if product.Stock > 0 {
+            dst.Remain = product.Stock - product.SoldNum
+            if dst.Remain < 0 {
+                dst.Remain = 0
+            }
+        }
+        dst.ValidFrom = formatOrderTime(product.ValidFrom)
+        dst.ValidTo = formatOrderTime(product.ValidTo)
+    }
+    return nil
+}
+
+/**
+ * 下单并调用支付平台统一下单，返回H5页面调起支付的参数
+ *
+ * 同一订单的商品需要属于同一届，下单时占用库存，统一下单失败时关闭订单释放库存
+ */
+func CreateOrder(args *protocol.CreateOrderArgs, reply *protocol.CreateOrderReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:create_order] args: %+v", args)
+
+    var err error
+    if err = checkPayEnabled(); nil != err {
+        return err
+    }
+    if len(args.Items) == 0 || len(args.Items) > ORDER_ITEM_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每单可以购买1到%d种门票", ORDER_ITEM_MAX))
+        utils.Logger.Error("CreateOrder failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    user_info := new(model.User)
+    if err = user_info.GetUserById(args.UserId); nil != err {
+        return err
+    }
+    if utils.PayClient.Name() == "wxpay" && user_info.Openid == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "请在微信中打开并授权登录后购买")
+        utils.Logger.Error("CreateOrder failed, user_id: %d has no openid", args.UserId)
+        return err
+    }
+
+    order := new(model.Order)
+    items := make([]model.OrderItem, 0, len(args.Items))
+    names := make([]string, 0, len(args.Items))
+    seen := make(map[int64]bool)
+    for _, arg := range args.Items {
+        if arg.Quantity <= 0 || arg.Quantity > ORDER_QUANTITY_MAX || seen[arg.ProductId] {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每种门票可以购买1到%d张", ORDER_QUANTITY_MAX))
+            utils.Logger.Error("CreateOrder failed, product_id: %d, quantity: %d", arg.ProductId, arg.Quantity)
+            return err
+        }
+        seen[arg.ProductId] = true
+
+        product := new(model.TicketProduct)
+        found, err := product.GetTicketProductById(arg.ProductId)
+        if nil != err {
+            return err
+        }
+        if !found || product.Status != model.PRODUCT_STATUS_ON {
+            err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "门票不存在或已下架")
+            utils.Logger.Error("CreateOrder failed, product_id: %d not on sale", arg.ProductId)
+            return err
+        }
+        if order.EditionId != 0 && order.EditionId != product.EditionId {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "不同届次的门票请分开购买")
+            utils.Logger.Error("CreateOrder failed, product_id: %d, edition_id: %d", arg.ProductId, product.EditionId)
+            return err
+        }
+        order.EditionId = product.EditionId
+        order.TotalFee += product.Price * arg.Quantity
+        items = append(items, model.OrderItem{
+            ProductId:      product.Id,
+            ProductName:    product.Name,
+            Price:          product.Price,
+            Quantity:       arg.Quantity,
+        })
+        names = append(names, product.Name)
+    }
+    if order.TotalFee <= 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "订单金额错误")
+        utils.Logger.Error("CreateOrder failed, total_fee: %d", order.TotalFee)
+        return err
+    }
+
+    order.OrderNo, err = newOrderNo()
+    if nil != err {
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    order.UserId = args.UserId
+    order.ExpireTime = time.Now().Add(utils.OrderExpireDuration())
+    err = model.CreateOrder(order, items)
+    if nil != err {
+        return err
+    }
+
+    prepay_id, err := utils.PayClient.UnifiedOrder(&utils.PayOrder{
+        OrderNo:    order.OrderNo,
+        Body:       strings.Join(names, ","),
+        TotalFee:   order.TotalFee,
+        ClientIp:   args.ClientIp,
+        Openid:     user_info.Openid,
+        ExpireTime: order.ExpireTime,
+    })
+    if nil != err {
+        utils.Logger.Error("unified order failed, order_no: %s, err: %v", order.OrderNo, err)
+        if _, err2 := model.CloseOrder(order); nil != err2 {
+            utils.Logger.Error("close order after unified order failed, order_no: %s, err: %v", order.OrderNo, err2)
+        }
+        return utils.NewInternalErrorByStr(utils.PayErrCode, "支付下单失败，请稍后重试")
+    }
+    err = order.SavePrepayId(utils.PayClient.Name(), prepay_id)
+    if nil != err {
+        return err
+    }
+
+    utils.Logger.Info("create order, order_no: %s, user_id: %d, total_fee: %d", order.OrderNo, order.UserId, order.TotalFee)
+    copyOrderData(order, items, &reply.Order)
+    reply.PayParams = utils.PayClient.JsapiParams(prepay_id)
+    return nil
+}
+
+// 待支付订单重新获取支付参数，超时的订单关闭
+func GetOrderPayParams(args *protocol.OrderPayParamsArgs, reply *protocol.OrderPayParamsReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:order_pay_params] args: %+v", args)
+
+    if err := checkPayEnabled(); nil != err {
+        return err
+    }
+    order, err := getUserOrder(args.UserId, args.OrderNo)
+    if nil != err {
+        return err
+    }
+    if order.Status == model.ORDER_STATUS_PENDING && time.Now().After(order.ExpireTime) {
+        if _, err = closeOrder(order); nil != err {
+            return err
+        }
+    }
+    if order.Status != model.ORDER_STATUS_PENDING || order.PrepayId == "" {
+        err = utils.NewInternalErrorByStr(utils.OrderStatusErrCode, "订单不是待支付状态")
+        utils.Logger.Error("GetOrderPayParams failed, order_no: %s, status: %d", order.OrderNo, order.Status)
+        return err
+    }
+    reply.PayParams = utils.PayClient.JsapiParams(order.PrepayId)
+    return nil
+}
+
+// 取消待支付订单
+func CancelOrder(args *protocol.CancelOrderArgs, reply *protocol.CancelOrderReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:cancel_order] args: %+v", args)
+
+    order, err := getUserOrder(args.UserId, args.OrderNo)
+    if nil != err {
+        return err
+    }
+    if !model.OrderCanTransit(order.Status, model.ORDER_STATUS_CLOSED) {
+        err = utils.NewInternalErrorByStr(utils.OrderStatusErrCode, "订单不是待支付状态")
+        utils.Logger.Error("CancelOrder failed, order_no: %s, status: %d", order.OrderNo, order.Status)
+        return err
+    }
+    if _, err = closeOrder(order); nil != err {
+        return err
+    }
+    reply.Status = order.Status
+    return nil
+}
+
+// 我的订单
+func GetMyOrderList(args *protocol.MyOrderListArgs, reply *protocol.MyOrderListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_order_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+
+    order_list, total_num, err := model.GetUserOrderListByPage(args.UserId, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    reply.OrderList = make([]protocol.OrderInfoJson, len(order_list))
+    for i := range order_list {
+        items, err := model.GetOrderItemList(order_list[i].Id)
+        if nil != err {
+            return err
+        }
+        copyOrderData(&order_list[i], items, &reply.OrderList[i])
+    }
+    reply.TotalNum = total_num
+    return nil
+}
+
+// 订单详情
+func GetOrderDetail(args *protocol.OrderDetailArgs, reply *protocol.OrderDetailReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:order_detail] args: %+v", args)
+
+    order, err := getUserOrder(args.UserId, args.OrderNo)
+    if nil != err {
+        return err
+    }
+    items, err := model.GetOrderItemList(order.Id)
+    if nil != err {
+        return err
+    }
+    copyOrderData(order, items, &reply.Order)
+
+    ticket_list, err := model.GetOrderTicketList(order.Id)
+    if nil != err {
+        return err
+    }
+    reply.TicketList = make([]protocol.TicketInfoJson, len(ticket_list))
+    for i := range ticket_list {
+        copyTicketData(&ticket_list[i], &reply.TicketList[i])
+    }
+
+    refund_list, err := model.GetOrderRefundList(order.Id)
+    if nil != err {
+        return err
+    }
+    reply.RefundList = copyRefundList(refund_list)
+    return nil
+}
+
+/**
+ * 处理支付结果通知，校验签名和金额后更新订单并发放门票
+ *
+ * 返回nil时应答支付平台成功，否则支付平台会重试通知
+ */
+func HandlePayNotify(body []byte) error {
+    if err := checkPayEnabled(); nil != err {
+        return err
+    }
+    notify, err := utils.PayClient.ParseNotify(body)
+    if nil != err {
+        utils.Logger.Error("parse pay notify failed, err: %v, body: %s", err, string(body))
+        return err
+    }
+    utils.Logger.Info("[cmd:pay_notify] notify: %+v", notify)
+
+    order := new(model.Order)
+    found, err := order.GetOrderByNo(notify.OrderNo)
+    if nil != err {
+        return err
+    }
+    if !found {
+        utils.Logger.Error("pay notify order not found, order_no: %s", notify.OrderNo)
+        return fmt.Errorf("order not found")
+    }
+    if !notify.Success {
+        utils.Logger.Warning("pay notify not success, order_no: %s", notify.OrderNo)
+        return nil
+    }
+    if notify.TotalFee != order.TotalFee {
+        utils.Logger.Error("pay notify total_fee mismatch, order_no: %s, total_fee: %d, notify: %d",
+            order.OrderNo, order.TotalFee, notify.TotalFee)
+        return fmt.Errorf("total_fee mismatch")
+    }
+
+    if order.Status != model.ORDER_STATUS_PAID {
+        if !model.OrderCanTransit(order.Status, model.ORDER_STATUS_PAID) {
+            utils.Logger.Error("pay notify order status err, order_no: %s, status: %d", order.OrderNo, order.Status)
+            return nil
+        }
+        paid, err := model.PayOrder(order, notify.TransactionId, notify.PaidTime)
+        if nil != err {
+            return err
+        }
+        if paid {
+            utils.Logger.Info("order paid, order_no: %s, transaction_id: %s", order.OrderNo, notify.TransactionId)
+        }
+        if order.Status != model.ORDER_STATUS_PAID {
+            return nil
+        }
+    }
+    return issueOrderTickets(order)
+}
+
+// 模拟支付成功，生成签名的支付通知并按正常流程处理
+func FakePayConfirm(args *protocol.FakePayConfirmArgs, reply *protocol.FakePayConfirmReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:fake_pay_confirm] args: %+v", args)
+
+    fake, ok := utils.PayClient.(*utils.FakePayGateway)
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "未启用模拟支付")
+    }
+    order, err := getUserOrder(args.UserId, args.OrderNo)
+    if nil != err {
+        return err
+    }
+    if order.Status != model.ORDER_STATUS_PENDING {
+        err = utils.NewInternalErrorByStr(utils.OrderStatusErrCode, "订单不是待支付状态")
+        utils.Logger.Error("FakePayConfirm failed, order_no: %s, status: %d", order.OrderNo, order.Status)
+        return err
+    }
+
+    err = HandlePayNotify(fake.Notify(order.OrderNo, order.TotalFee))
+    if nil != err {
+        return err
+    }
+    _, err = order.GetOrderByNo(order.OrderNo)
+    reply.Status = order.Status
+    return err
+}
+
+// 关闭超时未支付的订单，定时执行
+func CloseExpiredOrders() (int, error) {
+    closed_num := 0
+    last_id := int64(0)
+    for {
+        order_list, err := model.GetExpiredOrderList(time.Now(), CLOSE_EXPIRED_ORDER_BATCH)
+        if nil != err {
+            return closed_num, err
+        }
+        // 关闭失败的订单仍会被查出，没有进展时结束
+        if len(order_list) == 0 || order_list[len(order_list)-1].Id == last_id {
+            return closed_num, nil
+        }
+        last_id = order_list[len(order_list)-1].Id
+        for i := range order_list {
+            closed, err := closeOrder(&order_list[i])
+            if nil != err {
+                continue
+            }
+            if closed {
+                closed_num++
+            }
+        }
+    }
+}
@@ -0,0 +1,202 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/11/19 14:05
+ */
+package controller
+
+import (
+    "regexp"
+    "strconv"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+    "golang.org/x/crypto/bcrypt"
+)
+
+const (
+    LOGIN_FAIL_MAX_TIMES    = 5         // 连续输错密码次数上限
+    LOGIN_LOCK_EXPIRE       = 15 * 60   // 锁定15分钟
+)
+
+var passwordLetterRegexp = regexp.MustCompile(`[a-zA-Z]`)
+var passwordDigitRegexp = regexp.MustCompile(`[0-9]`)
+
+func loginFailKey(phone string) string {
+    return "login_fail:" + phone
+}
+
+// 密码格式校验，6-32位，必须包含字母和数字
+func passwordValid(password string) bool {
+    if len(password) < 6 || len(password) > 32 {
+        return false
+    }
+    return passwordLetterRegexp.MatchString(password) && passwordDigitRegexp.MatchString(password)
+}
+
+// 密码加密
+func hashPassword(password string) (string, error) {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if nil != err {
+        utils.Logger.Error("hash password err: %v", err)
+        return "", utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    return string(hash), nil
+}
+
+// 校验密码是否正确
+func comparePassword(hash, password string) bool {
+    if hash == "" {
+        return false
+    }
+    return nil == bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// 设置密码，已设置过密码需要校验旧密码
+func SetPassword(args *protocol.SetPasswordArgs, reply *protocol.SetPasswordReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:set_password] user_id: %d", args.UserId)
+
+    var err error
+    if !passwordValid(args.Password) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "密码需为6-32位字母和数字组合")
+        utils.Logger.Error("SetPassword failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    user_model := new(model.User)
+    err = user_model.GetUserById(args.UserId)
+    if nil != err {
+        return err
+    }
+    if user_model.UserId == 0 {
+        return utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录已失效，请重新登录")
+    }
+
+    if user_model.Password != "" && !comparePassword(user_model.Password, args.OldPassword) {
+        err = utils.NewInternalErrorByStr(utils.PasswordWrongErrCode, "原密码错误")
+        utils.Logger.Error("SetPassword failed, err: %s \n", err.Error())
+        return err
+    }
+
+    hash, err := hashPassword(args.Password)
+    if nil != err {
+        return err
+    }
+    return user_model.UpdatePassword(hash)
+}
+
+// 电话号码+密码登录，连续输错密码会被锁定
+func PasswordLogin(args *protocol.PasswordLoginArgs, reply *protocol.PasswordLoginReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:password_login] phone: %s", args.Phone)
+
+    var err error
+    if args.Phone == "" || args.Password == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码或密码不能为空")
+        utils.Logger.Error("PasswordLogin failed, param err: %s \n", err.Error())
+        return err
+    }
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("PasswordLogin failed, normalize phone err: %v", err)
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
+        return err
+    }
+
+    res, err := g_cache.Get(loginFailKey(args.Phone))
+    if nil != err && utils.CheckRedisReturnValue(err) != utils.KeyNotFound {
+        utils.Logger.Error("get login fail times cache err: %v", err)
+        return err
+    }
+    fail_times, _ := strconv.Atoi(string(res))
+    if fail_times >= LOGIN_FAIL_MAX_TIMES {
+        err = utils.NewInternalErrorByStr(utils.LoginLockedErrCode, "密码错误次数过多，请15分钟后再试")
+        utils.Logger.Error("PasswordLogin locked, phone: %s", args.Phone)
+        return err
+    }
+
+    user_model := new(model.User)
+    err = user_model.GetUserByPhone(args.Phone)
+    if nil != err {
+        return err
+    }
+
+    // 用户不存在和密码错误返回同样的错误，避免泄露注册信息
+    if user_model.UserId == 0 || !comparePassword(user_model.Password, args.Password) {
+        if _, err = g_cache.Incr(loginFailKey(args.Phone)); nil != err {
+            utils.Logger.Error("incr login fail times err: %v", err)
+            return err
+        }
+        g_cache.Expire(loginFailKey(args.Phone), LOGIN_LOCK_EXPIRE)
+
+        err = utils.NewInternalErrorByStr(utils.PasswordWrongErrCode, "电话号码或密码错误")
+        utils.Logger.Error("PasswordLogin failed, phone: %s, err: %s", args.Phone, err.Error())
+        return err
+    }
+
+    g_cache.Del(loginFailKey(args.Phone))
+
+    model.CopyUserData(user_model, &reply.User)
+    return UserLogin(user_model, &reply.Token)
+}
+
+// 通过短信验证码重置密码
+func ResetPassword(args *protocol.ResetPasswordArgs, reply *protocol.ResetPasswordReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:reset_password] phone: %s", args.Phone)
+
+    var err error
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("ResetPassword failed, normalize phone err: %v", err)
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
+        return err
+    }
+    if args.VerifyCode == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "验证码不能为空")
+        utils.Logger.Error("ResetPassword failed, param err: %s \n", err.Error())
+        return err
+    }
+    if !passwordValid(args.Password) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "密码需为6-32位字母和数字组合")
+        utils.Logger.Error("ResetPassword failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    err = verifyPhoneCode(args.Phone, VERIFY_PURPOSE_RESET_PASSWORD, args.VerifyCode)
+    if nil != err {
+        return err
+    }
+
+    user_model := new(model.User)
+    err = user_model.GetUserByPhone(args.Phone)
+    if nil != err {
+        return err
+    }
+    if user_model.UserId == 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码未注册")
+        utils.Logger.Error("ResetPassword failed, err: %s \n", err.Error())
+        return err
+    }
+
+    hash, err := hashPassword(args.Password)
+    if nil != err {
+        return err
+    }
+    err = user_model.UpdatePassword(hash)
+    if nil != err {
+        return err
+    }
+
+    // 重置密码后解除密码锁定
+    g_cache.Del(loginFailKey(args.Phone))
+    return nil
+}
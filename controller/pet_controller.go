@@ -0,0 +1,430 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/10 22:00
+ */
+package controller
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    PET_MAX_NUM             = 20                // 每个用户最多添加的宠物数
+    PET_PHOTO_MAX_NUM       = 9                 // 每只宠物最多照片数
+    PET_PHOTO_MAX_SIZE      = 5 * 1024 * 1024   // 照片最大5M
+    PET_WEIGHT_MAX          = 200 * 1000        // 体重上限，单位克
+)
+
+// 解析日期，为空时返回零值
+func parseOptionalDate(value string) (time.Time, error) {
+    if value == "" {
+        return time.Time{}, nil
+    }
+    return time.ParseInLocation(utils.DATE_FORMAT, value, time.Local)
+}
+
+// 校验并填充宠物资料，品种需要属于对应种类
+func fillPetData(pet *model.Pet, name string, species int, breed_id int64, birthday string, gender, weight int) error {
+    name = strings.TrimSpace(name)
+    if name == "" || utf8.RuneCountInString(name) > 32 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物名称不能为空且不能超过32个字")
+    }
+    if _, ok := model.PetSpeciesNames[species]; !ok {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物种类错误")
+    }
+    if breed_id != 0 {
+        breed := new(model.PetBreed)
+        found, err := breed.GetPetBreedById(breed_id)
+        if nil != err {
+            return err
+        }
+        if !found || breed.Species != species {
+            return utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物品种错误")
+        }
+    }
+    birthday_time, err := parseOptionalDate(birthday)
+    if nil != err || birthday_time.After(time.Now()) {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "出生日期错误")
+    }
+    if gender < 0 || gender > 2 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "性别错误")
+    }
+    if weight < 0 || weight > PET_WEIGHT_MAX {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "体重错误")
+    }
+
+    pet.Name = name
+    pet.Species = species
+    pet.BreedId = breed_id
+    pet.Birthday = birthday_time
+    pet.Gender = gender
+    pet.Weight = weight
+    return nil
+}
+
+// 获取用户自己的宠物，不是自己的宠物按不存在处理
+func getUserPet(user_id, pet_id int64) (*model.Pet, error) {
+    if pet_id <= 0 {
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物id错误")
+    }
+    pet := new(model.Pet)
+    found, err := pet.GetPetById(pet_id)
+    if nil != err {
+        return nil, err
+    }
+    if !found || pet.UserId != user_id {
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "宠物不存在")
+    }
+    return pet, nil
+}
+
+// 宠物资料，包括品种名称和封面
+func copyPetInfo(pet *model.Pet, dst *protocol.PetInfoJson, breed_names map[int64]string, cover string) {
+    model.CopyPetData(pet, dst)
+    dst.BreedName = breed_names[pet.BreedId]
+    dst.Cover = cover
+}
+
+func petBreedNames() (map[int64]string, error) {
+    breed_list, err := model.GetPetBreedList(0)
+    if nil != err {
+        return nil, err
+    }
+    breed_names := make(map[int64]string, len(breed_list))
+    for _, breed := range breed_list {
+        breed_names[breed.Id] = breed.Name
+    }
+    return breed_names, nil
+}
+
+// 品种列表
+func GetPetBreedList(args *protocol.PetBreedListArgs, reply *protocol.PetBreedListReply) error {
+    utils.Logger.Info("[cmd:pet_breed_list] args: %+v", args)
+
+    breed_list, err := model.GetPetBreedList(args.Species)
+    if nil != err {
+        return err
+    }
+    reply.BreedList = make([]protocol.PetBreedJson, len(breed_list))
+    for i := range breed_list {
+        utils.DumpStruct(&reply.BreedList[i], &breed_list[i])
+    }
+    return nil
+}
+
+// 添加宠物
+func CreatePet(args *protocol.CreatePetArgs, reply *protocol.CreatePetReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:create_pet] args: %+v", args)
+
+    pet := new(model.Pet)
+    pet.UserId = args.UserId
+    err := fillPetData(pet, args.Name, args.Species, args.BreedId, args.Birthday, args.Gender, args.Weight)
+    if nil != err {
+        utils.Logger.Error("CreatePet failed, user_id: %d, err: %v", args.UserId, err)
+        return err
+    }
+
+    pet_list, err := model.GetUserPetList(args.UserId)
+    if nil != err {
+        return err
+    }
+    if len(pet_list) >= PET_MAX_NUM {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("最多添加%d只宠物", PET_MAX_NUM))
+        utils.Logger.Error("CreatePet failed, user_id: %d, err: %v", args.UserId, err)
+        return err
+    }
+
+    err = pet.Create()
+    if nil != err {
+        return err
+    }
+    breed_names, err := petBreedNames()
+    if nil != err {
+        return err
+    }
+    copyPetInfo(pet, &reply.Pet, breed_names, "")
+    return nil
+}
+
+// 修改宠物资料
+func UpdatePet(args *protocol.UpdatePetArgs, reply *protocol.UpdatePetReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:update_pet] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.Id)
+    if nil != err {
+        utils.Logger.Error("UpdatePet failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.Id, err)
+        return err
+    }
+    err = fillPetData(pet, args.Name, args.Species, args.BreedId, args.Birthday, args.Gender, args.Weight)
+    if nil != err {
+        utils.Logger.Error("UpdatePet failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.Id, err)
+        return err
+    }
+    updated, err := pet.Update()
+    if nil != err {
+        return err
+    }
+    if !updated {
+        return utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "宠物报名了比赛，不能修改种类")
+    }
+
+    breed_names, err := petBreedNames()
+    if nil != err {
+        return err
+    }
+    photo_list, err := model.GetPetPhotoList([]int64{pet.Id})
+    if nil != err {
+        return err
+    }
+    var cover string
+    if len(photo_list) > 0 {
+        cover = photo_list[0].Url
+    }
+    copyPetInfo(pet, &reply.Pet, breed_names, cover)
+    return nil
+}
+
+// 删除宠物，疫苗记录和照片一起删除
+func DeletePet(args *protocol.DeletePetArgs, reply *protocol.DeletePetReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:delete_pet] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.Id)
+    if nil != err {
+        utils.Logger.Error("DeletePet failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.Id, err)
+        return err
+    }
+    deleted, err := pet.Delete()
+    if nil != err {
+        return err
+    }
+    if !deleted {
+        return utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "宠物报名了比赛，请先退赛")
+    }
+
+    // 退赛后排行榜中可能还有票数，删除后不再展示
+    if _, err = g_cache.Zrem(PET_VOTE_RANK_KEY, pet.Id); nil != err {
+        utils.Logger.Error("DeletePet remove vote rank err, pet_id: %d, err: %v", pet.Id, err)
+    }
+    return nil
+}
+
+// 我的宠物列表
+func GetMyPetList(args *protocol.MyPetListArgs, reply *protocol.MyPetListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_pet_list] args: %+v", args)
+
+    pet_list, err := model.GetUserPetList(args.UserId)
+    if nil != err {
+        return err
+    }
+    breed_names, err := petBreedNames()
+    if nil != err {
+        return err
+    }
+
+    pet_ids := make([]int64, len(pet_list))
+    for i := range pet_list {
+        pet_ids[i] = pet_list[i].Id
+    }
+    photo_list, err := model.GetPetPhotoList(pet_ids)
+    if nil != err {
+        return err
+    }
+    covers := make(map[int64]string)
+    for _, photo := range photo_list {
+        if _, ok := covers[photo.PetId]; !ok {
+            covers[photo.PetId] = photo.Url
+        }
+    }
+
+    reply.PetList = make([]protocol.PetInfoJson, len(pet_list))
+    // format data
+    for i := range pet_list {
+        copyPetInfo(&pet_list[i], &reply.PetList[i], breed_names, covers[pet_list[i].Id])
+    }
+    return nil
+}
+
+// 宠物详情，包括疫苗记录和照片
+func GetPetDetail(args *protocol.PetDetailArgs, reply *protocol.PetDetailReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:pet_detail] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.Id)
+    if nil != err {
+        utils.Logger.Error("GetPetDetail failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.Id, err)
+        return err
+    }
+    breed_names, err := petBreedNames()
+    if nil != err {
+        return err
+    }
+    vaccination_list, err := model.GetPetVaccinationList(pet.Id)
+    if nil != err {
+        return err
+    }
+    photo_list, err := model.GetPetPhotoList([]int64{pet.Id})
+    if nil != err {
+        return err
+    }
+
+    reply.Vaccinations = make([]protocol.PetVaccinationJson, len(vaccination_list))
+    for i := range vaccination_list {
+        model.CopyPetVaccinationData(&vaccination_list[i], &reply.Vaccinations[i])
+    }
+    reply.Photos = make([]protocol.PetPhotoJson, len(photo_list))
+    for i := range photo_list {
+        reply.Photos[i].Id = photo_list[i].Id
+        reply.Photos[i].Url = photo_list[i].Url
+    }
+    var cover string
+    if len(photo_list) > 0 {
+        cover = photo_list[0].Url
+    }
+    copyPetInfo(pet, &reply.Pet, breed_names, cover)
+    return nil
+}
+
+// 添加疫苗记录
+func AddPetVaccination(args *protocol.AddPetVaccinationArgs, reply *protocol.AddPetVaccinationReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:add_pet_vaccination] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.PetId)
+    if nil != err {
+        utils.Logger.Error("AddPetVaccination failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.PetId, err)
+        return err
+    }
+
+    vaccination := new(model.PetVaccination)
+    vaccination.PetId = pet.Id
+    vaccination.Vaccine = strings.TrimSpace(args.Vaccine)
+    vaccination.Hospital = strings.TrimSpace(args.Hospital)
+    if vaccination.Vaccine == "" || utf8.RuneCountInString(vaccination.Vaccine) > 64 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "疫苗名称不能为空且不能超过64个字")
+    } else if utf8.RuneCountInString(vaccination.Hospital) > 128 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "接种机构不能超过128个字")
+    } else if vaccination.VaccinateDate, err = parseOptionalDate(args.VaccinateDate); nil != err ||
+        vaccination.VaccinateDate.IsZero() || vaccination.VaccinateDate.After(time.Now()) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "接种日期错误")
+    } else if vaccination.ExpireDate, err = parseOptionalDate(args.ExpireDate); nil != err ||
+        (!vaccination.ExpireDate.IsZero() && vaccination.ExpireDate.Before(vaccination.VaccinateDate)) {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "有效期错误")
+    }
+    if nil != err {
+        utils.Logger.Error("AddPetVaccination failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    err = vaccination.Create()
+    if nil != err {
+        return err
+    }
+    model.CopyPetVaccinationData(vaccination, &reply.Vaccination)
+    return nil
+}
+
+// 删除疫苗记录
+func DeletePetVaccination(args *protocol.DeletePetVaccinationArgs, reply *protocol.DeletePetVaccinationReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:delete_pet_vaccination] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.PetId)
+    if nil != err {
+        utils.Logger.Error("DeletePetVaccination failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.PetId, err)
+        return err
+    }
+    deleted, err := model.DeletePetVaccination(pet.Id, args.Id)
+    if nil != err {
+        return err
+    }
+    if !deleted {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "疫苗记录不存在")
+    }
+    return nil
+}
+
+// 上传宠物照片
+func UploadPetPhoto(args *protocol.UploadPetPhotoArgs, reply *protocol.UploadPetPhotoReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:upload_pet_photo] user_id: %d, pet_id: %d, filename: %s, size: %d",
+        args.UserId, args.PetId, args.Filename, len(args.Data))
+
+    pet, err := getUserPet(args.UserId, args.PetId)
+    if nil != err {
+        utils.Logger.Error("UploadPetPhoto failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.PetId, err)
+        return err
+    }
+    photo_list, err := model.GetPetPhotoList([]int64{pet.Id})
+    if nil != err {
+        return err
+    }
+    if len(photo_list) >= PET_PHOTO_MAX_NUM {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每只宠物最多上传%d张照片", PET_PHOTO_MAX_NUM))
+        utils.Logger.Error("UploadPetPhoto failed, pet_id: %d, err: %v", pet.Id, err)
+        return err
+    }
+
+    url, err := saveImage("pet", pet.Id, args.Data)
+    if nil != err {
+        return err
+    }
+    photo := &model.PetPhoto{PetId: pet.Id, Url: url}
+    err = photo.Create()
+    if nil != err {
+        return err
+    }
+
+    reply.Photo.Id = photo.Id
+    reply.Photo.Url = photo.Url
+    return nil
+}
+
+// 删除宠物照片
+func DeletePetPhoto(args *protocol.DeletePetPhotoArgs, reply *protocol.DeletePetPhotoReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:delete_pet_photo] args: %+v", args)
+
+    pet, err := getUserPet(args.UserId, args.PetId)
+    if nil != err {
+        utils.Logger.Error("DeletePetPhoto failed, user_id: %d, pet_id: %d, err: %v", args.UserId, args.PetId, err)
+        return err
+    }
+    deleted, err := model.DeletePetPhoto(pet.Id, args.Id)
+    if nil != err {
+        return err
+    }
+    if !deleted {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "照片不存在")
+    }
+    return nil
+}
@@ -0,0 +1,130 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/9 22:05
+ */
+package controller
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "unicode/utf8"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    AVATAR_MAX_SIZE = 2 * 1024 * 1024   // 头像最大2M
+)
+
+// 允许上传的图片格式
+var imageExtensions = map[string]string{
+    "image/jpeg":   ".jpg",
+    "image/png":    ".png",
+    "image/gif":    ".gif",
+}
+
+// 性别，0: 无性别 1: 男 2: 女
+func genderValid(gender string) bool {
+    return gender == "0" || gender == "1" || gender == "2"
+}
+
+// 更新个人资料，只更新传入的字段
+func UpdateProfile(args *protocol.UpdateProfileArgs, reply *protocol.UpdateProfileReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:update_profile] args: %+v", args)
+
+    var err error
+    values := make(map[string]interface{})
+
+    if args.Name != "" {
+        name := strings.TrimSpace(args.Name)
+        if name == "" || utf8.RuneCountInString(name) > 32 {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "姓名不能超过32个字")
+            utils.Logger.Error("UpdateProfile failed, param err: %s \n", err.Error())
+            return err
+        }
+        values["name"] = name
+    }
+    if args.Gender != "" {
+        if !genderValid(args.Gender) {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "性别错误")
+            utils.Logger.Error("UpdateProfile failed, param err: %s \n", err.Error())
+            return err
+        }
+        values["gender"] = args.Gender
+    }
+    if args.Email != "" {
+        if !utils.EmailValid(args.Email) {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "邮箱格式错误")
+            utils.Logger.Error("UpdateProfile failed, param err: %s \n", err.Error())
+            return err
+        }
+        values["email"] = args.Email
+    }
+    if len(values) == 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "没有需要修改的资料")
+        utils.Logger.Error("UpdateProfile failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    user_model := new(model.User)
+    user_model.UserId = args.UserId
+    err = user_model.UpdateProfile(values)
+    if nil != err {
+        return err
+    }
+
+    model.CopyUserData(user_model, &reply.User)
+    return nil
+}
+
+// 保存上传的图片，返回图片地址
+func saveImage(dir string, owner_id int64, data []byte) (string, error) {
+    content_type := http.DetectContentType(data)
+    ext, ok := imageExtensions[content_type]
+    if !ok {
+        err := utils.NewInternalErrorByStr(utils.ParameterErrCode, "只支持jpg、png、gif格式的图片")
+        utils.Logger.Error("save image failed, content type: %s", content_type)
+        return "", err
+    }
+
+    name, err := utils.RandomHex(8)
+    if nil != err {
+        return "", utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    key := fmt.Sprintf("%s/%d/%s%s", dir, owner_id, name, ext)
+    url, err := utils.Storage.Save(key, data, content_type)
+    if nil != err {
+        return "", utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    return url, nil
+}
+
+// 上传头像
+func UploadAvatar(args *protocol.UploadAvatarArgs, reply *protocol.UploadAvatarReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:upload_avatar] user_id: %d, filename: %s, size: %d", args.UserId, args.Filename, len(args.Data))
+
+    url, err := saveImage("avatar", args.UserId, args.Data)
+    if nil != err {
+        return err
+    }
+
+    user_model := new(model.User)
+    user_model.UserId = args.UserId
+    err = user_model.UpdateProfile(map[string]interface{}{"avatar": url, "avatar_custom": 1})
+    if nil != err {
+        return err
+    }
+
+    reply.Avatar = url
+    return nil
+}
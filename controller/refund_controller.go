@@ -0,0 +1,411 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/19 21:40
+ */
+package controller
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    RETRY_REFUND_DELAY          = 5 * time.Minute
+    RETRY_REFUND_BATCH          = 100
+)
+
+// 退款单号，R加时间和随机数
+func newRefundNo() (string, error) {
+    random, err := utils.RandomHex(4)
+    if nil != err {
+        return "", err
+    }
+    return "R" + time.Now().Format("20060102150405") + strings.ToUpper(random), nil
+}
+
+func copyRefundData(from *model.Refund, dst *protocol.RefundInfoJson) {
+    dst.RefundNo = from.RefundNo
+    dst.OrderNo = from.OrderNo
+    dst.RefundFee = from.RefundFee
+    dst.TicketNum = from.TicketNum
+    dst.Reason = from.Reason
+    dst.Source = from.Source
+    dst.Status = from.Status
+    dst.SuccessTime = formatOrderTime(from.SuccessTime)
+    dst.CreateTime = formatOrderTime(from.CreateTime)
+}
+
+func copyRefundList(refund_list []model.Refund) []protocol.RefundInfoJson {
+    dst := make([]protocol.RefundInfoJson, len(refund_list))
+    for i := range refund_list {
+        copyRefundData(&refund_list[i], &dst[i])
+    }
+    return dst
+}
+
+/**
+ * 退款订单中的门票，ticket_nos为空时退全部可退的门票
+ *
+ * 门票按购买时的单价退款，先作废门票再调用支付平台，支付平台明确失败时恢复门票
+ * 用户申请时只能退还未到生效时间的门票
+ */
+func refundOrderTickets(order *model.Order, ticket_nos []string, reason string, source int, operator_id int64) (*model.Refund, error) {
+    if err := checkPayEnabled(); nil != err {
+        return nil, err
+    }
+    if order.Status != model.ORDER_STATUS_PAID {
+        err := utils.NewInternalErrorByStr(utils.OrderStatusErrCode, "订单不是已支付状态")
+        utils.Logger.Error("refund order failed, order_no: %s, status: %d", order.OrderNo, order.Status)
+        return nil, err
+    }
+
+    item_list, err := model.GetOrderItemList(order.Id)
+    if nil != err {
+        return nil, err
+    }
+    prices := make(map[int64]int, len(item_list))
+    for _, item := range item_list {
+        prices[item.Id] = item.Price
+    }
+    ticket_list, err := model.GetOrderTicketList(order.Id)
+    if nil != err {
+        return nil, err
+    }
+
+    now := time.Now()
+    refundable := func(ticket *model.Ticket) bool {
+        if ticket.Status != model.TICKET_STATUS_VALID || ticket.CheckinGate != "" {
+            return false
+        }
+        return source != model.REFUND_SOURCE_USER || ticket.ValidFrom.After(now)
+    }
+
+    selected := make(map[string]bool, len(ticket_nos))
+    for _, ticket_no := range ticket_nos {
+        selected[strings.ToUpper(strings.TrimSpace(ticket_no))] = true
+    }
+    partial := len(selected) > 0
+    ticket_ids := make([]int64, 0, len(ticket_list))
+    refund_fee := 0
+    for i := range ticket_list {
+        ticket := &ticket_list[i]
+        if partial && !selected[ticket.TicketNo] {
+            continue
+        }
+        delete(selected, ticket.TicketNo)
+        if !refundable(ticket) {
+            if partial {
+                err = utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, fmt.Sprintf("门票%s已入场、已退款或已生效", ticket.TicketNo))
+                utils.Logger.Error("refund order failed, order_no: %s, ticket_no: %s not refundable", order.OrderNo, ticket.TicketNo)
+                return nil, err
+            }
+            continue
+        }
+        ticket_ids = append(ticket_ids, ticket.Id)
+        refund_fee += prices[ticket.OrderItemId]
+    }
+    if len(selected) > 0 {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "门票不属于该订单")
+        utils.Logger.Error("refund order failed, order_no: %s, ticket_nos: %v", order.OrderNo, ticket_nos)
+        return nil, err
+    }
+    if len(ticket_ids) == 0 || refund_fee <= 0 {
+        err = utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "没有可退款的门票")
+        utils.Logger.Error("refund order failed, order_no: %s has no refundable ticket", order.OrderNo)
+        return nil, err
+    }
+
+    refund := new(model.Refund)
+    refund.RefundNo, err = newRefundNo()
+    if nil != err {
+        return nil, utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    refund.RefundFee = refund_fee
+    refund.Reason = reason
+    refund.Source = source
+    refund.OperatorId = operator_id
+    err = model.ApplyRefund(order, refund, ticket_ids)
+    if nil != err {
+        return nil, err
+    }
+
+    if err = sendPayRefund(order, refund); nil != err {
+        return nil, err
+    }
+
+    utils.Logger.Info("apply refund, order_no: %s, refund_no: %s, refund_fee: %d, ticket_num: %d, operator_id: %d",
+        order.OrderNo, refund.RefundNo, refund.RefundFee, refund.TicketNum, operator_id)
+    return refund, nil
+}
+
+// 用户申请退款，需要在退款截止时间前
+func ApplyRefund(args *protocol.ApplyRefundArgs, reply *protocol.ApplyRefundReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:apply_refund] args: %+v", args)
+
+    deadline, err := utils.RefundDeadline()
+    if nil != err {
+        utils.Logger.Error("refund deadline config err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    if !deadline.IsZero() && time.Now().After(deadline) {
+        err = utils.NewInternalErrorByStr(utils.RefundDeadlineErrCode, fmt.Sprintf("退款截止时间为%s", deadline.Format("01月02日")))
+        utils.Logger.Error("ApplyRefund failed, user_id: %d, order_no: %s after deadline", args.UserId, args.OrderNo)
+        return err
+    }
+
+    order, err := getUserOrder(args.UserId, args.OrderNo)
+    if nil != err {
+        return err
+    }
+    refund, err := refundOrderTickets(order, args.TicketNos, args.Reason, model.REFUND_SOURCE_USER, args.UserId)
+    if nil != err {
+        return err
+    }
+    copyRefundData(refund, &reply.Refund)
+    return nil
+}
+
+// 管理员退款
+func AdminRefundOrder(args *protocol.AdminRefundArgs, reply *protocol.AdminRefundReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:admin_refund] args: %+v", args)
+
+    var err error
+    if args.OrderNo == "" || args.Reason == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "订单号和退款原因不能为空")
+        utils.Logger.Error("AdminRefundOrder failed, param err: %s \n", err.Error())
+        return err
+    }
+    order := new(model.Order)
+    found, err := order.GetOrderByNo(args.OrderNo)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "订单不存在")
+    }
+    refund, err := refundOrderTickets(order, args.TicketNos, args.Reason, model.REFUND_SOURCE_ADMIN, args.OperatorId)
+    if nil != err {
+        return err
+    }
+    copyRefundData(refund, &reply.Refund)
+    return nil
+}
+
+// 订单流水和退款记录
+func GetOrderLogList(args *protocol.OrderLogListArgs, reply *protocol.OrderLogListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:order_log_list] args: %+v", args)
+
+    order := new(model.Order)
+    found, err := order.GetOrderByNo(args.OrderNo)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "订单不存在")
+    }
+    items, err := model.GetOrderItemList(order.Id)
+    if nil != err {
+        return err
+    }
+    copyOrderData(order, items, &reply.Order)
+
+    log_list, err := model.GetOrderLogList(order.Id)
+    if nil != err {
+        return err
+    }
+    reply.LogList = make([]protocol.OrderLogJson, len(log_list))
+    for i, log := range log_list {
+        dst := &reply.LogList[i]
+        dst.RefundNo = log.RefundNo
+        dst.Action = log.Action
+        dst.Status = log.Status
+        dst.Amount = log.Amount
+        dst.TransactionId = log.TransactionId
+        dst.OperatorId = log.OperatorId
+        dst.Remark = log.Remark
+        dst.CreateTime = formatOrderTime(log.CreateTime)
+    }
+
+    refund_list, err := model.GetOrderRefundList(order.Id)
+    if nil != err {
+        return err
+    }
+    reply.RefundList = copyRefundList(refund_list)
+    return nil
+}
+
+/**
+ * 向支付平台申请退款
+ *
+ * 支付平台明确失败时退款失败并恢复门票；网络超时等结果未知时保持退款中，
+ * 由脚本用同一退款单号重试，支付平台对同一退款单号只退一次
+ */
+func sendPayRefund(order *model.Order, refund *model.Refund) error {
+    refund_id, err := utils.PayClient.Refund(&utils.PayRefund{
+        OrderNo:    order.OrderNo,
+        RefundNo:   refund.RefundNo,
+        TotalFee:   order.TotalFee,
+        RefundFee:  refund.RefundFee,
+        Reason:     refund.Reason,
+    })
+    if nil != err && !utils.IsPayBizError(err) {
+        utils.Logger.Warning("pay refund result unknown, will retry, order_no: %s, refund_no: %s, err: %v",
+            order.OrderNo, refund.RefundNo, err)
+        return nil
+    }
+    if nil != err {
+        utils.Logger.Error("pay refund failed, order_no: %s, refund_no: %s, err: %v", order.OrderNo, refund.RefundNo, err)
+        if _, err2 := model.FailRefund(refund, err.Error()); nil != err2 {
+            utils.Logger.Error("fail refund after pay refund failed, refund_no: %s, err: %v", refund.RefundNo, err2)
+        }
+        return utils.NewInternalErrorByStr(utils.RefundErrCode, "退款申请失败，请稍后重试")
+    }
+    return refund.SaveRefundId(refund_id)
+}
+
+// 重试申请结果未知的退款，定时执行
+func RetryUnsentRefunds() (int, error) {
+    if err := checkPayEnabled(); nil != err {
+        return 0, err
+    }
+    sent_num := 0
+    last_id := int64(0)
+    // 刚申请的退款可能还在等待支付平台返回
+    before := time.Now().Add(-RETRY_REFUND_DELAY)
+    for {
+        refund_list, err := model.GetUnsentRefundList(before, last_id, RETRY_REFUND_BATCH)
+        if nil != err {
+            return sent_num, err
+        }
+        if len(refund_list) == 0 {
+            return sent_num, nil
+        }
+        last_id = refund_list[len(refund_list)-1].Id
+        for i := range refund_list {
+            refund := &refund_list[i]
+            order := new(model.Order)
+            found, err := order.GetOrderByNo(refund.OrderNo)
+            if nil != err {
+                continue
+            }
+            if !found {
+                utils.Logger.Error("retry refund order not found, refund_no: %s, order_no: %s", refund.RefundNo, refund.OrderNo)
+                continue
+            }
+            if err = sendPayRefund(order, refund); nil != err {
+                continue
+            }
+            if refund.RefundId != "" {
+                utils.Logger.Info("retry refund sent, order_no: %s, refund_no: %s", refund.OrderNo, refund.RefundNo)
+                sent_num++
+            }
+        }
+    }
+}
+
+/**
+ * 处理退款结果通知
+ *
+ * 返回nil时应答支付平台成功，否则支付平台会重试通知
+ */
+func HandleRefundNotify(body []byte) error {
+    if err := checkPayEnabled(); nil != err {
+        return err
+    }
+    notify, err := utils.PayClient.ParseRefundNotify(body)
+    if nil != err {
+        utils.Logger.Error("parse refund notify failed, err: %v", err)
+        return err
+    }
+    utils.Logger.Info("[cmd:refund_notify] notify: %+v", notify)
+
+    refund := new(model.Refund)
+    found, err := refund.GetRefundByNo(notify.RefundNo)
+    if nil != err {
+        return err
+    }
+    if !found || refund.OrderNo != notify.OrderNo {
+        utils.Logger.Error("refund notify refund not found, refund_no: %s, order_no: %s", notify.RefundNo, notify.OrderNo)
+        return fmt.Errorf("refund not found")
+    }
+    if notify.Success && notify.RefundFee != refund.RefundFee {
+        utils.Logger.Error("refund notify refund_fee mismatch, refund_no: %s, refund_fee: %d, notify: %d",
+            refund.RefundNo, refund.RefundFee, notify.RefundFee)
+        return fmt.Errorf("refund_fee mismatch")
+    }
+
+    switch refund.Status {
+    case model.REFUND_STATUS_SUCCESS:
+        return nil
+    case model.REFUND_STATUS_FAILED:
+        // 申请时接口返回失败但实际退款成功，门票已恢复，需要人工处理
+        if notify.Success {
+            utils.Logger.Error("refund notify success but refund failed, need manual handling, refund_no: %s, refund_id: %s",
+                refund.RefundNo, notify.RefundId)
+        }
+        return nil
+    }
+
+    if notify.Success {
+        ok, err := model.SucceedRefund(refund, notify.RefundId, notify.SuccessTime)
+        if nil != err {
+            return err
+        }
+        if ok {
+            utils.Logger.Info("refund success, order_no: %s, refund_no: %s, refund_fee: %d", refund.OrderNo, refund.RefundNo, refund.RefundFee)
+        }
+        return nil
+    }
+    ok, err := model.FailRefund(refund, "refund_status " + notify.Status)
+    if nil != err {
+        return err
+    }
+    if ok {
+        utils.Logger.Warning("refund failed, order_no: %s, refund_no: %s, status: %s", refund.OrderNo, refund.RefundNo, notify.Status)
+    }
+    return nil
+}
+
+// 模拟退款结果通知，生成加密的退款通知并按正常流程处理
+func FakeRefundConfirm(args *protocol.FakeRefundConfirmArgs, reply *protocol.FakeRefundConfirmReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:fake_refund_confirm] args: %+v", args)
+
+    fake, ok := utils.PayClient.(*utils.FakePayGateway)
+    if !ok {
+        return utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "未启用模拟支付")
+    }
+    refund := new(model.Refund)
+    found, err := refund.GetRefundByNo(args.RefundNo)
+    if nil != err {
+        return err
+    }
+    if !found || (refund.UserId != args.UserId && refund.OperatorId != args.UserId) {
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "退款单不存在")
+    }
+
+    err = HandleRefundNotify(fake.RefundNotify(refund.OrderNo, refund.RefundNo, refund.RefundFee, args.Success))
+    if nil != err {
+        return err
+    }
+    _, err = refund.GetRefundByNo(refund.RefundNo)
+    reply.Status = refund.Status
+    return err
+}
@@ -0,0 +1,404 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/20 21:10
+ */
+package controller
+
+import (
+    "fmt"
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    REGISTRATION_IMPORT_MAX_SIZE    = 5 * 1024 * 1024   // 上传文件最大5M
+    REGISTRATION_IMPORT_MAX_ROWS    = 5000
+    REGISTRATION_SYNC_MAX_ROWS      = 50                // 不超过时直接处理并返回结果，否则后台处理
+    REGISTRATION_PROGRESS_STEP      = 20                // 每处理多少行保存一次进度
+    REGISTRATION_JOB_STALE          = 10 * time.Minute  // 处理中的任务超过此时间没有进度，认为已中断
+)
+
+// 团体登记表格的表头，支持中文和英文，英文不区分大小写
+var registrationImportColumns = map[string]string{
+    "姓名":           "name",
+    "name":           "name",
+    "手机":           "phone",
+    "手机号":         "phone",
+    "电话":           "phone",
+    "phone":          "phone",
+    "国际区号":       "country_code",
+    "country_code":   "country_code",
+    "邮箱":           "email",
+    "email":          "email",
+}
+
+// 表格中的一行，values 为字段 => 内容
+type registrationImportRow struct {
+    row_num     int
+    values      map[string]string
+}
+
+// 登记任务编号，G加时间和随机数
+func newRegistrationJobNo() (string, error) {
+    random, err := utils.RandomHex(4)
+    if nil != err {
+        return "", err
+    }
+    return "G" + time.Now().Format("20060102150405") + strings.ToUpper(random), nil
+}
+
+func copyRegistrationJobData(from *model.RegistrationJob, dst *protocol.RegistrationJobJson) {
+    dst.JobNo = from.JobNo
+    dst.EditionId = from.EditionId
+    dst.Filename = from.Filename
+    dst.Status = from.Status
+    dst.TotalNum = from.TotalNum
+    dst.ProcessedNum = from.ProcessedNum
+    dst.CreatedNum = from.CreatedNum
+    dst.DuplicateNum = from.DuplicateNum
+    dst.FailNum = from.FailNum
+    dst.ErrMsg = from.ErrMsg
+    dst.CreateTime = formatOrderTime(from.CreateTime)
+    dst.FinishTime = formatOrderTime(from.FinishTime)
+}
+
+func copyRegistrationRowData(from *model.RegistrationRow, dst *protocol.RegistrationRowJson) {
+    dst.Row = from.RowNum
+    dst.Name = from.Name
+    dst.Phone = from.Phone
+    dst.Email = from.Email
+    dst.Status = from.Status
+    dst.UserId = from.UserId
+    dst.TicketNo = from.TicketNo
+    dst.Desc = from.Reason
+}
+
+/**
+ * 登记一行，已注册的电话号码不重复创建
+ *
+ * 用户和门票在同一个事务中创建，phones 为本任务已处理的电话号码
+ */
+func registerImportRow(job *model.RegistrationJob, row *registrationImportRow, phones map[string]bool) *model.RegistrationRow {
+    result := &model.RegistrationRow{
+        JobId:  job.Id,
+        RowNum: row.row_num,
+        Name:   row.values["name"],
+        Phone:  row.values["phone"],
+        Email:  row.values["email"],
+        Status: model.REGISTRATION_ROW_FAILED,
+    }
+
+    phone, err := model.ValidateRegistrationRow(row.values)
+    if nil != err {
+        _, _, result.Reason = utils.IsUserErr(err)
+        return result
+    }
+    result.Phone = phone
+    if phones[phone] {
+        result.Status = model.REGISTRATION_ROW_DUPLICATE
+        result.Reason = "表格中电话号码重复"
+        return result
+    }
+    phones[phone] = true
+
+    err, exist, user_info := model.CheckPhoneExist(phone)
+    if nil != err {
+        result.Reason = "查询用户失败"
+        return result
+    }
+    if exist {
+        result.Status = model.REGISTRATION_ROW_DUPLICATE
+        result.UserId = user_info.UserId
+        result.Reason = "电话号码已注册"
+        return result
+    }
+
+    ticket := new(model.Ticket)
+    ticket.ValidFrom, ticket.ValidTo, err = editionTicketValidTime(job.EditionId, time.Now())
+    if nil != err {
+        utils.Logger.Error("ticket valid time config err: %v", err)
+        result.Reason = "门票有效期配置错误"
+        return result
+    }
+    ticket_no, err := utils.RandomHex(8)
+    if nil != err {
+        result.Reason = "生成门票编号失败"
+        return result
+    }
+    ticket.TicketNo = strings.ToUpper(ticket_no)
+    ticket.EditionId = job.EditionId
+    ticket.Status = model.TICKET_STATUS_VALID
+
+    user_info = &model.User{
+        Name:       result.Name,
+        Phone:      phone,
+        Email:      result.Email,
+        RegistType: model.REGIST_TYPE_GROUP,
+    }
+    if err = model.RegisterUserWithTicket(user_info, ticket); nil != err {
+        result.Reason = "注册失败"
+        return result
+    }
+    result.Status = model.REGISTRATION_ROW_CREATED
+    result.UserId = user_info.UserId
+    result.TicketNo = ticket.TicketNo
+    return result
+}
+
+// 任务异常结束，标记为失败，已处理的行保留
+func failRegistrationJob(job *model.RegistrationJob, err_msg string) {
+    job.Status = model.REGISTRATION_JOB_FAILED
+    job.ErrMsg = err_msg
+    if err := job.SaveProgress(); nil != err {
+        utils.Logger.Error("save failed registration job error, job_no: %s, err: %v", job.JobNo, err)
+    }
+}
+
+/**
+ * 处理登记任务，逐行登记并保存结果，定期保存进度
+ *
+ * 保存登记结果失败时任务标记为失败并返回错误，已处理的行保留
+ */
+func processRegistrationJob(job *model.RegistrationJob, rows []registrationImportRow) ([]model.RegistrationRow, error) {
+    result_list := make([]model.RegistrationRow, 0, len(rows))
+    phones := make(map[string]bool, len(rows))
+    for i := range rows {
+        result := registerImportRow(job, &rows[i], phones)
+        if err := result.Create(); nil != err {
+            utils.Logger.Error("save registration row failed, job_no: %s, row_num: %d, err: %v", job.JobNo, result.RowNum, err)
+            failRegistrationJob(job, "保存登记结果失败")
+            return result_list, err
+        }
+        result_list = append(result_list, *result)
+
+        job.ProcessedNum++
+        switch result.Status {
+        case model.REGISTRATION_ROW_CREATED:
+            job.CreatedNum++
+        case model.REGISTRATION_ROW_DUPLICATE:
+            job.DuplicateNum++
+        default:
+            job.FailNum++
+        }
+        if job.ProcessedNum%REGISTRATION_PROGRESS_STEP == 0 && job.ProcessedNum < job.TotalNum {
+            job.SaveProgress()
+        }
+    }
+
+    job.Status = model.REGISTRATION_JOB_FINISHED
+    if err := job.SaveProgress(); nil != err {
+        return result_list, err
+    }
+    utils.Logger.Info("registration job finished, job_no: %s, created: %d, duplicate: %d, fail: %d",
+        job.JobNo, job.CreatedNum, job.DuplicateNum, job.FailNum)
+    return result_list, nil
+}
+
+// 后台处理登记任务，panic时标记为异常中断，避免服务退出
+func runRegistrationJob(job *model.RegistrationJob, rows []registrationImportRow) {
+    defer func() {
+        if r := recover(); r != nil {
+            utils.Logger.Error("process registration job panic, job_no: %s, err: %v", job.JobNo, r)
+            failRegistrationJob(job, "处理异常中断")
+        }
+    }()
+    if _, err := processRegistrationJob(job, rows); nil != err {
+        utils.Logger.Error("process registration job failed, job_no: %s, err: %v", job.JobNo, err)
+    }
+}
+
+/**
+ * 上传表格团体登记观众，支持csv和xlsx，第一行为表头
+ *
+ * 行数较少时直接处理并返回每行结果，否则后台处理，通过任务编号查询进度和结果
+ */
+func ImportRegistration(args *protocol.ImportRegistrationArgs, reply *protocol.ImportRegistrationReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:import_registration] operator_id: %d, filename: %s, size: %d",
+        args.OperatorId, args.Filename, len(args.Data))
+
+    edition_id := ticketEditionId()
+    if edition_id == 0 {
+        return utils.NewInternalErrorByStr(utils.InternalErrorCode, "未配置门票届次")
+    }
+
+    sheet_rows, err := utils.ReadSheetRows(args.Filename, args.Data)
+    if nil != err {
+        return err
+    }
+    if len(sheet_rows) > REGISTRATION_IMPORT_MAX_ROWS+1 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每次最多登记%d行", REGISTRATION_IMPORT_MAX_ROWS))
+    }
+
+    // 表头，列下标 => 字段
+    header := make(map[int]string)
+    columns := make(map[string]bool)
+    if len(sheet_rows) > 0 {
+        for i, title := range sheet_rows[0] {
+            if column, ok := registrationImportColumns[strings.ToLower(title)]; ok {
+                header[i] = column
+                columns[column] = true
+            }
+        }
+    }
+    if !columns["name"] || !columns["phone"] {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "表头需要包含姓名和手机")
+    }
+
+    rows := make([]registrationImportRow, 0, len(sheet_rows))
+    for i := 1; i < len(sheet_rows); i++ {
+        values := make(map[string]string)
+        for j, value := range sheet_rows[i] {
+            if column, ok := header[j]; ok && value != "" {
+                values[column] = value
+            }
+        }
+        if len(values) > 0 {
+            rows = append(rows, registrationImportRow{row_num: i + 1, values: values})
+        }
+    }
+    if len(rows) == 0 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "表格没有数据")
+    }
+
+    job := new(model.RegistrationJob)
+    job.JobNo, err = newRegistrationJobNo()
+    if nil != err {
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    job.OperatorId = args.OperatorId
+    job.EditionId = edition_id
+    job.Filename = args.Filename
+    job.TotalNum = len(rows)
+    if err = job.Create(); nil != err {
+        return err
+    }
+    utils.Logger.Info("create registration job, job_no: %s, operator_id: %d, total: %d", job.JobNo, job.OperatorId, job.TotalNum)
+
+    if len(rows) > REGISTRATION_SYNC_MAX_ROWS {
+        go runRegistrationJob(job, rows)
+        copyRegistrationJobData(job, &reply.Job)
+        reply.RowList = []protocol.RegistrationRowJson{}
+        return nil
+    }
+
+    result_list, err := processRegistrationJob(job, rows)
+    if nil != err {
+        return err
+    }
+    copyRegistrationJobData(job, &reply.Job)
+    reply.RowList = make([]protocol.RegistrationRowJson, len(result_list))
+    for i := range result_list {
+        copyRegistrationRowData(&result_list[i], &reply.RowList[i])
+    }
+    return nil
+}
+
+/**
+ * 获取登记任务，合作机构只能查看自己的任务
+ *
+ * 服务重启等原因中断的任务长时间没有进度，标记为异常中断
+ */
+func getRegistrationJob(operator_id int64, is_admin bool, job_no string) (*model.RegistrationJob, error) {
+    job := new(model.RegistrationJob)
+    found, err := job.GetJobByNo(job_no)
+    if nil != err {
+        return nil, err
+    }
+    if !found || (!is_admin && job.OperatorId != operator_id) {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "登记任务不存在")
+        utils.Logger.Error("get registration job failed, operator_id: %d, job_no: %s", operator_id, job_no)
+        return nil, err
+    }
+    if job.Status == model.REGISTRATION_JOB_RUNNING && time.Since(job.UpdateTime) > REGISTRATION_JOB_STALE {
+        job.Status = model.REGISTRATION_JOB_FAILED
+        job.ErrMsg = "处理中断，请重新上传未处理的行"
+        if err = job.SaveProgress(); nil != err {
+            return nil, err
+        }
+        utils.Logger.Warning("registration job stale, job_no: %s, processed: %d, total: %d", job.JobNo, job.ProcessedNum, job.TotalNum)
+    }
+    return job, nil
+}
+
+// 登记任务的进度
+func GetRegistrationJob(args *protocol.RegistrationJobArgs, reply *protocol.RegistrationJobReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:registration_job] args: %+v", args)
+
+    job, err := getRegistrationJob(args.OperatorId, args.IsAdmin, args.JobNo)
+    if nil != err {
+        return err
+    }
+    copyRegistrationJobData(job, &reply.Job)
+    return nil
+}
+
+// 登记任务每行的结果
+func GetRegistrationRowList(args *protocol.RegistrationRowListArgs, reply *protocol.RegistrationRowListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:registration_row_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 100
+    }
+
+    job, err := getRegistrationJob(args.OperatorId, args.IsAdmin, args.JobNo)
+    if nil != err {
+        return err
+    }
+    row_list, total_num, err := model.GetRegistrationRowListByPage(job.Id, args.Status, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    reply.RowList = make([]protocol.RegistrationRowJson, len(row_list))
+    for i := range row_list {
+        copyRegistrationRowData(&row_list[i], &reply.RowList[i])
+    }
+    reply.TotalNum = total_num
+    return nil
+}
+
+// 登记任务列表
+func GetRegistrationJobList(args *protocol.RegistrationJobListArgs, reply *protocol.RegistrationJobListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:registration_job_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+
+    operator_id := args.OperatorId
+    if args.IsAdmin {
+        operator_id = 0
+    }
+    job_list, total_num, err := model.GetRegistrationJobListByPage(operator_id, args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    reply.JobList = make([]protocol.RegistrationJobJson, len(job_list))
+    for i := range job_list {
+        copyRegistrationJobData(&job_list[i], &reply.JobList[i])
+    }
+    reply.TotalNum = total_num
+    return nil
+}
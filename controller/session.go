@@ -0,0 +1,190 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/11/12 16:48
+ */
+package controller
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    ACCESS_TOKEN_EXPIRE     = 2 * 3600          // access_token 2小时失效
+    REFRESH_TOKEN_EXPIRE    = 30 * 24 * 3600    // refresh_token 30天失效
+    PHONE_VERIFIED_EXPIRE   = 20 * 60           // 手机号验证通过后20分钟内可注册
+)
+
+func accessTokenKey(token string) string {
+    return "token:access:" + token
+}
+
+func refreshTokenKey(token string) string {
+    return "token:refresh:" + token
+}
+
+func phoneVerifiedKey(phone string) string {
+    return "phone_verified:" + phone
+}
+
+// 签发登录凭证，access_token和refresh_token都保存在redis中，便于注销
+func IssueUserToken(user_info *model.User, token *protocol.TokenInfoJson) error {
+    now := time.Now().Unix()
+
+    access_token, err := utils.GenerateSignedToken(user_info.UserId, now+ACCESS_TOKEN_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("generate access token err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    refresh_token, err := utils.GenerateSignedToken(user_info.UserId, now+REFRESH_TOKEN_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("generate refresh token err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+
+    err = g_cache.Set(accessTokenKey(access_token), user_info.UserId, ACCESS_TOKEN_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("set access token cache err: %v", err)
+        return err
+    }
+    // refresh_token 中记录对应的access_token，刷新时一并失效
+    err = g_cache.Set(refreshTokenKey(refresh_token), fmt.Sprintf("%d:%s", user_info.UserId, access_token), REFRESH_TOKEN_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("set refresh token cache err: %v", err)
+        return err
+    }
+
+    token.AccessToken = access_token
+    token.RefreshToken = refresh_token
+    token.ExpiresIn = ACCESS_TOKEN_EXPIRE
+    return nil
+}
+
+// 用户登录，更新最后登录时间并签发登录凭证
+func UserLogin(user_info *model.User, token *protocol.TokenInfoJson) error {
+    err := user_info.UpdateLastLogin()
+    if nil != err {
+        return err
+    }
+    return IssueUserToken(user_info, token)
+}
+
+// 通过access_token获取登录用户
+func GetUserByAccessToken(token string) (*model.User, error) {
+    invalid_err := utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录已失效，请重新登录")
+    if token == "" {
+        return nil, invalid_err
+    }
+
+    // 先校验签名，避免伪造的token打到redis
+    user_id, ok := utils.ParseSignedToken(token)
+    if !ok {
+        utils.Logger.Warning("access token sign invalid, token: %s", token)
+        return nil, invalid_err
+    }
+
+    res, err := g_cache.Get(accessTokenKey(token))
+    if nil != err && utils.CheckRedisReturnValue(err) != utils.KeyNotFound {
+        utils.Logger.Error("get access token cache err: %v", err)
+        return nil, err
+    }
+    if res == nil || string(res) != strconv.FormatInt(user_id, 10) {
+        return nil, invalid_err
+    }
+
+    user_info := new(model.User)
+    err = user_info.GetUserById(user_id)
+    if nil != err {
+        return nil, err
+    }
+    if user_info.UserId == 0 {
+        return nil, invalid_err
+    }
+    return user_info, nil
+}
+
+// 刷新登录凭证，旧的refresh_token和access_token同时失效
+func RefreshUserToken(args *protocol.RefreshTokenArgs, reply *protocol.RefreshTokenReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:refresh_token] args: %+v", args)
+
+    invalid_err := utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录已失效，请重新登录")
+    user_id, ok := utils.ParseSignedToken(args.RefreshToken)
+    if !ok {
+        return invalid_err
+    }
+
+    res, err := g_cache.Get(refreshTokenKey(args.RefreshToken))
+    if nil != err && utils.CheckRedisReturnValue(err) != utils.KeyNotFound {
+        utils.Logger.Error("get refresh token cache err: %v", err)
+        return err
+    }
+    if res == nil {
+        return invalid_err
+    }
+    values := strings.SplitN(string(res), ":", 2)
+    if len(values) != 2 || values[0] != strconv.FormatInt(user_id, 10) {
+        return invalid_err
+    }
+
+    user_info := new(model.User)
+    err = user_info.GetUserById(user_id)
+    if nil != err {
+        return err
+    }
+    if user_info.UserId == 0 {
+        return invalid_err
+    }
+
+    g_cache.Del(refreshTokenKey(args.RefreshToken))
+    g_cache.Del(accessTokenKey(values[1]))
+
+    return IssueUserToken(user_info, &reply.Token)
+}
+
+// 退出登录
+func UserLogout(args *protocol.UserLogoutArgs, reply *protocol.UserLogoutReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:user_logout] args: %+v", args)
+
+    if args.AccessToken != "" {
+        if err := g_cache.Del(accessTokenKey(args.AccessToken)); nil != err {
+            utils.Logger.Error("del access token cache err: %v", err)
+            return err
+        }
+    }
+    if args.RefreshToken != "" {
+        if err := g_cache.Del(refreshTokenKey(args.RefreshToken)); nil != err {
+            utils.Logger.Error("del refresh token cache err: %v", err)
+            return err
+        }
+    }
+    return nil
+}
+
+// 获取当前登录用户信息
+func GetUserInfo(args *protocol.GetUserInfoArgs, reply *protocol.GetUserInfoReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:get_user_info] args: %+v", args)
+
+    user_model := new(model.User)
+    err := user_model.GetUserById(args.UserId)
+    if nil != err {
+        return err
+    }
+    model.CopyUserData(user_model, &reply.User)
+    return nil
+}
@@ -0,0 +1,203 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/24 14:30
+ */
+package controller
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+    "pet/protocol"
+    "pet/utils"
+    "github.com/dchest/captcha"
+)
+
+const (
+    DEFAULT_SMS_DEVICE_HEADER   = "X-Device-Id"
+    SMS_DEVICE_ID_MAX_LEN       = 64
+)
+
+// 短信限流配置，未配置的项使用默认值
+func smsThrottleSetting() utils.SmsThrottleConfig {
+    setting := utils.Config.SmsThrottle
+    if setting.IpHourMax <= 0 {
+        setting.IpHourMax = 20
+    }
+    if setting.IpDayMax <= 0 {
+        setting.IpDayMax = 50
+    }
+    if setting.DeviceHourMax <= 0 {
+        setting.DeviceHourMax = 10
+    }
+    if setting.DeviceDayMax <= 0 {
+        setting.DeviceDayMax = 20
+    }
+    if setting.GlobalHourMax <= 0 {
+        setting.GlobalHourMax = 2000
+    }
+    if setting.DeviceHeader == "" {
+        setting.DeviceHeader = DEFAULT_SMS_DEVICE_HEADER
+    }
+    if setting.CaptchaIpThreshold <= 0 {
+        setting.CaptchaIpThreshold = 3
+    }
+    if setting.CaptchaDeviceThreshold <= 0 {
+        setting.CaptchaDeviceThreshold = 3
+    }
+    return setting
+}
+
+// 设备标识请求头
+func SmsDeviceHeader() string {
+    return smsThrottleSetting().DeviceHeader
+}
+
+func smsIpKey(ip, period string) string {
+    return "sms_ip:" + ip + ":" + period
+}
+
+func smsDeviceKey(device_id, period string) string {
+    return "sms_device:" + device_id + ":" + period
+}
+
+func smsGlobalKey(period string) string {
+    return "sms_global:" + period
+}
+
+// 同一电话号码两次发送的最短间隔，单位秒
+const SMS_PHONE_INTERVAL = 5
+
+// 同一电话号码每天最多发送次数
+const SMS_PHONE_DAY_MAX = 10
+
+// 一项发送配额，计数超过max时拒绝
+type smsQuota struct {
+    key         string
+    expire      int
+    max         int
+    code        utils.ErrCode
+    desc        string
+}
+
+func smsPhoneKey(phone string) string {
+    return phone
+}
+
+func smsPhoneDayKey(phone, day string) string {
+    return fmt.Sprintf("%s:%s", phone, day)
+}
+
+func getSmsCount(key string) int {
+    res, err := g_cache.Get(key)
+    if nil != err || res == nil {
+        return 0
+    }
+    times, _ := strconv.Atoi(string(res))
+    return times
+}
+
+func incrSmsCount(key string, expire int) (int, error) {
+    times, err := g_cache.Incr(key)
+    if nil != err {
+        utils.Logger.Error("incr sms count err, key: %s, err: %v", key, err)
+        return 0, err
+    }
+    g_cache.Expire(key, expire)
+    return times, nil
+}
+
+// 释放已占用的配额
+func releaseSmsQuota(keys []string) {
+    for _, key := range keys {
+        if _, err := g_cache.Decr(key); nil != err {
+            utils.Logger.Error("release sms quota err, key: %s, err: %v", key, err)
+        }
+    }
+}
+
+/**
+ * 超过ip、设备的图形验证码阈值后需要先通过图形验证码
+ *
+ * 只用于判断是否需要图形验证码，发送次数限制由 reserveSmsQuota 保证，需要先调用
+ */
+func checkSmsCaptcha(args *protocol.SendVerifyCodeArgs) error {
+    var err error
+    setting := smsThrottleSetting()
+    if len(args.DeviceId) > SMS_DEVICE_ID_MAX_LEN {
+        args.DeviceId = args.DeviceId[:SMS_DEVICE_ID_MAX_LEN]
+    }
+    if !setting.CaptchaEnable {
+        return nil
+    }
+
+    hour := time.Now().Format("2006010215")
+    var ip_hour, device_hour int
+    if args.ClientIp != "" {
+        ip_hour = getSmsCount(smsIpKey(args.ClientIp, hour))
+    }
+    if args.DeviceId != "" {
+        device_hour = getSmsCount(smsDeviceKey(args.DeviceId, hour))
+    }
+    if ip_hour < setting.CaptchaIpThreshold && device_hour < setting.CaptchaDeviceThreshold {
+        return nil
+    }
+    if args.CaptchaId == "" || args.CaptchaCode == "" {
+        err = utils.NewInternalErrorByStr(utils.CaptchaRequiredErrCode, "请输入图形验证码")
+        utils.Logger.Info("SendVerifyCode captcha required, ip: %s, device_id: %s", args.ClientIp, args.DeviceId)
+        return err
+    }
+    // 校验后图形验证码失效
+    if !captcha.VerifyString(args.CaptchaId, args.CaptchaCode) {
+        err = utils.NewInternalErrorByStr(utils.CaptchaWrongErrCode, "图形验证码错误")
+        utils.Logger.Error("SendVerifyCode captcha wrong, ip: %s, device_id: %s", args.ClientIp, args.DeviceId)
+        return err
+    }
+    return nil
+}
+
+/**
+ * 占用一次短信发送配额，按电话号码、ip、设备、全局依次计数
+ *
+ * 先计数再判断，并发请求不会超过上限；超过任一上限时释放本次已占用的配额并拒绝
+ * 全局每小时发送量超过上限时停止发送，防止短信费用被刷；发送失败也计入次数
+ */
+func reserveSmsQuota(args *protocol.SendVerifyCodeArgs) error {
+    setting := smsThrottleSetting()
+    now := time.Now()
+    hour, day := now.Format("2006010215"), now.Format("2006-01-02")
+
+    quotas := []smsQuota{
+        {smsPhoneKey(args.Phone), SMS_PHONE_INTERVAL, 1, utils.HighFrequencyErrCode, "验证码请求频率太快"},
+        {smsPhoneDayKey(args.Phone, day), 24*3600, SMS_PHONE_DAY_MAX, utils.DayMaxTimeErrCode, "验证码超过每天次数"},
+    }
+    if args.ClientIp != "" {
+        quotas = append(quotas,
+            smsQuota{smsIpKey(args.ClientIp, hour), 3600, setting.IpHourMax, utils.HighFrequencyErrCode, "验证码请求频率太快"},
+            smsQuota{smsIpKey(args.ClientIp, day), 24*3600, setting.IpDayMax, utils.DayMaxTimeErrCode, "验证码超过每天次数"})
+    }
+    if args.DeviceId != "" {
+        quotas = append(quotas,
+            smsQuota{smsDeviceKey(args.DeviceId, hour), 3600, setting.DeviceHourMax, utils.HighFrequencyErrCode, "验证码请求频率太快"},
+            smsQuota{smsDeviceKey(args.DeviceId, day), 24*3600, setting.DeviceDayMax, utils.DayMaxTimeErrCode, "验证码超过每天次数"})
+    }
+    quotas = append(quotas,
+        smsQuota{smsGlobalKey(hour), 3600, setting.GlobalHourMax, utils.SmsLimitErrCode, "短信发送量超过限制，请稍后再试"})
+
+    reserved := make([]string, 0, len(quotas))
+    for _, quota := range quotas {
+        times, err := incrSmsCount(quota.key, quota.expire)
+        if nil != err {
+            releaseSmsQuota(reserved)
+            return utils.NewInternalError(utils.CacheErrCode, err)
+        }
+        reserved = append(reserved, quota.key)
+        if times > quota.max {
+            releaseSmsQuota(reserved)
+            utils.Logger.Error("SendVerifyCode limit reached, key: %s, times: %d, max: %d", quota.key, times, quota.max)
+            return utils.NewInternalErrorByStr(quota.code, quota.desc)
+        }
+    }
+    return nil
+}
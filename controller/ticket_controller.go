@@ -0,0 +1,261 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/3 21:35
+ */
+package controller
+
+import (
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+    "github.com/skip2/go-qrcode"
+)
+
+const (
+    TICKET_QRCODE_SIZE      = 256
+    TICKET_QRCODE_MAX_SIZE  = 1024
+)
+
+// 发放门票的届次，未单独配置时使用当前届次
+func ticketEditionId() int64 {
+    if utils.Config.TicketSetting.EditionId != 0 {
+        return utils.Config.TicketSetting.EditionId
+    }
+    return utils.Config.EditionSetting.CurrentId
+}
+
+// 已发布的届次，不存在或未发布时返回 found=false
+func getActiveEdition(edition_id int64) (*model.Edition, bool, error) {
+    edition := new(model.Edition)
+    found, err := edition.GetEditionById(edition_id)
+    if nil != err {
+        return nil, false, err
+    }
+    return edition, found && edition.Status == model.EDITION_STATUS_PUBLISHED, nil
+}
+
+/**
+ * 门票的有效期
+ *
+ * 配置的发放届次使用 TicketSetting 的有效期，其他届次使用届次的开展和闭展日期，
+ * 都未配置时从发放时起一年内有效
+ */
+func ticketValidTime(edition *model.Edition, now time.Time) (valid_from, valid_to time.Time, err error) {
+    config := &utils.Config.TicketSetting
+    if edition.Id != ticketEditionId() {
+        valid_from = now
+        if !edition.StartDate.IsZero() {
+            valid_from = edition.StartDate
+        }
+        valid_to = valid_from.AddDate(1, 0, 0)
+        if !edition.EndDate.IsZero() {
+            valid_to = edition.EndDate.AddDate(0, 0, 1).Add(-time.Second)
+        }
+        return
+    }
+    valid_from = now
+    if config.ValidFrom != "" {
+        valid_from, err = time.ParseInLocation(utils.DATE_FORMAT, config.ValidFrom, time.Local)
+        if nil != err {
+            return
+        }
+    }
+    valid_to = valid_from.AddDate(1, 0, 0)
+    if config.ValidTo != "" {
+        valid_to, err = time.ParseInLocation(utils.DATE_FORMAT, config.ValidTo, time.Local)
+        if nil != err {
+            return
+        }
+        // 包含失效日期当天
+        valid_to = valid_to.AddDate(0, 0, 1).Add(-time.Second)
+    }
+    return
+}
+
+// 按届次id确定门票的有效期
+func editionTicketValidTime(edition_id int64, now time.Time) (valid_from, valid_to time.Time, err error) {
+    edition := new(model.Edition)
+    found, err := edition.GetEditionById(edition_id)
+    if nil != err {
+        return
+    }
+    if !found {
+        edition = &model.Edition{Id: edition_id}
+    }
+    return ticketValidTime(edition, now)
+}
+
+// 门票二维码内容
+func ticketPayload(ticket *model.Ticket) string {
+    return utils.TicketSign.Sign(&utils.TicketClaims{
+        TicketNo:   ticket.TicketNo,
+        EditionId:  ticket.EditionId,
+        ValidFrom:  ticket.ValidFrom.Unix(),
+        ValidTo:    ticket.ValidTo.Unix(),
+    })
+}
+
+func copyTicketData(from *model.Ticket, dst *protocol.TicketInfoJson) {
+    dst.TicketNo = from.TicketNo
+    dst.EditionId = from.EditionId
+    dst.ProductId = from.ProductId
+    dst.Status = from.Status
+    dst.ValidFrom = from.ValidFrom.Format(utils.TIME_FORMAT)
+    dst.ValidTo = from.ValidTo.Format(utils.TIME_FORMAT)
+    if from.Status == model.TICKET_STATUS_VALID {
+        dst.Payload = ticketPayload(from)
+    }
+}
+
+// 发放届次的免费门票，已有门票时直接返回
+func IssueTicket(user_id, edition_id int64) (*model.Ticket, error) {
+    if edition_id == 0 {
+        return nil, utils.NewInternalErrorByStr(utils.InternalErrorCode, "ticket edition not configured")
+    }
+
+    ticket := new(model.Ticket)
+    found, err := ticket.GetUserTicket(user_id, edition_id)
+    if nil != err || found {
+        return ticket, err
+    }
+
+    edition, active, err := getActiveEdition(edition_id)
+    if nil != err {
+        return nil, err
+    }
+    if !active {
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "届次不存在或未发布")
+    }
+
+    now := time.Now()
+    ticket.ValidFrom, ticket.ValidTo, err = ticketValidTime(edition, now)
+    if nil != err {
+        utils.Logger.Error("ticket valid time config err: %v", err)
+        return nil, utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    ticket_no, err := utils.RandomHex(8)
+    if nil != err {
+        return nil, utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    ticket.TicketNo = strings.ToUpper(ticket_no)
+    ticket.UserId = user_id
+    ticket.EditionId = edition_id
+    ticket.Status = model.TICKET_STATUS_VALID
+
+    err = ticket.Create()
+    if nil != err {
+        // 并发发放时唯一索引冲突，返回已发放的门票
+        found, err2 := ticket.GetUserTicket(user_id, edition_id)
+        if nil == err2 && found {
+            return ticket, nil
+        }
+        return nil, err
+    }
+    utils.Logger.Info("issue ticket, user_id: %d, edition_id: %d, ticket_no: %s", user_id, edition_id, ticket.TicketNo)
+    return ticket, nil
+}
+
+// 届次是否发放免费门票，售卖门票的届次通过下单发放门票
+func freeTicketEdition(edition_id int64) (bool, error) {
+    if edition_id == 0 {
+        return false, nil
+    }
+    if edition_id == ticketEditionId() {
+        return true, nil
+    }
+    product_list, err := model.GetTicketProductList(edition_id)
+    if nil != err {
+        return false, err
+    }
+    return len(product_list) == 0, nil
+}
+
+/**
+ * 我的门票，返回所有届次的门票
+ *
+ * 届次按参数或请求域名确定，该届发放免费门票且还没有时补发
+ */
+func GetMyTicketList(args *protocol.MyTicketListArgs, reply *protocol.MyTicketListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_ticket_list] args: %+v", args)
+
+    edition_id, err := resolveEditionId(args.EditionId, args.Host)
+    if nil != err {
+        return err
+    }
+    free, err := freeTicketEdition(edition_id)
+    if nil != err {
+        return err
+    }
+    if free {
+        _, err = IssueTicket(args.UserId, edition_id)
+        if nil != err {
+            return err
+        }
+    }
+
+    ticket_list, err := model.GetUserTicketList(args.UserId)
+    if nil != err {
+        return err
+    }
+    reply.TicketList = make([]protocol.TicketInfoJson, len(ticket_list))
+    for i := range ticket_list {
+        copyTicketData(&ticket_list[i], &reply.TicketList[i])
+    }
+    return nil
+}
+
+// 门票二维码图片，只能获取自己的门票
+func GetTicketQrcode(args *protocol.TicketQrcodeArgs, reply *protocol.TicketQrcodeReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:ticket_qrcode] args: %+v", args)
+
+    var err error
+    if args.TicketNo == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "门票编号不能为空")
+        utils.Logger.Error("GetTicketQrcode failed, param err: %s \n", err.Error())
+        return err
+    }
+    if args.Size <= 0 || args.Size > TICKET_QRCODE_MAX_SIZE {
+        args.Size = TICKET_QRCODE_SIZE
+    }
+
+    ticket := new(model.Ticket)
+    found, err := ticket.GetTicketByNo(args.TicketNo)
+    if nil != err {
+        return err
+    }
+    if !found || ticket.UserId != args.UserId {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "门票不存在")
+        utils.Logger.Error("GetTicketQrcode failed, user_id: %d, ticket_no: %s, err: %s", args.UserId, args.TicketNo, err.Error())
+        return err
+    }
+    if ticket.Status != model.TICKET_STATUS_VALID {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "门票已作废")
+        utils.Logger.Error("GetTicketQrcode failed, ticket_no: %s, status: %d", args.TicketNo, ticket.Status)
+        return err
+    }
+
+    reply.Png, err = qrcode.Encode(ticketPayload(ticket), qrcode.Medium, args.Size)
+    if nil != err {
+        utils.Logger.Error("encode ticket qrcode err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    return nil
+}
+
+// 门票签名公钥
+func GetTicketPublicKey(args *protocol.TicketPublicKeyArgs, reply *protocol.TicketPublicKeyReply) error {
+    reply.Algorithm = "Ed25519"
+    reply.KeyId = utils.TicketSign.KeyId()
+    reply.PublicKey = utils.TicketSign.PublicKey()
+    return nil
+}
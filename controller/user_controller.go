@@ -9,11 +9,7 @@ import (
     "pet/protocol"
     "pet/model"
     "pet/utils"
-    "strconv"
-    "math/rand"
     "third/go-local"
-    "fmt"
-    "time"
 )
 
 // 用户电话注册
@@ -32,16 +28,17 @@ func UserPhoneRegist(args *protocol.UserPhoneRegistArgs, reply *protocol.UserPho
         return err
     }
 
-    // 电话号码校验
-    if !utils.PhoneValid(args.Phone) {
+    // 电话号码校验，统一转换成E.164格式
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("UserPhoneRegist failed, normalize phone err: %v", err)
         err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
-        utils.Logger.Error("UserPhoneRegist failed, param err: %s \n", err.Error())
         return err
     }
 
     if args.RegistType == 1 {
-        if args.Openid == "" {
-            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "openid不能为空")
+        if args.Ticket == "" {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "ticket不能为空")
             utils.Logger.Error("UserPhoneRegist failed, param err: %s \n", err.Error())
             return err
         }
@@ -58,20 +55,70 @@ func UserPhoneRegist(args *protocol.UserPhoneRegistArgs, reply *protocol.UserPho
         return err
     }
 
-    // 参数拷贝，插入数据库
-    user_model := new(model.User)
-    utils.DumpStruct(user_model, args)
-
-    var user_id int64
-    err = user_model.Create(&user_id)
+    // 电话号码需要先通过验证码校验
+    verified, err := g_cache.Exists(phoneVerifiedKey(args.Phone))
     if nil != err {
         return err
     }
+    if !verified {
+        err = utils.NewInternalErrorByStr(utils.VerifyCodeWrong, "请先验证电话号码")
+        utils.Logger.Error("UserPhoneRegist failed, err: %s \n", err.Error())
+        return err
+    }
+
+    var user_model *model.User
+    if args.RegistType == 1 {
+        // 微信用户在授权回调时已创建，只通过回调签发的一次性票据确定微信用户，绑定电话号码
+        var found bool
+        user_model, found, err = useLoginTicket(args.Ticket)
+        if nil != err {
+            return err
+        }
+        if !found {
+            err = utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录票据已失效，请重新授权")
+            utils.Logger.Error("UserPhoneRegist failed, err: %s \n", err.Error())
+            return err
+        }
+        if user_model.Phone != "" {
+            err = utils.NewInternalErrorByStr(utils.AccountBindErrCode, "该微信已注册，请直接登录")
+            utils.Logger.Error("UserPhoneRegist failed, user_id: %d, err: %s", user_model.UserId, err.Error())
+            return err
+        }
+        err = user_model.UpdateProfile(map[string]interface{}{
+            "name":     args.Name,
+            "gender":   args.Gender,
+            "phone":    args.Phone,
+            "email":    args.Email,
+        })
+        if nil != err {
+            return err
+        }
+    } else {
+        // 参数拷贝，插入数据库
+        user_model = new(model.User)
+        utils.DumpStruct(user_model, args)
+
+        var user_id int64
+        err = user_model.Create(&user_id)
+        if nil != err {
+            return err
+        }
+    }
+
+    g_cache.Del(phoneVerifiedKey(args.Phone))
+
+    // 发放当前届次门票，失败时查看门票会补发，不影响注册
+    if ticketEditionId() != 0 {
+        if _, err = IssueTicket(user_model.UserId, ticketEditionId()); nil != err {
+            utils.Logger.Error("UserPhoneRegist issue ticket failed, user_id: %d, err: %v", user_model.UserId, err)
+        }
+    }
 
     // 复制数据，输出到api
-    model.CopyUserData(user_model, &reply.User)
+    model.CopyUserData(user_model, &reply.UserInfoJson)
 
-    return nil
+    // 注册成功即登录
+    return UserLogin(user_model, &reply.Token)
 }
 
 // 获取用户信息 by openid
@@ -90,20 +137,18 @@ func GetUserByOpenid(args *protocol.GetUserByOpenidArgs, reply *protocol.GetUser
     if nil != err {
         return err
     }
+    // 只能查看自己的信息
+    if user_model.UserId != 0 && user_model.UserId != args.UserId {
+        err = utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "无权限查看该用户")
+        utils.Logger.Error("GetUserByOpenid failed, err: %s \n", err.Error())
+        return err
+    }
     // 复制数据，输出到api
     model.CopyUserData(user_model, &reply.User)
 
     return nil
 }
 
-func GenerateVerifyCode() string {
-    var code = ""
-    for i := 0; i < 6; i++ {
-        code = code + strconv.Itoa(rand.Intn(9))
-    }
-    return code
-}
-
 // 发送验证码
 func SendVerifyCode(args *protocol.SendVerifyCodeArgs, reply *protocol.SendVerifyCodeReply) error {
     local.TempTraceInfoArgs(args)
@@ -113,65 +158,60 @@ func SendVerifyCode(args *protocol.SendVerifyCodeArgs, reply *protocol.SendVerif
 
     var err error
 
-    if args.Phone == "" || !utils.PhoneValid(args.Phone) {
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("SendVerifyCode failed, normalize phone err: %v", err)
         err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
-        utils.Logger.Error("SendVerifyCode failed, param err: %s \n", err.Error())
         return err
     }
 
-    // redis 判断请求频率（5秒）
-    res, err := g_cache.Get(args.Phone)
-    if res != nil {
-        err = utils.NewInternalErrorByStr(utils.HighFrequencyErrCode, "验证码请求频率太快")
-        utils.Logger.Error("SendVerifyCode HighFrequencyErrCode: %v", err)
+    // 兼容旧版本，默认为登录验证码
+    if args.Purpose == "" {
+        args.Purpose = VERIFY_PURPOSE_LOGIN
+    }
+    if !verifyPurposes[args.Purpose] {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "验证码用途错误")
+        utils.Logger.Error("SendVerifyCode failed, param err: %s \n", err.Error())
         return err
     }
 
-    // redis 判断每天请求次数
-    var times int
-    res, err = g_cache.Get(fmt.Sprintf("%s:%s", args.Phone, time.Now().Format("2006-01-02")))
-    if nil != err {
-        times = 0
-    } else {
-        times, _ = strconv.Atoi(string(res))
-    }
-    if times > 10 {
-        err = utils.NewInternalErrorByStr(utils.DayMaxTimeErrCode, "验证码超过每天次数")
-        utils.Logger.Error("SendVerifyCode DayMaxTimeErrCode: %v", err)
-        return err
+    // 测试账号使用固定验证码，不发送短信
+    if account, ok := getTestAccount(args.Phone); ok {
+        utils.Logger.Warning("send verify code skipped for test account, phone: %s, reviewer: %v, env: %s",
+            args.Phone, account.Reviewer, utils.Env)
+        return nil
     }
 
-    // redis 设置验证码，过期时间15分钟
-    code := GenerateVerifyCode()
-    err = g_cache.Set(code + ":" + args.Phone, 0, 20*60)    // 20分钟失效
+    // ip、设备超过阈值需要图形验证码
+    err = checkSmsCaptcha(args)
     if nil != err {
-        utils.Logger.Error("set code cache err")
-        err = utils.NewInternalError(utils.CacheErrCode, err)
         return err
     }
 
-    // redis 设置
-    err = g_cache.Set(args.Phone, code, 5)
+    // 先占用发送配额，再保存验证码和发送短信
+    err = reserveSmsQuota(args)
     if nil != err {
-        utils.Logger.Error("set phone expire key err")
-        err = utils.NewInternalError(utils.CacheErrCode, err)
         return err
     }
 
-    result_code := utils.YpSendSms(args.Phone, code)
-    if 0 != result_code {
-		err = utils.NewInternalErrorByStr(utils.VerifyCodeSendErrCode, "验证码发送失败")
-        utils.Logger.Error("sms client send failed, err: %v", err)
+    // redis 设置验证码，过期时间20分钟
+    code, err := GenerateVerifyCode()
+    if nil != err {
+        utils.Logger.Error("generate verify code err: %v", err)
+        return utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    err = saveVerifyCode(args.Purpose, args.Phone, code)
+    if nil != err {
+        err = utils.NewInternalError(utils.CacheErrCode, err)
         return err
     }
 
-    _, err = g_cache.Incr(fmt.Sprintf("%s:%s", args.Phone, time.Now().Format("2006-01-02")))
+    err = utils.SendSms(args.Phone, utils.SMS_PURPOSE_VERIFY_CODE, map[string]string{"code": code})
     if nil != err {
-        utils.Logger.Error("set code max time err")
-        err = utils.NewInternalError(utils.CacheErrCode, err)
+        utils.Logger.Error("sms client send failed, err: %v", err)
+        err = utils.NewInternalErrorByStr(utils.VerifyCodeSendErrCode, "验证码发送失败")
         return err
     }
-    g_cache.Expire(fmt.Sprintf("%s:%s", args.Phone, time.Now().Format("2006-01-02")), 24*3600)
 
     return nil
 }
@@ -188,9 +228,10 @@ func CheckVerifyCode(args *protocol.CheckVerifyCodeArgs, reply *protocol.CheckVe
     utils.Logger.Info("[cmd:check_verify_code] args: %+v", args)
 
     var err error
-    if args.Phone == "" || !utils.PhoneValid(args.Phone) {
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("CheckVerifyCode failed, normalize phone err: %v", err)
         err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
-        utils.Logger.Error("CheckVerifyCode failed, param err: %s \n", err.Error())
         return err
     }
 
@@ -200,23 +241,20 @@ func CheckVerifyCode(args *protocol.CheckVerifyCodeArgs, reply *protocol.CheckVe
         return err
     }
 
-    // TODO: 设置一个内部校验吗
-    if args.VerifyCode == "123456" {
-
-	} else {
-
-		res, err := g_cache.Get(args.VerifyCode + ":" + args.Phone)
-		if nil != err {
-			utils.Logger.Error("get verify code cache err: %v", err)
-			err = utils.NewInternalErrorByStr(utils.VerifyCodeWrong, "验证码错误")
-			return err
-		}
+    // check_verify_code 同时用于注册和登录
+    if args.Purpose == "" {
+        args.Purpose = VERIFY_PURPOSE_LOGIN
+    }
+    if args.Purpose != VERIFY_PURPOSE_LOGIN && args.Purpose != VERIFY_PURPOSE_REGISTER {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "验证码用途错误")
+        utils.Logger.Error("CheckVerifyCode failed, param err: %s \n", err.Error())
+        return err
+    }
 
-		if res == nil {
-			err = utils.NewInternalErrorByStr(utils.VerifyCodeWrong, "验证码错误")
-			return err
-		}
-	}
+    err = verifyPhoneCode(args.Phone, args.Purpose, args.VerifyCode)
+    if nil != err {
+        return err
+    }
 
     user_model := new(model.User)
     err = user_model.GetUserByPhone(args.Phone)
@@ -225,13 +263,121 @@ func CheckVerifyCode(args *protocol.CheckVerifyCodeArgs, reply *protocol.CheckVe
     }
     if user_model.UserId == 0 {
         reply.State = 0
+
+        // 标记电话号码已验证，用于后续注册
+        err = g_cache.Set(phoneVerifiedKey(args.Phone), 1, PHONE_VERIFIED_EXPIRE)
+        if nil != err {
+            utils.Logger.Error("set phone verified cache err: %v", err)
+            return err
+        }
     } else {
         if user_model.RegistType == 1 {
             reply.State = 1
-        } else if user_model.RegistType == 2 {
+        } else if user_model.RegistType == 2 || user_model.RegistType == model.REGIST_TYPE_GROUP {
             reply.State = 2
         }
         model.CopyUserData(user_model, &reply.User)
+
+        // 已注册用户直接登录
+        err = UserLogin(user_model, &reply.Token)
+        if nil != err {
+            return err
+        }
     }
     return nil
-}
\ No newline at end of file
+}
+// 合并账号，被合并账号收藏的对象收藏数会变化，合并后清除收藏数缓存
+func mergeUser(primary, secondary *model.User, reason string, operator_id int64) error {
+    favorite_list, err := model.GetUserFavoriteList(secondary.UserId)
+    if nil != err {
+        return err
+    }
+    err = model.MergeUser(primary, secondary, reason, operator_id)
+    if nil != err {
+        return err
+    }
+    for _, favorite := range favorite_list {
+        clearFavoriteCount(favorite.TargetType, favorite.TargetId)
+    }
+    return nil
+}
+
+/**
+ * 绑定电话号码
+ *
+ * 电话号码未注册时直接绑定到当前账号
+ * 电话号码已在官网注册时，当前微信账号合并到电话号码账号，返回新的登录凭证
+ */
+func BindPhone(args *protocol.BindPhoneArgs, reply *protocol.BindPhoneReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:bind_phone] args: %+v", args)
+
+    var err error
+    args.Phone, err = utils.NormalizePhone(args.Phone, args.CountryCode)
+    if nil != err {
+        utils.Logger.Error("BindPhone failed, normalize phone err: %v", err)
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
+        return err
+    }
+    if args.VerifyCode == "" {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "验证码不能为空")
+        utils.Logger.Error("BindPhone failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    err = verifyPhoneCode(args.Phone, VERIFY_PURPOSE_BIND_PHONE, args.VerifyCode)
+    if nil != err {
+        return err
+    }
+
+    user_model := new(model.User)
+    err = user_model.GetUserById(args.UserId)
+    if nil != err {
+        return err
+    }
+    if user_model.UserId == 0 {
+        return utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录已失效，请重新登录")
+    }
+
+    if user_model.Phone == args.Phone {
+        model.CopyUserData(user_model, &reply.User)
+        return nil
+    }
+    if user_model.Phone != "" {
+        err = utils.NewInternalErrorByStr(utils.AccountBindErrCode, "当前账号已绑定其他电话号码")
+        utils.Logger.Error("BindPhone failed, user_id: %d, err: %s", user_model.UserId, err.Error())
+        return err
+    }
+
+    err, exist, phone_user := model.CheckPhoneExist(args.Phone)
+    if nil != err {
+        return err
+    }
+    if !exist {
+        err = user_model.UpdatePhone(args.Phone)
+        if nil != err {
+            return err
+        }
+        model.CopyUserData(user_model, &reply.User)
+        return nil
+    }
+
+    // 电话号码账号已绑定其他微信，不能合并
+    if phone_user.Openid != "" && user_model.Openid != "" && phone_user.Openid != user_model.Openid {
+        err = utils.NewInternalErrorByStr(utils.AccountBindErrCode, "该电话号码已绑定其他微信")
+        utils.Logger.Error("BindPhone failed, user_id: %d, phone_user_id: %d, err: %s",
+            user_model.UserId, phone_user.UserId, err.Error())
+        return err
+    }
+
+    err = mergeUser(phone_user, user_model, "bind_phone", user_model.UserId)
+    if nil != err {
+        return err
+    }
+
+    reply.Merged = true
+    model.CopyUserData(phone_user, &reply.User)
+    return UserLogin(phone_user, &reply.Token)
+}
@@ -0,0 +1,124 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/23 15:20
+ */
+package controller
+
+import (
+    "crypto/rand"
+    "math/big"
+    "strconv"
+    "pet/utils"
+)
+
+// 验证码用途，不同用途的验证码互不通用
+const (
+    VERIFY_PURPOSE_REGISTER         = "register"
+    VERIFY_PURPOSE_LOGIN            = "login"
+    VERIFY_PURPOSE_RESET_PASSWORD   = "reset_password"
+    VERIFY_PURPOSE_BIND_PHONE       = "bind_phone"
+)
+
+const (
+    VERIFY_CODE_EXPIRE      = 20 * 60   // 验证码20分钟失效
+    VERIFY_CODE_FAIL_MAX    = 5         // 验证码最多错误次数，超过后验证码失效
+)
+
+var verifyPurposes = map[string]bool{
+    VERIFY_PURPOSE_REGISTER:        true,
+    VERIFY_PURPOSE_LOGIN:           true,
+    VERIFY_PURPOSE_RESET_PASSWORD:  true,
+    VERIFY_PURPOSE_BIND_PHONE:      true,
+}
+
+func verifyCodeKey(purpose, phone string) string {
+    return "verify_code:" + purpose + ":" + phone
+}
+
+func verifyCodeFailKey(purpose, phone string) string {
+    return "verify_code_fail:" + purpose + ":" + phone
+}
+
+// 验证码只保存hash
+func hashVerifyCode(purpose, phone, code string) string {
+    return utils.HmacSha256(utils.TokenSecret(), purpose+":"+phone+":"+code)
+}
+
+// 生成6位数字验证码
+func GenerateVerifyCode() (string, error) {
+    var code = ""
+    for i := 0; i < 6; i++ {
+        n, err := rand.Int(rand.Reader, big.NewInt(10))
+        if nil != err {
+            return "", err
+        }
+        code = code + strconv.FormatInt(n.Int64(), 10)
+    }
+    return code, nil
+}
+
+// 保存验证码，同时清空错误次数
+func saveVerifyCode(purpose, phone, code string) error {
+    err := g_cache.Set(verifyCodeKey(purpose, phone), hashVerifyCode(purpose, phone, code), VERIFY_CODE_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("set verify code cache err: %v", err)
+        return err
+    }
+    return g_cache.Del(verifyCodeFailKey(purpose, phone))
+}
+
+// 获取生效的测试账号，非线上环境所有测试账号生效，线上环境只有审核账号生效
+func getTestAccount(phone string) (*utils.TestAccountConfig, bool) {
+    for i := range utils.Config.TestAccounts {
+        account := &utils.Config.TestAccounts[i]
+        // 配置中的电话号码可以不带国际区号，默认中国大陆
+        account_phone, err := utils.NormalizePhone(account.Phone, "")
+        if nil != err || account_phone != phone || account.Code == "" {
+            continue
+        }
+        if !utils.IsOnline() || account.Reviewer {
+            return account, true
+        }
+    }
+    return nil, false
+}
+
+// 校验电话号码的验证码，校验成功后验证码失效，错误次数过多验证码也会失效
+func verifyPhoneCode(phone, purpose, verify_code string) error {
+    // 测试账号使用固定验证码
+    if account, ok := getTestAccount(phone); ok && account.Code == verify_code {
+        utils.Logger.Warning("verify code bypass by test account, phone: %s, purpose: %s, reviewer: %v, env: %s",
+            phone, purpose, account.Reviewer, utils.Env)
+        return nil
+    }
+
+    if !verifyPurposes[purpose] {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "验证码用途错误")
+    }
+
+    // 比较和删除是原子操作，保证验证码只能使用一次
+    ok, err := g_cache.DelIfEqual(verifyCodeKey(purpose, phone), hashVerifyCode(purpose, phone, verify_code))
+    if nil != err {
+        utils.Logger.Error("check verify code cache err: %v", err)
+        return err
+    }
+    if ok {
+        g_cache.Del(verifyCodeFailKey(purpose, phone))
+        return nil
+    }
+
+    fail_times, err := g_cache.Incr(verifyCodeFailKey(purpose, phone))
+    if nil != err {
+        utils.Logger.Error("incr verify code fail times err: %v", err)
+        return err
+    }
+    g_cache.Expire(verifyCodeFailKey(purpose, phone), VERIFY_CODE_EXPIRE)
+
+    if fail_times >= VERIFY_CODE_FAIL_MAX {
+        g_cache.Del(verifyCodeKey(purpose, phone))
+        utils.Logger.Error("verify code locked, phone: %s, purpose: %s, fail times: %d", phone, purpose, fail_times)
+        return utils.NewInternalErrorByStr(utils.VerifyCodeLockedErrCode, "验证码错误次数过多，请重新获取")
+    }
+    return utils.NewInternalErrorByStr(utils.VerifyCodeWrong, "验证码错误")
+}
@@ -0,0 +1,407 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/15 21:30
+ */
+package controller
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    PET_VOTE_RANK_KEY       = "pet_vote_rank"   // 人气排行，zset，member为pet_id，score为票数
+    PET_VOTE_RANK_LOCK_KEY  = "pet_vote_rank:lock"      // 重建排行榜时加锁
+    PET_VOTE_RANK_PENDING   = "pet_vote_rank:pending"   // 重建期间的投票，list，元素为vote_id:pet_id
+    VOTE_RANK_LOCK_TIMEOUT  = 300
+    VOTE_RANK_PAGE_MAX      = 50
+    VOID_VOTE_BATCH_MAX     = 500
+)
+
+// 投票配置，未配置的项使用默认值
+func voteSetting() utils.VoteConfig {
+    return utils.Config.VoteSetting.WithDefaults()
+}
+
+func voteUserKey(user_id int64, day string) string {
+    return fmt.Sprintf("pet_vote_user:%d:%s", user_id, day)
+}
+
+// 突发投票按统计时长分段计数
+func voteBurstKey(kind, id string, setting utils.VoteConfig) string {
+    return fmt.Sprintf("pet_vote_%s:%s:%d", kind, id, time.Now().Unix()/int64(setting.BurstSeconds))
+}
+
+// 计数加一并设置过期时间
+func incrVoteCount(key string, expire int) (int, error) {
+    times, err := g_cache.Incr(key)
+    if nil != err {
+        utils.Logger.Error("incr vote count err, key: %s, err: %v", key, err)
+        return 0, err
+    }
+    g_cache.Expire(key, expire)
+    return times, nil
+}
+
+// 当前计数，不存在时为0
+func getVoteCount(key string) int {
+    res, err := g_cache.Get(key)
+    if nil != err || res == nil {
+        return 0
+    }
+    times, _ := strconv.Atoi(string(res))
+    return times
+}
+
+/**
+ * 可疑投票检查，返回可疑标记
+ *
+ * 同一ip、设备短时间内大量投票，或者新注册账号投票
+ */
+func voteFraudFlags(args *protocol.VotePetArgs, user_info *model.User, setting utils.VoteConfig) []string {
+    var ip_times, device_times int
+    if args.ClientIp != "" {
+        ip_times, _ = incrVoteCount(voteBurstKey("ip", args.ClientIp, setting), setting.BurstSeconds)
+    }
+    if args.DeviceId != "" {
+        device_times, _ = incrVoteCount(voteBurstKey("device", args.DeviceId, setting), setting.BurstSeconds)
+    }
+    return model.VoteFraudFlags(ip_times, device_times, user_info.CreateTime, time.Now(), setting)
+}
+
+// 人气排行榜
+func GetPetVoteRankList(args *protocol.PetVoteRankListArgs, reply *protocol.PetVoteRankListReply) error {
+    utils.Logger.Info("[cmd:pet_vote_rank_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 || args.PageSize > VOTE_RANK_PAGE_MAX {
+        args.PageSize = 10
+    }
+
+    total_num, err := g_cache.Zcard(PET_VOTE_RANK_KEY)
+    if nil != err {
+        return err
+    }
+    start := (args.PageNum - 1) * args.PageSize
+    res, err := g_cache.ZrevrangeStrings(PET_VOTE_RANK_KEY, start, start+args.PageSize-1, true)
+    if nil != err {
+        return err
+    }
+
+    reply.RankList = make([]protocol.PetVoteRankJson, 0, len(res)/2)
+    pet_ids := make([]int64, 0, len(res)/2)
+    for i := 0; i+1 < len(res); i += 2 {
+        var rank protocol.PetVoteRankJson
+        rank.PetId, _ = strconv.ParseInt(res[i], 10, 64)
+        rank.Votes, _ = strconv.Atoi(res[i+1])
+        rank.Ranking = start + i/2 + 1
+        reply.RankList = append(reply.RankList, rank)
+        pet_ids = append(pet_ids, rank.PetId)
+    }
+
+    photo_list, err := model.GetPetPhotoList(pet_ids)
+    if nil != err {
+        return err
+    }
+    covers := make(map[int64]string)
+    for _, photo := range photo_list {
+        if _, ok := covers[photo.PetId]; !ok {
+            covers[photo.PetId] = photo.Url
+        }
+    }
+    for i := range reply.RankList {
+        pet := new(model.Pet)
+        if _, err = pet.GetPetById(reply.RankList[i].PetId); nil != err {
+            return err
+        }
+        reply.RankList[i].PetName = pet.Name
+        reply.RankList[i].Cover = covers[pet.Id]
+    }
+    reply.TotalNum = total_num
+    return nil
+}
+
+// 投票，每天票数有限，可疑投票会被标记
+func VotePet(args *protocol.VotePetArgs, reply *protocol.VotePetReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:vote_pet] args: %+v", args)
+
+    var err error
+    setting := voteSetting()
+    if len(args.DeviceId) > SMS_DEVICE_ID_MAX_LEN {
+        args.DeviceId = args.DeviceId[:SMS_DEVICE_ID_MAX_LEN]
+    }
+    if args.PetId <= 0 {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物id错误")
+        utils.Logger.Error("VotePet failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    pet := new(model.Pet)
+    found, err := pet.GetPetById(args.PetId)
+    if nil != err {
+        return err
+    }
+    entered, err := model.PetHasCompetitionEntry(args.PetId)
+    if nil != err {
+        return err
+    }
+    if !found || !entered {
+        err = utils.NewInternalErrorByStr(utils.NotFoundErrCode, "宠物不存在或没有参赛")
+        utils.Logger.Error("VotePet failed, pet_id: %d, err: %v", args.PetId, err)
+        return err
+    }
+
+    user_info := new(model.User)
+    if err = user_info.GetUserById(args.UserId); nil != err {
+        return err
+    }
+
+    // 先占用票数，保证并发投票不会超过每天票数
+    used, err := incrVoteCount(voteUserKey(args.UserId, time.Now().Format(utils.DATE_FORMAT)), 2*24*3600)
+    if nil != err {
+        return utils.NewInternalError(utils.CacheErrCode, err)
+    }
+    if used > setting.DailyMax {
+        err = utils.NewInternalErrorByStr(utils.VoteLimitErrCode, "今日投票次数已用完")
+        utils.Logger.Error("VotePet failed, user_id: %d, used: %d, err: %v", args.UserId, used, err)
+        return err
+    }
+
+    vote := new(model.PetVote)
+    vote.UserId = args.UserId
+    vote.PetId = args.PetId
+    vote.Ip = args.ClientIp
+    vote.DeviceId = args.DeviceId
+    vote.FraudFlags = strings.Join(voteFraudFlags(args, user_info, setting), ",")
+    err = vote.Create()
+    if nil != err {
+        releaseVoteQuota(voteUserKey(args.UserId, time.Now().Format(utils.DATE_FORMAT)))
+        return err
+    }
+    if vote.FraudFlags != "" {
+        utils.Logger.Warning("suspicious vote, vote_id: %d, user_id: %d, pet_id: %d, ip: %s, device_id: %s, flags: %s",
+            vote.Id, args.UserId, args.PetId, args.ClientIp, args.DeviceId, vote.FraudFlags)
+    }
+
+    // 投票已经记录，排行榜更新失败不影响投票结果，重建排行榜时会修正
+    votes, err := incrVoteRank(vote)
+    if nil != err {
+        utils.Logger.Error("VotePet incr rank err, vote_id: %d, pet_id: %d, err: %v", vote.Id, args.PetId, err)
+        go rebuildVoteRank()
+    }
+
+    reply.RemainVotes = setting.DailyMax - used
+    reply.Rank.PetId = pet.Id
+    reply.Rank.PetName = pet.Name
+    reply.Rank.Votes = votes
+    if rank, err := g_cache.Zrevrank(PET_VOTE_RANK_KEY, args.PetId); nil == err {
+        reply.Rank.Ranking = rank + 1
+    } else {
+        utils.Logger.Error("VotePet get rank err, pet_id: %d, err: %v", args.PetId, err)
+    }
+
+    photo_list, err := model.GetPetPhotoList([]int64{pet.Id})
+    if nil == err && len(photo_list) > 0 {
+        reply.Rank.Cover = photo_list[0].Url
+    }
+    return nil
+}
+
+// 投票写库失败时归还占用的票数
+func releaseVoteQuota(key string) {
+    if _, err := g_cache.Decr(key); nil != err {
+        utils.Logger.Error("release vote quota err, key: %s, err: %v", key, err)
+    }
+}
+
+/**
+ * 投票计入排行榜，返回宠物当前票数
+ *
+ * 正在重建排行榜时不直接加分，放入待处理列表由重建方计入，避免被替换掉
+ */
+func incrVoteRank(vote *model.PetVote) (int, error) {
+    votes, done, err := g_cache.ZincrbyUnlessLocked(PET_VOTE_RANK_LOCK_KEY, PET_VOTE_RANK_PENDING, PET_VOTE_RANK_KEY,
+        1, vote.PetId, fmt.Sprintf("%d:%d", vote.Id, vote.PetId))
+    if nil != err {
+        return 0, err
+    }
+    if done {
+        return int(votes), nil
+    }
+    score, err := g_cache.Zscore(PET_VOTE_RANK_KEY, vote.PetId)
+    if nil != err || score == "" {
+        return 1, nil
+    }
+    current, _ := strconv.ParseFloat(score, 64)
+    return int(current) + 1, nil
+}
+
+// 我今天的剩余票数
+func GetMyVoteInfo(args *protocol.MyVoteInfoArgs, reply *protocol.MyVoteInfoReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:my_vote_info] args: %+v", args)
+
+    setting := voteSetting()
+    used := getVoteCount(voteUserKey(args.UserId, time.Now().Format(utils.DATE_FORMAT)))
+    reply.DailyVotes = setting.DailyMax
+    reply.RemainVotes = setting.DailyMax - used
+    if reply.RemainVotes < 0 {
+        reply.RemainVotes = 0
+    }
+    return nil
+}
+
+// 可疑投票列表
+func GetSuspiciousVoteList(args *protocol.SuspiciousVoteListArgs, reply *protocol.SuspiciousVoteListReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:suspicious_vote_list] args: %+v", args)
+
+    if args.PageNum <= 0 {
+        args.PageNum = 1
+    }
+    if args.PageSize <= 0 {
+        args.PageSize = 10
+    }
+
+    vote_list, total_num, err := model.GetSuspiciousVoteListByPage(args.PageNum, args.PageSize)
+    if nil != err {
+        return err
+    }
+    reply.VoteList = make([]protocol.PetVoteJson, len(vote_list))
+    for i := range vote_list {
+        utils.DumpStruct(&reply.VoteList[i], &vote_list[i])
+        reply.VoteList[i].CreateTime = vote_list[i].CreateTime.Format(utils.TIME_FORMAT)
+    }
+    reply.TotalNum = total_num
+    return nil
+}
+
+/**
+ * 按数据库中的有效投票重新计算排行榜
+ *
+ * 先写入临时key再替换，计算期间排行榜可以正常读取
+ * 重建期间加锁，新投票放入待处理列表，替换后只补上快照之后的投票
+ */
+func rebuildVoteRank() (int, error) {
+    token := strconv.FormatInt(time.Now().UnixNano(), 10)
+    locked, err := g_cache.SetNx(PET_VOTE_RANK_LOCK_KEY, token, VOTE_RANK_LOCK_TIMEOUT)
+    if nil != err {
+        return 0, err
+    }
+    if !locked {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "排行榜正在重建，请稍后再试")
+        utils.Logger.Error("rebuild vote rank failed, err: %v", err)
+        return 0, err
+    }
+
+    pet_num, max_id, err := replaceVoteRank(token)
+    pending, drain_err := g_cache.UnlockAndDrain(PET_VOTE_RANK_LOCK_KEY, PET_VOTE_RANK_PENDING, token)
+    if nil != drain_err {
+        utils.Logger.Error("rebuild vote rank, drain pending err: %v", drain_err)
+        return 0, drain_err
+    }
+
+    // 快照之前的投票已经在数据库统计中，只补上之后的；替换失败时max_id为0，全部补上
+    for _, item := range pending {
+        var vote_id, pet_id int64
+        if _, err := fmt.Sscanf(item, "%d:%d", &vote_id, &pet_id); nil != err || vote_id <= max_id {
+            continue
+        }
+        if _, err := g_cache.ZincrbyFloat(PET_VOTE_RANK_KEY, 1, pet_id); nil != err {
+            utils.Logger.Error("rebuild vote rank, apply pending vote err, vote_id: %d, pet_id: %d, err: %v", vote_id, pet_id, err)
+        }
+    }
+    if nil != err {
+        return 0, err
+    }
+    return pet_num, nil
+}
+
+// 按快照统计票数并替换排行榜，返回宠物数和快照的最大投票id
+func replaceVoteRank(token string) (int, int64, error) {
+    max_id, err := model.GetMaxPetVoteId()
+    if nil != err {
+        return 0, 0, err
+    }
+    count_list, err := model.GetPetVoteCounts(max_id)
+    if nil != err {
+        return 0, 0, err
+    }
+    if len(count_list) == 0 {
+        return 0, max_id, g_cache.Del(PET_VOTE_RANK_KEY)
+    }
+
+    tmp_key := fmt.Sprintf("%s:rebuild:%s", PET_VOTE_RANK_KEY, token)
+    for _, count := range count_list {
+        if _, err = g_cache.Zadd(tmp_key, count.Votes, count.PetId); nil != err {
+            g_cache.Del(tmp_key)
+            utils.Logger.Error("rebuild vote rank err: %v", err)
+            return 0, 0, err
+        }
+    }
+    err = g_cache.Rename(tmp_key, PET_VOTE_RANK_KEY)
+    if nil != err {
+        g_cache.Del(tmp_key)
+        utils.Logger.Error("rebuild vote rank, rename err: %v", err)
+        return 0, 0, err
+    }
+    return len(count_list), max_id, nil
+}
+
+// 作废投票，并重新计算排行榜
+func VoidPetVote(args *protocol.VoidPetVoteArgs, reply *protocol.VoidPetVoteReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:void_pet_vote] operator_id: %d, vote_ids: %v", args.OperatorId, args.VoteIds)
+
+    var err error
+    if len(args.VoteIds) == 0 || len(args.VoteIds) > VOID_VOTE_BATCH_MAX {
+        err = utils.NewInternalErrorByStr(utils.ParameterErrCode, fmt.Sprintf("每次作废1到%d票", VOID_VOTE_BATCH_MAX))
+        utils.Logger.Error("VoidPetVote failed, param err: %s \n", err.Error())
+        return err
+    }
+
+    reply.VoidNum, err = model.VoidPetVotes(args.VoteIds, args.OperatorId)
+    if nil != err {
+        return err
+    }
+    utils.Logger.Info("pet votes voided, operator_id: %d, void_num: %d", args.OperatorId, reply.VoidNum)
+    if reply.VoidNum == 0 {
+        return nil
+    }
+    _, err = rebuildVoteRank()
+    return err
+}
+
+// 重新计算排行榜
+func RebuildVoteRank(args *protocol.RebuildVoteRankArgs, reply *protocol.RebuildVoteRankReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:rebuild_vote_rank] args: %+v", args)
+
+    var err error
+    reply.PetNum, err = rebuildVoteRank()
+    if nil != err {
+        return err
+    }
+    utils.Logger.Info("vote rank rebuilt, operator_id: %d, pet_num: %d", args.OperatorId, reply.PetNum)
+    return nil
+}
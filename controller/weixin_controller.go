@@ -0,0 +1,138 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/11/26 15:12
+ */
+package controller
+
+import (
+    "strconv"
+    "pet/model"
+    "pet/protocol"
+    "pet/utils"
+    "third/go-local"
+)
+
+const (
+    LOGIN_TICKET_EXPIRE = 5 * 60   // 一次性登录票据5分钟失效
+)
+
+func loginTicketKey(ticket string) string {
+    return "login_ticket:" + ticket
+}
+
+// 通过openid或unionid获取微信用户，不存在时 UserId 为0
+func getWeixinUser(openid, unionid string) (*model.User, error) {
+    user_model := new(model.User)
+    err := user_model.GetUserByOpenid(openid)
+    if nil != err {
+        return nil, err
+    }
+    if user_model.UserId == 0 && unionid != "" {
+        err = user_model.GetUserByUnionid(unionid)
+        if nil != err {
+            return nil, err
+        }
+    }
+    return user_model, nil
+}
+
+/**
+ * 微信授权登录，保存微信用户信息，返回一次性登录票据和是否需要注册电话号码
+ *
+ * 未绑定电话号码的微信用户可以直接用票据调用 phone_regist 注册
+ */
+func WeixinOAuthLogin(openid, unionid, nickname, avatar string) (string, bool, error) {
+    utils.Logger.Info("[cmd:weixin_oauth_login] openid: %s, unionid: %s, nickname: %s", openid, unionid, nickname)
+
+    if openid == "" {
+        return "", false, utils.NewInternalErrorByStr(utils.ParameterErrCode, "openid不能为空")
+    }
+
+    user_model, err := getWeixinUser(openid, unionid)
+    if nil != err {
+        return "", false, err
+    }
+
+    if user_model.UserId == 0 {
+        user_model.RegistType = 1
+        user_model.Openid = openid
+        user_model.Unionid = unionid
+        user_model.Nickname = nickname
+        user_model.Avatar = avatar
+
+        var user_id int64
+        err = user_model.Create(&user_id)
+        // 同一微信用户并发的授权回调已创建了用户，改为更新
+        if utils.IsDuplicateKeyError(err) {
+            utils.Logger.Warning("weixin user already created, openid: %s, unionid: %s", openid, unionid)
+            user_model, err = getWeixinUser(openid, unionid)
+            if nil == err && user_model.UserId == 0 {
+                err = utils.NewInternalErrorByStr(utils.InternalErrorCode, "微信用户保存失败")
+            }
+            if nil == err {
+                err = user_model.UpdateWeixinInfo(openid, unionid, nickname, avatar)
+            }
+        }
+    } else {
+        err = user_model.UpdateWeixinInfo(openid, unionid, nickname, avatar)
+    }
+    if nil != err {
+        return "", false, err
+    }
+
+    ticket, err := utils.RandomHex(16)
+    if nil != err {
+        utils.Logger.Error("generate login ticket err: %v", err)
+        return "", false, utils.NewInternalError(utils.InternalErrorCode, err)
+    }
+    err = g_cache.Set(loginTicketKey(ticket), user_model.UserId, LOGIN_TICKET_EXPIRE)
+    if nil != err {
+        utils.Logger.Error("set login ticket cache err: %v", err)
+        return "", false, err
+    }
+    return ticket, user_model.Phone == "", nil
+}
+
+// 使用一次性登录票据，返回票据对应的用户，票据无效或已使用时返回 found=false
+func useLoginTicket(ticket string) (user_model *model.User, found bool, err error) {
+    if ticket == "" {
+        return nil, false, nil
+    }
+    // 票据只能使用一次
+    res, err := g_cache.GetDel(loginTicketKey(ticket))
+    if nil != err && utils.CheckRedisReturnValue(err) != utils.KeyNotFound {
+        utils.Logger.Error("get login ticket cache err: %v", err)
+        return nil, false, err
+    }
+    if res == nil {
+        return nil, false, nil
+    }
+    user_id, _ := strconv.ParseInt(string(res), 10, 64)
+
+    user_model = new(model.User)
+    err = user_model.GetUserById(user_id)
+    if nil != err {
+        return nil, false, err
+    }
+    return user_model, user_model.UserId != 0, nil
+}
+
+// 一次性登录票据换取用户信息和登录凭证
+func ExchangeLoginTicket(args *protocol.ExchangeLoginTicketArgs, reply *protocol.ExchangeLoginTicketReply) error {
+    local.TempTraceInfoArgs(args)
+    defer local.Clear()
+
+    utils.Logger.Info("[cmd:exchange_login_ticket] args: %+v", args)
+
+    user_model, found, err := useLoginTicket(args.Ticket)
+    if nil != err {
+        return err
+    }
+    if !found {
+        return utils.NewInternalErrorByStr(utils.TokenInvalidErrCode, "登录票据已失效，请重新授权")
+    }
+
+    model.CopyUserData(user_model, &reply.User)
+    return UserLogin(user_model, &reply.Token)
+}
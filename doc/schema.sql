@@ -0,0 +1,488 @@
+-- pet 数据库变更记录，按时间顺序执行
+
+-- 2017-11-26 微信授权登录保存用户
+ALTER TABLE pet.user
+    ADD COLUMN unionid varchar(255) NOT NULL DEFAULT '' COMMENT '微信开放平台的用户标志' AFTER openid,
+    MODIFY COLUMN avatar varchar(255) NOT NULL DEFAULT '' COMMENT '微信头像',
+    ADD INDEX idx_openid (openid),
+    ADD INDEX idx_unionid (unionid);
+-- openid、unionid非空时唯一，避免并发的授权回调重复创建用户，执行前先合并重复的微信账号
+ALTER TABLE pet.user
+    ADD COLUMN openid_key varchar(255) AS (NULLIF(openid, '')) STORED COMMENT '非空的openid，用于唯一索引',
+    ADD COLUMN unionid_key varchar(255) AS (NULLIF(unionid, '')) STORED COMMENT '非空的unionid，用于唯一索引',
+    ADD UNIQUE KEY uk_openid (openid_key),
+    ADD UNIQUE KEY uk_unionid (unionid_key);
+
+-- 2017-12-03 微信账号绑定电话号码，账号合并记录
+CREATE TABLE pet.user_merge_log (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    primary_user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '保留的账号',
+    secondary_user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '被合并删除的账号',
+    primary_snapshot text COMMENT '合并前主账号数据',
+    secondary_snapshot text COMMENT '合并前被合并账号数据',
+    merged_snapshot text COMMENT '合并后主账号数据',
+    reason varchar(64) NOT NULL DEFAULT '' COMMENT '合并原因',
+    operator_id bigint(20) NOT NULL DEFAULT 0 COMMENT '操作人',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_primary_user_id (primary_user_id),
+    KEY idx_secondary_user_id (secondary_user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账号合并记录';
+
+-- 2017-12-28 展会活动
+CREATE TABLE pet.activity (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    title varchar(128) NOT NULL DEFAULT '' COMMENT '活动名称',
+    type smallint(6) NOT NULL DEFAULT 0 COMMENT '类型，1: 讲座 2: 宠物表演 3: 工作坊',
+    description text COMMENT '活动介绍',
+    hall varchar(32) NOT NULL DEFAULT '' COMMENT '展馆',
+    venue varchar(128) NOT NULL DEFAULT '' COMMENT '具体场地',
+    start_time datetime NOT NULL,
+    end_time datetime NOT NULL,
+    capacity int(11) NOT NULL DEFAULT 0 COMMENT '人数上限，0: 不限',
+    cover varchar(255) NOT NULL DEFAULT '' COMMENT '封面图',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，0: 草稿 1: 已发布 2: 已取消',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_start_time (start_time),
+    KEY idx_hall_start_time (hall, start_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展会活动';
+
+-- 2017-12-30 活动报名和候补
+ALTER TABLE pet.activity
+    ADD COLUMN signup_num int(11) NOT NULL DEFAULT 0 COMMENT '已报名人数' AFTER capacity;
+
+CREATE TABLE pet.activity_signup (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    activity_id bigint(20) NOT NULL DEFAULT 0,
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 已报名 2: 候补 3: 已取消',
+    queue_time datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '进入候补的时间',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_activity_user (activity_id, user_id),
+    KEY idx_activity_status_queue (activity_id, status, queue_time),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='活动报名';
+
+-- 2018-01-03 电子门票
+CREATE TABLE pet.ticket (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    ticket_no varchar(32) NOT NULL DEFAULT '' COMMENT '门票编号',
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 有效 2: 作废',
+    valid_from datetime NOT NULL COMMENT '生效时间',
+    valid_to datetime NOT NULL COMMENT '失效时间',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_ticket_no (ticket_no),
+    UNIQUE KEY uk_user_edition (user_id, edition_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='电子门票';
+
+-- 2018-01-06 用户角色、门票入场
+ALTER TABLE pet.user ADD COLUMN role smallint(6) NOT NULL DEFAULT 0 COMMENT '角色，0: 观众 1: 检票员 2: 管理员';
+ALTER TABLE pet.ticket
+    ADD COLUMN checkin_gate varchar(32) NOT NULL DEFAULT '' COMMENT '入场闸口，空表示未入场',
+    ADD COLUMN checkin_time datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '入场时间',
+    ADD COLUMN checkin_by bigint(20) NOT NULL DEFAULT 0 COMMENT '检票员user_id';
+
+-- 2018-01-08 展商
+CREATE TABLE pet.exhibitor (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    name varchar(128) NOT NULL DEFAULT '' COMMENT '公司名称',
+    logo varchar(255) NOT NULL DEFAULT '',
+    description text COMMENT '公司介绍',
+    hall varchar(32) NOT NULL DEFAULT '' COMMENT '展馆',
+    booth varchar(32) NOT NULL DEFAULT '' COMMENT '展位号',
+    category_ids varchar(255) NOT NULL DEFAULT '' COMMENT '产品类别id，逗号分隔',
+    contact_name varchar(64) NOT NULL DEFAULT '' COMMENT '联系人',
+    contact_phone varchar(32) NOT NULL DEFAULT '' COMMENT '联系电话',
+    contact_email varchar(128) NOT NULL DEFAULT '' COMMENT '邮箱',
+    website varchar(255) NOT NULL DEFAULT '' COMMENT '网站',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_name (name),
+    KEY idx_hall_booth (hall, booth)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展商';
+
+CREATE TABLE pet.exhibitor_category (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    name varchar(32) NOT NULL DEFAULT '' COMMENT '类别名称',
+    sort int(11) NOT NULL DEFAULT 0 COMMENT '排序，从小到大',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展商产品类别';
+
+-- 2018-01-10 宠物档案
+CREATE TABLE pet.pet (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '主人',
+    name varchar(128) NOT NULL DEFAULT '' COMMENT '宠物名称',
+    species smallint(6) NOT NULL DEFAULT 0 COMMENT '种类，1: 狗 2: 猫 3: 小宠 4: 鸟 5: 爬宠 6: 水族',
+    breed_id bigint(20) NOT NULL DEFAULT 0 COMMENT '品种，0: 未知',
+    birthday date NOT NULL DEFAULT '1970-01-01' COMMENT '出生日期',
+    gender smallint(6) NOT NULL DEFAULT 0 COMMENT '性别，0: 未知 1: 公 2: 母',
+    weight int(11) NOT NULL DEFAULT 0 COMMENT '体重，单位克',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='宠物';
+
+CREATE TABLE pet.pet_breed (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    species smallint(6) NOT NULL DEFAULT 0 COMMENT '种类',
+    name varchar(64) NOT NULL DEFAULT '' COMMENT '品种名称',
+    sort int(11) NOT NULL DEFAULT 0 COMMENT '排序，从小到大',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_species_name (species, name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='宠物品种字典';
+
+CREATE TABLE pet.pet_vaccination (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    pet_id bigint(20) NOT NULL DEFAULT 0,
+    vaccine varchar(64) NOT NULL DEFAULT '' COMMENT '疫苗名称',
+    vaccinate_date date NOT NULL COMMENT '接种日期',
+    expire_date date NOT NULL DEFAULT '1970-01-01' COMMENT '有效期至',
+    hospital varchar(128) NOT NULL DEFAULT '' COMMENT '接种机构',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_pet_id (pet_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='宠物疫苗接种记录';
+
+CREATE TABLE pet.pet_photo (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    pet_id bigint(20) NOT NULL DEFAULT 0,
+    url varchar(255) NOT NULL DEFAULT '',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_pet_id (pet_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='宠物照片';
+
+-- 2018-01-13 比赛和评分
+ALTER TABLE pet.user MODIFY COLUMN role smallint(6) NOT NULL DEFAULT 0 COMMENT '角色，0: 观众 1: 检票员 2: 管理员 3: 裁判';
+
+CREATE TABLE pet.competition_class (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    activity_id bigint(20) NOT NULL DEFAULT 0 COMMENT '关联的活动',
+    name varchar(64) NOT NULL DEFAULT '' COMMENT '组别名称',
+    species smallint(6) NOT NULL DEFAULT 0 COMMENT '限制宠物种类，0: 不限',
+    description text COMMENT '比赛规则说明',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 报名中 2: 评分中 3: 已结束',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_activity_id (activity_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='比赛组别';
+
+CREATE TABLE pet.competition_criterion (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    class_id bigint(20) NOT NULL DEFAULT 0,
+    name varchar(32) NOT NULL DEFAULT '' COMMENT '评分项名称',
+    weight int(11) NOT NULL DEFAULT 0 COMMENT '权重',
+    max_score int(11) NOT NULL DEFAULT 0 COMMENT '满分',
+    sort int(11) NOT NULL DEFAULT 0 COMMENT '排序，同分时按排序依次比较',
+    PRIMARY KEY (id),
+    KEY idx_class_id (class_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='比赛评分项';
+
+CREATE TABLE pet.competition_judge (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    class_id bigint(20) NOT NULL DEFAULT 0,
+    judge_id bigint(20) NOT NULL DEFAULT 0 COMMENT '裁判user_id',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_class_judge (class_id, judge_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='比赛组别的裁判';
+
+CREATE TABLE pet.competition_entry (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    class_id bigint(20) NOT NULL DEFAULT 0,
+    pet_id bigint(20) NOT NULL DEFAULT 0,
+    user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '宠物主人',
+    pet_name varchar(128) NOT NULL DEFAULT '' COMMENT '报名时的宠物名称',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 已报名 2: 已退赛',
+    total_score decimal(8,2) NOT NULL DEFAULT 0 COMMENT '总分，百分制',
+    ranking int(11) NOT NULL DEFAULT 0 COMMENT '名次，同分并列',
+    criterion_scores varchar(255) NOT NULL DEFAULT '' COMMENT '各评分项平均分，json数组',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_class_pet (class_id, pet_id),
+    KEY idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='比赛参赛记录';
+
+CREATE TABLE pet.competition_score (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    class_id bigint(20) NOT NULL DEFAULT 0,
+    entry_id bigint(20) NOT NULL DEFAULT 0,
+    judge_id bigint(20) NOT NULL DEFAULT 0,
+    criterion_id bigint(20) NOT NULL DEFAULT 0,
+    score int(11) NOT NULL DEFAULT 0,
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_entry_judge_criterion (entry_id, judge_id, criterion_id),
+    KEY idx_class_judge (class_id, judge_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='裁判评分';
+
+-- 2018-01-15 人气投票
+CREATE TABLE pet.pet_vote (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    pet_id bigint(20) NOT NULL DEFAULT 0,
+    ip varchar(64) NOT NULL DEFAULT '',
+    device_id varchar(64) NOT NULL DEFAULT '',
+    fraud_flags varchar(64) NOT NULL DEFAULT '' COMMENT '可疑标记，逗号分隔',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 有效 2: 已作废',
+    void_by bigint(20) NOT NULL DEFAULT 0 COMMENT '作废的管理员',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_pet_status (pet_id, status),
+    KEY idx_user_id (user_id),
+    KEY idx_status_flags (status, fraud_flags)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='人气投票';
+
+-- 2018-01-16 收藏
+CREATE TABLE pet.favorite (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    target_type smallint(6) NOT NULL DEFAULT 0 COMMENT '类型，1: 展商 2: 活动 3: 文章',
+    target_id bigint(20) NOT NULL DEFAULT 0,
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uniq_user_target (user_id, target_type, target_id),
+    KEY idx_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='收藏';
+
+-- 2018-01-17 多届次展会，已有数据归到当前届次
+CREATE TABLE pet.edition (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    name varchar(64) NOT NULL DEFAULT '' COMMENT '名称',
+    city varchar(32) NOT NULL DEFAULT '',
+    year int(11) NOT NULL DEFAULT 0,
+    host varchar(128) NOT NULL DEFAULT '' COMMENT '专属域名，为空时不绑定',
+    start_date datetime DEFAULT NULL COMMENT '开展日期',
+    end_date datetime DEFAULT NULL COMMENT '闭展日期',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，0: 草稿 1: 已发布',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_host (host)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展会届次';
+
+ALTER TABLE pet.banner
+    ADD COLUMN edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次' AFTER id,
+    ADD INDEX idx_edition_type (edition_id, type);
+ALTER TABLE pet.article
+    ADD COLUMN edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次' AFTER id,
+    ADD INDEX idx_edition_type (edition_id, type);
+ALTER TABLE pet.activity
+    ADD COLUMN edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次' AFTER id,
+    ADD INDEX idx_edition_start (edition_id, start_time);
+ALTER TABLE pet.exhibitor
+    ADD COLUMN edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次' AFTER id,
+    DROP INDEX uk_name,
+    ADD UNIQUE KEY uk_edition_name (edition_id, name);
+
+-- 当前届次，名称、日期按实际修改，执行后把 EditionSetting.CurrentId 配置为该届次的id
+INSERT INTO pet.edition (name, city, year, status, create_time, update_time)
+    VALUES ('上海宠物展', '上海', 2018, 1, NOW(), NOW());
+SET @edition_id = LAST_INSERT_ID();
+
+UPDATE pet.banner SET edition_id = @edition_id WHERE edition_id = 0;
+UPDATE pet.article SET edition_id = @edition_id WHERE edition_id = 0;
+UPDATE pet.activity SET edition_id = @edition_id WHERE edition_id = 0;
+UPDATE pet.exhibitor SET edition_id = @edition_id WHERE edition_id = 0;
+
+-- 2018-01-18 付费门票、订单
+CREATE TABLE pet.ticket_product (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次',
+    name varchar(64) NOT NULL DEFAULT '',
+    description varchar(255) NOT NULL DEFAULT '',
+    price int(11) NOT NULL DEFAULT 0 COMMENT '价格，单位分',
+    stock int(11) NOT NULL DEFAULT 0 COMMENT '库存，0: 不限',
+    sold_num int(11) NOT NULL DEFAULT 0 COMMENT '已售数量，包含未支付订单占用的',
+    valid_from datetime DEFAULT NULL COMMENT '门票生效时间，为空时使用届次门票配置',
+    valid_to datetime DEFAULT NULL COMMENT '门票失效时间',
+    sort int(11) NOT NULL DEFAULT 0 COMMENT '排序，从小到大',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，0: 未上架 1: 在售',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_edition_status (edition_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='付费门票商品';
+
+CREATE TABLE pet.ticket_order (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    order_no varchar(32) NOT NULL DEFAULT '' COMMENT '订单号',
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次',
+    total_fee int(11) NOT NULL DEFAULT 0 COMMENT '金额，单位分',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 待支付 2: 已支付 3: 已关闭 4: 已退款',
+    pay_channel varchar(16) NOT NULL DEFAULT '' COMMENT '支付渠道，wxpay, fake',
+    prepay_id varchar(64) NOT NULL DEFAULT '' COMMENT '预支付交易会话标识',
+    transaction_id varchar(64) NOT NULL DEFAULT '' COMMENT '支付平台的交易号',
+    paid_time datetime DEFAULT NULL,
+    expire_time datetime NOT NULL COMMENT '超过此时间未支付自动关闭',
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_order_no (order_no),
+    KEY idx_user (user_id),
+    KEY idx_status_expire (status, expire_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='门票订单';
+
+CREATE TABLE pet.ticket_order_item (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    order_id bigint(20) NOT NULL DEFAULT 0,
+    product_id bigint(20) NOT NULL DEFAULT 0,
+    product_name varchar(64) NOT NULL DEFAULT '' COMMENT '下单时的商品名称',
+    price int(11) NOT NULL DEFAULT 0 COMMENT '下单时的单价，单位分',
+    quantity int(11) NOT NULL DEFAULT 0,
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_order (order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='门票订单明细';
+
+-- 购买的门票每张一条，免费门票 order_item_id、seq 为0
+ALTER TABLE pet.ticket
+    ADD COLUMN product_id bigint(20) NOT NULL DEFAULT 0 COMMENT '门票商品id，免费门票为0' AFTER edition_id,
+    ADD COLUMN order_id bigint(20) NOT NULL DEFAULT 0 COMMENT '订单id' AFTER product_id,
+    ADD COLUMN order_item_id bigint(20) NOT NULL DEFAULT 0 COMMENT '订单明细id' AFTER order_id,
+    ADD COLUMN seq int(11) NOT NULL DEFAULT 0 COMMENT '订单明细中的序号，从1开始' AFTER order_item_id,
+    DROP INDEX uk_user_edition,
+    ADD UNIQUE KEY uk_user_edition (user_id, edition_id, order_item_id, seq),
+    ADD INDEX idx_order (order_id);
+
+-- 2018-01-19 门票退款、订单流水
+CREATE TABLE pet.ticket_refund (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    refund_no varchar(32) NOT NULL DEFAULT '' COMMENT '退款单号',
+    order_id bigint(20) NOT NULL DEFAULT 0,
+    order_no varchar(32) NOT NULL DEFAULT '',
+    user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '订单的用户',
+    refund_fee int(11) NOT NULL DEFAULT 0 COMMENT '退款金额，单位分',
+    ticket_num int(11) NOT NULL DEFAULT 0 COMMENT '退款的门票数量',
+    reason varchar(255) NOT NULL DEFAULT '',
+    source smallint(6) NOT NULL DEFAULT 0 COMMENT '来源，1: 用户申请 2: 管理员操作',
+    operator_id bigint(20) NOT NULL DEFAULT 0,
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 退款中 2: 退款成功 3: 退款失败',
+    refund_id varchar(64) NOT NULL DEFAULT '' COMMENT '支付平台的退款单号',
+    success_time datetime DEFAULT NULL,
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_refund_no (refund_no),
+    KEY idx_order (order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='门票退款';
+
+CREATE TABLE pet.ticket_order_log (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    order_id bigint(20) NOT NULL DEFAULT 0,
+    order_no varchar(32) NOT NULL DEFAULT '',
+    refund_no varchar(32) NOT NULL DEFAULT '' COMMENT '退款相关操作的退款单号',
+    action varchar(16) NOT NULL DEFAULT '' COMMENT '操作，create, pay, close, refund_apply, refund_success, refund_fail',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '操作后的订单状态',
+    amount int(11) NOT NULL DEFAULT 0 COMMENT '涉及的金额，单位分',
+    transaction_id varchar(64) NOT NULL DEFAULT '' COMMENT '支付平台的交易号或退款单号',
+    operator_id bigint(20) NOT NULL DEFAULT 0 COMMENT '操作人，0: 系统',
+    remark varchar(255) NOT NULL DEFAULT '',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_order (order_id),
+    KEY idx_create_time (create_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单流水';
+
+ALTER TABLE pet.ticket_order
+    ADD COLUMN refund_fee int(11) NOT NULL DEFAULT 0 COMMENT '已申请退款的金额，不包含退款失败的' AFTER total_fee;
+ALTER TABLE pet.ticket
+    ADD COLUMN refund_id bigint(20) NOT NULL DEFAULT 0 COMMENT '退款单，退款中和已退款的门票作废' AFTER seq;
+
+-- 2018-01-20 团体登记
+ALTER TABLE pet.user MODIFY COLUMN role smallint(6) NOT NULL DEFAULT 0 COMMENT '角色，0: 观众 1: 检票员 2: 管理员 3: 裁判 4: 合作机构';
+
+CREATE TABLE pet.registration_job (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    job_no varchar(32) NOT NULL DEFAULT '' COMMENT '任务编号',
+    operator_id bigint(20) NOT NULL DEFAULT 0 COMMENT '上传的用户',
+    edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '发放门票的届次',
+    filename varchar(255) NOT NULL DEFAULT '',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '状态，1: 处理中 2: 已完成 3: 异常中断',
+    total_num int(11) NOT NULL DEFAULT 0 COMMENT '数据行数',
+    processed_num int(11) NOT NULL DEFAULT 0 COMMENT '已处理行数',
+    created_num int(11) NOT NULL DEFAULT 0,
+    duplicate_num int(11) NOT NULL DEFAULT 0,
+    fail_num int(11) NOT NULL DEFAULT 0,
+    err_msg varchar(255) NOT NULL DEFAULT '' COMMENT '异常中断的原因',
+    finish_time datetime DEFAULT NULL,
+    create_time datetime NOT NULL,
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_job_no (job_no),
+    KEY idx_operator (operator_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='团体登记任务';
+
+CREATE TABLE pet.registration_row (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    job_id bigint(20) NOT NULL DEFAULT 0,
+    row_num int(11) NOT NULL DEFAULT 0 COMMENT '表格中的行号',
+    name varchar(128) NOT NULL DEFAULT '',
+    phone varchar(64) NOT NULL DEFAULT '',
+    email varchar(128) NOT NULL DEFAULT '',
+    status smallint(6) NOT NULL DEFAULT 0 COMMENT '结果，1: 已注册 2: 重复 3: 失败',
+    user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '新注册或已存在的用户',
+    ticket_no varchar(32) NOT NULL DEFAULT '' COMMENT '发放的门票',
+    reason varchar(255) NOT NULL DEFAULT '' COMMENT '重复或失败的原因',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_job_row (job_id, row_num),
+    KEY idx_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='团体登记每行的结果';
+
+-- 2018-01-21 展商扫码记录潜在客户
+CREATE TABLE pet.exhibitor_staff (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    exhibitor_id bigint(20) NOT NULL DEFAULT 0,
+    user_id bigint(20) NOT NULL DEFAULT 0,
+    create_by bigint(20) NOT NULL DEFAULT 0 COMMENT '添加的管理员',
+    create_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_exhibitor_user (exhibitor_id, user_id),
+    KEY idx_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展商工作人员';
+
+CREATE TABLE pet.exhibitor_lead (
+    id bigint(20) NOT NULL AUTO_INCREMENT,
+    exhibitor_id bigint(20) NOT NULL DEFAULT 0,
+    edition_id bigint(20) NOT NULL DEFAULT 0 COMMENT '展会届次',
+    user_id bigint(20) NOT NULL DEFAULT 0 COMMENT '被扫码的观众',
+    ticket_no varchar(32) NOT NULL DEFAULT '' COMMENT '扫码的门票',
+    scanned_by bigint(20) NOT NULL DEFAULT 0 COMMENT '最后扫码的工作人员',
+    notes varchar(1024) NOT NULL DEFAULT '' COMMENT '备注',
+    rating smallint(6) NOT NULL DEFAULT 0 COMMENT '意向评级，1-5，0: 未评级',
+    consent_contact smallint(6) NOT NULL DEFAULT 0 COMMENT '观众同意展商查看联系方式，0: 否 1: 是',
+    consent_marketing smallint(6) NOT NULL DEFAULT 0 COMMENT '观众同意接收展商的推广信息，0: 否 1: 是',
+    consent_time datetime DEFAULT NULL COMMENT '最后一次确认授权的时间',
+    consent_revoked smallint(6) NOT NULL DEFAULT 0 COMMENT '观众撤回了授权，撤回后扫码不能重新授权，0: 否 1: 是',
+    scan_num int(11) NOT NULL DEFAULT 0 COMMENT '扫码次数',
+    last_scan_time datetime NOT NULL,
+    create_time datetime NOT NULL COMMENT '第一次扫码时间',
+    update_time datetime NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_exhibitor_user (exhibitor_id, user_id),
+    KEY idx_exhibitor_scan (exhibitor_id, last_scan_time),
+    KEY idx_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='展商潜在客户';
+
+-- 2018-01-22 用户上传的头像不被微信头像覆盖
+ALTER TABLE pet.user ADD COLUMN avatar_custom smallint(6) NOT NULL DEFAULT 0 COMMENT '头像是否用户上传，0: 微信头像 1: 用户上传' AFTER avatar;
@@ -9,6 +9,8 @@ import (
     "third/gin"
     "time"
     "net/http"
+    "io/ioutil"
+    "strconv"
     "pet/protocol"
     "pet/utils"
     "pet/controller"
@@ -32,7 +34,7 @@ NOTICE:
     g_logger.Notice("[cmd:user_phone_regist][Cost:%dus][Err:%v]",
         time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
 
-    utils.SendResponse(c, http_code, &reply.User, err)
+    utils.SendResponse(c, http_code, &reply, err)
 }
 
 // 用户会员名注册
@@ -47,6 +49,7 @@ func GetUserByOpenid(c *gin.Context) {
     if nil != err {
         goto NOTICE
     }
+    args.UserId = GetLoginUserId(c)
     err = controller.GetUserByOpenid(&args, &reply)
 
 NOTICE:
@@ -68,10 +71,12 @@ func SendVerifyCode(c *gin.Context) {
     if nil != err {
         goto NOTICE
     }
+    args.ClientIp = c.ClientIP()
+    args.DeviceId = c.Request.Header.Get(controller.SmsDeviceHeader())
     err = controller.SendVerifyCode(&args, &reply)
 
 NOTICE:
-    g_logger.Notice("[cmd:send_verify_code][Cost:%dus][Err:%v]",
+    g_logger.Notice("[cmd:send_verify_code][ip:%s][device_id:%s][Cost:%dus][Err:%v]", args.ClientIp, args.DeviceId,
         time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
 
     utils.SendResponse(c, http_code, &reply, err)
@@ -98,6 +103,235 @@ NOTICE:
     utils.SendResponse(c, http_code, &reply, err)
 }
 
+// 刷新登录凭证
+func RefreshUserToken(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RefreshTokenArgs
+    var reply protocol.RefreshTokenReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.RefreshUserToken(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:refresh_token][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 获取当前登录用户信息
+func GetUserInfo(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.GetUserInfoArgs
+    var reply protocol.GetUserInfoReply
+
+    args.UserId = GetLoginUserId(c)
+    err := controller.GetUserInfo(&args, &reply)
+
+    g_logger.Notice("[cmd:get_user_info][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply.User, err)
+}
+
+// 退出登录
+func UserLogout(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UserLogoutArgs
+    var reply protocol.UserLogoutReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.AccessToken = utils.GetTokenFromHeader(c.Request)
+    err = controller.UserLogout(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:user_logout][user_id:%d][Cost:%dus][Err:%v]",
+        GetLoginUserId(c), time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 设置密码
+func SetPassword(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.SetPasswordArgs
+    var reply protocol.SetPasswordReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.SetPassword(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:set_password][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 密码登录
+func PasswordLogin(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.PasswordLoginArgs
+    var reply protocol.PasswordLoginReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.PasswordLogin(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:password_login][phone:%s][Cost:%dus][Err:%v]",
+        args.Phone, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 重置密码
+func ResetPassword(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ResetPasswordArgs
+    var reply protocol.ResetPasswordReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.ResetPassword(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:reset_password][phone:%s][Cost:%dus][Err:%v]",
+        args.Phone, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 微信授权登录票据换取登录凭证
+func ExchangeLoginTicket(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExchangeLoginTicketArgs
+    var reply protocol.ExchangeLoginTicketReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.ExchangeLoginTicket(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:exchange_login_ticket][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 绑定电话号码
+func BindPhone(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.BindPhoneArgs
+    var reply protocol.BindPhoneReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.BindPhone(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:bind_phone][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 更新个人资料
+func UpdateProfile(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UpdateProfileArgs
+    var reply protocol.UpdateProfileReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.UpdateProfile(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:update_profile][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply.User, err)
+}
+
+// 上传头像，multipart/form-data，文件字段为avatar
+func UploadAvatar(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UploadAvatarArgs
+    var reply protocol.UploadAvatarReply
+
+    args.UserId = GetLoginUserId(c)
+    data, filename, err := utils.ReadFormFile(c.Request, "avatar", controller.AVATAR_MAX_SIZE)
+    if nil != err {
+        goto NOTICE
+    }
+    args.Data = data
+    args.Filename = filename
+    err = controller.UploadAvatar(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:upload_avatar][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 获取图形验证码
+func NewCaptcha(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.NewCaptchaArgs
+    var reply protocol.NewCaptchaReply
+
+    err := controller.NewCaptcha(&args, &reply)
+
+    g_logger.Notice("[cmd:new_captcha][ip:%s][Cost:%dus][Err:%v]",
+        c.ClientIP(), time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
 // 分页获取banner列表
 func GetBannerListByPage(c *gin.Context) {
     var http_code int = http.StatusOK
@@ -111,6 +345,7 @@ func GetBannerListByPage(c *gin.Context) {
     if nil != err {
         goto NOTICE
     }
+    args.Host = c.Request.Host
     err = controller.GetBannerListByPage(&args, &reply)
 
 NOTICE:
@@ -132,6 +367,7 @@ func GetArticleListByPage(c *gin.Context) {
     if nil != err {
         goto NOTICE
     }
+    args.Host = c.Request.Host
     err = controller.GetArticleListByPage(&args, &reply)
 
 NOTICE:
@@ -141,6 +377,1630 @@ NOTICE:
     utils.SendResponse(c, http_code, &reply, err)
 }
 
+// 分页获取活动列表
+func GetActivityListByPage(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ActivityListArgs
+    var reply protocol.ActivityListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.Host = c.Request.Host
+    err = controller.GetActivityListByPage(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:activity_list_by_page][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 活动详情
+func GetActivityDetail(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ActivityDetailArgs
+    var reply protocol.ActivityDetailReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetActivityDetail(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:activity_detail][id:%d][Cost:%dus][Err:%v]",
+        args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 报名活动
+func SignupActivity(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.SignupActivityArgs
+    var reply protocol.SignupActivityReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.SignupActivity(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:signup_activity][user_id:%d][activity_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ActivityId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 取消活动报名
+func CancelActivitySignup(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CancelActivitySignupArgs
+    var reply protocol.CancelActivitySignupReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.CancelActivitySignup(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:cancel_activity_signup][user_id:%d][activity_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ActivityId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的活动报名列表
+func GetMySignupList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MySignupListArgs
+    var reply protocol.MySignupListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMySignupList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_signup_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的门票
+func GetMyTicketList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyTicketListArgs
+    var reply protocol.MyTicketListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    args.Host = c.Request.Host
+    err = controller.GetMyTicketList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_ticket_list][user_id:%d][edition_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.EditionId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 门票二维码图片，成功时返回png
+func GetTicketQrcode(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.TicketQrcodeArgs
+    var reply protocol.TicketQrcodeReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetTicketQrcode(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:ticket_qrcode][user_id:%d][ticket_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.TicketNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    if nil != err {
+        utils.SendResponse(c, http_code, &reply, err)
+        return
+    }
+    c.Writer.Header().Set("Cache-Control", "private, no-store")
+    c.Data(http_code, "image/png", reply.Png)
+}
+
+// 门票签名公钥
+func GetTicketPublicKey(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.TicketPublicKeyArgs
+    var reply protocol.TicketPublicKeyReply
+
+    err := controller.GetTicketPublicKey(&args, &reply)
+
+    g_logger.Notice("[cmd:ticket_public_key][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 扫码入场
+func TicketCheckin(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.TicketCheckinArgs
+    var reply protocol.TicketCheckinReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.TicketCheckin(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:ticket_checkin][operator_id:%d][gate_id:%s][Cost:%dus][Err:%v]",
+        args.OperatorId, args.GateId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 离线入场记录批量同步
+func CheckinBatchSync(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CheckinBatchSyncArgs
+    var reply protocol.CheckinBatchSyncReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.CheckinBatchSync(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:checkin_batch_sync][operator_id:%d][records:%d][success:%d][fail:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, len(args.Records), reply.SuccessNum, reply.FailNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 产品类别列表
+func GetExhibitorCategoryList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExhibitorCategoryListArgs
+    var reply protocol.ExhibitorCategoryListReply
+
+    err := controller.GetExhibitorCategoryList(&args, &reply)
+
+    g_logger.Notice("[cmd:exhibitor_category_list][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 分页获取展商列表
+func GetExhibitorListByPage(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExhibitorListArgs
+    var reply protocol.ExhibitorListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.Host = c.Request.Host
+    err = controller.GetExhibitorListByPage(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:exhibitor_list_by_page][keyword:%s][Cost:%dus][Err:%v]",
+        args.Keyword, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 展商详情
+func GetExhibitorDetail(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExhibitorDetailArgs
+    var reply protocol.ExhibitorDetailReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetExhibitorDetail(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:exhibitor_detail][id:%d][Cost:%dus][Err:%v]",
+        args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 导入展商
+func ImportExhibitor(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ImportExhibitorArgs
+    var reply protocol.ImportExhibitorReply
+
+    args.OperatorId = GetLoginUserId(c)
+    data, filename, err := utils.ReadFormFile(c.Request, "file", controller.EXHIBITOR_IMPORT_MAX_SIZE)
+    if nil != err {
+        goto NOTICE
+    }
+    if value := c.Request.FormValue("edition_id"); value != "" {
+        args.EditionId, err = strconv.ParseInt(value, 10, 64)
+        if nil != err {
+            err = utils.NewInternalErrorByStr(utils.ParameterErrCode, "届次id错误")
+            goto NOTICE
+        }
+    }
+    args.Data = data
+    args.Filename = filename
+    err = controller.ImportExhibitor(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:import_exhibitor][operator_id:%d][edition_id:%d][filename:%s][created:%d][updated:%d][fail:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.EditionId, args.Filename, reply.CreatedNum, reply.UpdatedNum, reply.FailNum,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 宠物品种列表
+func GetPetBreedList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.PetBreedListArgs
+    var reply protocol.PetBreedListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetPetBreedList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:pet_breed_list][species:%d][Cost:%dus][Err:%v]",
+        args.Species, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 添加宠物
+func CreatePet(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CreatePetArgs
+    var reply protocol.CreatePetReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.CreatePet(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:create_pet][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 修改宠物资料
+func UpdatePet(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UpdatePetArgs
+    var reply protocol.UpdatePetReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.UpdatePet(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:update_pet][user_id:%d][id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 删除宠物
+func DeletePet(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.DeletePetArgs
+    var reply protocol.DeletePetReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.DeletePet(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:delete_pet][user_id:%d][id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的宠物列表
+func GetMyPetList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyPetListArgs
+    var reply protocol.MyPetListReply
+
+    args.UserId = GetLoginUserId(c)
+    err := controller.GetMyPetList(&args, &reply)
+
+    g_logger.Notice("[cmd:my_pet_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 宠物详情
+func GetPetDetail(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.PetDetailArgs
+    var reply protocol.PetDetailReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetPetDetail(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:pet_detail][user_id:%d][id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 添加疫苗记录
+func AddPetVaccination(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.AddPetVaccinationArgs
+    var reply protocol.AddPetVaccinationReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.AddPetVaccination(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:add_pet_vaccination][user_id:%d][pet_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.PetId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 删除疫苗记录
+func DeletePetVaccination(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.DeletePetVaccinationArgs
+    var reply protocol.DeletePetVaccinationReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.DeletePetVaccination(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:delete_pet_vaccination][user_id:%d][pet_id:%d][id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.PetId, args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 上传宠物照片
+func UploadPetPhoto(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UploadPetPhotoArgs
+    var reply protocol.UploadPetPhotoReply
+
+    args.UserId = GetLoginUserId(c)
+    data, filename, err := utils.ReadFormFile(c.Request, "photo", controller.PET_PHOTO_MAX_SIZE)
+    if nil != err {
+        goto NOTICE
+    }
+    args.PetId, _ = strconv.ParseInt(c.Request.FormValue("pet_id"), 10, 64)
+    args.Data = data
+    args.Filename = filename
+    err = controller.UploadPetPhoto(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:upload_pet_photo][user_id:%d][pet_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.PetId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 删除宠物照片
+func DeletePetPhoto(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.DeletePetPhotoArgs
+    var reply protocol.DeletePetPhotoReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.DeletePetPhoto(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:delete_pet_photo][user_id:%d][pet_id:%d][id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.PetId, args.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 比赛组别列表
+func GetCompetitionClassList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CompetitionClassListArgs
+    var reply protocol.CompetitionClassListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetCompetitionClassList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:competition_class_list][activity_id:%d][Cost:%dus][Err:%v]",
+        args.ActivityId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 比赛成绩
+func GetCompetitionResult(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CompetitionResultArgs
+    var reply protocol.CompetitionResultReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetCompetitionResult(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:competition_result][class_id:%d][Cost:%dus][Err:%v]",
+        args.ClassId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 宠物报名比赛
+func EnterCompetition(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.EnterCompetitionArgs
+    var reply protocol.EnterCompetitionReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.EnterCompetition(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:enter_competition][user_id:%d][class_id:%d][pet_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ClassId, args.PetId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 退赛
+func WithdrawCompetition(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.WithdrawCompetitionArgs
+    var reply protocol.WithdrawCompetitionReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.WithdrawCompetition(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:withdraw_competition][user_id:%d][class_id:%d][pet_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ClassId, args.PetId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的参赛记录
+func GetMyCompetitionEntryList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyCompetitionEntryListArgs
+    var reply protocol.MyCompetitionEntryListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMyCompetitionEntryList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_competition_entry_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 裁判获取组别的参赛记录
+func GetJudgeEntryList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.JudgeEntryListArgs
+    var reply protocol.JudgeEntryListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.JudgeId = GetLoginUserId(c)
+    err = controller.GetJudgeEntryList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:judge_entry_list][judge_id:%d][class_id:%d][Cost:%dus][Err:%v]",
+        args.JudgeId, args.ClassId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 裁判提交评分
+func SubmitCompetitionScore(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.SubmitCompetitionScoreArgs
+    var reply protocol.SubmitCompetitionScoreReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.JudgeId = GetLoginUserId(c)
+    err = controller.SubmitCompetitionScore(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:submit_competition_score][judge_id:%d][entry_id:%d][Cost:%dus][Err:%v]",
+        args.JudgeId, args.EntryId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 创建比赛组别
+func CreateCompetitionClass(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CreateCompetitionClassArgs
+    var reply protocol.CreateCompetitionClassReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.CreateCompetitionClass(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:create_competition_class][operator_id:%d][class_id:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, reply.Class.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 推进比赛组别状态
+func UpdateCompetitionStatus(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UpdateCompetitionStatusArgs
+    var reply protocol.UpdateCompetitionStatusReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.UpdateCompetitionStatus(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:update_competition_status][operator_id:%d][class_id:%d][status:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.ClassId, args.Status, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 人气排行榜
+func GetPetVoteRankList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.PetVoteRankListArgs
+    var reply protocol.PetVoteRankListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetPetVoteRankList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:pet_vote_rank_list][page_num:%d][Cost:%dus][Err:%v]",
+        args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 人气投票
+func VotePet(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.VotePetArgs
+    var reply protocol.VotePetReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    args.ClientIp = c.ClientIP()
+    args.DeviceId = c.Request.Header.Get(controller.SmsDeviceHeader())
+    err = controller.VotePet(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:vote_pet][user_id:%d][pet_id:%d][ip:%s][device_id:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.PetId, args.ClientIp, args.DeviceId,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我今天的剩余票数
+func GetMyVoteInfo(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyVoteInfoArgs
+    var reply protocol.MyVoteInfoReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMyVoteInfo(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_vote_info][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 可疑投票列表
+func GetSuspiciousVoteList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.SuspiciousVoteListArgs
+    var reply protocol.SuspiciousVoteListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetSuspiciousVoteList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:suspicious_vote_list][page_num:%d][Cost:%dus][Err:%v]",
+        args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 作废投票
+func VoidPetVote(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.VoidPetVoteArgs
+    var reply protocol.VoidPetVoteReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.VoidPetVote(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:void_pet_vote][operator_id:%d][void_num:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, reply.VoidNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 重新计算人气排行榜
+func RebuildVoteRank(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RebuildVoteRankArgs
+    var reply protocol.RebuildVoteRankReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.RebuildVoteRank(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:rebuild_vote_rank][operator_id:%d][pet_num:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, reply.PetNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 收藏
+func AddFavorite(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.FavoriteArgs
+    var reply protocol.FavoriteReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.AddFavorite(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:add_favorite][user_id:%d][target_type:%d][target_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.TargetType, args.TargetId,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 取消收藏
+func RemoveFavorite(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.FavoriteArgs
+    var reply protocol.FavoriteReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.RemoveFavorite(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:remove_favorite][user_id:%d][target_type:%d][target_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.TargetType, args.TargetId,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的收藏
+func GetMyFavoriteList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyFavoriteListArgs
+    var reply protocol.MyFavoriteListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMyFavoriteList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_favorite_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 收藏数
+func GetFavoriteCountList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.FavoriteCountListArgs
+    var reply protocol.FavoriteCountListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetFavoriteCountList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:favorite_count_list][target_type:%d][target_ids:%s][Cost:%dus][Err:%v]",
+        args.TargetType, args.TargetIds, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 届次列表
+func GetEditionList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.EditionListArgs
+    var reply protocol.EditionListReply
+
+    err := controller.GetEditionList(&args, &reply)
+
+    g_logger.Notice("[cmd:edition_list][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 当前届次
+func GetCurrentEdition(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CurrentEditionArgs
+    var reply protocol.CurrentEditionReply
+
+    args.Host = c.Request.Host
+    err := controller.GetCurrentEdition(&args, &reply)
+
+    g_logger.Notice("[cmd:current_edition][host:%s][Cost:%dus][Err:%v]",
+        args.Host, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 新建、修改届次
+func SaveEdition(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.SaveEditionArgs
+    var reply protocol.SaveEditionReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.SaveEdition(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:save_edition][operator_id:%d][edition_id:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, reply.Edition.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 在售门票
+func GetTicketProductList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.TicketProductListArgs
+    var reply protocol.TicketProductListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.Host = c.Request.Host
+    err = controller.GetTicketProductList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:ticket_product_list][edition_id:%d][host:%s][Cost:%dus][Err:%v]",
+        args.EditionId, args.Host, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 门票下单
+func CreateOrder(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CreateOrderArgs
+    var reply protocol.CreateOrderReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    args.ClientIp = c.ClientIP()
+    err = controller.CreateOrder(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:create_order][user_id:%d][order_no:%s][total_fee:%d][Cost:%dus][Err:%v]",
+        args.UserId, reply.Order.OrderNo, reply.Order.TotalFee,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 待支付订单重新获取支付参数
+func GetOrderPayParams(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.OrderPayParamsArgs
+    var reply protocol.OrderPayParamsReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetOrderPayParams(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:order_pay_params][user_id:%d][order_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.OrderNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 取消订单
+func CancelOrder(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.CancelOrderArgs
+    var reply protocol.CancelOrderReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.CancelOrder(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:cancel_order][user_id:%d][order_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.OrderNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我的订单
+func GetMyOrderList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyOrderListArgs
+    var reply protocol.MyOrderListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMyOrderList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_order_list][user_id:%d][page_num:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 订单详情
+func GetOrderDetail(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.OrderDetailArgs
+    var reply protocol.OrderDetailReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetOrderDetail(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:order_detail][user_id:%d][order_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.OrderNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 支付结果通知，按支付平台要求返回xml
+func PayNotify(c *gin.Context) {
+    handle_start_time := time.Now()
+
+    body, err := ioutil.ReadAll(c.Request.Body)
+    if nil == err {
+        err = controller.HandlePayNotify(body)
+    }
+
+    g_logger.Notice("[cmd:pay_notify][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    if nil != err {
+        c.Data(http.StatusOK, "application/xml", utils.WxpayNotifyReply(false, "FAIL"))
+        return
+    }
+    c.Data(http.StatusOK, "application/xml", utils.WxpayNotifyReply(true, "OK"))
+}
+
+// 模拟支付成功
+func FakePayConfirm(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.FakePayConfirmArgs
+    var reply protocol.FakePayConfirmReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.FakePayConfirm(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:fake_pay_confirm][user_id:%d][order_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.OrderNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 申请退款
+func ApplyRefund(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ApplyRefundArgs
+    var reply protocol.ApplyRefundReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.ApplyRefund(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:apply_refund][user_id:%d][order_no:%s][refund_no:%s][refund_fee:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.OrderNo, reply.Refund.RefundNo, reply.Refund.RefundFee,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 管理员退款
+func AdminRefundOrder(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.AdminRefundArgs
+    var reply protocol.AdminRefundReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.AdminRefundOrder(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:admin_refund][operator_id:%d][order_no:%s][refund_no:%s][refund_fee:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.OrderNo, reply.Refund.RefundNo, reply.Refund.RefundFee,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 订单流水
+func GetOrderLogList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.OrderLogListArgs
+    var reply protocol.OrderLogListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetOrderLogList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:order_log_list][order_no:%s][Cost:%dus][Err:%v]",
+        args.OrderNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 退款结果通知，按支付平台要求返回xml
+func RefundNotify(c *gin.Context) {
+    handle_start_time := time.Now()
+
+    body, err := ioutil.ReadAll(c.Request.Body)
+    if nil == err {
+        err = controller.HandleRefundNotify(body)
+    }
+
+    g_logger.Notice("[cmd:refund_notify][Cost:%dus][Err:%v]",
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    if nil != err {
+        c.Data(http.StatusOK, "application/xml", utils.WxpayNotifyReply(false, "FAIL"))
+        return
+    }
+    c.Data(http.StatusOK, "application/xml", utils.WxpayNotifyReply(true, "OK"))
+}
+
+// 模拟退款结果
+func FakeRefundConfirm(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.FakeRefundConfirmArgs
+    var reply protocol.FakeRefundConfirmReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.FakeRefundConfirm(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:fake_refund_confirm][user_id:%d][refund_no:%s][Cost:%dus][Err:%v]",
+        args.UserId, args.RefundNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 团体登记
+func ImportRegistration(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ImportRegistrationArgs
+    var reply protocol.ImportRegistrationReply
+
+    args.OperatorId = GetLoginUserId(c)
+    data, filename, err := utils.ReadFormFile(c.Request, "file", controller.REGISTRATION_IMPORT_MAX_SIZE)
+    if nil != err {
+        goto NOTICE
+    }
+    args.Data = data
+    args.Filename = filename
+    err = controller.ImportRegistration(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:import_registration][operator_id:%d][filename:%s][job_no:%s][total:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.Filename, reply.Job.JobNo, reply.Job.TotalNum,
+        time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 团体登记任务进度
+func GetRegistrationJob(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RegistrationJobArgs
+    var reply protocol.RegistrationJobReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    args.IsAdmin = IsLoginAdmin(c)
+    err = controller.GetRegistrationJob(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:registration_job][operator_id:%d][job_no:%s][Cost:%dus][Err:%v]",
+        args.OperatorId, args.JobNo, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 团体登记每行结果
+func GetRegistrationRowList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RegistrationRowListArgs
+    var reply protocol.RegistrationRowListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    args.IsAdmin = IsLoginAdmin(c)
+    err = controller.GetRegistrationRowList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:registration_row_list][operator_id:%d][job_no:%s][page_num:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.JobNo, args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 团体登记任务列表
+func GetRegistrationJobList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RegistrationJobListArgs
+    var reply protocol.RegistrationJobListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    args.IsAdmin = IsLoginAdmin(c)
+    err = controller.GetRegistrationJobList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:registration_job_list][operator_id:%d][page_num:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 添加展商工作人员
+func AddExhibitorStaff(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.AddExhibitorStaffArgs
+    var reply protocol.AddExhibitorStaffReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.AddExhibitorStaff(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:add_exhibitor_staff][operator_id:%d][exhibitor_id:%d][user_id:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.ExhibitorId, reply.Staff.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 移除展商工作人员
+func RemoveExhibitorStaff(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RemoveExhibitorStaffArgs
+    var reply protocol.RemoveExhibitorStaffReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.OperatorId = GetLoginUserId(c)
+    err = controller.RemoveExhibitorStaff(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:remove_exhibitor_staff][operator_id:%d][exhibitor_id:%d][user_id:%d][Cost:%dus][Err:%v]",
+        args.OperatorId, args.ExhibitorId, args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 展商工作人员列表
+func GetExhibitorStaffList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExhibitorStaffListArgs
+    var reply protocol.ExhibitorStaffListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    err = controller.GetExhibitorStaffList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:exhibitor_staff_list][exhibitor_id:%d][Cost:%dus][Err:%v]",
+        args.ExhibitorId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 我所属的展商
+func GetStaffExhibitorList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.StaffExhibitorListArgs
+    var reply protocol.StaffExhibitorListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetStaffExhibitorList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:staff_exhibitor_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 展位扫码记录潜在客户
+func ScanLead(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ScanLeadArgs
+    var reply protocol.ScanLeadReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.ScanLead(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:scan_lead][user_id:%d][exhibitor_id:%d][lead_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ExhibitorId, reply.Lead.Id, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 修改潜在客户备注
+func UpdateLead(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.UpdateLeadArgs
+    var reply protocol.UpdateLeadReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.UpdateLead(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:update_lead][user_id:%d][exhibitor_id:%d][lead_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ExhibitorId, args.LeadId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 展商的潜在客户列表
+func GetLeadList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.LeadListArgs
+    var reply protocol.LeadListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetLeadList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:lead_list][user_id:%d][exhibitor_id:%d][page_num:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ExhibitorId, args.PageNum, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 导出展商的潜在客户，成功时返回csv文件
+func ExportLead(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.ExportLeadArgs
+    var reply protocol.ExportLeadReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.ExportLead(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:export_lead][user_id:%d][exhibitor_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.ExhibitorId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    if nil != err {
+        utils.SendResponse(c, http_code, &reply, err)
+        return
+    }
+    c.Writer.Header().Set("Cache-Control", "private, no-store")
+    c.Writer.Header().Set("Content-Disposition", "attachment; filename=" + reply.Filename)
+    c.Data(http_code, "text/csv; charset=utf-8", reply.Csv)
+}
+
+// 我被哪些展商记录
+func GetMyLeadList(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.MyLeadListArgs
+    var reply protocol.MyLeadListReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GetMyLeadList(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:my_lead_list][user_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 撤回对展商的授权
+func RevokeLeadConsent(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.RevokeLeadConsentArgs
+    var reply protocol.RevokeLeadConsentReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.RevokeLeadConsent(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:revoke_lead_consent][user_id:%d][lead_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.LeadId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
+// 重新授权给展商
+func GrantLeadConsent(c *gin.Context) {
+    var http_code int = http.StatusOK
+    handle_start_time := time.Now()
+
+    var args protocol.GrantLeadConsentArgs
+    var reply protocol.GrantLeadConsentReply
+
+    err := utils.ParseHttpBodyToArgs(c, &args)
+    if nil != err {
+        goto NOTICE
+    }
+    args.UserId = GetLoginUserId(c)
+    err = controller.GrantLeadConsent(&args, &reply)
+
+NOTICE:
+    g_logger.Notice("[cmd:grant_lead_consent][user_id:%d][lead_id:%d][Cost:%dus][Err:%v]",
+        args.UserId, args.LeadId, time.Now().Sub(handle_start_time).Nanoseconds()/1000, err)
+
+    utils.SendResponse(c, http_code, &reply, err)
+}
+
 // 观众中心授权
 func VistorCenterAuth(c *gin.Context) {
     AuthPage(c.Writer, c.Request)
@@ -29,6 +29,9 @@ var g_actor_type string
 
 const (
     ACTOR_TYPE_INIT_WEIXIN_MENU = "init_weixin_menu"
+    ACTOR_TYPE_MIGRATE_USER_PHONE = "migrate_user_phone"
+    ACTOR_TYPE_CLOSE_EXPIRED_ORDER = "close_expired_order"
+    ACTOR_TYPE_RETRY_REFUND = "retry_refund"
 )
 
 func init() {
@@ -69,14 +72,49 @@ func main() {
         return
     }
 
+    // init token secret
+    if err = utils.InitTokenSecret(); nil != err {
+        fmt.Printf("init token secret failed, err: %v", err)
+        return
+    }
+
+    // init ticket signer
+    if err = utils.InitTicketSigner(); nil != err {
+        fmt.Printf("init ticket signer failed, err: %v", err)
+        return
+    }
+
+    // init captcha
+    controller.InitCaptcha()
+
+    // init file storage
+    if err = utils.InitFileStorage(); nil != err {
+        fmt.Printf("init file storage failed, err: %v", err)
+        return
+    }
+
     // init weixin server
     InitWeixinServer()
-    // init yunpian sms client
-    utils.InitYpClient()
+    // init sms sender
+    if err = utils.InitSmsSender(); nil != err {
+        fmt.Printf("init sms sender failed, err: %v", err)
+        return
+    }
+    // init pay gateway
+    if err = utils.InitPayGateway(); nil != err {
+        fmt.Printf("init pay gateway failed, err: %v", err)
+        return
+    }
 
     // start http server
     if ACTOR_TYPE_INIT_WEIXIN_MENU == g_actor_type {
         InitWinxinMenuList()
+    } else if ACTOR_TYPE_MIGRATE_USER_PHONE == g_actor_type {
+        MigrateUserPhone()
+    } else if ACTOR_TYPE_CLOSE_EXPIRED_ORDER == g_actor_type {
+        CloseExpiredOrder()
+    } else if ACTOR_TYPE_RETRY_REFUND == g_actor_type {
+        RetryRefund()
     } else {
         StartHttpServer()
     }
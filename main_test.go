@@ -8,12 +8,419 @@ package main
 import (
     "testing"
     "fmt"
+    "strings"
+    "time"
     "pet/utils"
+    "pet/model"
+    "pet/protocol"
 )
 
 func TestPhoneValid(t *testing.T) {
-    s := []string{"18505921256", "13489594009", "12759029321", "17358547087"}
-    for _, v := range s {
-        fmt.Println(utils.PhoneValid(v))
+    cases := []struct {
+        phone   string
+        valid   bool
+    }{
+        {"18505921256", true},
+        {"13489594009", true},
+        {"17358547087", true},
+        {"19912345678", true},
+        {"12759029321", false},     // 第二位不能是0-2
+        {"10012345678", false},
+        {"1850592125", false},      // 10位
+        {"185059212567", false},    // 12位
+        {"28505921256", false},
+        {"+8618505921256", false},  // 不带国际区号
+        {"1850592125a", false},
+        {" 18505921256", false},
+        {"", false},
     }
-}
\ No newline at end of file
+    for _, c := range cases {
+        if valid := utils.PhoneValid(c.phone); valid != c.valid {
+            t.Errorf("phone %q valid: %v, expect: %v", c.phone, valid, c.valid)
+        }
+    }
+}
+
+func TestNormalizePhone(t *testing.T) {
+    cases := []struct {
+        phone           string
+        country_code    string
+        expect          string      // 为空时应该返回错误
+    }{
+        {"13800138000", "", "+8613800138000"},      // 默认中国大陆
+        {"13800138000", "86", "+8613800138000"},
+        {"13800138000", "+86", "+8613800138000"},
+        {"13800138000", "CN", "+8613800138000"},
+        {"+8613800138000", "", "+8613800138000"},
+        {" 13800138000 ", "", "+8613800138000"},
+        {"138 0013 8000", "86", "+8613800138000"},
+        {"91234567", "852", "+85291234567"},
+        {"91234567", "HK", "+85291234567"},
+        {"+85291234567", "86", "+85291234567"},     // 以+开头时忽略country_code
+        {"2025550123", "1", "+12025550123"},
+        {"+12025550123", "", "+12025550123"},
+        {"", "86", ""},
+        {"abc", "86", ""},
+        {"123", "86", ""},
+        {"12759029321", "86", ""},
+        {"01087654321", "86", ""},                  // 固定电话
+        {"13800138000", "999", ""},                 // 未知区号
+        {"13800138000", "XX", ""},                  // 未知地区
+    }
+    for _, c := range cases {
+        phone, err := utils.NormalizePhone(c.phone, c.country_code)
+        if c.expect == "" {
+            if nil == err {
+                t.Errorf("phone %q, country_code %q should be invalid, got: %s", c.phone, c.country_code, phone)
+            }
+            continue
+        }
+        if nil != err || phone != c.expect {
+            t.Errorf("phone %q, country_code %q => %q, err: %v, expect: %s", c.phone, c.country_code, phone, err, c.expect)
+        }
+    }
+
+    if !utils.IsMainlandPhone("+8613800138000") || !utils.IsMainlandPhone("13800138000") || utils.IsMainlandPhone("+85291234567") {
+        t.Errorf("IsMainlandPhone wrong")
+    }
+    if phone := utils.MainlandPhone("+8613800138000"); phone != "13800138000" || !utils.PhoneValid(phone) {
+        t.Errorf("MainlandPhone wrong: %s", phone)
+    }
+}
+
+func TestSignedToken(t *testing.T) {
+    token, err := utils.GenerateSignedToken(10086, time.Now().Unix()+60)
+    if nil != err {
+        t.Fatalf("generate token err: %v", err)
+    }
+    if user_id, ok := utils.ParseSignedToken(token); !ok || user_id != 10086 {
+        t.Errorf("parse token failed, user_id: %d, ok: %v", user_id, ok)
+    }
+    if _, ok := utils.ParseSignedToken(token + "0"); ok {
+        t.Errorf("token with wrong sign should be invalid")
+    }
+
+    expired, _ := utils.GenerateSignedToken(10086, time.Now().Unix()-1)
+    if _, ok := utils.ParseSignedToken(expired); ok {
+        t.Errorf("expired token should be invalid")
+    }
+}
+
+
+func TestEmailValid(t *testing.T) {
+    valid := []string{"liangbo@petfair.cc", "a.b+c@mail.example.com"}
+    for _, v := range valid {
+        if !utils.EmailValid(v) {
+            t.Errorf("email %s should be valid", v)
+        }
+    }
+    invalid := []string{"", "liangbo", "liangbo@", "@petfair.cc", "liangbo@petfair", "liang bo@petfair.cc"}
+    for _, v := range invalid {
+        if utils.EmailValid(v) {
+            t.Errorf("email %s should be invalid", v)
+        }
+    }
+}
+
+func TestTicketSign(t *testing.T) {
+    signer, err := utils.NewTicketSigner("1", make([]byte, 32))
+    if nil != err {
+        t.Fatalf("new ticket signer err: %v", err)
+    }
+    now := time.Now()
+    payload := signer.Sign(&utils.TicketClaims{
+        TicketNo:   "a1b2c3d4e5f60708",
+        EditionId:  20,
+        ValidFrom:  now.Unix() - 60,
+        ValidTo:    now.Unix() + 60,
+    })
+
+    claims, err := signer.Verify(payload)
+    if nil != err || claims.TicketNo != "a1b2c3d4e5f60708" || claims.EditionId != 20 || !claims.ValidAt(now) {
+        t.Fatalf("verify ticket failed, claims: %+v, err: %v", claims, err)
+    }
+    if _, err = signer.Verify(strings.Replace(payload, ".20.", ".21.", 1)); nil == err {
+        t.Errorf("tampered ticket should be invalid")
+    }
+
+    other, _ := utils.NewTicketSigner("2", make([]byte, 32))
+    if _, err = other.Verify(payload); nil == err {
+        t.Errorf("ticket with other key id should be invalid")
+    }
+}
+
+func TestReadSheetRows(t *testing.T) {
+    data := []byte("\xEF\xBB\xBF公司名称,展位号,产品类别\n\" 宠物食品有限公司 \",W1A01,\"食品,用品\"\n")
+    rows, err := utils.ReadSheetRows("exhibitor.CSV", data)
+    if nil != err {
+        t.Fatalf("read csv err: %v", err)
+    }
+    if len(rows) != 2 || rows[0][0] != "公司名称" || rows[1][0] != "宠物食品有限公司" || rows[1][2] != "食品,用品" {
+        t.Errorf("read csv rows wrong: %q", rows)
+    }
+    if _, err = utils.ReadSheetRows("exhibitor.csv", []byte{0xC9, 0xCF, 0xBA, 0xA3}); nil == err {
+        t.Errorf("gbk csv should be rejected")
+    }
+    if _, err = utils.ReadSheetRows("exhibitor.xls", data); nil == err {
+        t.Errorf("xls should be rejected")
+    }
+}
+
+func TestWriteCsvRows(t *testing.T) {
+    data, err := utils.WriteCsvRows([][]string{{"姓名", "电话", "备注"}, {"张三", "+8613800000000", "=HYPERLINK(\"x\")"}})
+    if nil != err {
+        t.Fatalf("write csv err: %v", err)
+    }
+    rows, err := utils.ReadSheetRows("lead.csv", data)
+    if nil != err {
+        t.Fatalf("read written csv err: %v", err)
+    }
+    if len(rows) != 2 || rows[1][1] != "+8613800000000" || rows[1][2] != "'=HYPERLINK(\"x\")" {
+        t.Errorf("write csv rows wrong: %q", rows)
+    }
+}
+
+func TestRankCompetitionEntries(t *testing.T) {
+    criteria := []model.CompetitionCriterion{
+        {Id: 1, Name: "外形", Weight: 60, MaxScore: 10},
+        {Id: 2, Name: "步态", Weight: 40, MaxScore: 10},
+    }
+    entry_list := []model.CompetitionEntry{{Id: 1}, {Id: 2}, {Id: 3}}
+    // entry_id => 每个裁判对两个评分项的分数
+    raw := map[int64][][]int{
+        1: {{9, 8}, {9, 8}},     // 总分86
+        2: {{8, 9}, {8, 10}},    // 总分86，外形分低
+        3: {{9, 8}, {9, 8}},     // 和1完全相同，并列
+    }
+    var score_list []model.CompetitionScore
+    for entry_id, judges := range raw {
+        for judge, scores := range judges {
+            for i, score := range scores {
+                score_list = append(score_list, model.CompetitionScore{
+                    EntryId: entry_id, JudgeId: int64(judge + 1), CriterionId: criteria[i].Id, Score: score})
+            }
+        }
+    }
+
+    err := model.RankCompetitionEntries(entry_list, criteria, score_list, 2)
+    if nil != err {
+        t.Fatalf("rank entries err: %v", err)
+    }
+    rankings := []int{entry_list[0].Ranking, entry_list[1].Ranking, entry_list[2].Ranking}
+    if rankings[0] != 1 || rankings[1] != 3 || rankings[2] != 1 {
+        t.Errorf("rankings wrong: %v", rankings)
+    }
+    if entry_list[1].TotalScore != 86 || entry_list[1].CriterionScores != "[8,9.5]" {
+        t.Errorf("entry 2 score wrong, total: %v, criterion scores: %s", entry_list[1].TotalScore, entry_list[1].CriterionScores)
+    }
+
+    if err = model.RankCompetitionEntries(entry_list, criteria, score_list[1:], 2); nil == err {
+        t.Errorf("incomplete scores should be rejected")
+    }
+}
+
+func TestWxpaySign(t *testing.T) {
+    // 微信支付文档中的签名示例
+    params := map[string]string{
+        "appid":        "wxd930ea5d5a258f4f",
+        "mch_id":       "10000100",
+        "device_info":  "1000",
+        "body":         "test",
+        "nonce_str":    "ibuaiVcKdpRxkhJA",
+        "sign_type":    "",
+    }
+    sign := utils.WxpaySign(params, "192006250b4c09247ec02edce69f6a2d")
+    if sign != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+        t.Errorf("wxpay sign wrong: %s", sign)
+    }
+
+    gateway := utils.NewFakePayGateway("wx_app", "", "")
+    notify, err := gateway.ParseNotify(gateway.Notify("201801182240001A2B3C4D", 3800))
+    if nil != err {
+        t.Fatalf("parse notify err: %v", err)
+    }
+    if !notify.Success || notify.OrderNo != "201801182240001A2B3C4D" || notify.TotalFee != 3800 {
+        t.Errorf("notify wrong: %+v", notify)
+    }
+
+    body := strings.Replace(string(gateway.Notify("201801182240001A2B3C4D", 3800)), "3800", "1", 1)
+    if _, err = gateway.ParseNotify([]byte(body)); nil == err {
+        t.Errorf("tampered notify should be rejected")
+    }
+}
+
+func TestWxpayRefundNotify(t *testing.T) {
+    gateway := utils.NewFakePayGateway("wx_app", "", "")
+    body := gateway.RefundNotify("201801182240001A2B3C4D", "R201801192140001A2B3C4D", 1900, true)
+    notify, err := gateway.ParseRefundNotify(body)
+    if nil != err {
+        t.Fatalf("parse refund notify err: %v", err)
+    }
+    if !notify.Success || notify.RefundNo != "R201801192140001A2B3C4D" || notify.RefundFee != 1900 {
+        t.Errorf("refund notify wrong: %+v", notify)
+    }
+
+    // 其他商户密钥加密的通知不能解密
+    other := utils.NewFakePayGateway("wx_app", "", "other_api_key")
+    if _, err = other.ParseRefundNotify(body); nil == err {
+        t.Errorf("refund notify with wrong key should be rejected")
+    }
+
+    notify, err = gateway.ParseRefundNotify(gateway.RefundNotify("201801182240001A2B3C4D", "R201801192140001A2B3C4D", 1900, false))
+    if nil != err || notify.Success || notify.Status != "REFUNDCLOSE" {
+        t.Errorf("refund close notify wrong: %+v, err: %v", notify, err)
+    }
+}
+
+func TestPayRefundBizError(t *testing.T) {
+    gateway := utils.NewFakePayGateway("wx_app", "", "")
+    _, err := gateway.Refund(&utils.PayRefund{OrderNo: "201801182240001A2B3C4D", RefundNo: "R201801192140001A2B3C4D",
+        TotalFee: 1900, RefundFee: 2000})
+    if !utils.IsPayBizError(err) {
+        t.Errorf("refund more than total_fee should be biz error, err: %v", err)
+    }
+    if utils.IsPayBizError(fmt.Errorf("i/o timeout")) {
+        t.Errorf("network error should not be biz error")
+    }
+}
+
+func TestActivityVisible(t *testing.T) {
+    cases := map[int]bool{
+        model.ACTIVITY_STATUS_DRAFT:        false,
+        model.ACTIVITY_STATUS_PUBLISHED:    true,
+        model.ACTIVITY_STATUS_CANCELLED:    true,
+    }
+    for status, visible := range cases {
+        activity := &model.Activity{Status: status}
+        if activity.Visible() != visible {
+            t.Errorf("activity status %d visible should be %v", status, visible)
+        }
+    }
+
+    activity := &model.Activity{
+        Id:         12,
+        Title:      "猫咪行为讲座",
+        Hall:       "W1",
+        StartTime:  time.Date(2018, 8, 17, 10, 0, 0, 0, time.Local),
+        EndTime:    time.Date(2018, 8, 17, 11, 30, 0, 0, time.Local),
+        Capacity:   50,
+        Status:     model.ACTIVITY_STATUS_PUBLISHED,
+    }
+    var info protocol.ActivityInfoJson
+    model.CopyActivityData(activity, &info)
+    if info.Id != 12 || info.Title != activity.Title || info.Hall != "W1" || info.Capacity != 50 ||
+        info.StartTime != "2018-08-17 10:00:00" || info.EndTime != "2018-08-17 11:30:00" {
+        t.Errorf("copy activity data wrong: %+v", info)
+    }
+}
+
+func TestActivitySignupOpen(t *testing.T) {
+    now := time.Now()
+    cases := []struct {
+        status      int
+        start_time  time.Time
+        open        bool
+    }{
+        {model.ACTIVITY_STATUS_PUBLISHED, now.Add(time.Hour), true},
+        {model.ACTIVITY_STATUS_PUBLISHED, now, false},                  // 开始时间到了就不能报名
+        {model.ACTIVITY_STATUS_PUBLISHED, now.Add(-time.Hour), false},
+        {model.ACTIVITY_STATUS_CANCELLED, now.Add(time.Hour), false},
+        {model.ACTIVITY_STATUS_DRAFT, now.Add(time.Hour), false},
+    }
+    for _, c := range cases {
+        activity := &model.Activity{Status: c.status, StartTime: c.start_time}
+        if activity.SignupOpen(now) != c.open {
+            t.Errorf("activity status %d, start_time %v, signup open should be %v", c.status, c.start_time, c.open)
+        }
+    }
+}
+
+func TestVoteSettingDefaults(t *testing.T) {
+    setting := utils.VoteConfig{}.WithDefaults()
+    if setting.DailyMax != 3 || setting.BurstSeconds != 600 || setting.IpBurstMax != 30 ||
+        setting.DeviceBurstMax != 10 || setting.NewAccountHours != 24 {
+        t.Errorf("vote setting defaults wrong: %+v", setting)
+    }
+
+    // 已配置的项不覆盖，负数按未配置处理
+    setting = utils.VoteConfig{DailyMax: 5, BurstSeconds: -1, IpBurstMax: 100}.WithDefaults()
+    if setting.DailyMax != 5 || setting.BurstSeconds != 600 || setting.IpBurstMax != 100 || setting.DeviceBurstMax != 10 {
+        t.Errorf("vote setting with config wrong: %+v", setting)
+    }
+}
+
+func TestVoteFraudFlags(t *testing.T) {
+    setting := utils.VoteConfig{}.WithDefaults()
+    now := time.Now()
+    old_account := now.Add(-48 * time.Hour)
+    cases := []struct {
+        ip_times        int
+        device_times    int
+        create_time     time.Time
+        flags           string
+    }{
+        {1, 1, old_account, ""},
+        {0, 0, old_account, ""},                        // 没有ip和设备
+        {30, 10, old_account, ""},                      // 等于阈值不标记
+        {31, 10, old_account, model.VOTE_FLAG_IP_BURST},
+        {30, 11, old_account, model.VOTE_FLAG_DEVICE_BURST},
+        {1, 1, now.Add(-23 * time.Hour), model.VOTE_FLAG_NEW_ACCOUNT},
+        {1, 1, now.Add(-24 * time.Hour), ""},
+        {31, 11, now, strings.Join([]string{model.VOTE_FLAG_IP_BURST, model.VOTE_FLAG_DEVICE_BURST, model.VOTE_FLAG_NEW_ACCOUNT}, ",")},
+    }
+    for _, c := range cases {
+        flags := strings.Join(model.VoteFraudFlags(c.ip_times, c.device_times, c.create_time, now, setting), ",")
+        if flags != c.flags {
+            t.Errorf("ip_times: %d, device_times: %d, create_time: %v, flags: %q, expect: %q",
+                c.ip_times, c.device_times, c.create_time, flags, c.flags)
+        }
+    }
+}
+
+func TestMergeFavoriteCounts(t *testing.T) {
+    // 1、2 来自缓存，3、4 缓存中没有，数据库中只有 3
+    counts := map[int64]int{1: 5, 2: 0}
+    model.MergeFavoriteCounts(counts, []int64{3, 4}, []model.FavoriteCount{{TargetId: 3, Num: 7}})
+
+    expect := map[int64]int{1: 5, 2: 0, 3: 7, 4: 0}
+    if len(counts) != len(expect) {
+        t.Fatalf("favorite counts wrong: %v", counts)
+    }
+    for target_id, num := range expect {
+        if num_now, ok := counts[target_id]; !ok || num_now != num {
+            t.Errorf("target %d favorite count: %d, expect: %d", target_id, num_now, num)
+        }
+    }
+}
+
+func TestValidateRegistrationRow(t *testing.T) {
+    cases := []struct {
+        values  map[string]string
+        phone   string      // 为空时应该返回错误
+    }{
+        {map[string]string{"name": "张三", "phone": "13800138000"}, "+8613800138000"},
+        {map[string]string{"name": "张三", "phone": "138-0013-8000", "email": "zhangsan@petfair.cc"}, "+8613800138000"},
+        {map[string]string{"name": "张三", "phone": "138 0013 8000", "country_code": "+86"}, "+8613800138000"},
+        {map[string]string{"name": "Chan", "phone": "91234567", "country_code": "852"}, "+85291234567"},
+        {map[string]string{"name": "Chan", "phone": "+85291234567"}, "+85291234567"},
+        {map[string]string{"name": "", "phone": "13800138000"}, ""},
+        {map[string]string{"name": strings.Repeat("名", 65), "phone": "13800138000"}, ""},
+        {map[string]string{"name": "张三", "phone": ""}, ""},
+        {map[string]string{"name": "张三", "phone": "12759029321"}, ""},
+        {map[string]string{"name": "张三", "phone": "01087654321", "country_code": "86"}, ""},
+        {map[string]string{"name": "张三", "phone": "13800138000", "country_code": "999"}, ""},
+        {map[string]string{"name": "张三", "phone": "13800138000", "email": "zhangsan"}, ""},
+    }
+    for _, c := range cases {
+        phone, err := model.ValidateRegistrationRow(c.values)
+        if c.phone == "" {
+            if nil == err {
+                t.Errorf("row %v should be invalid, phone: %s", c.values, phone)
+            }
+            continue
+        }
+        if nil != err || phone != c.phone {
+            t.Errorf("row %v => %q, err: %v, expect: %s", c.values, phone, err, c.phone)
+        }
+    }
+}
@@ -0,0 +1,75 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/11/12 17:30
+ */
+package main
+
+import (
+    "net/http"
+    "pet/controller"
+    "pet/model"
+    "pet/utils"
+    "third/gin"
+)
+
+const (
+    CONTEXT_LOGIN_USER = "login_user"
+)
+
+// 登录校验，token 通过 Authorization: Bearer 或者 sessionid cookie 传递
+func GinAuthRequired() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        token := utils.GetTokenFromHeader(c.Request)
+        user_info, err := controller.GetUserByAccessToken(token)
+        if nil != err {
+            g_logger.Warning("auth failed, path: %s, err: %v", c.Request.URL.Path, err)
+            utils.SendResponse(c, http.StatusOK, nil, err)
+            c.Abort()
+            return
+        }
+
+        c.Set(CONTEXT_LOGIN_USER, user_info)
+        c.Next()
+    }
+}
+
+// 角色校验，需要在 GinAuthRequired 之后使用，管理员拥有所有权限
+func GinRoleRequired(roles ...int) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        user_info := GetLoginUser(c)
+        if nil == user_info || !user_info.HasRole(roles...) {
+            err := utils.NewInternalErrorByStr(utils.PermissionDeniedErrCode, "无权限")
+            g_logger.Warning("permission denied, path: %s, user_id: %d", c.Request.URL.Path, GetLoginUserId(c))
+            utils.SendResponse(c, http.StatusOK, nil, err)
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// 获取当前登录用户，未经过GinAuthRequired时返回nil
+func GetLoginUser(c *gin.Context) *model.User {
+    value, ok := c.Get(CONTEXT_LOGIN_USER)
+    if !ok {
+        return nil
+    }
+    user_info, _ := value.(*model.User)
+    return user_info
+}
+
+// 获取当前登录用户id
+func GetLoginUserId(c *gin.Context) int64 {
+    user_info := GetLoginUser(c)
+    if nil == user_info {
+        return 0
+    }
+    return user_info.UserId
+}
+
+// 当前登录用户是否管理员
+func IsLoginAdmin(c *gin.Context) bool {
+    user_info := GetLoginUser(c)
+    return nil != user_info && user_info.Role == model.USER_ROLE_ADMIN
+}
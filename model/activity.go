@@ -5,10 +5,166 @@
  */
 package model
 
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 活动类型
+const (
+    ACTIVITY_TYPE_SEMINAR   = 1     // 讲座
+    ACTIVITY_TYPE_SHOW      = 2     // 宠物表演
+    ACTIVITY_TYPE_WORKSHOP  = 3     // 工作坊
+)
+
+// 活动状态
+const (
+    ACTIVITY_STATUS_DRAFT       = 0     // 草稿，不对外展示
+    ACTIVITY_STATUS_PUBLISHED   = 1     // 已发布
+    ACTIVITY_STATUS_CANCELLED   = 2     // 已取消
+)
+
 // 活动表
 type Activity struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    Title           string          `sql:"type:varchar(128)"`   // 活动名称
+    Type            int             `sql:"type:smallint(6)"`    // 类型，1: 讲座 2: 宠物表演 3: 工作坊
+    Description     string          `sql:"type:text"`           // 活动介绍
+    Hall            string          `sql:"type:varchar(32)"`    // 展馆，如 W1
+    Venue           string          `sql:"type:varchar(128)"`   // 具体场地
+    StartTime       time.Time       `sql:"type:datetime"`
+    EndTime         time.Time       `sql:"type:datetime"`
+    Capacity        int             `sql:"type:int(11)"`        // 人数上限，0: 不限
+    SignupNum       int             `sql:"type:int(11)"`        // 已报名人数
+    Cover           string          `sql:"type:varchar(255)"`   // 封面图
+    Status          int             `sql:"type:smallint(6)"`    // 状态，0: 草稿 1: 已发布 2: 已取消
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (activity *Activity) TableName() string {
+    return "pet.activity"
+}
+
+func (activity *Activity) Create() error {
+    activity.CreateTime = time.Now()
+    activity.UpdateTime = activity.CreateTime
+
+    err := PET_DB.Table(activity.TableName()).Create(activity).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create activity error: %v", err)
+        return err
+    }
+    return nil
+}
+
+func (activity *Activity) Save() error {
+    activity.UpdateTime = time.Now()
+    if activity.Id == 0 {
+        activity.CreateTime = activity.UpdateTime
+    }
+
+    err := PET_DB.Table(activity.TableName()).Save(activity).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("save activity error: %v", err)
+        return err
+    }
+    return nil
+}
+
+func (activity *Activity) Delete() error {
+    err := PET_DB.Table(activity.TableName()).Where("id = ?", activity.Id).Delete(activity).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("delete activity error, id: %d, error: %v", activity.Id, err)
+        return err
+    }
+    return nil
+}
+
+// 获取活动，不存在时返回 found=false
+func (activity *Activity) GetActivityById(id int64) (found bool, err error) {
+    err = PET_DB.Table(activity.TableName()).Where("id = ?", id).Limit(1).Find(activity).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("activity not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get activity failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 按id批量获取对外展示的活动，不包含草稿
+func GetActivityListByIds(ids []int64) ([]Activity, error) {
+    var activity_list []Activity
+    if len(ids) == 0 {
+        return activity_list, nil
+    }
+    err := PET_DB.Table(new(Activity).TableName()).
+        Where("id IN (?) AND status IN (?)", ids, []int{ACTIVITY_STATUS_PUBLISHED, ACTIVITY_STATUS_CANCELLED}).
+        Find(&activity_list).Error
+    if nil != err {
+        utils.Logger.Error("get activity list by ids error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return activity_list, nil
+}
+
+// 是否对外展示
+func (activity *Activity) Visible() bool {
+    return activity.Status == ACTIVITY_STATUS_PUBLISHED || activity.Status == ACTIVITY_STATUS_CANCELLED
+}
+
+// 是否可以报名、取消报名，活动开始后或已取消不能报名
+func (activity *Activity) SignupOpen(now time.Time) bool {
+    return activity.Status == ACTIVITY_STATUS_PUBLISHED && now.Before(activity.StartTime)
+}
+
+/**
+ * 分页列表，按开始时间排序
+ *
+ * edition_id不为0时只返回该届的活动，day不为空时只返回当天开始的活动，hall不为空时只返回该展馆的活动，草稿不返回
+ */
+func (activity *Activity) GetActivityListByPage(edition_id int64, day time.Time, hall string, page_num, page_size int) (activity_list []Activity, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
 
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
 
+    query := PET_DB.Table(activity.TableName()).
+        Where("status IN (?)", []int{ACTIVITY_STATUS_PUBLISHED, ACTIVITY_STATUS_CANCELLED})
+    if edition_id != 0 {
+        query = query.Where("edition_id = ?", edition_id)
+    }
+    if !day.IsZero() {
+        query = query.Where("start_time >= ? AND start_time < ?", day, day.AddDate(0, 0, 1))
+    }
+    if hall != "" {
+        query = query.Where("hall = ?", hall)
+    }
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count activity list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
 
+    query = query.Order("start_time, id").Limit(page_size).Offset(offset)
 
-}
\ No newline at end of file
+    err = query.Find(&activity_list).Error
+    if nil != err {
+        utils.Logger.Error("get activity list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
@@ -0,0 +1,232 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/30 16:20
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 报名状态
+const (
+    SIGNUP_STATUS_SIGNED    = 1     // 已报名
+    SIGNUP_STATUS_WAITING   = 2     // 候补
+    SIGNUP_STATUS_CANCELLED = 3     // 已取消
+)
+
+// 活动报名表，同一用户同一活动只有一条记录
+type ActivitySignup struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ActivityId      int64           `sql:"type:bigint(20)"`
+    UserId          int64           `sql:"type:bigint(20)"`
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 已报名 2: 候补 3: 已取消
+    QueueTime       time.Time       `sql:"type:datetime"`       // 进入候补的时间，按先后顺序递补
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (signup *ActivitySignup) TableName() string {
+    return "pet.activity_signup"
+}
+
+// 获取用户的报名记录，不存在时返回 found=false
+func (signup *ActivitySignup) GetSignup(activity_id, user_id int64) (found bool, err error) {
+    err = PET_DB.Table(signup.TableName()).Where("activity_id = ? AND user_id = ?", activity_id, user_id).
+        Limit(1).Find(signup).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get activity signup failed, activity_id: %d, user_id: %d, error: %v", activity_id, user_id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 候补排位，从1开始
+func (signup *ActivitySignup) WaitingPosition() (int, error) {
+    var count int
+    err := PET_DB.Table(signup.TableName()).
+        Where("activity_id = ? AND status = ? AND (queue_time < ? OR (queue_time = ? AND id <= ?))",
+            signup.ActivityId, SIGNUP_STATUS_WAITING, signup.QueueTime, signup.QueueTime, signup.Id).
+        Count(&count).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("count waiting position failed, signup_id: %d, error: %v", signup.Id, err)
+        return 0, err
+    }
+    return count, nil
+}
+
+/**
+ * 报名活动，有名额时报名成功，名额已满时进入候补
+ *
+ * 通过带条件的 update 占用名额，同一活动的并发报名由 activity 行锁串行，不会超卖
+ */
+func SignupActivity(activity_id, user_id int64) (*ActivitySignup, error) {
+    signup := new(ActivitySignup)
+    found, err := signup.GetSignup(activity_id, user_id)
+    if nil != err {
+        return nil, err
+    }
+    if found && signup.Status != SIGNUP_STATUS_CANCELLED {
+        return signup, nil
+    }
+
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    // 占用名额，capacity为0时不限人数
+    result := tx.Exec("UPDATE pet.activity SET signup_num = signup_num + 1 WHERE id = ? AND (capacity = 0 OR signup_num < capacity)",
+        activity_id)
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("signup activity, update signup num error: %v", result.Error)
+        return nil, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 1 {
+        signup.Status = SIGNUP_STATUS_SIGNED
+    } else {
+        signup.Status = SIGNUP_STATUS_WAITING
+        signup.QueueTime = now
+    }
+
+    signup.ActivityId = activity_id
+    signup.UserId = user_id
+    signup.UpdateTime = now
+    if !found {
+        signup.CreateTime = now
+        err = tx.Table(signup.TableName()).Create(signup).Error
+    } else {
+        // 取消后重新报名，只有仍是取消状态时才更新，避免并发重复占用名额
+        result = tx.Table(signup.TableName()).Where("id = ? AND status = ?", signup.Id, SIGNUP_STATUS_CANCELLED).
+            Updates(map[string]interface{}{"status": signup.Status, "queue_time": signup.QueueTime, "update_time": now})
+        err = result.Error
+        if nil == err && result.RowsAffected == 0 {
+            tx.Rollback()
+            _, err = signup.GetSignup(activity_id, user_id)
+            if nil != err {
+                return nil, err
+            }
+            return signup, nil
+        }
+    }
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("signup activity, save signup error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("signup activity, commit error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return signup, nil
+}
+
+/**
+ * 取消报名，已报名的取消后名额由候补第一位递补
+ *
+ * 返回递补成功的报名记录，没有递补时返回nil
+ */
+func CancelActivitySignup(signup *ActivitySignup) (*ActivitySignup, error) {
+    if signup.Status == SIGNUP_STATUS_CANCELLED {
+        return nil, nil
+    }
+
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    result := tx.Table(signup.TableName()).Where("id = ? AND status = ?", signup.Id, signup.Status).
+        Updates(map[string]interface{}{"status": SIGNUP_STATUS_CANCELLED, "update_time": now})
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("cancel signup, update status error: %v", result.Error)
+        return nil, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    // 已被其他请求取消
+    if result.RowsAffected == 0 || signup.Status == SIGNUP_STATUS_WAITING {
+        err := tx.Commit().Error
+        if nil != err {
+            utils.Logger.Error("cancel signup, commit error: %v", err)
+            return nil, utils.NewInternalError(utils.DbErrCode, err)
+        }
+        signup.Status = SIGNUP_STATUS_CANCELLED
+        return nil, nil
+    }
+
+    // 释放名额，同时锁住活动行，递补期间其他报名等待
+    result = tx.Exec("UPDATE pet.activity SET signup_num = signup_num - 1 WHERE id = ? AND signup_num > 0", signup.ActivityId)
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("cancel signup, update signup num error: %v", result.Error)
+        return nil, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+
+    promoted := new(ActivitySignup)
+    err := tx.Table(promoted.TableName()).Where("activity_id = ? AND status = ?", signup.ActivityId, SIGNUP_STATUS_WAITING).
+        Order("queue_time, id").Limit(1).Find(promoted).Error
+    if gorm.RecordNotFound == err {
+        promoted = nil
+        err = nil
+    } else if nil == err {
+        result = tx.Exec("UPDATE pet.activity SET signup_num = signup_num + 1 WHERE id = ? AND (capacity = 0 OR signup_num < capacity)",
+            signup.ActivityId)
+        err = result.Error
+        if nil == err && result.RowsAffected == 1 {
+            err = tx.Table(promoted.TableName()).Where("id = ?", promoted.Id).
+                Updates(map[string]interface{}{"status": SIGNUP_STATUS_SIGNED, "update_time": now}).Error
+            promoted.Status = SIGNUP_STATUS_SIGNED
+            promoted.UpdateTime = now
+        } else {
+            promoted = nil
+        }
+    }
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("cancel signup, promote waiting error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("cancel signup, commit error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    signup.Status = SIGNUP_STATUS_CANCELLED
+    signup.UpdateTime = now
+    return promoted, nil
+}
+
+// 用户的报名列表，不包含已取消的
+func GetUserSignupListByPage(user_id int64, page_num, page_size int) (signup_list []ActivitySignup, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table("pet.activity_signup").
+        Where("user_id = ? AND status IN (?)", user_id, []int{SIGNUP_STATUS_SIGNED, SIGNUP_STATUS_WAITING})
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count signup list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    err = query.Order("create_time desc").Limit(page_size).Offset(offset).Find(&signup_list).Error
+    if nil != err {
+        utils.Logger.Error("get signup list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
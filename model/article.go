@@ -7,12 +7,14 @@ package model
 
 import (
     "time"
+    "third/gorm"
     "pet/utils"
 )
 
 // 文章
 type Article struct {
     Id              int64           `gorm:"primary_key"; sql:"AUTO_INCREMENT"`
+    EditionId       int64           `sql:"type:bigint(20)"` // 展会届次
     Title           string          `sql:"type:varchar(128)"`
     Content         string          `sql:"type:text"`
     Type            int             `sql:"type:smallint(6)"` // 类型，1:展会动态
@@ -49,7 +51,36 @@ func (article *Article) Save() error {
     return nil
 }
 
-func (article *Article) GetArticleListByPage(article_type int, page_num, page_size int) (article_list []Article, total_num int, err error) {
+// 获取文章，不存在时返回 found=false
+func (article *Article) GetArticleById(id int64) (found bool, err error) {
+    err = PET_DB.Table(article.TableName()).Where("id = ?", id).Limit(1).Find(article).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("article not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get article failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 按id批量获取文章
+func GetArticleListByIds(ids []int64) ([]Article, error) {
+    var article_list []Article
+    if len(ids) == 0 {
+        return article_list, nil
+    }
+    err := PET_DB.Table(new(Article).TableName()).Where("id IN (?)", ids).Find(&article_list).Error
+    if nil != err {
+        utils.Logger.Error("get article list by ids error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return article_list, nil
+}
+
+// 分页列表，edition_id不为0时只返回该届的文章
+func (article *Article) GetArticleListByPage(edition_id int64, article_type int, page_num, page_size int) (article_list []Article, total_num int, err error) {
     if page_size < 0 {
         page_size = 10
     }
@@ -60,6 +91,9 @@ func (article *Article) GetArticleListByPage(article_type int, page_num, page_si
     }
 
     query := PET_DB.Table(article.TableName())
+    if edition_id != 0 {
+        query = query.Where("edition_id = ?", edition_id)
+    }
     if article_type != 0 {
         query = query.Where("type = ?", article_type)
     }
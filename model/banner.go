@@ -13,6 +13,7 @@ import (
 // banner表
 type Banner struct {
     Id              int64           `gorm:"primary_key"; sql:"AUTO_INCREMENT" json:"id"`
+    EditionId       int64           `sql:"type:bigint(20)" json:"edition_id"` // 展会届次
     Pic             string          `sql:"type:varchar(255)" json:"pic"`
     RefUrl          string          `sql:"type:varchar(255)" json:"ref_url"`
     Type            int             `sql:"type:smallint(6)" json:"type"` // 类型，1:首页 2:展商 3: 合作媒体
@@ -48,8 +49,8 @@ func (banner *Banner) Save() error {
     return nil
 }
 
-// 分页列表
-func (banner *Banner) GetBannerListByPage(banner_type int, page_num, page_size int) (banner_list []Banner, total_num int, err error) {
+// 分页列表，edition_id不为0时只返回该届的banner
+func (banner *Banner) GetBannerListByPage(edition_id int64, banner_type int, page_num, page_size int) (banner_list []Banner, total_num int, err error) {
     if page_size < 0 {
         page_size = 10
     }
@@ -60,6 +61,9 @@ func (banner *Banner) GetBannerListByPage(banner_type int, page_num, page_size i
     }
 
     query := PET_DB.Table(banner.TableName())
+    if edition_id != 0 {
+        query = query.Where("edition_id = ?", edition_id)
+    }
     if banner_type != 0 {
         query = query.Where("type = ?", banner_type)
     }
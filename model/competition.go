@@ -0,0 +1,173 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/12 20:40
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 比赛组别状态，只能按顺序推进
+const (
+    COMPETITION_STATUS_OPEN     = 1     // 报名中
+    COMPETITION_STATUS_JUDGING  = 2     // 评分中，停止报名
+    COMPETITION_STATUS_CLOSED   = 3     // 已结束，成绩公布，评分不可修改
+)
+
+// 比赛组别，如 美容赛-犬组
+type CompetitionClass struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ActivityId      int64           `sql:"type:bigint(20)"`     // 关联的活动
+    Name            string          `sql:"type:varchar(64)"`
+    Species         int             `sql:"type:smallint(6)"`    // 限制宠物种类，0: 不限
+    Description     string          `sql:"type:text"`           // 比赛规则说明
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 报名中 2: 评分中 3: 已结束
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 评分项
+type CompetitionCriterion struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ClassId         int64           `sql:"type:bigint(20)"`
+    Name            string          `sql:"type:varchar(32)"`    // 如 外形、步态
+    Weight          int             `sql:"type:int(11)"`        // 权重
+    MaxScore        int             `sql:"type:int(11)"`        // 满分
+    Sort            int             `sql:"type:int(11)"`        // 排序，同分时按排序依次比较
+}
+
+// 组别的裁判
+type CompetitionJudge struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ClassId         int64           `sql:"type:bigint(20)"`
+    JudgeId         int64           `sql:"type:bigint(20)"`     // 裁判的user_id
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (class *CompetitionClass) TableName() string {
+    return "pet.competition_class"
+}
+
+func (criterion *CompetitionCriterion) TableName() string {
+    return "pet.competition_criterion"
+}
+
+func (judge *CompetitionJudge) TableName() string {
+    return "pet.competition_judge"
+}
+
+// 创建组别，同时保存评分项和裁判
+func CreateCompetitionClass(class *CompetitionClass, criteria []CompetitionCriterion, judge_ids []int64) error {
+    now := time.Now()
+    class.Status = COMPETITION_STATUS_OPEN
+    class.CreateTime = now
+    class.UpdateTime = now
+
+    tx := PET_DB.Begin()
+    err := tx.Table(class.TableName()).Create(class).Error
+    for i := 0; nil == err && i < len(criteria); i++ {
+        criteria[i].ClassId = class.Id
+        criteria[i].Sort = i + 1
+        err = tx.Table(criteria[i].TableName()).Create(&criteria[i]).Error
+    }
+    for i := 0; nil == err && i < len(judge_ids); i++ {
+        judge := &CompetitionJudge{ClassId: class.Id, JudgeId: judge_ids[i], CreateTime: now}
+        err = tx.Table(judge.TableName()).Create(judge).Error
+    }
+    if nil != err {
+        tx.Rollback()
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create competition class error: %v", err)
+        return err
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create competition class, commit error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 获取组别，不存在时返回 found=false
+func (class *CompetitionClass) GetCompetitionClassById(id int64) (found bool, err error) {
+    err = PET_DB.Table(class.TableName()).Where("id = ?", id).Limit(1).Find(class).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("competition class not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get competition class failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 组别列表，activity_id为0时返回所有组别
+func GetCompetitionClassList(activity_id int64) ([]CompetitionClass, error) {
+    var class_list []CompetitionClass
+    query := PET_DB.Table(new(CompetitionClass).TableName())
+    if activity_id != 0 {
+        query = query.Where("activity_id = ?", activity_id)
+    }
+    err := query.Order("activity_id, id").Find(&class_list).Error
+    if nil != err {
+        utils.Logger.Error("get competition class list error, activity_id: %d, error: %v", activity_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return class_list, nil
+}
+
+/**
+ * 推进组别状态，from => to，返回是否修改成功
+ *
+ * 结束评分请使用 CloseCompetitionClass
+ */
+func (class *CompetitionClass) UpdateStatus(from, to int) (bool, error) {
+    now := time.Now()
+    result := PET_DB.Table(class.TableName()).Where("id = ? AND status = ?", class.Id, from).
+        Updates(map[string]interface{}{"status": to, "update_time": now})
+    if nil != result.Error {
+        err := utils.NewInternalError(utils.DbErrCode, result.Error)
+        utils.Logger.Error("update competition class status error, id: %d, error: %v", class.Id, err)
+        return false, err
+    }
+    if result.RowsAffected == 0 {
+        return false, nil
+    }
+    class.Status = to
+    class.UpdateTime = now
+    return true, nil
+}
+
+// 组别的评分项，按排序
+func GetCompetitionCriterionList(class_id int64) ([]CompetitionCriterion, error) {
+    var criterion_list []CompetitionCriterion
+    err := PET_DB.Table(new(CompetitionCriterion).TableName()).Where("class_id = ?", class_id).
+        Order("sort, id").Find(&criterion_list).Error
+    if nil != err {
+        utils.Logger.Error("get competition criterion list error, class_id: %d, error: %v", class_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return criterion_list, nil
+}
+
+// 组别的裁判user_id
+func GetCompetitionJudgeIds(class_id int64) ([]int64, error) {
+    var judge_list []CompetitionJudge
+    err := PET_DB.Table(new(CompetitionJudge).TableName()).Where("class_id = ?", class_id).Order("id").Find(&judge_list).Error
+    if nil != err {
+        utils.Logger.Error("get competition judge list error, class_id: %d, error: %v", class_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    judge_ids := make([]int64, len(judge_list))
+    for i := range judge_list {
+        judge_ids[i] = judge_list[i].JudgeId
+    }
+    return judge_ids, nil
+}
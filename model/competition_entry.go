@@ -0,0 +1,467 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/12 21:30
+ */
+package model
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "sort"
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 参赛状态
+const (
+    ENTRY_STATUS_ENTERED    = 1     // 已报名
+    ENTRY_STATUS_WITHDRAWN  = 2     // 已退赛
+)
+
+// 参赛记录
+type CompetitionEntry struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ClassId         int64           `sql:"type:bigint(20)"`
+    PetId           int64           `sql:"type:bigint(20)"`
+    UserId          int64           `sql:"type:bigint(20)"`     // 宠物主人
+    PetName         string          `sql:"type:varchar(128)"`   // 报名时的宠物名称
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 已报名 2: 已退赛
+    TotalScore      float64         `sql:"type:decimal(8,2)"`   // 总分，百分制，比赛结束时计算
+    Ranking         int             `sql:"type:int(11)"`        // 名次，比赛结束时计算，同分并列
+    CriterionScores string          `sql:"type:varchar(255)"`   // 各评分项的平均分，json数组，按评分项排序
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 裁判评分，每个裁判对每个参赛记录的每个评分项一条
+type CompetitionScore struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ClassId         int64           `sql:"type:bigint(20)"`
+    EntryId         int64           `sql:"type:bigint(20)"`
+    JudgeId         int64           `sql:"type:bigint(20)"`
+    CriterionId     int64           `sql:"type:bigint(20)"`
+    Score           int             `sql:"type:int(11)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (entry *CompetitionEntry) TableName() string {
+    return "pet.competition_entry"
+}
+
+func (score *CompetitionScore) TableName() string {
+    return "pet.competition_score"
+}
+
+func (entry *CompetitionEntry) Save() error {
+    entry.UpdateTime = time.Now()
+    if entry.Id == 0 {
+        entry.CreateTime = entry.UpdateTime
+    }
+
+    err := PET_DB.Table(entry.TableName()).Save(entry).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("save competition entry error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 获取宠物在组别的参赛记录，不存在时返回 found=false
+func (entry *CompetitionEntry) GetCompetitionEntry(class_id, pet_id int64) (found bool, err error) {
+    err = PET_DB.Table(entry.TableName()).Where("class_id = ? AND pet_id = ?", class_id, pet_id).Limit(1).Find(entry).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get competition entry failed, class_id: %d, pet_id: %d, error: %v", class_id, pet_id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 获取参赛记录，不存在时返回 found=false
+func (entry *CompetitionEntry) GetCompetitionEntryById(id int64) (found bool, err error) {
+    err = PET_DB.Table(entry.TableName()).Where("id = ?", id).Limit(1).Find(entry).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("competition entry not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get competition entry failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 组别的有效参赛记录，按名次排序，未结束的比赛名次都为0，按报名顺序
+func GetCompetitionEntryList(class_id int64) ([]CompetitionEntry, error) {
+    var entry_list []CompetitionEntry
+    err := PET_DB.Table(new(CompetitionEntry).TableName()).Where("class_id = ? AND status = ?", class_id, ENTRY_STATUS_ENTERED).
+        Order("ranking, id").Find(&entry_list).Error
+    if nil != err {
+        utils.Logger.Error("get competition entry list error, class_id: %d, error: %v", class_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return entry_list, nil
+}
+
+// 用户的参赛记录，包括已退赛的
+func GetUserCompetitionEntryList(user_id int64) ([]CompetitionEntry, error) {
+    var entry_list []CompetitionEntry
+    err := PET_DB.Table(new(CompetitionEntry).TableName()).Where("user_id = ?", user_id).Order("id desc").Find(&entry_list).Error
+    if nil != err {
+        utils.Logger.Error("get user competition entry list error, user_id: %d, error: %v", user_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return entry_list, nil
+}
+
+// 裁判在组别的评分
+func GetJudgeCompetitionScoreList(class_id, judge_id int64) ([]CompetitionScore, error) {
+    var score_list []CompetitionScore
+    err := PET_DB.Table(new(CompetitionScore).TableName()).Where("class_id = ? AND judge_id = ?", class_id, judge_id).
+        Find(&score_list).Error
+    if nil != err {
+        utils.Logger.Error("get judge competition score list error, class_id: %d, judge_id: %d, error: %v", class_id, judge_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return score_list, nil
+}
+
+// 锁定组别，在事务中和评分、结束比赛互斥
+func lockCompetitionClass(tx *gorm.DB, class_id int64) (*CompetitionClass, error) {
+    class := new(CompetitionClass)
+    err := tx.Raw("SELECT * FROM pet.competition_class WHERE id = ? FOR UPDATE", class_id).Scan(class).Error
+    if nil != err {
+        utils.Logger.Error("lock competition class error, id: %d, error: %v", class_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return class, nil
+}
+
+/**
+ * 宠物报名比赛，退赛后重新报名复用原参赛记录
+ *
+ * 锁定组别和宠物后再检查，和推进比赛状态、修改宠物种类、删除宠物互斥
+ */
+func EnterCompetitionClass(class_id, pet_id, user_id int64) (*CompetitionEntry, error) {
+    tx := PET_DB.Begin()
+
+    class, err := lockCompetitionClass(tx, class_id)
+    if nil != err {
+        tx.Rollback()
+        return nil, err
+    }
+    if class.Status != COMPETITION_STATUS_OPEN {
+        tx.Rollback()
+        return nil, utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "比赛已停止报名")
+    }
+    pet, found, err := lockPet(tx, pet_id)
+    if nil != err {
+        tx.Rollback()
+        return nil, err
+    }
+    if !found || pet.UserId != user_id {
+        tx.Rollback()
+        return nil, utils.NewInternalErrorByStr(utils.NotFoundErrCode, "宠物不存在")
+    }
+    if class.Species != 0 && class.Species != pet.Species {
+        tx.Rollback()
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物种类不符合比赛要求")
+    }
+
+    entry := new(CompetitionEntry)
+    err = tx.Table(entry.TableName()).Where("class_id = ? AND pet_id = ?", class_id, pet_id).Limit(1).Find(entry).Error
+    if nil != err && gorm.RecordNotFound != err {
+        tx.Rollback()
+        utils.Logger.Error("enter competition, get entry error, class_id: %d, pet_id: %d, error: %v", class_id, pet_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    if entry.Status == ENTRY_STATUS_ENTERED {
+        tx.Rollback()
+        return nil, utils.NewInternalErrorByStr(utils.ParameterErrCode, "宠物已报名该比赛")
+    }
+
+    entry.ClassId = class_id
+    entry.PetId = pet_id
+    entry.UserId = user_id
+    entry.PetName = pet.Name
+    entry.Status = ENTRY_STATUS_ENTERED
+    entry.UpdateTime = time.Now()
+    if entry.Id == 0 {
+        entry.CreateTime = entry.UpdateTime
+    }
+    err = tx.Table(entry.TableName()).Save(entry).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("enter competition, save entry error, class_id: %d, pet_id: %d, error: %v", class_id, pet_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("enter competition, commit error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return entry, nil
+}
+
+// 退赛，锁定组别后再检查状态，只能在报名阶段
+func WithdrawCompetitionClass(class_id, pet_id, user_id int64) error {
+    tx := PET_DB.Begin()
+
+    class, err := lockCompetitionClass(tx, class_id)
+    if nil != err {
+        tx.Rollback()
+        return err
+    }
+    if class.Status != COMPETITION_STATUS_OPEN {
+        tx.Rollback()
+        return utils.NewInternalErrorByStr(utils.CompetitionStatusErrCode, "比赛已停止报名，不能退赛")
+    }
+
+    result := tx.Table(new(CompetitionEntry).TableName()).
+        Where("class_id = ? AND pet_id = ? AND user_id = ? AND status = ?", class_id, pet_id, user_id, ENTRY_STATUS_ENTERED).
+        Updates(map[string]interface{}{"status": ENTRY_STATUS_WITHDRAWN, "update_time": time.Now()})
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("withdraw competition error, class_id: %d, pet_id: %d, error: %v", class_id, pet_id, result.Error)
+        return utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        return utils.NewInternalErrorByStr(utils.NotFoundErrCode, "报名记录不存在")
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("withdraw competition, commit error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+/**
+ * 提交裁判评分，重复提交覆盖之前的评分，返回是否提交成功
+ *
+ * 锁定组别后再检查状态，比赛结束后评分不能修改
+ */
+func SubmitCompetitionScores(class_id int64, score_list []CompetitionScore) (bool, error) {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    class, err := lockCompetitionClass(tx, class_id)
+    if nil != err {
+        tx.Rollback()
+        return false, err
+    }
+    if class.Status != COMPETITION_STATUS_JUDGING {
+        tx.Rollback()
+        return false, nil
+    }
+
+    for _, score := range score_list {
+        err = tx.Exec("INSERT INTO pet.competition_score (class_id, entry_id, judge_id, criterion_id, score, create_time, update_time) "+
+            "VALUES (?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE score = VALUES(score), update_time = VALUES(update_time)",
+            class_id, score.EntryId, score.JudgeId, score.CriterionId, score.Score, now, now).Error
+        if nil != err {
+            tx.Rollback()
+            utils.Logger.Error("submit competition score error, entry_id: %d, judge_id: %d, error: %v", score.EntryId, score.JudgeId, err)
+            return false, utils.NewInternalError(utils.DbErrCode, err)
+        }
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("submit competition score, commit error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return true, nil
+}
+
+/**
+ * 结束比赛，计算成绩和名次，返回是否结束成功，组别不在评分中时返回false
+ *
+ * 所有裁判需要对所有参赛记录的所有评分项打分，否则返回错误
+ */
+func CloseCompetitionClass(class_id int64) (bool, error) {
+    criteria, err := GetCompetitionCriterionList(class_id)
+    if nil != err {
+        return false, err
+    }
+    judge_ids, err := GetCompetitionJudgeIds(class_id)
+    if nil != err {
+        return false, err
+    }
+
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    class, err := lockCompetitionClass(tx, class_id)
+    if nil != err {
+        tx.Rollback()
+        return false, err
+    }
+    if class.Status != COMPETITION_STATUS_JUDGING {
+        tx.Rollback()
+        return false, nil
+    }
+
+    var entry_list []CompetitionEntry
+    var score_list []CompetitionScore
+    err = tx.Table(new(CompetitionEntry).TableName()).Where("class_id = ? AND status = ?", class_id, ENTRY_STATUS_ENTERED).
+        Find(&entry_list).Error
+    if nil == err {
+        err = tx.Table(new(CompetitionScore).TableName()).Where("class_id = ? AND judge_id IN (?)", class_id, judge_ids).
+            Find(&score_list).Error
+    }
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("close competition class, get scores error, id: %d, error: %v", class_id, err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = RankCompetitionEntries(entry_list, criteria, score_list, len(judge_ids))
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("close competition class, rank error, id: %d, error: %v", class_id, err)
+        return false, err
+    }
+
+    for _, entry := range entry_list {
+        err = tx.Table(entry.TableName()).Where("id = ?", entry.Id).Updates(map[string]interface{}{
+            "total_score":      entry.TotalScore,
+            "ranking":          entry.Ranking,
+            "criterion_scores": entry.CriterionScores,
+            "update_time":      now,
+        }).Error
+        if nil != err {
+            break
+        }
+    }
+    if nil == err {
+        err = tx.Table(class.TableName()).Where("id = ?", class_id).
+            Updates(map[string]interface{}{"status": COMPETITION_STATUS_CLOSED, "update_time": now}).Error
+    }
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("close competition class, update error, id: %d, error: %v", class_id, err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("close competition class, commit error, id: %d, error: %v", class_id, err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    utils.Logger.Info("competition class closed, id: %d, entries: %d, judges: %d", class_id, len(entry_list), len(judge_ids))
+    return true, nil
+}
+
+/**
+ * 计算成绩和名次，结果写入 entry_list
+ *
+ * 每个评分项取所有裁判的平均分，按权重折算为百分制总分，保留两位小数
+ * 总分高的名次靠前，总分相同时按评分项排序依次比较平均分，全部相同时名次并列，下一名次顺延
+ */
+func RankCompetitionEntries(entry_list []CompetitionEntry, criteria []CompetitionCriterion, score_list []CompetitionScore, judge_num int) error {
+    if len(entry_list) == 0 {
+        return nil
+    }
+    if judge_num == 0 || len(criteria) == 0 {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "组别没有裁判或评分项")
+    }
+
+    entry_index := make(map[int64]int, len(entry_list))
+    for i := range entry_list {
+        entry_index[entry_list[i].Id] = i
+    }
+    criterion_index := make(map[int64]int, len(criteria))
+    total_weight := 0
+    for i := range criteria {
+        criterion_index[criteria[i].Id] = i
+        total_weight += criteria[i].Weight
+    }
+
+    // 各评分项的分数和评分次数
+    sums := make([][]int, len(entry_list))
+    counts := make([][]int, len(entry_list))
+    for i := range entry_list {
+        sums[i] = make([]int, len(criteria))
+        counts[i] = make([]int, len(criteria))
+    }
+    for _, score := range score_list {
+        i, ok := entry_index[score.EntryId]
+        j, ok2 := criterion_index[score.CriterionId]
+        if ok && ok2 {
+            sums[i][j] += score.Score
+            counts[i][j]++
+        }
+    }
+
+    // 平均分和总分，单位0.01分
+    averages := make([][]int64, len(entry_list))
+    totals := make([]int64, len(entry_list))
+    for i := range entry_list {
+        averages[i] = make([]int64, len(criteria))
+        total := 0.0
+        for j := range criteria {
+            if counts[i][j] != judge_num {
+                return utils.NewInternalErrorByStr(utils.ParameterErrCode,
+                    fmt.Sprintf("%s的%s评分未完成", entry_list[i].PetName, criteria[j].Name))
+            }
+            averages[i][j] = int64(math.Floor(float64(sums[i][j]*100)/float64(judge_num) + 0.5))
+            total += float64(averages[i][j]) * 100 / float64(criteria[j].MaxScore) * float64(criteria[j].Weight) / float64(total_weight)
+        }
+        totals[i] = int64(math.Floor(total + 0.5))
+    }
+
+    // 0: 相同，<0: a 靠前
+    compare := func(a, b int) int {
+        if totals[a] != totals[b] {
+            if totals[a] > totals[b] {
+                return -1
+            }
+            return 1
+        }
+        for j := range criteria {
+            if averages[a][j] != averages[b][j] {
+                if averages[a][j] > averages[b][j] {
+                    return -1
+                }
+                return 1
+            }
+        }
+        return 0
+    }
+    order := make([]int, len(entry_list))
+    for i := range order {
+        order[i] = i
+    }
+    sort.SliceStable(order, func(x, y int) bool {
+        return compare(order[x], order[y]) < 0
+    })
+
+    for k, i := range order {
+        entry := &entry_list[i]
+        if k > 0 && compare(order[k-1], i) == 0 {
+            entry.Ranking = entry_list[order[k-1]].Ranking
+        } else {
+            entry.Ranking = k + 1
+        }
+        entry.TotalScore = float64(totals[i]) / 100
+
+        criterion_scores := make([]float64, len(criteria))
+        for j := range criteria {
+            criterion_scores[j] = float64(averages[i][j]) / 100
+        }
+        bytes, _ := json.Marshal(criterion_scores)
+        entry.CriterionScores = string(bytes)
+    }
+    return nil
+}
@@ -0,0 +1,92 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/17 20:20
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 届次状态
+const (
+    EDITION_STATUS_DRAFT        = 0     // 草稿
+    EDITION_STATUS_PUBLISHED    = 1     // 已发布
+)
+
+// 展会届次，不同城市、年份的展会各为一届
+// banner、文章、活动、展商、门票都属于某一届
+type Edition struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    Name            string          `sql:"type:varchar(64)"`    // 名称，如 2018上海宠物展
+    City            string          `sql:"type:varchar(32)"`
+    Year            int             `sql:"type:int(11)"`
+    Host            string          `sql:"type:varchar(128)"`   // 该届专属域名，按请求域名确定届次，为空时不绑定
+    StartDate       time.Time       `sql:"type:datetime"`       // 开展日期
+    EndDate         time.Time       `sql:"type:datetime"`       // 闭展日期
+    Status          int             `sql:"type:smallint(6)"`    // 状态，0: 草稿 1: 已发布
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (edition *Edition) TableName() string {
+    return "pet.edition"
+}
+
+func (edition *Edition) Save() error {
+    edition.UpdateTime = time.Now()
+    if edition.Id == 0 {
+        edition.CreateTime = edition.UpdateTime
+    }
+
+    err := PET_DB.Table(edition.TableName()).Save(edition).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("save edition error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 获取届次，不存在时返回 found=false
+func (edition *Edition) GetEditionById(id int64) (found bool, err error) {
+    err = PET_DB.Table(edition.TableName()).Where("id = ?", id).Limit(1).Find(edition).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("edition not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get edition failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 按域名获取已发布的届次，不存在时返回 found=false
+func (edition *Edition) GetEditionByHost(host string) (found bool, err error) {
+    err = PET_DB.Table(edition.TableName()).Where("host = ? AND status = ?", host, EDITION_STATUS_PUBLISHED).
+        Limit(1).Find(edition).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get edition failed, host: %s, error: %v", host, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 已发布的届次，按开展日期倒序
+func GetEditionList() ([]Edition, error) {
+    var edition_list []Edition
+    err := PET_DB.Table(new(Edition).TableName()).Where("status = ?", EDITION_STATUS_PUBLISHED).
+        Order("start_date desc, id desc").Find(&edition_list).Error
+    if nil != err {
+        utils.Logger.Error("get edition list error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return edition_list, nil
+}
@@ -0,0 +1,209 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/8 20:30
+ */
+package model
+
+import (
+    "strconv"
+    "strings"
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 展商表
+type Exhibitor struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    Name            string          `sql:"type:varchar(128)"`   // 公司名称，导入时按同一届的名称更新
+    Logo            string          `sql:"type:varchar(255)"`
+    Description     string          `sql:"type:text"`           // 公司介绍
+    Hall            string          `sql:"type:varchar(32)"`    // 展馆，如 W1
+    Booth           string          `sql:"type:varchar(32)"`    // 展位号，如 W1A01
+    CategoryIds     string          `sql:"type:varchar(255)"`   // 产品类别id，逗号分隔
+    ContactName     string          `sql:"type:varchar(64)"`    // 联系人
+    ContactPhone    string          `sql:"type:varchar(32)"`
+    ContactEmail    string          `sql:"type:varchar(128)"`
+    Website         string          `sql:"type:varchar(255)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 展商产品类别
+type ExhibitorCategory struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    Name            string          `sql:"type:varchar(32)"`
+    Sort            int             `sql:"type:int(11)"`        // 排序，从小到大
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (exhibitor *Exhibitor) TableName() string {
+    return "pet.exhibitor"
+}
+
+func (exhibitor *Exhibitor) Create() error {
+    exhibitor.CreateTime = time.Now()
+    exhibitor.UpdateTime = exhibitor.CreateTime
+
+    err := PET_DB.Table(exhibitor.TableName()).Create(exhibitor).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create exhibitor error: %v", err)
+        return err
+    }
+    return nil
+}
+
+func (exhibitor *Exhibitor) Save() error {
+    exhibitor.UpdateTime = time.Now()
+    if exhibitor.Id == 0 {
+        exhibitor.CreateTime = exhibitor.UpdateTime
+    }
+
+    err := PET_DB.Table(exhibitor.TableName()).Save(exhibitor).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("save exhibitor error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 获取展商，不存在时返回 found=false
+func (exhibitor *Exhibitor) GetExhibitorById(id int64) (found bool, err error) {
+    err = PET_DB.Table(exhibitor.TableName()).Where("id = ?", id).Limit(1).Find(exhibitor).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("exhibitor not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get exhibitor failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 按id批量获取展商
+func GetExhibitorListByIds(ids []int64) ([]Exhibitor, error) {
+    var exhibitor_list []Exhibitor
+    if len(ids) == 0 {
+        return exhibitor_list, nil
+    }
+    err := PET_DB.Table(new(Exhibitor).TableName()).Where("id IN (?)", ids).Find(&exhibitor_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor list by ids error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return exhibitor_list, nil
+}
+
+// 按公司名称获取某一届的展商，不存在时返回 found=false
+func (exhibitor *Exhibitor) GetExhibitorByName(edition_id int64, name string) (found bool, err error) {
+    err = PET_DB.Table(exhibitor.TableName()).Where("edition_id = ? AND name = ?", edition_id, name).Limit(1).Find(exhibitor).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get exhibitor failed, name: %s, error: %v", name, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 产品类别id列表
+func (exhibitor *Exhibitor) CategoryIdList() []int64 {
+    var id_list []int64
+    for _, s := range strings.Split(exhibitor.CategoryIds, ",") {
+        id, err := strconv.ParseInt(s, 10, 64)
+        if nil == err && id > 0 {
+            id_list = append(id_list, id)
+        }
+    }
+    return id_list
+}
+
+func (exhibitor *Exhibitor) SetCategoryIdList(id_list []int64) {
+    strs := make([]string, len(id_list))
+    for i, id := range id_list {
+        strs[i] = strconv.FormatInt(id, 10)
+    }
+    exhibitor.CategoryIds = strings.Join(strs, ",")
+}
+
+// like 查询转义
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+/**
+ * 分页列表，按展馆、展位号排序
+ *
+ * edition_id不为0时只返回该届的展商，category_id不为0时只返回该类别的展商，
+ * hall不为空时只返回该展馆的展商，keyword不为空时按公司名称模糊匹配或展位号精确匹配
+ */
+func (exhibitor *Exhibitor) GetExhibitorListByPage(edition_id, category_id int64, hall, keyword string, page_num, page_size int) (exhibitor_list []Exhibitor, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(exhibitor.TableName())
+    if edition_id != 0 {
+        query = query.Where("edition_id = ?", edition_id)
+    }
+    if category_id != 0 {
+        query = query.Where("FIND_IN_SET(?, category_ids)", category_id)
+    }
+    if hall != "" {
+        query = query.Where("hall = ?", hall)
+    }
+    if keyword != "" {
+        query = query.Where("name LIKE ? OR booth = ?", "%"+likeEscaper.Replace(keyword)+"%", keyword)
+    }
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count exhibitor list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    query = query.Order("hall, booth, id").Limit(page_size).Offset(offset)
+
+    err = query.Find(&exhibitor_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+func (category *ExhibitorCategory) TableName() string {
+    return "pet.exhibitor_category"
+}
+
+func (category *ExhibitorCategory) Create() error {
+    category.CreateTime = time.Now()
+
+    err := PET_DB.Table(category.TableName()).Create(category).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create exhibitor category error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 所有产品类别，按排序
+func GetExhibitorCategoryList() ([]ExhibitorCategory, error) {
+    var category_list []ExhibitorCategory
+    err := PET_DB.Table(new(ExhibitorCategory).TableName()).Order("sort, id").Find(&category_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor category list error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return category_list, nil
+}
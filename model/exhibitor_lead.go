@@ -0,0 +1,253 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/21 20:30
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 展商工作人员，可以在展位扫观众门票记录潜在客户
+type ExhibitorStaff struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ExhibitorId     int64           `sql:"type:bigint(20)"`
+    UserId          int64           `sql:"type:bigint(20)"`
+    CreateBy        int64           `sql:"type:bigint(20)"`     // 添加的管理员
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 展商扫码记录的潜在客户，同一展商同一观众只有一条，重复扫码时更新
+type ExhibitorLead struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    ExhibitorId     int64           `sql:"type:bigint(20)"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    UserId          int64           `sql:"type:bigint(20)"`     // 被扫码的观众
+    TicketNo        string          `sql:"type:varchar(32)"`    // 扫码的门票
+    ScannedBy       int64           `sql:"type:bigint(20)"`     // 最后扫码的工作人员
+    Notes           string          `sql:"type:varchar(1024)"`  // 备注
+    Rating          int             `sql:"type:smallint(6)"`    // 意向评级，1-5，0: 未评级
+    ConsentContact  int             `sql:"type:smallint(6)"`    // 观众同意展商查看联系方式，0: 否 1: 是
+    ConsentMarketing int            `sql:"type:smallint(6)"`    // 观众同意接收展商的推广信息，0: 否 1: 是
+    ConsentTime     time.Time       `sql:"type:datetime"`       // 最后一次确认授权的时间
+    ConsentRevoked  int             `sql:"type:smallint(6)"`    // 观众撤回了授权，撤回后只能由观众自己重新授权
+    ScanNum         int             `sql:"type:int(11)"`        // 扫码次数
+    LastScanTime    time.Time       `sql:"type:datetime"`
+    CreateTime      time.Time       `sql:"type:datetime"`       // 第一次扫码时间
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (staff *ExhibitorStaff) TableName() string {
+    return "pet.exhibitor_staff"
+}
+
+func (lead *ExhibitorLead) TableName() string {
+    return "pet.exhibitor_lead"
+}
+
+// 添加工作人员，已添加时忽略
+func AddExhibitorStaff(exhibitor_id, user_id, create_by int64) error {
+    err := PET_DB.Exec("INSERT IGNORE INTO pet.exhibitor_staff (exhibitor_id, user_id, create_by, create_time) VALUES (?, ?, ?, ?)",
+        exhibitor_id, user_id, create_by, time.Now()).Error
+    if nil != err {
+        utils.Logger.Error("add exhibitor staff error, exhibitor_id: %d, user_id: %d, error: %v", exhibitor_id, user_id, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+func RemoveExhibitorStaff(exhibitor_id, user_id int64) error {
+    err := PET_DB.Exec("DELETE FROM pet.exhibitor_staff WHERE exhibitor_id = ? AND user_id = ?", exhibitor_id, user_id).Error
+    if nil != err {
+        utils.Logger.Error("remove exhibitor staff error, exhibitor_id: %d, user_id: %d, error: %v", exhibitor_id, user_id, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+// 是否展商的工作人员
+func IsExhibitorStaff(exhibitor_id, user_id int64) (bool, error) {
+    var count int
+    err := PET_DB.Table(new(ExhibitorStaff).TableName()).Where("exhibitor_id = ? AND user_id = ?", exhibitor_id, user_id).
+        Count(&count).Error
+    if nil != err {
+        utils.Logger.Error("check exhibitor staff error, exhibitor_id: %d, user_id: %d, error: %v", exhibitor_id, user_id, err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return count > 0, nil
+}
+
+// 展商的工作人员
+func GetExhibitorStaffList(exhibitor_id int64) ([]ExhibitorStaff, error) {
+    var staff_list []ExhibitorStaff
+    err := PET_DB.Table(new(ExhibitorStaff).TableName()).Where("exhibitor_id = ?", exhibitor_id).Order("id").Find(&staff_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor staff list error, exhibitor_id: %d, error: %v", exhibitor_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return staff_list, nil
+}
+
+// 用户作为工作人员所属的展商
+func GetStaffExhibitorIds(user_id int64) ([]int64, error) {
+    var staff_list []ExhibitorStaff
+    err := PET_DB.Table(new(ExhibitorStaff).TableName()).Where("user_id = ?", user_id).Order("id").Find(&staff_list).Error
+    if nil != err {
+        utils.Logger.Error("get staff exhibitor ids error, user_id: %d, error: %v", user_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    ids := make([]int64, len(staff_list))
+    for i, staff := range staff_list {
+        ids[i] = staff.ExhibitorId
+    }
+    return ids, nil
+}
+
+/**
+ * 保存扫码记录，已扫过的观众更新扫码次数和授权
+ *
+ * 授权以最后一次扫码时观众的确认为准，观众撤回过授权时扫码不能重新授权，
+ * 备注和评级为空时保留原值
+ */
+func SaveLeadScan(lead *ExhibitorLead) error {
+    now := time.Now()
+    err := PET_DB.Exec("INSERT INTO pet.exhibitor_lead (exhibitor_id, edition_id, user_id, ticket_no, scanned_by, notes, rating, "+
+        "consent_contact, consent_marketing, consent_time, scan_num, last_scan_time, create_time, update_time) "+
+        "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?) ON DUPLICATE KEY UPDATE ticket_no = VALUES(ticket_no), "+
+        "scanned_by = VALUES(scanned_by), notes = IF(VALUES(notes) = '', notes, VALUES(notes)), "+
+        "rating = IF(VALUES(rating) = 0, rating, VALUES(rating)), "+
+        "consent_contact = IF(consent_revoked = 1, 0, VALUES(consent_contact)), "+
+        "consent_marketing = IF(consent_revoked = 1, 0, VALUES(consent_marketing)), "+
+        "consent_time = IF(consent_revoked = 1, consent_time, VALUES(consent_time)), scan_num = scan_num + 1, "+
+        "last_scan_time = VALUES(last_scan_time), update_time = VALUES(update_time)",
+        lead.ExhibitorId, lead.EditionId, lead.UserId, lead.TicketNo, lead.ScannedBy, lead.Notes, lead.Rating,
+        lead.ConsentContact, lead.ConsentMarketing, now, now, now, now).Error
+    if nil != err {
+        utils.Logger.Error("save lead scan error, exhibitor_id: %d, user_id: %d, error: %v", lead.ExhibitorId, lead.UserId, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = PET_DB.Table(lead.TableName()).Where("exhibitor_id = ? AND user_id = ?", lead.ExhibitorId, lead.UserId).
+        Limit(1).Find(lead).Error
+    if nil != err {
+        utils.Logger.Error("get lead after scan error, exhibitor_id: %d, user_id: %d, error: %v", lead.ExhibitorId, lead.UserId, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+// 获取展商的潜在客户，不是该展商的返回 found=false
+func (lead *ExhibitorLead) GetExhibitorLead(exhibitor_id, id int64) (found bool, err error) {
+    err = PET_DB.Table(lead.TableName()).Where("id = ? AND exhibitor_id = ?", id, exhibitor_id).Limit(1).Find(lead).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get exhibitor lead failed, exhibitor_id: %d, id: %d, error: %v", exhibitor_id, id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 修改备注和评级
+func (lead *ExhibitorLead) UpdateNotes(notes string, rating int) error {
+    now := time.Now()
+    err := PET_DB.Table(lead.TableName()).Where("id = ? AND exhibitor_id = ?", lead.Id, lead.ExhibitorId).
+        Updates(map[string]interface{}{"notes": notes, "rating": rating, "update_time": now}).Error
+    if nil != err {
+        utils.Logger.Error("update lead notes error, id: %d, error: %v", lead.Id, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    lead.Notes = notes
+    lead.Rating = rating
+    lead.UpdateTime = now
+    return nil
+}
+
+// 展商的潜在客户，按最后扫码时间倒序
+func GetExhibitorLeadListByPage(exhibitor_id int64, page_num, page_size int) (lead_list []ExhibitorLead, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(new(ExhibitorLead).TableName()).Where("exhibitor_id = ?", exhibitor_id)
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count exhibitor lead list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    err = query.Order("last_scan_time desc, id desc").Limit(page_size).Offset(offset).Find(&lead_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor lead list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+// 展商的全部潜在客户，导出时使用，最多返回limit条
+func GetExhibitorLeadList(exhibitor_id int64, limit int) ([]ExhibitorLead, error) {
+    var lead_list []ExhibitorLead
+    err := PET_DB.Table(new(ExhibitorLead).TableName()).Where("exhibitor_id = ?", exhibitor_id).
+        Order("id").Limit(limit).Find(&lead_list).Error
+    if nil != err {
+        utils.Logger.Error("get exhibitor lead list error, exhibitor_id: %d, error: %v", exhibitor_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return lead_list, nil
+}
+
+// 观众被哪些展商扫码记录
+func GetUserLeadList(user_id int64) ([]ExhibitorLead, error) {
+    var lead_list []ExhibitorLead
+    err := PET_DB.Table(new(ExhibitorLead).TableName()).Where("user_id = ?", user_id).
+        Order("last_scan_time desc").Find(&lead_list).Error
+    if nil != err {
+        utils.Logger.Error("get user lead list error, user_id: %d, error: %v", user_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return lead_list, nil
+}
+
+// 观众撤回对展商的授权，返回是否有记录被修改
+func RevokeLeadConsent(id, user_id int64) (bool, error) {
+    result := PET_DB.Table(new(ExhibitorLead).TableName()).Where("id = ? AND user_id = ?", id, user_id).
+        Updates(map[string]interface{}{
+            "consent_contact":      0,
+            "consent_marketing":    0,
+            "consent_revoked":      1,
+            "consent_time":         time.Now(),
+            "update_time":          time.Now(),
+        })
+    if nil != result.Error {
+        utils.Logger.Error("revoke lead consent error, id: %d, user_id: %d, error: %v", id, user_id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// 观众重新授权，返回是否有记录被修改
+func GrantLeadConsent(id, user_id int64, consent_contact, consent_marketing int) (bool, error) {
+    result := PET_DB.Table(new(ExhibitorLead).TableName()).Where("id = ? AND user_id = ?", id, user_id).
+        Updates(map[string]interface{}{
+            "consent_contact":      consent_contact,
+            "consent_marketing":    consent_marketing,
+            "consent_revoked":      0,
+            "consent_time":         time.Now(),
+            "update_time":          time.Now(),
+        })
+    if nil != result.Error {
+        utils.Logger.Error("grant lead consent error, id: %d, user_id: %d, error: %v", id, user_id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,103 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/16 20:10
+ */
+package model
+
+import (
+    "time"
+    "pet/utils"
+)
+
+// 收藏类型
+const (
+    FAVORITE_TYPE_EXHIBITOR     = 1     // 展商
+    FAVORITE_TYPE_ACTIVITY      = 2     // 活动
+    FAVORITE_TYPE_ARTICLE       = 3     // 文章
+)
+
+// 收藏表，同一用户同一对象只有一条记录
+type Favorite struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    UserId          int64           `sql:"type:bigint(20)"`
+    TargetType      int             `sql:"type:smallint(6)"`    // 类型，1: 展商 2: 活动 3: 文章
+    TargetId        int64           `sql:"type:bigint(20)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 对象的收藏数
+type FavoriteCount struct {
+    TargetId        int64
+    Num             int
+}
+
+func (favorite *Favorite) TableName() string {
+    return "pet.favorite"
+}
+
+func FavoriteTypeValid(target_type int) bool {
+    return target_type == FAVORITE_TYPE_EXHIBITOR || target_type == FAVORITE_TYPE_ACTIVITY ||
+        target_type == FAVORITE_TYPE_ARTICLE
+}
+
+// 收藏，已收藏时返回 added=false
+func AddFavorite(user_id int64, target_type int, target_id int64) (added bool, err error) {
+    result := PET_DB.Exec("INSERT IGNORE INTO pet.favorite (user_id, target_type, target_id, create_time) VALUES (?, ?, ?, ?)",
+        user_id, target_type, target_id, time.Now())
+    if nil != result.Error {
+        utils.Logger.Error("add favorite error, user_id: %d, target_type: %d, target_id: %d, error: %v",
+            user_id, target_type, target_id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected == 1, nil
+}
+
+// 取消收藏，未收藏时返回 removed=false
+func RemoveFavorite(user_id int64, target_type int, target_id int64) (removed bool, err error) {
+    result := PET_DB.Exec("DELETE FROM pet.favorite WHERE user_id = ? AND target_type = ? AND target_id = ?",
+        user_id, target_type, target_id)
+    if nil != result.Error {
+        utils.Logger.Error("remove favorite error, user_id: %d, target_type: %d, target_id: %d, error: %v",
+            user_id, target_type, target_id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// 用户的所有收藏，按收藏时间倒序
+func GetUserFavoriteList(user_id int64) ([]Favorite, error) {
+    var favorite_list []Favorite
+    err := PET_DB.Table(new(Favorite).TableName()).Where("user_id = ?", user_id).
+        Order("create_time desc, id desc").Find(&favorite_list).Error
+    if nil != err {
+        utils.Logger.Error("get user favorite list error, user_id: %d, error: %v", user_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return favorite_list, nil
+}
+
+// 对象的收藏数，没有被收藏的对象不返回
+func GetFavoriteCounts(target_type int, target_ids []int64) ([]FavoriteCount, error) {
+    var count_list []FavoriteCount
+    if len(target_ids) == 0 {
+        return count_list, nil
+    }
+    err := PET_DB.Table(new(Favorite).TableName()).Select("target_id, count(*) AS num").
+        Where("target_type = ? AND target_id IN (?)", target_type, target_ids).Group("target_id").Scan(&count_list).Error
+    if nil != err {
+        utils.Logger.Error("get favorite counts error, target_type: %d, error: %v", target_type, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return count_list, nil
+}
+
+// 缓存中没有的对象按数据库统计的收藏数补上，数据库中没有的是0
+func MergeFavoriteCounts(counts map[int64]int, missing []int64, count_list []FavoriteCount) {
+    for _, target_id := range missing {
+        counts[target_id] = 0
+    }
+    for _, count := range count_list {
+        counts[count.TargetId] = count.Num
+    }
+}
@@ -0,0 +1,316 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 21:30
+ */
+package model
+
+import (
+    "fmt"
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 订单状态
+const (
+    ORDER_STATUS_PENDING    = 1     // 待支付
+    ORDER_STATUS_PAID       = 2     // 已支付
+    ORDER_STATUS_CLOSED     = 3     // 已关闭，超时未支付或用户取消
+    ORDER_STATUS_REFUNDED   = 4     // 已退款
+)
+
+// 订单允许的状态变化
+// 关闭后仍可能收到支付成功的通知，以支付结果为准
+var orderTransitions = map[int][]int{
+    ORDER_STATUS_PENDING:   {ORDER_STATUS_PAID, ORDER_STATUS_CLOSED},
+    ORDER_STATUS_CLOSED:    {ORDER_STATUS_PAID},
+    ORDER_STATUS_PAID:      {ORDER_STATUS_REFUNDED},
+}
+
+func OrderCanTransit(from, to int) bool {
+    for _, status := range orderTransitions[from] {
+        if status == to {
+            return true
+        }
+    }
+    return false
+}
+
+// 门票订单
+type Order struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    OrderNo         string          `sql:"type:varchar(32)"`    // 订单号，支付时的商户订单号
+    UserId          int64           `sql:"type:bigint(20)"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    TotalFee        int             `sql:"type:int(11)"`        // 金额，单位分
+    RefundFee       int             `sql:"type:int(11)"`        // 已申请退款的金额，不包含退款失败的
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 待支付 2: 已支付 3: 已关闭 4: 已退款
+    PayChannel      string          `sql:"type:varchar(16)"`    // 支付渠道，wxpay, fake
+    PrepayId        string          `sql:"type:varchar(64)"`    // 预支付交易会话标识
+    TransactionId   string          `sql:"type:varchar(64)"`    // 支付平台的交易号
+    PaidTime        time.Time       `sql:"type:datetime"`
+    ExpireTime      time.Time       `sql:"type:datetime"`       // 超过此时间未支付自动关闭
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 订单明细
+type OrderItem struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    OrderId         int64           `sql:"type:bigint(20)"`
+    ProductId       int64           `sql:"type:bigint(20)"`
+    ProductName     string          `sql:"type:varchar(64)"`    // 下单时的商品名称
+    Price           int             `sql:"type:int(11)"`        // 下单时的单价，单位分
+    Quantity        int             `sql:"type:int(11)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (order *Order) TableName() string {
+    return "pet.ticket_order"
+}
+
+func (item *OrderItem) TableName() string {
+    return "pet.ticket_order_item"
+}
+
+// 通过订单号获取，不存在时返回 found=false
+func (order *Order) GetOrderByNo(order_no string) (found bool, err error) {
+    err = PET_DB.Table(order.TableName()).Where("order_no = ?", order_no).Limit(1).Find(order).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("order not found, order_no: %s", order_no)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get order failed, order_no: %s, error: %v", order_no, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 保存预支付交易会话标识
+func (order *Order) SavePrepayId(pay_channel, prepay_id string) error {
+    now := time.Now()
+    err := PET_DB.Table(order.TableName()).Where("id = ?", order.Id).
+        Updates(map[string]interface{}{"pay_channel": pay_channel, "prepay_id": prepay_id, "update_time": now}).Error
+    if nil != err {
+        utils.Logger.Error("save order prepay id error, order_no: %s, error: %v", order.OrderNo, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    order.PayChannel = pay_channel
+    order.PrepayId = prepay_id
+    order.UpdateTime = now
+    return nil
+}
+
+// 订单明细
+func GetOrderItemList(order_id int64) ([]OrderItem, error) {
+    var item_list []OrderItem
+    err := PET_DB.Table(new(OrderItem).TableName()).Where("order_id = ?", order_id).Order("id").Find(&item_list).Error
+    if nil != err {
+        utils.Logger.Error("get order item list error, order_id: %d, error: %v", order_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return item_list, nil
+}
+
+/**
+ * 创建订单，同时占用库存
+ *
+ * 通过带条件的 update 占用库存，并发下单不会超卖，任一商品库存不足时整单失败
+ */
+func CreateOrder(order *Order, items []OrderItem) error {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    for _, item := range items {
+        result := tx.Exec("UPDATE pet.ticket_product SET sold_num = sold_num + ? WHERE id = ? AND (stock = 0 OR sold_num + ? <= stock)",
+            item.Quantity, item.ProductId, item.Quantity)
+        if nil != result.Error {
+            tx.Rollback()
+            utils.Logger.Error("create order, update sold num error: %v", result.Error)
+            return utils.NewInternalError(utils.DbErrCode, result.Error)
+        }
+        if result.RowsAffected == 0 {
+            tx.Rollback()
+            return utils.NewInternalErrorByStr(utils.SoldOutErrCode, fmt.Sprintf("%s已售罄", item.ProductName))
+        }
+    }
+
+    order.Status = ORDER_STATUS_PENDING
+    order.CreateTime = now
+    order.UpdateTime = now
+    err := tx.Table(order.TableName()).Create(order).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("create order, save order error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    for i := range items {
+        items[i].OrderId = order.Id
+        items[i].CreateTime = now
+        err = tx.Table(items[i].TableName()).Create(&items[i]).Error
+        if nil != err {
+            tx.Rollback()
+            utils.Logger.Error("create order, save order item error: %v", err)
+            return utils.NewInternalError(utils.DbErrCode, err)
+        }
+    }
+    err = addOrderLog(tx, &OrderLog{OrderId: order.Id, OrderNo: order.OrderNo, Action: ORDER_ACTION_CREATE,
+        Status: order.Status, Amount: order.TotalFee, OperatorId: order.UserId})
+    if nil != err {
+        tx.Rollback()
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("create order, commit error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+// 订单商品的库存变化，delta为负时释放
+func updateOrderSoldNum(tx *gorm.DB, order_id int64, delta int) error {
+    return tx.Exec("UPDATE pet.ticket_product p, pet.ticket_order_item i SET p.sold_num = GREATEST(p.sold_num + i.quantity * ?, 0) "+
+        "WHERE i.order_id = ? AND p.id = i.product_id", delta, order_id).Error
+}
+
+/**
+ * 关闭未支付的订单并释放库存，返回是否本次关闭
+ *
+ * 通过带条件的 update 保证和支付通知并发时只有一个成功
+ */
+func CloseOrder(order *Order) (bool, error) {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    result := tx.Table(order.TableName()).Where("id = ? AND status = ?", order.Id, ORDER_STATUS_PENDING).
+        Updates(map[string]interface{}{"status": ORDER_STATUS_CLOSED, "update_time": now})
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("close order, update status error: %v", result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        _, err := order.GetOrderByNo(order.OrderNo)
+        return false, err
+    }
+
+    err := updateOrderSoldNum(tx, order.Id, -1)
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("close order, release sold num error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    err = addOrderLog(tx, &OrderLog{OrderId: order.Id, OrderNo: order.OrderNo, Action: ORDER_ACTION_CLOSE,
+        Status: ORDER_STATUS_CLOSED})
+    if nil != err {
+        tx.Rollback()
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("close order, commit error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    order.Status = ORDER_STATUS_CLOSED
+    order.UpdateTime = now
+    return true, nil
+}
+
+/**
+ * 订单支付成功，返回是否本次更新
+ *
+ * 已关闭的订单收到支付成功时也改为已支付，并重新占用库存，这时不检查库存上限
+ */
+func PayOrder(order *Order, transaction_id string, paid_time time.Time) (bool, error) {
+    now := time.Now()
+    values := map[string]interface{}{
+        "status":           ORDER_STATUS_PAID,
+        "transaction_id":   transaction_id,
+        "paid_time":        paid_time,
+        "update_time":      now,
+    }
+    tx := PET_DB.Begin()
+
+    remark := ""
+    result := tx.Table(order.TableName()).Where("id = ? AND status = ?", order.Id, ORDER_STATUS_PENDING).Updates(values)
+    if nil == result.Error && result.RowsAffected == 0 {
+        result = tx.Table(order.TableName()).Where("id = ? AND status = ?", order.Id, ORDER_STATUS_CLOSED).Updates(values)
+        if nil == result.Error && result.RowsAffected == 1 {
+            utils.Logger.Warning("closed order paid, order_no: %s, transaction_id: %s", order.OrderNo, transaction_id)
+            remark = "订单关闭后支付"
+            if err := updateOrderSoldNum(tx, order.Id, 1); nil != err {
+                result.Error = err
+            }
+        }
+    }
+    if nil == result.Error && result.RowsAffected == 1 {
+        result.Error = addOrderLog(tx, &OrderLog{OrderId: order.Id, OrderNo: order.OrderNo, Action: ORDER_ACTION_PAY,
+            Status: ORDER_STATUS_PAID, Amount: order.TotalFee, TransactionId: transaction_id, Remark: remark})
+    }
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("pay order, update status error: %v", result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        _, err := order.GetOrderByNo(order.OrderNo)
+        return false, err
+    }
+
+    err := tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("pay order, commit error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    order.Status = ORDER_STATUS_PAID
+    order.TransactionId = transaction_id
+    order.PaidTime = paid_time
+    order.UpdateTime = now
+    return true, nil
+}
+
+// 用户的订单列表，按下单时间倒序
+func GetUserOrderListByPage(user_id int64, page_num, page_size int) (order_list []Order, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(new(Order).TableName()).Where("user_id = ?", user_id)
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count order list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    err = query.Order("id desc").Limit(page_size).Offset(offset).Find(&order_list).Error
+    if nil != err {
+        utils.Logger.Error("get order list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+// 已超时的待支付订单
+func GetExpiredOrderList(now time.Time, limit int) ([]Order, error) {
+    var order_list []Order
+    err := PET_DB.Table(new(Order).TableName()).Where("status = ? AND expire_time < ?", ORDER_STATUS_PENDING, now).
+        Order("id").Limit(limit).Find(&order_list).Error
+    if nil != err {
+        utils.Logger.Error("get expired order list error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return order_list, nil
+}
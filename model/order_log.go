@@ -0,0 +1,62 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/19 20:30
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 订单操作
+const (
+    ORDER_ACTION_CREATE         = "create"          // 下单
+    ORDER_ACTION_PAY            = "pay"             // 支付成功
+    ORDER_ACTION_CLOSE          = "close"           // 关闭
+    ORDER_ACTION_REFUND_APPLY   = "refund_apply"    // 申请退款
+    ORDER_ACTION_REFUND_SUCCESS = "refund_success"  // 退款成功
+    ORDER_ACTION_REFUND_FAIL    = "refund_fail"     // 退款失败
+)
+
+// 订单流水，记录订单的每次资金和状态变化，用于财务对账
+type OrderLog struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    OrderId         int64           `sql:"type:bigint(20)"`
+    OrderNo         string          `sql:"type:varchar(32)"`
+    RefundNo        string          `sql:"type:varchar(32)"`    // 退款相关操作的退款单号
+    Action          string          `sql:"type:varchar(16)"`    // 操作，create, pay, close, refund_apply, refund_success, refund_fail
+    Status          int             `sql:"type:smallint(6)"`    // 操作后的订单状态
+    Amount          int             `sql:"type:int(11)"`        // 涉及的金额，单位分
+    TransactionId   string          `sql:"type:varchar(64)"`    // 支付平台的交易号或退款单号
+    OperatorId      int64           `sql:"type:bigint(20)"`     // 操作人，0: 系统
+    Remark          string          `sql:"type:varchar(255)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (log *OrderLog) TableName() string {
+    return "pet.ticket_order_log"
+}
+
+// 在订单的事务中记录流水，和状态变化一起提交
+func addOrderLog(tx *gorm.DB, log *OrderLog) error {
+    log.CreateTime = time.Now()
+    err := tx.Table(log.TableName()).Create(log).Error
+    if nil != err {
+        utils.Logger.Error("add order log error, order_no: %s, action: %s, error: %v", log.OrderNo, log.Action, err)
+    }
+    return err
+}
+
+// 订单的流水，按时间顺序
+func GetOrderLogList(order_id int64) ([]OrderLog, error) {
+    var log_list []OrderLog
+    err := PET_DB.Table(new(OrderLog).TableName()).Where("order_id = ?", order_id).Order("id").Find(&log_list).Error
+    if nil != err {
+        utils.Logger.Error("get order log list error, order_id: %d, error: %v", order_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return log_list, nil
+}
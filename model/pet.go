@@ -5,12 +5,298 @@
  */
 package model
 
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 宠物种类
+const (
+    PET_SPECIES_DOG         = 1     // 狗
+    PET_SPECIES_CAT         = 2     // 猫
+    PET_SPECIES_SMALL       = 3     // 小宠，兔子、仓鼠等
+    PET_SPECIES_BIRD        = 4     // 鸟
+    PET_SPECIES_REPTILE     = 5     // 爬宠
+    PET_SPECIES_AQUATIC     = 6     // 水族
+)
+
+var PetSpeciesNames = map[int]string{
+    PET_SPECIES_DOG:        "狗",
+    PET_SPECIES_CAT:        "猫",
+    PET_SPECIES_SMALL:      "小宠",
+    PET_SPECIES_BIRD:       "鸟",
+    PET_SPECIES_REPTILE:    "爬宠",
+    PET_SPECIES_AQUATIC:    "水族",
+}
+
 // 宠物表
 type Pet struct {
     Id              int64           `gorm:"primary_key"; sql:"AUTO_INCREMENT"`
+    UserId          int64           `sql:"type:bigint(20)"`     // 主人
     Name            string          `sql:"type:varchar(128)"`   // 宠物名称
+    Species         int             `sql:"type:smallint(6)"`    // 种类，见 PET_SPECIES_*
+    BreedId         int64           `sql:"type:bigint(20)"`     // 品种，0: 未知
+    Birthday        time.Time       `sql:"type:date"`           // 出生日期，未填写时为零值
+    Gender          int             `sql:"type:smallint(6)"`    // 性别，0: 未知 1: 公 2: 母
+    Weight          int             `sql:"type:int(11)"`        // 体重，单位克，0: 未知
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 疫苗接种记录
+type PetVaccination struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    PetId           int64           `sql:"type:bigint(20)"`
+    Vaccine         string          `sql:"type:varchar(64)"`    // 疫苗名称
+    VaccinateDate   time.Time       `sql:"type:date"`           // 接种日期
+    ExpireDate      time.Time       `sql:"type:date"`           // 有效期至，未填写时为零值
+    Hospital        string          `sql:"type:varchar(128)"`   // 接种机构
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 宠物照片
+type PetPhoto struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    PetId           int64           `sql:"type:bigint(20)"`
+    Url             string          `sql:"type:varchar(255)"`
+    CreateTime      time.Time       `sql:"type:datetime"`
 }
 
 func (pet *Pet) TableName() string {
     return "pet.pet"
-}
\ No newline at end of file
+}
+
+func (pet *Pet) Create() error {
+    pet.CreateTime = time.Now()
+    pet.UpdateTime = pet.CreateTime
+
+    err := PET_DB.Table(pet.TableName()).Create(pet).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create pet error: %v", err)
+        return err
+    }
+    return nil
+}
+
+func (pet *Pet) Save() error {
+    pet.UpdateTime = time.Now()
+    if pet.Id == 0 {
+        pet.CreateTime = pet.UpdateTime
+    }
+
+    err := PET_DB.Table(pet.TableName()).Save(pet).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("save pet error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 锁定宠物，报名比赛、修改种类和删除宠物互斥
+func lockPet(tx *gorm.DB, pet_id int64) (*Pet, bool, error) {
+    pet := new(Pet)
+    err := tx.Raw("SELECT * FROM pet.pet WHERE id = ? FOR UPDATE", pet_id).Scan(pet).Error
+    if gorm.RecordNotFound == err {
+        return nil, false, nil
+    }
+    if nil != err {
+        utils.Logger.Error("lock pet error, id: %d, error: %v", pet_id, err)
+        return nil, false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return pet, true, nil
+}
+
+// 宠物报名中的比赛数
+func countPetEntries(tx *gorm.DB, pet_id int64) (int, error) {
+    var count int
+    err := tx.Table(new(CompetitionEntry).TableName()).Where("pet_id = ? AND status = ?", pet_id, ENTRY_STATUS_ENTERED).
+        Count(&count).Error
+    if nil != err {
+        utils.Logger.Error("count pet entries error, pet_id: %d, error: %v", pet_id, err)
+        return 0, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return count, nil
+}
+
+/**
+ * 修改宠物资料，返回是否修改成功
+ *
+ * 比赛按种类报名，宠物报名中时不能修改种类，返回false
+ */
+func (pet *Pet) Update() (bool, error) {
+    tx := PET_DB.Begin()
+
+    current, found, err := lockPet(tx, pet.Id)
+    var count int
+    if nil == err && found && current.Species != pet.Species {
+        count, err = countPetEntries(tx, pet.Id)
+    }
+    if nil != err || count > 0 {
+        tx.Rollback()
+        return false, err
+    }
+
+    pet.UpdateTime = time.Now()
+    err = tx.Table(pet.TableName()).Save(pet).Error
+    if nil != err {
+        tx.Rollback()
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update pet error, id: %d, error: %v", pet.Id, err)
+        return false, err
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update pet, commit error, id: %d, error: %v", pet.Id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+/**
+ * 删除宠物，同时删除疫苗记录和照片，返回是否删除成功
+ *
+ * 宠物报名中时不能删除，返回false，需要先退赛
+ */
+func (pet *Pet) Delete() (bool, error) {
+    tx := PET_DB.Begin()
+
+    _, _, err := lockPet(tx, pet.Id)
+    var count int
+    if nil == err {
+        count, err = countPetEntries(tx, pet.Id)
+    }
+    if nil != err || count > 0 {
+        tx.Rollback()
+        return false, err
+    }
+
+    err = tx.Table(pet.TableName()).Where("id = ?", pet.Id).Delete(pet).Error
+    if nil == err {
+        err = tx.Table(new(PetVaccination).TableName()).Where("pet_id = ?", pet.Id).Delete(PetVaccination{}).Error
+    }
+    if nil == err {
+        err = tx.Table(new(PetPhoto).TableName()).Where("pet_id = ?", pet.Id).Delete(PetPhoto{}).Error
+    }
+    if nil != err {
+        tx.Rollback()
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("delete pet error, id: %d, error: %v", pet.Id, err)
+        return false, err
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("delete pet, commit error, id: %d, error: %v", pet.Id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 获取宠物，不存在时返回 found=false
+func (pet *Pet) GetPetById(id int64) (found bool, err error) {
+    err = PET_DB.Table(pet.TableName()).Where("id = ?", id).Limit(1).Find(pet).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("pet not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get pet failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 用户的宠物列表，按添加时间排序
+func GetUserPetList(user_id int64) ([]Pet, error) {
+    var pet_list []Pet
+    err := PET_DB.Table(new(Pet).TableName()).Where("user_id = ?", user_id).Order("id").Find(&pet_list).Error
+    if nil != err {
+        utils.Logger.Error("get user pet list error, user_id: %d, error: %v", user_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return pet_list, nil
+}
+
+func (vaccination *PetVaccination) TableName() string {
+    return "pet.pet_vaccination"
+}
+
+func (vaccination *PetVaccination) Create() error {
+    vaccination.CreateTime = time.Now()
+
+    err := PET_DB.Table(vaccination.TableName()).Create(vaccination).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create pet vaccination error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 删除宠物的疫苗记录，返回是否删除
+func DeletePetVaccination(pet_id, id int64) (bool, error) {
+    result := PET_DB.Table(new(PetVaccination).TableName()).Where("id = ? AND pet_id = ?", id, pet_id).Delete(PetVaccination{})
+    if nil != result.Error {
+        utils.Logger.Error("delete pet vaccination error, id: %d, error: %v", id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// 宠物的疫苗记录，按接种日期倒序
+func GetPetVaccinationList(pet_id int64) ([]PetVaccination, error) {
+    var vaccination_list []PetVaccination
+    err := PET_DB.Table(new(PetVaccination).TableName()).Where("pet_id = ?", pet_id).
+        Order("vaccinate_date desc, id desc").Find(&vaccination_list).Error
+    if nil != err {
+        utils.Logger.Error("get pet vaccination list error, pet_id: %d, error: %v", pet_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return vaccination_list, nil
+}
+
+func (photo *PetPhoto) TableName() string {
+    return "pet.pet_photo"
+}
+
+func (photo *PetPhoto) Create() error {
+    photo.CreateTime = time.Now()
+
+    err := PET_DB.Table(photo.TableName()).Create(photo).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create pet photo error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 删除宠物的照片，返回是否删除
+func DeletePetPhoto(pet_id, id int64) (bool, error) {
+    result := PET_DB.Table(new(PetPhoto).TableName()).Where("id = ? AND pet_id = ?", id, pet_id).Delete(PetPhoto{})
+    if nil != result.Error {
+        utils.Logger.Error("delete pet photo error, id: %d, error: %v", id, result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// 宠物的照片，按上传顺序，第一张为封面
+func GetPetPhotoList(pet_ids []int64) ([]PetPhoto, error) {
+    var photo_list []PetPhoto
+    if len(pet_ids) == 0 {
+        return photo_list, nil
+    }
+    err := PET_DB.Table(new(PetPhoto).TableName()).Where("pet_id IN (?)", pet_ids).Order("id").Find(&photo_list).Error
+    if nil != err {
+        utils.Logger.Error("get pet photo list error, pet_ids: %v, error: %v", pet_ids, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return photo_list, nil
+}
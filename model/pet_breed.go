@@ -0,0 +1,54 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/10 21:05
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 宠物品种字典，由运营维护
+type PetBreed struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    Species         int             `sql:"type:smallint(6)"`    // 种类，见 PET_SPECIES_*
+    Name            string          `sql:"type:varchar(64)"`    // 品种名称
+    Sort            int             `sql:"type:int(11)"`        // 排序，从小到大
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (breed *PetBreed) TableName() string {
+    return "pet.pet_breed"
+}
+
+// 获取品种，不存在时返回 found=false
+func (breed *PetBreed) GetPetBreedById(id int64) (found bool, err error) {
+    err = PET_DB.Table(breed.TableName()).Where("id = ?", id).Limit(1).Find(breed).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("pet breed not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get pet breed failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 品种列表，species为0时返回所有种类
+func GetPetBreedList(species int) ([]PetBreed, error) {
+    var breed_list []PetBreed
+    query := PET_DB.Table(new(PetBreed).TableName())
+    if species != 0 {
+        query = query.Where("species = ?", species)
+    }
+    err := query.Order("species, sort, id").Find(&breed_list).Error
+    if nil != err {
+        utils.Logger.Error("get pet breed list error, species: %d, error: %v", species, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return breed_list, nil
+}
@@ -0,0 +1,159 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/15 20:30
+ */
+package model
+
+import (
+    "time"
+    "pet/utils"
+)
+
+// 投票状态
+const (
+    VOTE_STATUS_VALID       = 1     // 有效
+    VOTE_STATUS_VOID        = 2     // 已作废
+)
+
+// 可疑投票标记
+const (
+    VOTE_FLAG_IP_BURST      = "ip_burst"        // 同一ip短时间内大量投票
+    VOTE_FLAG_DEVICE_BURST  = "device_burst"    // 同一设备短时间内大量投票
+    VOTE_FLAG_NEW_ACCOUNT   = "new_account"     // 新注册账号
+)
+
+// 人气投票记录
+type PetVote struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    UserId          int64           `sql:"type:bigint(20)"`
+    PetId           int64           `sql:"type:bigint(20)"`
+    Ip              string          `sql:"type:varchar(64)"`
+    DeviceId        string          `sql:"type:varchar(64)"`
+    FraudFlags      string          `sql:"type:varchar(64)"`    // 可疑标记，逗号分隔，为空时正常
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 有效 2: 已作废
+    VoidBy          int64           `sql:"type:bigint(20)"`     // 作废的管理员
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 宠物的有效票数
+type PetVoteCount struct {
+    PetId           int64
+    Votes           int
+}
+
+func (vote *PetVote) TableName() string {
+    return "pet.pet_vote"
+}
+
+func (vote *PetVote) Create() error {
+    vote.Status = VOTE_STATUS_VALID
+    vote.CreateTime = time.Now()
+    vote.UpdateTime = vote.CreateTime
+
+    err := PET_DB.Table(vote.TableName()).Create(vote).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create pet vote error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 分页获取有效的可疑投票，按时间倒序
+func GetSuspiciousVoteListByPage(page_num, page_size int) (vote_list []PetVote, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(new(PetVote).TableName()).Where("status = ? AND fraud_flags != ''", VOTE_STATUS_VALID)
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count suspicious vote list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    query = query.Order("id desc").Limit(page_size).Offset(offset)
+
+    err = query.Find(&vote_list).Error
+    if nil != err {
+        utils.Logger.Error("get suspicious vote list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+// 作废投票，返回作废的条数
+func VoidPetVotes(vote_ids []int64, operator_id int64) (int64, error) {
+    result := PET_DB.Table(new(PetVote).TableName()).Where("id IN (?) AND status = ?", vote_ids, VOTE_STATUS_VALID).
+        Updates(map[string]interface{}{"status": VOTE_STATUS_VOID, "void_by": operator_id, "update_time": time.Now()})
+    if nil != result.Error {
+        utils.Logger.Error("void pet votes error, vote_ids: %v, error: %v", vote_ids, result.Error)
+        return 0, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    return result.RowsAffected, nil
+}
+
+/**
+ * 按统计时长内的ip、设备投票次数和注册时间返回可疑标记
+ *
+ * 次数为0表示没有统计，不会标记
+ */
+func VoteFraudFlags(ip_times, device_times int, create_time, now time.Time, setting utils.VoteConfig) []string {
+    var flags []string
+    if ip_times > setting.IpBurstMax {
+        flags = append(flags, VOTE_FLAG_IP_BURST)
+    }
+    if device_times > setting.DeviceBurstMax {
+        flags = append(flags, VOTE_FLAG_DEVICE_BURST)
+    }
+    if now.Sub(create_time) < time.Duration(setting.NewAccountHours)*time.Hour {
+        flags = append(flags, VOTE_FLAG_NEW_ACCOUNT)
+    }
+    return flags
+}
+
+// 当前最大的投票id，重建排行榜时作为快照
+func GetMaxPetVoteId() (int64, error) {
+    var res struct {
+        MaxId   int64
+    }
+    err := PET_DB.Table(new(PetVote).TableName()).Select("IFNULL(MAX(id), 0) AS max_id").Scan(&res).Error
+    if nil != err {
+        utils.Logger.Error("get max pet vote id error: %v", err)
+        return 0, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return res.MaxId, nil
+}
+
+// 所有宠物的有效票数，只统计id不大于max_id的投票，已删除的宠物不统计
+func GetPetVoteCounts(max_id int64) ([]PetVoteCount, error) {
+    var count_list []PetVoteCount
+    err := PET_DB.Table(new(PetVote).TableName()).Select("pet_id, count(*) AS votes").
+        Where("status = ? AND id <= ? AND pet_id IN (SELECT id FROM pet.pet)", VOTE_STATUS_VALID, max_id).
+        Group("pet_id").Scan(&count_list).Error
+    if nil != err {
+        utils.Logger.Error("get pet vote counts error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return count_list, nil
+}
+
+// 宠物是否报名了比赛，报名了比赛的宠物才能被投票
+func PetHasCompetitionEntry(pet_id int64) (bool, error) {
+    var count int
+    err := PET_DB.Table(new(CompetitionEntry).TableName()).Where("pet_id = ? AND status = ?", pet_id, ENTRY_STATUS_ENTERED).
+        Count(&count).Error
+    if nil != err {
+        utils.Logger.Error("count pet competition entry error, pet_id: %d, error: %v", pet_id, err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return count > 0, nil
+}
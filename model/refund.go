@@ -0,0 +1,289 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/19 21:00
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 退款状态
+const (
+    REFUND_STATUS_PROCESSING    = 1     // 退款中，等待支付平台的退款通知
+    REFUND_STATUS_SUCCESS       = 2     // 退款成功
+    REFUND_STATUS_FAILED        = 3     // 退款失败，门票恢复有效
+)
+
+// 退款来源
+const (
+    REFUND_SOURCE_USER          = 1     // 用户申请
+    REFUND_SOURCE_ADMIN         = 2     // 管理员操作
+)
+
+// 订单退款，一个订单可以多次部分退款，每次退款对应若干张门票
+type Refund struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    RefundNo        string          `sql:"type:varchar(32)"`    // 退款单号，退款时的商户退款单号
+    OrderId         int64           `sql:"type:bigint(20)"`
+    OrderNo         string          `sql:"type:varchar(32)"`
+    UserId          int64           `sql:"type:bigint(20)"`     // 订单的用户
+    RefundFee       int             `sql:"type:int(11)"`        // 退款金额，单位分
+    TicketNum       int             `sql:"type:int(11)"`        // 退款的门票数量
+    Reason          string          `sql:"type:varchar(255)"`
+    Source          int             `sql:"type:smallint(6)"`    // 来源，1: 用户申请 2: 管理员操作
+    OperatorId      int64           `sql:"type:bigint(20)"`
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 退款中 2: 退款成功 3: 退款失败
+    RefundId        string          `sql:"type:varchar(64)"`    // 支付平台的退款单号
+    SuccessTime     time.Time       `sql:"type:datetime"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (refund *Refund) TableName() string {
+    return "pet.ticket_refund"
+}
+
+// 通过退款单号获取，不存在时返回 found=false
+func (refund *Refund) GetRefundByNo(refund_no string) (found bool, err error) {
+    err = PET_DB.Table(refund.TableName()).Where("refund_no = ?", refund_no).Limit(1).Find(refund).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("refund not found, refund_no: %s", refund_no)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get refund failed, refund_no: %s, error: %v", refund_no, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 订单的退款列表
+func GetOrderRefundList(order_id int64) ([]Refund, error) {
+    var refund_list []Refund
+    err := PET_DB.Table(new(Refund).TableName()).Where("order_id = ?", order_id).Order("id").Find(&refund_list).Error
+    if nil != err {
+        utils.Logger.Error("get order refund list error, order_id: %d, error: %v", order_id, err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return refund_list, nil
+}
+
+// 已申请但未拿到支付平台退款单号的退款，申请时结果未知，需要用原退款单号重试
+func GetUnsentRefundList(before time.Time, last_id int64, limit int) ([]Refund, error) {
+    var refund_list []Refund
+    err := PET_DB.Table(new(Refund).TableName()).
+        Where("status = ? AND refund_id = '' AND create_time < ? AND id > ?", REFUND_STATUS_PROCESSING, before, last_id).
+        Order("id").Limit(limit).Find(&refund_list).Error
+    if nil != err {
+        utils.Logger.Error("get unsent refund list error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return refund_list, nil
+}
+
+/**
+ * 申请退款，同时作废退款的门票
+ *
+ * 订单已申请退款的金额不能超过订单金额，门票需要有效且未入场，
+ * 都通过带条件的 update 保证并发申请时不会重复退款
+ */
+func ApplyRefund(order *Order, refund *Refund, ticket_ids []int64) error {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    result := tx.Exec("UPDATE pet.ticket_order SET refund_fee = refund_fee + ?, update_time = ? "+
+        "WHERE id = ? AND status = ? AND refund_fee + ? <= total_fee",
+        refund.RefundFee, now, order.Id, ORDER_STATUS_PAID, refund.RefundFee)
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("apply refund, update order refund fee error: %v", result.Error)
+        return utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        return utils.NewInternalErrorByStr(utils.OrderStatusErrCode, "订单当前状态不能退款")
+    }
+
+    refund.OrderId = order.Id
+    refund.OrderNo = order.OrderNo
+    refund.UserId = order.UserId
+    refund.TicketNum = len(ticket_ids)
+    refund.Status = REFUND_STATUS_PROCESSING
+    refund.CreateTime = now
+    refund.UpdateTime = now
+    err := tx.Table(refund.TableName()).Create(refund).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("apply refund, save refund error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    result = tx.Table("pet.ticket").
+        Where("id IN (?) AND order_id = ? AND status = ? AND checkin_gate = ''", ticket_ids, order.Id, TICKET_STATUS_VALID).
+        Updates(map[string]interface{}{"status": TICKET_STATUS_VOID, "refund_id": refund.Id, "update_time": now})
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("apply refund, void ticket error: %v", result.Error)
+        return utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected != int64(len(ticket_ids)) {
+        tx.Rollback()
+        return utils.NewInternalErrorByStr(utils.TicketInvalidErrCode, "门票已入场或已退款")
+    }
+
+    err = addOrderLog(tx, &OrderLog{OrderId: order.Id, OrderNo: order.OrderNo, RefundNo: refund.RefundNo,
+        Action: ORDER_ACTION_REFUND_APPLY, Status: order.Status, Amount: refund.RefundFee,
+        OperatorId: refund.OperatorId, Remark: refund.Reason})
+    if nil != err {
+        tx.Rollback()
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("apply refund, commit error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    order.RefundFee += refund.RefundFee
+    order.UpdateTime = now
+    return nil
+}
+
+// 保存支付平台的退款单号
+func (refund *Refund) SaveRefundId(refund_id string) error {
+    now := time.Now()
+    err := PET_DB.Table(refund.TableName()).Where("id = ?", refund.Id).
+        Updates(map[string]interface{}{"refund_id": refund_id, "update_time": now}).Error
+    if nil != err {
+        utils.Logger.Error("save refund id error, refund_no: %s, error: %v", refund.RefundNo, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    refund.RefundId = refund_id
+    refund.UpdateTime = now
+    return nil
+}
+
+/**
+ * 退款成功，返回是否本次更新
+ *
+ * 释放退款门票占用的库存，订单金额全部退款成功时订单改为已退款
+ */
+func SucceedRefund(refund *Refund, refund_id string, success_time time.Time) (bool, error) {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    result := tx.Table(refund.TableName()).Where("id = ? AND status = ?", refund.Id, REFUND_STATUS_PROCESSING).
+        Updates(map[string]interface{}{
+            "status":       REFUND_STATUS_SUCCESS,
+            "refund_id":    refund_id,
+            "success_time": success_time,
+            "update_time":  now,
+        })
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("succeed refund, update status error: %v", result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        _, err := refund.GetRefundByNo(refund.RefundNo)
+        return false, err
+    }
+
+    err := tx.Exec("UPDATE pet.ticket_product p, (SELECT product_id, COUNT(*) AS num FROM pet.ticket WHERE refund_id = ? GROUP BY product_id) t "+
+        "SET p.sold_num = GREATEST(p.sold_num - t.num, 0) WHERE p.id = t.product_id", refund.Id).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("succeed refund, release sold num error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    result = tx.Exec("UPDATE pet.ticket_order SET status = ?, update_time = ? WHERE id = ? AND status = ? "+
+        "AND total_fee = (SELECT SUM(refund_fee) FROM pet.ticket_refund WHERE order_id = ? AND status = ?)",
+        ORDER_STATUS_REFUNDED, now, refund.OrderId, ORDER_STATUS_PAID, refund.OrderId, REFUND_STATUS_SUCCESS)
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("succeed refund, update order status error: %v", result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    order_status := ORDER_STATUS_PAID
+    if result.RowsAffected == 1 {
+        order_status = ORDER_STATUS_REFUNDED
+    }
+
+    err = addOrderLog(tx, &OrderLog{OrderId: refund.OrderId, OrderNo: refund.OrderNo, RefundNo: refund.RefundNo,
+        Action: ORDER_ACTION_REFUND_SUCCESS, Status: order_status, Amount: refund.RefundFee, TransactionId: refund_id})
+    if nil != err {
+        tx.Rollback()
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("succeed refund, commit error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    refund.Status = REFUND_STATUS_SUCCESS
+    refund.RefundId = refund_id
+    refund.SuccessTime = success_time
+    refund.UpdateTime = now
+    return true, nil
+}
+
+/**
+ * 退款失败，返回是否本次更新
+ *
+ * 退回订单的已申请退款金额，退款的门票恢复有效，可以重新申请
+ */
+func FailRefund(refund *Refund, remark string) (bool, error) {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    result := tx.Table(refund.TableName()).Where("id = ? AND status = ?", refund.Id, REFUND_STATUS_PROCESSING).
+        Updates(map[string]interface{}{"status": REFUND_STATUS_FAILED, "update_time": now})
+    if nil != result.Error {
+        tx.Rollback()
+        utils.Logger.Error("fail refund, update status error: %v", result.Error)
+        return false, utils.NewInternalError(utils.DbErrCode, result.Error)
+    }
+    if result.RowsAffected == 0 {
+        tx.Rollback()
+        _, err := refund.GetRefundByNo(refund.RefundNo)
+        return false, err
+    }
+
+    err := tx.Exec("UPDATE pet.ticket_order SET refund_fee = GREATEST(refund_fee - ?, 0), update_time = ? WHERE id = ?",
+        refund.RefundFee, now, refund.OrderId).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("fail refund, update order refund fee error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    err = tx.Table("pet.ticket").Where("refund_id = ? AND status = ?", refund.Id, TICKET_STATUS_VOID).
+        Updates(map[string]interface{}{"status": TICKET_STATUS_VALID, "refund_id": 0, "update_time": now}).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("fail refund, restore ticket error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = addOrderLog(tx, &OrderLog{OrderId: refund.OrderId, OrderNo: refund.OrderNo, RefundNo: refund.RefundNo,
+        Action: ORDER_ACTION_REFUND_FAIL, Status: ORDER_STATUS_PAID, Amount: refund.RefundFee, Remark: remark})
+    if nil != err {
+        tx.Rollback()
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("fail refund, commit error: %v", err)
+        return false, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    refund.Status = REFUND_STATUS_FAILED
+    refund.UpdateTime = now
+    return true, nil
+}
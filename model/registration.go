@@ -0,0 +1,262 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/20 20:30
+ */
+package model
+
+import (
+    "strings"
+    "time"
+    "unicode/utf8"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 团体登记任务状态
+const (
+    REGISTRATION_JOB_RUNNING    = 1     // 处理中
+    REGISTRATION_JOB_FINISHED   = 2     // 已完成
+    REGISTRATION_JOB_FAILED     = 3     // 异常中断，已处理的行保留
+)
+
+// 团体登记每行的结果
+const (
+    REGISTRATION_ROW_CREATED    = 1     // 已注册并发放门票
+    REGISTRATION_ROW_DUPLICATE  = 2     // 电话号码已注册或表格中重复
+    REGISTRATION_ROW_FAILED     = 3     // 数据错误或注册失败
+)
+
+// 团体登记任务，行业协会、宠物俱乐部上传表格批量登记观众
+type RegistrationJob struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    JobNo           string          `sql:"type:varchar(32)"`
+    OperatorId      int64           `sql:"type:bigint(20)"`     // 上传的用户
+    EditionId       int64           `sql:"type:bigint(20)"`     // 发放门票的届次
+    Filename        string          `sql:"type:varchar(255)"`
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 处理中 2: 已完成 3: 异常中断
+    TotalNum        int             `sql:"type:int(11)"`        // 数据行数
+    ProcessedNum    int             `sql:"type:int(11)"`        // 已处理行数
+    CreatedNum      int             `sql:"type:int(11)"`
+    DuplicateNum    int             `sql:"type:int(11)"`
+    FailNum         int             `sql:"type:int(11)"`
+    ErrMsg          string          `sql:"type:varchar(255)"`   // 异常中断的原因
+    FinishTime      time.Time       `sql:"type:datetime"`
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+// 团体登记每行的处理结果
+type RegistrationRow struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    JobId           int64           `sql:"type:bigint(20)"`
+    RowNum          int             `sql:"type:int(11)"`        // 表格中的行号，从1开始
+    Name            string          `sql:"type:varchar(128)"`
+    Phone           string          `sql:"type:varchar(64)"`    // 校验通过时为E.164格式
+    Email           string          `sql:"type:varchar(128)"`
+    Status          int             `sql:"type:smallint(6)"`    // 结果，1: 已注册 2: 重复 3: 失败
+    UserId          int64           `sql:"type:bigint(20)"`     // 新注册或已存在的用户
+    TicketNo        string          `sql:"type:varchar(32)"`    // 发放的门票
+    Reason          string          `sql:"type:varchar(255)"`   // 重复或失败的原因
+    CreateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (job *RegistrationJob) TableName() string {
+    return "pet.registration_job"
+}
+
+func (row *RegistrationRow) TableName() string {
+    return "pet.registration_row"
+}
+
+func (job *RegistrationJob) Create() error {
+    job.Status = REGISTRATION_JOB_RUNNING
+    job.CreateTime = time.Now()
+    job.UpdateTime = job.CreateTime
+
+    err := PET_DB.Table(job.TableName()).Create(job).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create registration job error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 通过任务编号获取，不存在时返回 found=false
+func (job *RegistrationJob) GetJobByNo(job_no string) (found bool, err error) {
+    err = PET_DB.Table(job.TableName()).Where("job_no = ?", job_no).Limit(1).Find(job).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("registration job not found, job_no: %s", job_no)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get registration job failed, job_no: %s, error: %v", job_no, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 保存处理进度和各结果的数量
+func (job *RegistrationJob) SaveProgress() error {
+    job.UpdateTime = time.Now()
+    values := map[string]interface{}{
+        "status":           job.Status,
+        "processed_num":    job.ProcessedNum,
+        "created_num":      job.CreatedNum,
+        "duplicate_num":    job.DuplicateNum,
+        "fail_num":         job.FailNum,
+        "err_msg":          job.ErrMsg,
+        "update_time":      job.UpdateTime,
+    }
+    if job.Status != REGISTRATION_JOB_RUNNING {
+        job.FinishTime = job.UpdateTime
+        values["finish_time"] = job.FinishTime
+    }
+    err := PET_DB.Table(job.TableName()).Where("id = ?", job.Id).Updates(values).Error
+    if nil != err {
+        utils.Logger.Error("save registration job progress error, job_no: %s, error: %v", job.JobNo, err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+// 任务列表，operator_id为0时返回所有人的
+func GetRegistrationJobListByPage(operator_id int64, page_num, page_size int) (job_list []RegistrationJob, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(new(RegistrationJob).TableName())
+    if operator_id != 0 {
+        query = query.Where("operator_id = ?", operator_id)
+    }
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count registration job list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    err = query.Order("id desc").Limit(page_size).Offset(offset).Find(&job_list).Error
+    if nil != err {
+        utils.Logger.Error("get registration job list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+func (row *RegistrationRow) Create() error {
+    row.CreateTime = time.Now()
+    err := PET_DB.Table(row.TableName()).Create(row).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create registration row error, job_id: %d, row: %d, error: %v", row.JobId, row.RowNum, err)
+        return err
+    }
+    return nil
+}
+
+// 任务每行的结果，status为0时返回全部
+func GetRegistrationRowListByPage(job_id int64, status, page_num, page_size int) (row_list []RegistrationRow, total_num int, err error) {
+    if page_size < 0 {
+        page_size = 10
+    }
+
+    offset := (page_num - 1) * page_size
+    if offset < 0 {
+        offset = 0
+    }
+
+    query := PET_DB.Table(new(RegistrationRow).TableName()).Where("job_id = ?", job_id)
+    if status != 0 {
+        query = query.Where("status = ?", status)
+    }
+    if err2 := query.Count(&total_num).Error; nil != err2 {
+        utils.Logger.Error("count registration row list err: %v", err2)
+        err = utils.NewInternalError(utils.DbErrCode, err2)
+        return
+    }
+
+    err = query.Order("row_num").Limit(page_size).Offset(offset).Find(&row_list).Error
+    if nil != err {
+        utils.Logger.Error("get registration row list by page error :%s\n", err.Error())
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        return
+    }
+    return
+}
+
+// 在一个事务中注册用户并发放门票，任一失败时都不保存
+func RegisterUserWithTicket(user_info *User, ticket *Ticket) error {
+    now := time.Now()
+    tx := PET_DB.Begin()
+
+    user_info.CreateTime = now
+    user_info.UpdateTime = now
+    user_info.LastLogin = now
+    err := tx.Table(user_info.TableName()).Create(user_info).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("register user with ticket, create user error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    ticket.UserId = user_info.UserId
+    ticket.CreateTime = now
+    ticket.UpdateTime = now
+    err = tx.Table(ticket.TableName()).Create(ticket).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("register user with ticket, create ticket error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("register user with ticket, commit error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return nil
+}
+
+/**
+ * 校验一行数据，返回转换成E.164格式的电话号码
+ *
+ * 没有国际区号或区号为86时按中国大陆手机号码校验
+ */
+func ValidateRegistrationRow(values map[string]string) (string, error) {
+    name := values["name"]
+    if name == "" {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "姓名不能为空")
+    }
+    if utf8.RuneCountInString(name) > 64 {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "姓名不能超过64个字")
+    }
+
+    phone := strings.NewReplacer(" ", "", "-", "").Replace(values["phone"])
+    if phone == "" {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码不能为空")
+    }
+    country_code := strings.TrimPrefix(values["country_code"], "+")
+    if country_code == "" {
+        country_code = utils.DEFAULT_COUNTRY_CODE
+    }
+    if country_code == utils.DEFAULT_COUNTRY_CODE && !strings.HasPrefix(phone, "+") && !utils.PhoneValid(phone) {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "手机号码格式错误")
+    }
+    phone, err := utils.NormalizePhone(phone, country_code)
+    if nil != err {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "电话号码错误")
+    }
+
+    if values["email"] != "" && !utils.EmailValid(values["email"]) {
+        return "", utils.NewInternalErrorByStr(utils.ParameterErrCode, "邮箱格式错误")
+    }
+    return phone, nil
+}
@@ -0,0 +1,140 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/3 21:00
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 门票状态
+const (
+    TICKET_STATUS_VALID     = 1     // 有效
+    TICKET_STATUS_VOID      = 2     // 作废
+)
+
+// 电子门票表，每个用户每届展会一张免费门票，付费门票按订单数量发放
+type Ticket struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    TicketNo        string          `sql:"type:varchar(32)"`    // 门票编号，二维码中使用
+    UserId          int64           `sql:"type:bigint(20)"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    ProductId       int64           `sql:"type:bigint(20)"`     // 付费门票商品，0: 免费门票
+    OrderId         int64           `sql:"type:bigint(20)"`     // 付费门票的订单
+    OrderItemId     int64           `sql:"type:bigint(20)"`     // 付费门票的订单明细，和seq一起保证不重复发放
+    Seq             int             `sql:"type:int(11)"`        // 同一订单明细中的序号，从1开始
+    RefundId        int64           `sql:"type:bigint(20)"`     // 退款单，退款中和已退款的门票作废
+    Status          int             `sql:"type:smallint(6)"`    // 状态，1: 有效 2: 作废
+    ValidFrom       time.Time       `sql:"type:datetime"`       // 生效时间
+    ValidTo         time.Time       `sql:"type:datetime"`       // 失效时间
+    CheckinGate     string          `sql:"type:varchar(32)"`    // 入场闸口，为空时未入场
+    CheckinTime     time.Time       `sql:"type:datetime"`       // 入场时间
+    CheckinBy       int64           `sql:"type:bigint(20)"`     // 检票员
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (ticket *Ticket) TableName() string {
+    return "pet.ticket"
+}
+
+func (ticket *Ticket) Create() error {
+    ticket.CreateTime = time.Now()
+    ticket.UpdateTime = ticket.CreateTime
+
+    err := PET_DB.Table(ticket.TableName()).Create(ticket).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("create ticket error: %v", err)
+        return err
+    }
+    return nil
+}
+
+// 通过门票编号获取，不存在时返回 found=false
+func (ticket *Ticket) GetTicketByNo(ticket_no string) (found bool, err error) {
+    err = PET_DB.Table(ticket.TableName()).Where("ticket_no = ?", ticket_no).Limit(1).Find(ticket).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("ticket not found, ticket_no: %s", ticket_no)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get ticket failed, ticket_no: %s, error: %v", ticket_no, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 获取用户某届展会的免费门票，不存在时返回 found=false
+func (ticket *Ticket) GetUserTicket(user_id, edition_id int64) (found bool, err error) {
+    err = PET_DB.Table(ticket.TableName()).Where("user_id = ? AND edition_id = ? AND order_item_id = 0", user_id, edition_id).
+        Limit(1).Find(ticket).Error
+    if gorm.RecordNotFound == err {
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get user ticket failed, user_id: %d, edition_id: %d, error: %v", user_id, edition_id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 用户的门票列表，按届次倒序
+func GetUserTicketList(user_id int64) ([]Ticket, error) {
+    ticket_list := make([]Ticket, 0)
+    err := PET_DB.Table("pet.ticket").Where("user_id = ?", user_id).Order("edition_id desc, id").Find(&ticket_list).Error
+    if nil != err && gorm.RecordNotFound != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get user ticket list failed, user_id: %d, error: %v", user_id, err)
+        return nil, err
+    }
+    return ticket_list, nil
+}
+
+// 订单发放的门票
+func GetOrderTicketList(order_id int64) ([]Ticket, error) {
+    ticket_list := make([]Ticket, 0)
+    err := PET_DB.Table("pet.ticket").Where("order_id = ?", order_id).Order("id").Find(&ticket_list).Error
+    if nil != err && gorm.RecordNotFound != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get order ticket list failed, order_id: %d, error: %v", order_id, err)
+        return nil, err
+    }
+    return ticket_list, nil
+}
+
+/**
+ * 门票入场，返回是否本次入场
+ *
+ * 通过带条件的 update 保证多个闸口同时扫码时只有一次入场成功，已入场时返回false并读取入场信息
+ */
+func (ticket *Ticket) Checkin(gate_id string, checkin_time time.Time, operator_id int64) (bool, error) {
+    now := time.Now()
+    result := PET_DB.Table(ticket.TableName()).
+        Where("ticket_no = ? AND status = ? AND checkin_gate = ''", ticket.TicketNo, TICKET_STATUS_VALID).
+        Updates(map[string]interface{}{
+            "checkin_gate": gate_id,
+            "checkin_time": checkin_time,
+            "checkin_by":   operator_id,
+            "update_time":  now,
+        })
+    if nil != result.Error {
+        err := utils.NewInternalError(utils.DbErrCode, result.Error)
+        utils.Logger.Error("ticket checkin error, ticket_no: %s, error: %v", ticket.TicketNo, err)
+        return false, err
+    }
+    if result.RowsAffected == 1 {
+        ticket.CheckinGate = gate_id
+        ticket.CheckinTime = checkin_time
+        ticket.CheckinBy = operator_id
+        ticket.UpdateTime = now
+        return true, nil
+    }
+
+    _, err := ticket.GetTicketByNo(ticket.TicketNo)
+    return false, err
+}
@@ -0,0 +1,82 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 21:10
+ */
+package model
+
+import (
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 门票商品状态
+const (
+    PRODUCT_STATUS_OFF      = 0     // 未上架
+    PRODUCT_STATUS_ON       = 1     // 在售
+)
+
+// 付费门票商品，如单日票、VIP通票
+type TicketProduct struct {
+    Id              int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    EditionId       int64           `sql:"type:bigint(20)"`     // 展会届次
+    Name            string          `sql:"type:varchar(64)"`
+    Description     string          `sql:"type:varchar(255)"`
+    Price           int             `sql:"type:int(11)"`        // 价格，单位分
+    Stock           int             `sql:"type:int(11)"`        // 库存，0: 不限
+    SoldNum         int             `sql:"type:int(11)"`        // 已售数量，包含未支付订单占用的
+    ValidFrom       time.Time       `sql:"type:datetime"`       // 门票生效时间，为空时使用届次门票配置
+    ValidTo         time.Time       `sql:"type:datetime"`       // 门票失效时间
+    Sort            int             `sql:"type:int(11)"`        // 排序，从小到大
+    Status          int             `sql:"type:smallint(6)"`    // 状态，0: 未上架 1: 在售
+    CreateTime      time.Time       `sql:"type:datetime"`
+    UpdateTime      time.Time       `sql:"type:datetime"`
+}
+
+func (product *TicketProduct) TableName() string {
+    return "pet.ticket_product"
+}
+
+// 获取门票商品，不存在时返回 found=false
+func (product *TicketProduct) GetTicketProductById(id int64) (found bool, err error) {
+    err = PET_DB.Table(product.TableName()).Where("id = ?", id).Limit(1).Find(product).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("ticket product not found, id: %d", id)
+        return false, nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get ticket product failed, id: %d, error: %v", id, err)
+        return false, err
+    }
+    return true, nil
+}
+
+// 在售的门票商品，edition_id不为0时只返回该届的
+func GetTicketProductList(edition_id int64) ([]TicketProduct, error) {
+    var product_list []TicketProduct
+    query := PET_DB.Table(new(TicketProduct).TableName()).Where("status = ?", PRODUCT_STATUS_ON)
+    if edition_id != 0 {
+        query = query.Where("edition_id = ?", edition_id)
+    }
+    err := query.Order("sort, id").Find(&product_list).Error
+    if nil != err {
+        utils.Logger.Error("get ticket product list error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return product_list, nil
+}
+
+// 按id批量获取门票商品
+func GetTicketProductListByIds(ids []int64) ([]TicketProduct, error) {
+    var product_list []TicketProduct
+    if len(ids) == 0 {
+        return product_list, nil
+    }
+    err := PET_DB.Table(new(TicketProduct).TableName()).Where("id IN (?)", ids).Find(&product_list).Error
+    if nil != err {
+        utils.Logger.Error("get ticket product list by ids error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return product_list, nil
+}
@@ -19,16 +19,44 @@ type User struct {
     Gender          string          `sql:"type:smallint(6)"`    // 性别，0: 无性别 1: 男 2: 女
     Email           string          `sql:"type:varchar(128)"`
 
-    RegistType      int             `sql:"type:"smallint(6)"`   // 1：微信  2：官网
+    RegistType      int             `sql:"type:"smallint(6)"`   // 1：微信  2：官网  3：团体登记
     Nickname        string          `sql:"type:varchar(128)"`   // 微信昵称
-    Avatar          string          `sql:"type:varchar(128)"`   // 微信头像
+    Avatar          string          `sql:"type:varchar(255)"`   // 头像，默认为微信头像
+    AvatarCustom    int             `sql:"type:smallint(6)"`    // 头像是否用户上传，上传后不再使用微信头像覆盖
     Openid          string          `sql:"type:varchar(255)"`   // 微信公共号的用户标志
+    Unionid         string          `sql:"type:varchar(255)"`   // 微信开放平台的用户标志
 
     CreateTime      time.Time       `sql:"type:datetime"`
     UpdateTime      time.Time       `sql:"type:datetime"`
 
     LastLogin       time.Time       `sql:"type:datetime"`
     Password        string          `sql:"type:varbinary(128)"`
+    Role            int             `sql:"type:smallint(6)"`    // 角色，0: 观众 1: 检票员 2: 管理员 3: 裁判 4: 合作机构
+}
+
+// 用户角色
+const (
+    USER_ROLE_VISITOR       = 0     // 观众
+    USER_ROLE_GATE_STAFF    = 1     // 检票员
+    USER_ROLE_ADMIN         = 2     // 管理员，拥有所有权限
+    USER_ROLE_JUDGE         = 3     // 比赛裁判
+    USER_ROLE_PARTNER       = 4     // 合作机构，如行业协会、宠物俱乐部，可以团体登记观众
+)
+
+// 团体登记的用户注册类型
+const REGIST_TYPE_GROUP = 3
+
+// 是否拥有角色权限
+func (user_info *User) HasRole(roles ...int) bool {
+    if user_info.Role == USER_ROLE_ADMIN {
+        return true
+    }
+    for _, role := range roles {
+        if user_info.Role == role {
+            return true
+        }
+    }
+    return false
 }
 
 func (user_info *User) TableName() string {
@@ -52,6 +80,62 @@ func (user_info *User) Create(id *int64) error {
     return nil
 }
 
+// 更新最后登录时间
+func (user_info *User) UpdateLastLogin() error {
+    now := time.Now()
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_info.UserId).
+        Update("last_login", now).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update user last login error, user_id: %d, error: %v", user_info.UserId, err)
+        return err
+    }
+    user_info.LastLogin = now
+    return nil
+}
+
+// 更新密码，password为加密后的密码
+func (user_info *User) UpdatePassword(password string) error {
+    now := time.Now()
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_info.UserId).
+        Updates(map[string]interface{}{"password": password, "update_time": now}).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update user password error, user_id: %d, error: %v", user_info.UserId, err)
+        return err
+    }
+    user_info.Password = password
+    user_info.UpdateTime = now
+    return nil
+}
+
+// 绑定电话号码
+func (user_info *User) UpdatePhone(phone string) error {
+    now := time.Now()
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_info.UserId).
+        Updates(map[string]interface{}{"phone": phone, "update_time": now}).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update user phone error, user_id: %d, error: %v", user_info.UserId, err)
+        return err
+    }
+    user_info.Phone = phone
+    user_info.UpdateTime = now
+    return nil
+}
+
+// 更新个人资料，values为需要更新的字段
+func (user_info *User) UpdateProfile(values map[string]interface{}) error {
+    values["update_time"] = time.Now()
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_info.UserId).Updates(values).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update user profile error, user_id: %d, error: %v", user_info.UserId, err)
+        return err
+    }
+    return user_info.GetUserById(user_info.UserId)
+}
+
 // 通过微信用户标志拉取用户标志
 func (user_info *User) GetUserByOpenid(openid string) error {
     err := PET_DB.Table(user_info.TableName()).Where("openid = ?", openid).Limit(1).Find(user_info).Error
@@ -66,6 +150,88 @@ func (user_info *User) GetUserByOpenid(openid string) error {
     return nil
 }
 
+// 通过用户id获取用户
+func (user_info *User) GetUserById(user_id int64) error {
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_id).Limit(1).Find(user_info).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("user not found by id, user_id: %d", user_id)
+        err = nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get user by id failed, user_id: %d, error: %v", user_id, err)
+        return err
+    }
+    return nil
+}
+
+// 通过用户id批量获取用户
+func GetUserListByIds(user_ids []int64) ([]User, error) {
+    var user_list []User
+    if len(user_ids) == 0 {
+        return user_list, nil
+    }
+    err := PET_DB.Table(new(User).TableName()).Where("user_id IN (?)", user_ids).Find(&user_list).Error
+    if nil != err {
+        utils.Logger.Error("get user list by ids error: %v", err)
+        return nil, utils.NewInternalError(utils.DbErrCode, err)
+    }
+    return user_list, nil
+}
+
+// 通过微信unionid获取用户
+func (user_info *User) GetUserByUnionid(unionid string) error {
+    err := PET_DB.Table(user_info.TableName()).Where("unionid = ?", unionid).Limit(1).Find(user_info).Error
+    if gorm.RecordNotFound == err {
+        utils.Logger.Warning("user not found by unionid, unionid: %s", unionid)
+        err = nil
+    } else if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get user by unionid failed, unionid: %s, error: %v", unionid, err)
+        return err
+    }
+    return nil
+}
+
+/**
+ * 更新微信信息
+ *
+ * 每次授权登录刷新微信昵称和头像，用户上传过头像时保留上传的头像
+ */
+func (user_info *User) UpdateWeixinInfo(openid, unionid, nickname, avatar string) error {
+    now := time.Now()
+    values := map[string]interface{}{
+        "openid":       openid,
+        "update_time":  now,
+    }
+    if unionid != "" {
+        values["unionid"] = unionid
+    }
+    if nickname != "" {
+        values["nickname"] = nickname
+    }
+    if user_info.AvatarCustom == 0 && avatar != "" {
+        values["avatar"] = avatar
+    }
+    err := PET_DB.Table(user_info.TableName()).Where("user_id = ?", user_info.UserId).Updates(values).Error
+    if nil != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("update user weixin info error, user_id: %d, error: %v", user_info.UserId, err)
+        return err
+    }
+    user_info.Openid = openid
+    if unionid != "" {
+        user_info.Unionid = unionid
+    }
+    if _, ok := values["nickname"]; ok {
+        user_info.Nickname = nickname
+    }
+    if _, ok := values["avatar"]; ok {
+        user_info.Avatar = avatar
+    }
+    user_info.UpdateTime = now
+    return nil
+}
+
 // get user by phone
 func (user_info *User) GetUserByPhone(phone string) error {
     err := PET_DB.Table(user_info.TableName()).Where("phone = ?", phone).Limit(1).Find(user_info).Error
@@ -80,6 +246,19 @@ func (user_info *User) GetUserByPhone(phone string) error {
     return nil
 }
 
+// 获取电话号码不是E.164格式的用户，按user_id分批读取
+func GetLegacyPhoneUsers(last_user_id int64, limit int) ([]*User, error) {
+    user_list := make([]*User, 0)
+    err := PET_DB.Table("pet.user").Where("phone != '' AND phone NOT LIKE '+%' AND user_id > ?", last_user_id).
+        Order("user_id").Limit(limit).Find(&user_list).Error
+    if nil != err && gorm.RecordNotFound != err {
+        err = utils.NewInternalError(utils.DbErrCode, err)
+        utils.Logger.Error("get legacy phone users failed, last_user_id: %d, error: %v", last_user_id, err)
+        return nil, err
+    }
+    return user_list, nil
+}
+
 // 判断电话号码是否存在
 func CheckPhoneExist(phone string) (err error, flag bool, user_info *User) {
     user_info = new(User)
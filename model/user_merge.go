@@ -0,0 +1,233 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/3 15:40
+ */
+package model
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+    "third/gorm"
+    "pet/utils"
+)
+
+// 关联用户的表，合并账号时需要把数据迁移到主账号
+type UserRelatedTable struct {
+    Table           string
+    Column          string      // 用户id字段
+    UniqueKeys      []string    // 和用户id一起组成唯一索引的其他字段，为空时没有唯一索引
+}
+
+// 新增关联用户的表需要加到这里
+var UserRelatedTables = []UserRelatedTable{
+    {"pet.activity_signup", "user_id", []string{"activity_id"}},
+    {"pet.ticket", "user_id", []string{"edition_id", "order_item_id", "seq"}},
+    {"pet.pet", "user_id", nil},
+    {"pet.competition_judge", "judge_id", []string{"class_id"}},
+    {"pet.competition_entry", "user_id", nil},
+    {"pet.competition_score", "judge_id", []string{"entry_id", "criterion_id"}},
+    {"pet.pet_vote", "user_id", nil},
+    {"pet.favorite", "user_id", []string{"target_type", "target_id"}},
+    {"pet.ticket_order", "user_id", nil},
+    {"pet.ticket_refund", "user_id", nil},
+    {"pet.registration_row", "user_id", nil},
+    {"pet.exhibitor_staff", "user_id", []string{"exhibitor_id"}},
+    {"pet.exhibitor_lead", "user_id", []string{"exhibitor_id"}},
+    {"pet.exhibitor_lead", "scanned_by", nil},
+}
+
+// 账号合并记录表
+type UserMergeLog struct {
+    Id                  int64           `gorm:"primary_key" sql:"AUTO_INCREMENT"`
+    PrimaryUserId       int64           `sql:"type:bigint(20)"`     // 保留的账号
+    SecondaryUserId     int64           `sql:"type:bigint(20)"`     // 被合并删除的账号
+    PrimarySnapshot     string          `sql:"type:text"`           // 合并前主账号数据
+    SecondarySnapshot   string          `sql:"type:text"`           // 合并前被合并账号数据
+    MergedSnapshot      string          `sql:"type:text"`           // 合并后主账号数据
+    Reason              string          `sql:"type:varchar(64)"`    // 合并原因，bind_phone: 微信账号绑定电话号码
+    OperatorId          int64           `sql:"type:bigint(20)"`     // 操作人
+    CreateTime          time.Time       `sql:"type:datetime"`
+}
+
+func (merge_log *UserMergeLog) TableName() string {
+    return "pet.user_merge_log"
+}
+
+// 用户快照，不包含密码
+func userSnapshot(user_info *User) string {
+    snapshot := *user_info
+    snapshot.Password = ""
+    bytes, _ := json.Marshal(&snapshot)
+    return string(bytes)
+}
+
+// 合并字段，主账号为空时使用被合并账号的值
+func mergeField(primary, secondary string) string {
+    if primary == "" {
+        return secondary
+    }
+    return primary
+}
+
+// 删除被合并账号和主账号冲突的数据，参数为被合并账号、主账号
+func (related *UserRelatedTable) conflictSql() string {
+    conds := make([]string, len(related.UniqueKeys))
+    for i, key := range related.UniqueKeys {
+        conds[i] = fmt.Sprintf("p.%s = s.%s", key, key)
+    }
+    return fmt.Sprintf("DELETE s FROM %s s JOIN %s p ON %s AND p.%s = ? WHERE s.%s = ?",
+        related.Table, related.Table, strings.Join(conds, " AND "), related.Column, related.Column)
+}
+
+/**
+ * 两个账号报名了同一活动时保留一条报名记录
+ *
+ * 被合并账号已报名、主账号没有报名成功时保留被合并账号的，都已报名时释放一个名额
+ */
+func mergeActivitySignup(tx *gorm.DB, primary_id, secondary_id int64) error {
+    err := tx.Exec("UPDATE pet.activity a JOIN pet.activity_signup s ON s.activity_id = a.id AND s.user_id = ? AND s.status = ? "+
+        "JOIN pet.activity_signup p ON p.activity_id = s.activity_id AND p.user_id = ? AND p.status = ? "+
+        "SET a.signup_num = a.signup_num - 1 WHERE a.signup_num > 0",
+        secondary_id, SIGNUP_STATUS_SIGNED, primary_id, SIGNUP_STATUS_SIGNED).Error
+    if nil != err {
+        return err
+    }
+    return tx.Exec("DELETE p FROM pet.activity_signup p JOIN pet.activity_signup s ON s.activity_id = p.activity_id "+
+        "AND s.user_id = ? AND s.status = ? WHERE p.user_id = ? AND p.status != ?",
+        secondary_id, SIGNUP_STATUS_SIGNED, primary_id, SIGNUP_STATUS_SIGNED).Error
+}
+
+/**
+ * 两个账号被同一展商记录时保留主账号的记录，授权取两者中最严格的
+ *
+ * 被合并账号撤回过授权时主账号的记录也标记为撤回，避免合并后撤回失效
+ */
+func mergeExhibitorLead(tx *gorm.DB, primary_id, secondary_id int64) error {
+    return tx.Exec("UPDATE pet.exhibitor_lead p JOIN pet.exhibitor_lead s ON s.exhibitor_id = p.exhibitor_id AND s.user_id = ? "+
+        "SET p.consent_contact = LEAST(p.consent_contact, s.consent_contact), "+
+        "p.consent_marketing = LEAST(p.consent_marketing, s.consent_marketing), "+
+        "p.consent_revoked = GREATEST(p.consent_revoked, s.consent_revoked) "+
+        "WHERE p.user_id = ?", secondary_id, primary_id).Error
+}
+
+/**
+ * 合并账号，secondary 合并到 primary
+ *
+ * 个人资料以主账号为准，主账号为空的字段使用被合并账号的值，微信信息以微信账号为准
+ * 关联数据迁移到主账号，两个账号都有的数据保留一条，被合并账号删除，合并前后的数据记录到 user_merge_log
+ */
+func MergeUser(primary, secondary *User, reason string, operator_id int64) error {
+    if primary.UserId == 0 || secondary.UserId == 0 || primary.UserId == secondary.UserId {
+        return utils.NewInternalErrorByStr(utils.ParameterErrCode, "合并账号参数错误")
+    }
+
+    merge_log := new(UserMergeLog)
+    merge_log.PrimaryUserId = primary.UserId
+    merge_log.SecondaryUserId = secondary.UserId
+    merge_log.PrimarySnapshot = userSnapshot(primary)
+    merge_log.SecondarySnapshot = userSnapshot(secondary)
+    merge_log.Reason = reason
+    merge_log.OperatorId = operator_id
+    merge_log.CreateTime = time.Now()
+
+    merged := *primary
+    merged.Phone = mergeField(primary.Phone, secondary.Phone)
+    merged.Name = mergeField(primary.Name, secondary.Name)
+    merged.Email = mergeField(primary.Email, secondary.Email)
+    if primary.Gender == "" || primary.Gender == "0" {
+        merged.Gender = mergeField(secondary.Gender, primary.Gender)
+    }
+    merged.Openid = mergeField(secondary.Openid, primary.Openid)
+    merged.Unionid = mergeField(secondary.Unionid, primary.Unionid)
+    merged.Nickname = mergeField(secondary.Nickname, primary.Nickname)
+    merged.Avatar = mergeField(secondary.Avatar, primary.Avatar)
+    merged.AvatarCustom = secondary.AvatarCustom
+    // 用户上传的头像优先于微信头像
+    if primary.AvatarCustom == 1 && secondary.AvatarCustom == 0 {
+        merged.Avatar = primary.Avatar
+        merged.AvatarCustom = 1
+    }
+    merged.Password = mergeField(primary.Password, secondary.Password)
+    if secondary.CreateTime.Before(merged.CreateTime) {
+        merged.CreateTime = secondary.CreateTime
+    }
+    if secondary.LastLogin.After(merged.LastLogin) {
+        merged.LastLogin = secondary.LastLogin
+    }
+    merged.UpdateTime = time.Now()
+    merge_log.MergedSnapshot = userSnapshot(&merged)
+
+    tx := PET_DB.Begin()
+
+    // 先删除被合并账号，避免openid、phone重复
+    err := tx.Table(secondary.TableName()).Where("user_id = ?", secondary.UserId).Delete(secondary).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("merge user, delete secondary user error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Table(merged.TableName()).Save(&merged).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("merge user, save primary user error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    // 两个账号都有的数据（唯一索引冲突）保留一条，其余迁移到主账号
+    err = mergeActivitySignup(tx, primary.UserId, secondary.UserId)
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("merge user, merge activity signup error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    err = mergeExhibitorLead(tx, primary.UserId, secondary.UserId)
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("merge user, merge exhibitor lead error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+    for _, related := range UserRelatedTables {
+        if len(related.UniqueKeys) > 0 {
+            result := tx.Exec(related.conflictSql(), secondary.UserId, primary.UserId)
+            if nil != result.Error {
+                tx.Rollback()
+                utils.Logger.Error("merge user, delete %s conflict records error: %v", related.Table, result.Error)
+                return utils.NewInternalError(utils.DbErrCode, result.Error)
+            }
+            if result.RowsAffected > 0 {
+                utils.Logger.Info("merge user, delete %d %s records of %d conflicting with %d",
+                    result.RowsAffected, related.Table, secondary.UserId, primary.UserId)
+            }
+        }
+
+        sql := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", related.Table, related.Column, related.Column)
+        err = tx.Exec(sql, primary.UserId, secondary.UserId).Error
+        if nil != err {
+            tx.Rollback()
+            utils.Logger.Error("merge user, move %s records error: %v", related.Table, err)
+            return utils.NewInternalError(utils.DbErrCode, err)
+        }
+    }
+
+    err = tx.Table(merge_log.TableName()).Create(merge_log).Error
+    if nil != err {
+        tx.Rollback()
+        utils.Logger.Error("merge user, create merge log error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    err = tx.Commit().Error
+    if nil != err {
+        utils.Logger.Error("merge user, commit error: %v", err)
+        return utils.NewInternalError(utils.DbErrCode, err)
+    }
+
+    utils.Logger.Info("merge user %d into %d, reason: %s, operator: %d",
+        secondary.UserId, primary.UserId, reason, operator_id)
+    *primary = merged
+    return nil
+}
@@ -8,6 +8,7 @@ package model
 import (
     "third/gorm"
     "fmt"
+    "time"
     "pet/utils"
     "pet/protocol"
 )
@@ -52,4 +53,56 @@ func CopyUserData(from *User, dst *protocol.UserInfoJson) error {
     dst.Openid = from.Openid
 
     return nil
-}
\ No newline at end of file
+}
+func CopyActivityData(from *Activity, dst *protocol.ActivityInfoJson) error {
+    utils.DumpStruct(dst, from)
+    dst.StartTime = from.StartTime.Format(utils.TIME_FORMAT)
+    dst.EndTime = from.EndTime.Format(utils.TIME_FORMAT)
+
+    return nil
+}
+
+// categories 为所有产品类别，id => 名称
+func CopyExhibitorData(from *Exhibitor, dst *protocol.ExhibitorInfoJson, categories map[int64]string) error {
+    utils.DumpStruct(dst, from)
+    dst.Categories = []protocol.ExhibitorCategoryJson{}
+    for _, id := range from.CategoryIdList() {
+        if name, ok := categories[id]; ok {
+            dst.Categories = append(dst.Categories, protocol.ExhibitorCategoryJson{Id: id, Name: name})
+        }
+    }
+
+    return nil
+}
+
+// 日期，零值返回空字符串
+func formatDate(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.Format(utils.DATE_FORMAT)
+}
+
+func CopyEditionData(from *Edition, dst *protocol.EditionInfoJson) error {
+    utils.DumpStruct(dst, from)
+    dst.StartDate = formatDate(from.StartDate)
+    dst.EndDate = formatDate(from.EndDate)
+
+    return nil
+}
+
+func CopyPetData(from *Pet, dst *protocol.PetInfoJson) error {
+    utils.DumpStruct(dst, from)
+    dst.SpeciesName = PetSpeciesNames[from.Species]
+    dst.Birthday = formatDate(from.Birthday)
+
+    return nil
+}
+
+func CopyPetVaccinationData(from *PetVaccination, dst *protocol.PetVaccinationJson) error {
+    utils.DumpStruct(dst, from)
+    dst.VaccinateDate = formatDate(from.VaccinateDate)
+    dst.ExpireDate = formatDate(from.ExpireDate)
+
+    return nil
+}
@@ -0,0 +1,92 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/28 21:40
+ */
+package protocol
+
+import "third/go-local"
+
+type ActivityInfoJson struct {
+    Id              int64           `json:"id"`
+    Title           string          `json:"title"`
+    Type            int             `json:"type"`           // 类型，1: 讲座 2: 宠物表演 3: 工作坊
+    Description     string          `json:"description"`
+    Hall            string          `json:"hall"`           // 展馆
+    Venue           string          `json:"venue"`          // 具体场地
+    StartTime       string          `json:"start_time"`     // 2006-01-02 15:04:05
+    EndTime         string          `json:"end_time"`
+    Capacity        int             `json:"capacity"`       // 人数上限，0: 不限
+    SignupNum       int             `json:"signup_num"`     // 已报名人数
+    Cover           string          `json:"cover"`
+    Status          int             `json:"status"`         // 状态，1: 已发布 2: 已取消
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+type ActivityListArgs struct {
+    local.TraceParam
+
+    EditionId           int64       `json:"edition_id" mapstructure:"edition_id"`  // 展会届次，为0时按域名确定或使用当前届次
+    Host                string      `json:"-" mapstructure:"-"`
+    Day                 string      `json:"day"`            // 日期，2006-01-02
+    Hall                string      `json:"hall"`           // 展馆
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type ActivityListReply struct {
+    ActivityList        []ActivityInfoJson      `json:"activity_list"`
+    TotalNum		    int 			        `json:"total_num"`
+}
+
+type ActivityDetailArgs struct {
+    local.TraceParam
+
+    Id                  int64       `json:"id"`
+}
+type ActivityDetailReply struct {
+    Activity            ActivityInfoJson        `json:"activity"`
+}
+
+// 报名信息
+type ActivitySignupJson struct {
+    Activity            ActivityInfoJson        `json:"activity"`
+    Status              int                     `json:"status"`             // 状态，1: 已报名 2: 候补
+    WaitingPosition     int                     `json:"waiting_position"`   // 候补排位，从1开始
+}
+
+// 报名活动
+type SignupActivityArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ActivityId          int64       `json:"activity_id" mapstructure:"activity_id"`
+}
+type SignupActivityReply struct {
+    Status              int         `json:"status"`             // 状态，1: 已报名 2: 候补
+    WaitingPosition     int         `json:"waiting_position"`   // 候补排位，从1开始
+}
+
+// 取消报名
+type CancelActivitySignupArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ActivityId          int64       `json:"activity_id" mapstructure:"activity_id"`
+}
+type CancelActivitySignupReply struct {
+
+}
+
+// 我的报名列表
+type MySignupListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type MySignupListReply struct {
+    SignupList          []ActivitySignupJson    `json:"signup_list"`
+    TotalNum		    int 			        `json:"total_num"`
+}
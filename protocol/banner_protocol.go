@@ -21,6 +21,8 @@ type BannerInfoJson struct {
 type BannerListArgs struct {
     local.TraceParam
 
+    EditionId           int64       `json:"edition_id" mapstructure:"edition_id"`  // 展会届次，为0时按域名确定或使用当前届次
+    Host                string      `json:"-" mapstructure:"-"`
     Type                int         `json:"type"`
     PageNum     		int			`json:"page_num" mapstructure:"page_num"`
     PageSize    		int         `json:"page_size" mapstructure:"page_size"`
@@ -0,0 +1,61 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/6 15:30
+ */
+package protocol
+
+import "third/go-local"
+
+// 入场结果
+type CheckinInfoJson struct {
+    TicketNo        string          `json:"ticket_no"`
+    EditionId       int64           `json:"edition_id"`
+    UserName        string          `json:"user_name"`      // 观众姓名
+    CheckinGate     string          `json:"checkin_gate"`   // 入场闸口
+    CheckinTime     string          `json:"checkin_time"`   // 入场时间，2006-01-02 15:04:05
+}
+
+// 离线入场记录
+type CheckinRecordJson struct {
+    Payload         string          `json:"payload"`
+    GateId          string          `json:"gate_id" mapstructure:"gate_id"`
+    EditionId       int64           `json:"edition_id" mapstructure:"edition_id"`      // 闸口设备配置的届次，为0时不限
+    CheckinTime     int64           `json:"checkin_time" mapstructure:"checkin_time"`  // 扫码时间，unix时间戳
+}
+
+// 离线入场记录的同步结果
+type CheckinResultJson struct {
+    Index           int             `json:"index"`          // 对应请求中 records 的下标
+    TicketNo        string          `json:"ticket_no"`
+    Code            int             `json:"code"`           // 200: 成功，其他为错误码
+    Desc            string          `json:"desc"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 扫码入场
+type TicketCheckinArgs struct {
+    local.TraceParam
+
+    OperatorId      int64           `json:"-" mapstructure:"-"`
+    Payload         string          `json:"payload"`        // 二维码内容
+    GateId          string          `json:"gate_id" mapstructure:"gate_id"`
+    EditionId       int64           `json:"edition_id" mapstructure:"edition_id"`  // 闸口设备配置的届次，为0时不限
+}
+type TicketCheckinReply struct {
+    Checkin         CheckinInfoJson     `json:"checkin"`
+}
+
+// 离线入场记录批量同步
+type CheckinBatchSyncArgs struct {
+    local.TraceParam
+
+    OperatorId      int64               `json:"-" mapstructure:"-"`
+    Records         []CheckinRecordJson `json:"records"`
+}
+type CheckinBatchSyncReply struct {
+    SuccessNum      int                 `json:"success_num"`
+    FailNum         int                 `json:"fail_num"`
+    Results         []CheckinResultJson `json:"results"`
+}
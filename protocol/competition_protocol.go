@@ -0,0 +1,164 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/13 14:20
+ */
+package protocol
+
+import "third/go-local"
+
+type CompetitionCriterionJson struct {
+    Id              int64           `json:"id"`
+    Name            string          `json:"name"`
+    Weight          int             `json:"weight"`         // 权重
+    MaxScore        int             `json:"max_score" mapstructure:"max_score"`  // 满分
+}
+
+type CompetitionClassJson struct {
+    Id              int64                       `json:"id"`
+    ActivityId      int64                       `json:"activity_id"`
+    Name            string                      `json:"name"`
+    Species         int                         `json:"species"`        // 限制宠物种类，0: 不限
+    Description     string                      `json:"description"`
+    Status          int                         `json:"status"`         // 状态，1: 报名中 2: 评分中 3: 已结束
+    Criteria        []CompetitionCriterionJson  `json:"criteria"`
+}
+
+type CompetitionEntryJson struct {
+    Id              int64           `json:"id"`
+    ClassId         int64           `json:"class_id"`
+    PetId           int64           `json:"pet_id"`
+    PetName         string          `json:"pet_name"`
+    Status          int             `json:"status"`         // 状态，1: 已报名 2: 已退赛
+}
+
+// 比赛成绩
+type CompetitionResultJson struct {
+    Ranking         int             `json:"ranking"`        // 名次，同分并列
+    EntryId         int64           `json:"entry_id"`
+    PetName         string          `json:"pet_name"`
+    OwnerName       string          `json:"owner_name"`     // 主人姓名
+    TotalScore      float64         `json:"total_score"`    // 总分，百分制
+    CriterionScores []float64       `json:"criterion_scores"`   // 各评分项平均分，和 criteria 顺序一致
+}
+
+// 裁判评分
+type CompetitionScoreJson struct {
+    CriterionId     int64           `json:"criterion_id" mapstructure:"criterion_id"`
+    Score           int             `json:"score"`
+}
+
+// 裁判看到的参赛记录
+type JudgeEntryJson struct {
+    Entry           CompetitionEntryJson    `json:"entry"`
+    Scores          []CompetitionScoreJson  `json:"scores"`     // 当前裁判的评分，未评分时为空
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 组别列表
+type CompetitionClassListArgs struct {
+    local.TraceParam
+
+    ActivityId          int64       `json:"activity_id" mapstructure:"activity_id"`   // 不传返回所有组别
+}
+type CompetitionClassListReply struct {
+    ClassList           []CompetitionClassJson      `json:"class_list"`
+}
+
+// 比赛成绩
+type CompetitionResultArgs struct {
+    local.TraceParam
+
+    ClassId             int64       `json:"class_id" mapstructure:"class_id"`
+}
+type CompetitionResultReply struct {
+    Class               CompetitionClassJson        `json:"class"`
+    ResultList          []CompetitionResultJson     `json:"result_list"`
+}
+
+// 宠物报名比赛
+type EnterCompetitionArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ClassId             int64       `json:"class_id" mapstructure:"class_id"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+}
+type EnterCompetitionReply struct {
+    Entry               CompetitionEntryJson        `json:"entry"`
+}
+
+// 退赛
+type WithdrawCompetitionArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ClassId             int64       `json:"class_id" mapstructure:"class_id"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+}
+type WithdrawCompetitionReply struct {
+
+}
+
+// 我的参赛记录
+type MyCompetitionEntryListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+}
+type MyCompetitionEntryListReply struct {
+    EntryList           []CompetitionEntryJson      `json:"entry_list"`
+}
+
+// 裁判获取组别的参赛记录
+type JudgeEntryListArgs struct {
+    local.TraceParam
+
+    JudgeId             int64       `json:"-" mapstructure:"-"`
+    ClassId             int64       `json:"class_id" mapstructure:"class_id"`
+}
+type JudgeEntryListReply struct {
+    Class               CompetitionClassJson        `json:"class"`
+    EntryList           []JudgeEntryJson            `json:"entry_list"`
+}
+
+// 裁判提交评分
+type SubmitCompetitionScoreArgs struct {
+    local.TraceParam
+
+    JudgeId             int64                   `json:"-" mapstructure:"-"`
+    EntryId             int64                   `json:"entry_id" mapstructure:"entry_id"`
+    Scores              []CompetitionScoreJson  `json:"scores"`
+}
+type SubmitCompetitionScoreReply struct {
+
+}
+
+// 创建组别
+type CreateCompetitionClassArgs struct {
+    local.TraceParam
+
+    OperatorId          int64                       `json:"-" mapstructure:"-"`
+    ActivityId          int64                       `json:"activity_id" mapstructure:"activity_id"`
+    Name                string                      `json:"name"`
+    Species             int                         `json:"species"`
+    Description         string                      `json:"description"`
+    Criteria            []CompetitionCriterionJson  `json:"criteria"`
+    JudgeIds            []int64                     `json:"judge_ids" mapstructure:"judge_ids"`
+}
+type CreateCompetitionClassReply struct {
+    Class               CompetitionClassJson        `json:"class"`
+}
+
+// 推进组别状态
+type UpdateCompetitionStatusArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    ClassId             int64       `json:"class_id" mapstructure:"class_id"`
+    Status              int         `json:"status"`         // 2: 停止报名开始评分 3: 结束比赛公布成绩
+}
+type UpdateCompetitionStatusReply struct {
+
+}
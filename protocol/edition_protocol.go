@@ -0,0 +1,58 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/17 20:50
+ */
+package protocol
+
+import "third/go-local"
+
+type EditionInfoJson struct {
+    Id              int64           `json:"id"`
+    Name            string          `json:"name"`
+    City            string          `json:"city"`
+    Year            int             `json:"year"`
+    Host            string          `json:"host"`           // 专属域名
+    StartDate       string          `json:"start_date"`     // 开展日期，2006-01-02
+    EndDate         string          `json:"end_date"`       // 闭展日期
+    Status          int             `json:"status"`         // 状态，0: 草稿 1: 已发布
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 届次列表
+type EditionListArgs struct {
+    local.TraceParam
+}
+type EditionListReply struct {
+    EditionList         []EditionInfoJson   `json:"edition_list"`
+    CurrentId           int64               `json:"current_id"`     // 当前届次
+}
+
+// 当前届次，按请求域名确定，没有绑定时为默认届次
+type CurrentEditionArgs struct {
+    local.TraceParam
+
+    Host                string      `json:"-" mapstructure:"-"`
+}
+type CurrentEditionReply struct {
+    Edition             EditionInfoJson     `json:"edition"`
+}
+
+// 新建、修改届次，id为0时新建
+type SaveEditionArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    Id                  int64       `json:"id"`
+    Name                string      `json:"name"`
+    City                string      `json:"city"`
+    Year                int         `json:"year"`
+    Host                string      `json:"host"`
+    StartDate           string      `json:"start_date" mapstructure:"start_date"`
+    EndDate             string      `json:"end_date" mapstructure:"end_date"`
+    Status              int         `json:"status"`
+}
+type SaveEditionReply struct {
+    Edition             EditionInfoJson     `json:"edition"`
+}
@@ -0,0 +1,85 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/8 21:00
+ */
+package protocol
+
+import "third/go-local"
+
+type ExhibitorCategoryJson struct {
+    Id              int64           `json:"id"`
+    Name            string          `json:"name"`
+}
+
+type ExhibitorInfoJson struct {
+    Id              int64                   `json:"id"`
+    Name            string                  `json:"name"`           // 公司名称
+    Logo            string                  `json:"logo"`
+    Description     string                  `json:"description"`
+    Hall            string                  `json:"hall"`           // 展馆
+    Booth           string                  `json:"booth"`          // 展位号
+    Categories      []ExhibitorCategoryJson `json:"categories"`     // 产品类别
+    ContactName     string                  `json:"contact_name"`
+    ContactPhone    string                  `json:"contact_phone"`
+    ContactEmail    string                  `json:"contact_email"`
+    Website         string                  `json:"website"`
+}
+
+// 导入失败的行
+type ImportErrorJson struct {
+    Row             int             `json:"row"`            // 表格中的行号，从1开始
+    Desc            string          `json:"desc"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 产品类别列表
+type ExhibitorCategoryListArgs struct {
+    local.TraceParam
+}
+type ExhibitorCategoryListReply struct {
+    CategoryList        []ExhibitorCategoryJson `json:"category_list"`
+}
+
+// 展商列表，keyword不为空时为搜索
+type ExhibitorListArgs struct {
+    local.TraceParam
+
+    EditionId           int64       `json:"edition_id" mapstructure:"edition_id"`  // 展会届次，为0时按域名确定或使用当前届次
+    Host                string      `json:"-" mapstructure:"-"`
+    CategoryId          int64       `json:"category_id" mapstructure:"category_id"`    // 产品类别
+    Hall                string      `json:"hall"`           // 展馆
+    Keyword             string      `json:"keyword"`        // 公司名称或展位号
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type ExhibitorListReply struct {
+    ExhibitorList       []ExhibitorInfoJson     `json:"exhibitor_list"`
+    TotalNum		    int 			        `json:"total_num"`
+}
+
+type ExhibitorDetailArgs struct {
+    local.TraceParam
+
+    Id                  int64       `json:"id"`
+}
+type ExhibitorDetailReply struct {
+    Exhibitor           ExhibitorInfoJson       `json:"exhibitor"`
+}
+
+// 导入展商
+type ImportExhibitorArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    EditionId           int64       `json:"-" mapstructure:"-"`    // 导入到的届次，为0时使用当前届次
+    Filename            string      `json:"-" mapstructure:"-"`
+    Data                []byte      `json:"-" mapstructure:"-"`
+}
+type ImportExhibitorReply struct {
+    CreatedNum          int                 `json:"created_num"`    // 新增数
+    UpdatedNum          int                 `json:"updated_num"`    // 更新数
+    FailNum             int                 `json:"fail_num"`
+    Errors              []ImportErrorJson   `json:"errors"`
+}
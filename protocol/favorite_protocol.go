@@ -0,0 +1,52 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/16 20:40
+ */
+package protocol
+
+import "third/go-local"
+
+// 收藏数
+type FavoriteCountJson struct {
+    TargetId        int64           `json:"target_id"`
+    FavoriteNum     int             `json:"favorite_num"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 收藏、取消收藏
+type FavoriteArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    TargetType          int         `json:"target_type" mapstructure:"target_type"`    // 类型，1: 展商 2: 活动 3: 文章
+    TargetId            int64       `json:"target_id" mapstructure:"target_id"`
+}
+type FavoriteReply struct {
+    IsFavorite          bool        `json:"is_favorite"`
+    FavoriteNum         int         `json:"favorite_num"`       // 收藏数
+}
+
+// 我的收藏，按类型分组
+type MyFavoriteListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+}
+type MyFavoriteListReply struct {
+    ExhibitorList       []ExhibitorInfoJson     `json:"exhibitor_list"`
+    ActivityList        []ActivityInfoJson      `json:"activity_list"`
+    ArticleList         []ArticleInfoJson       `json:"article_list"`
+}
+
+// 收藏数
+type FavoriteCountListArgs struct {
+    local.TraceParam
+
+    TargetType          int         `json:"target_type" mapstructure:"target_type"`
+    TargetIds           string      `json:"target_ids" mapstructure:"target_ids"`      // 逗号分隔
+}
+type FavoriteCountListReply struct {
+    CountList           []FavoriteCountJson     `json:"count_list"`
+}
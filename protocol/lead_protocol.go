@@ -0,0 +1,185 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/21 20:50
+ */
+package protocol
+
+import "third/go-local"
+
+// 展商工作人员
+type ExhibitorStaffJson struct {
+    UserId          int64           `json:"user_id"`
+    Name            string          `json:"name"`
+    Phone           string          `json:"phone"`
+    CreateTime      string          `json:"create_time"`
+}
+
+// 工作人员所属的展商
+type StaffExhibitorJson struct {
+    Id              int64           `json:"id"`
+    EditionId       int64           `json:"edition_id"`
+    Name            string          `json:"name"`
+    Booth           string          `json:"booth"`
+}
+
+// 展商看到的潜在客户，观众未同意时不返回联系方式
+type LeadInfoJson struct {
+    Id                  int64       `json:"id"`
+    ExhibitorId         int64       `json:"exhibitor_id"`
+    Name                string      `json:"name"`
+    Phone               string      `json:"phone"`
+    Email               string      `json:"email"`
+    Notes               string      `json:"notes"`
+    Rating              int         `json:"rating"`             // 意向评级，1-5，0: 未评级
+    ConsentContact      bool        `json:"consent_contact"`    // 观众同意展商查看联系方式
+    ConsentMarketing    bool        `json:"consent_marketing"`  // 观众同意接收展商的推广信息
+    ScanNum             int         `json:"scan_num"`
+    FirstScanTime       string      `json:"first_scan_time"`
+    LastScanTime        string      `json:"last_scan_time"`
+}
+
+// 观众看到的扫码记录
+type MyLeadJson struct {
+    Id                  int64       `json:"id"`
+    ExhibitorId         int64       `json:"exhibitor_id"`
+    ExhibitorName       string      `json:"exhibitor_name"`
+    Booth               string      `json:"booth"`
+    ConsentContact      bool        `json:"consent_contact"`
+    ConsentMarketing    bool        `json:"consent_marketing"`
+    LastScanTime        string      `json:"last_scan_time"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 添加展商工作人员
+type AddExhibitorStaffArgs struct {
+    local.TraceParam
+
+    OperatorId      int64           `json:"-" mapstructure:"-"`
+    ExhibitorId     int64           `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+    Phone           string          `json:"phone"`          // 已注册用户的电话号码
+    CountryCode     string          `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
+}
+type AddExhibitorStaffReply struct {
+    Staff           ExhibitorStaffJson  `json:"staff"`
+}
+
+// 移除展商工作人员
+type RemoveExhibitorStaffArgs struct {
+    local.TraceParam
+
+    OperatorId      int64           `json:"-" mapstructure:"-"`
+    ExhibitorId     int64           `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+    UserId          int64           `json:"user_id" mapstructure:"user_id"`
+}
+type RemoveExhibitorStaffReply struct {
+}
+
+// 展商工作人员列表
+type ExhibitorStaffListArgs struct {
+    local.TraceParam
+
+    ExhibitorId     int64           `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+}
+type ExhibitorStaffListReply struct {
+    StaffList       []ExhibitorStaffJson    `json:"staff_list"`
+}
+
+// 我所属的展商
+type StaffExhibitorListArgs struct {
+    local.TraceParam
+
+    UserId          int64           `json:"-" mapstructure:"-"`
+}
+type StaffExhibitorListReply struct {
+    ExhibitorList   []StaffExhibitorJson    `json:"exhibitor_list"`
+}
+
+// 扫观众门票记录潜在客户
+type ScanLeadArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ExhibitorId         int64       `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+    Payload             string      `json:"payload"`            // 门票二维码内容
+    Notes               string      `json:"notes"`
+    Rating              int         `json:"rating"`
+    ConsentContact      bool        `json:"consent_contact" mapstructure:"consent_contact"`      // 需要观众当面确认，默认不同意
+    ConsentMarketing    bool        `json:"consent_marketing" mapstructure:"consent_marketing"`
+}
+type ScanLeadReply struct {
+    Lead                LeadInfoJson    `json:"lead"`
+}
+
+// 修改潜在客户的备注和评级
+type UpdateLeadArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ExhibitorId         int64       `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+    LeadId              int64       `json:"lead_id" mapstructure:"lead_id"`
+    Notes               string      `json:"notes"`
+    Rating              int         `json:"rating"`
+}
+type UpdateLeadReply struct {
+    Lead                LeadInfoJson    `json:"lead"`
+}
+
+// 展商的潜在客户列表
+type LeadListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ExhibitorId         int64       `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type LeadListReply struct {
+    LeadList            []LeadInfoJson  `json:"lead_list"`
+    TotalNum		    int 			`json:"total_num"`
+}
+
+// 导出展商的潜在客户
+type ExportLeadArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ExhibitorId         int64       `json:"exhibitor_id" mapstructure:"exhibitor_id"`
+}
+type ExportLeadReply struct {
+    Filename            string      `json:"-"`
+    Csv                 []byte      `json:"-"`
+}
+
+// 观众查看被哪些展商记录
+type MyLeadListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+}
+type MyLeadListReply struct {
+    LeadList            []MyLeadJson    `json:"lead_list"`
+}
+
+// 观众撤回对展商的授权
+type RevokeLeadConsentArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    LeadId              int64       `json:"lead_id" mapstructure:"lead_id"`
+}
+type RevokeLeadConsentReply struct {
+}
+
+// 观众重新授权
+type GrantLeadConsentArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    LeadId              int64       `json:"lead_id" mapstructure:"lead_id"`
+    ConsentContact      bool        `json:"consent_contact" mapstructure:"consent_contact"`
+    ConsentMarketing    bool        `json:"consent_marketing" mapstructure:"consent_marketing"`
+}
+type GrantLeadConsentReply struct {
+}
@@ -0,0 +1,202 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 22:10
+ */
+package protocol
+
+import "third/go-local"
+
+type TicketProductJson struct {
+    Id              int64           `json:"id"`
+    EditionId       int64           `json:"edition_id"`
+    Name            string          `json:"name"`
+    Description     string          `json:"description"`
+    Price           int             `json:"price"`          // 价格，单位分
+    Remain          int             `json:"remain"`         // 剩余数量，-1: 不限
+    ValidFrom       string          `json:"valid_from"`     // 门票生效时间，为空时使用届次门票有效期
+    ValidTo         string          `json:"valid_to"`
+}
+
+type OrderItemJson struct {
+    ProductId       int64           `json:"product_id"`
+    ProductName     string          `json:"product_name"`
+    Price           int             `json:"price"`          // 单价，单位分
+    Quantity        int             `json:"quantity"`
+}
+
+type OrderInfoJson struct {
+    OrderNo         string          `json:"order_no"`
+    EditionId       int64           `json:"edition_id"`
+    TotalFee        int             `json:"total_fee"`      // 金额，单位分
+    RefundFee       int             `json:"refund_fee"`     // 已申请退款的金额，单位分
+    Status          int             `json:"status"`         // 状态，1: 待支付 2: 已支付 3: 已关闭 4: 已退款
+    PaidTime        string          `json:"paid_time"`
+    ExpireTime      string          `json:"expire_time"`    // 超过此时间未支付自动关闭
+    CreateTime      string          `json:"create_time"`
+    Items           []OrderItemJson `json:"items"`
+}
+
+type RefundInfoJson struct {
+    RefundNo        string          `json:"refund_no"`
+    OrderNo         string          `json:"order_no"`
+    RefundFee       int             `json:"refund_fee"`     // 退款金额，单位分
+    TicketNum       int             `json:"ticket_num"`     // 退款的门票数量
+    Reason          string          `json:"reason"`
+    Source          int             `json:"source"`         // 来源，1: 用户申请 2: 管理员操作
+    Status          int             `json:"status"`         // 状态，1: 退款中 2: 退款成功 3: 退款失败
+    SuccessTime     string          `json:"success_time"`
+    CreateTime      string          `json:"create_time"`
+}
+
+type OrderLogJson struct {
+    RefundNo        string          `json:"refund_no"`
+    Action          string          `json:"action"`         // 操作，create, pay, close, refund_apply, refund_success, refund_fail
+    Status          int             `json:"status"`         // 操作后的订单状态
+    Amount          int             `json:"amount"`         // 涉及的金额，单位分
+    TransactionId   string          `json:"transaction_id"` // 支付平台的交易号或退款单号
+    OperatorId      int64           `json:"operator_id"`
+    Remark          string          `json:"remark"`
+    CreateTime      string          `json:"create_time"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 在售门票
+type TicketProductListArgs struct {
+    local.TraceParam
+
+    EditionId           int64       `json:"edition_id" mapstructure:"edition_id"`  // 展会届次，为0时按域名确定或使用当前届次
+    Host                string      `json:"-" mapstructure:"-"`
+}
+type TicketProductListReply struct {
+    ProductList         []TicketProductJson     `json:"product_list"`
+}
+
+// 下单的商品
+type CreateOrderItemArgs struct {
+    ProductId           int64       `json:"product_id" mapstructure:"product_id"`
+    Quantity            int         `json:"quantity"`
+}
+
+// 下单，返回H5页面调起支付的参数
+type CreateOrderArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    ClientIp            string      `json:"-" mapstructure:"-"`
+    Items               []CreateOrderItemArgs   `json:"items"`
+}
+type CreateOrderReply struct {
+    Order               OrderInfoJson       `json:"order"`
+    PayParams           map[string]string   `json:"pay_params"`     // WeixinJSBridge getBrandWCPayRequest 的参数
+}
+
+// 待支付订单重新获取支付参数
+type OrderPayParamsArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+}
+type OrderPayParamsReply struct {
+    PayParams           map[string]string   `json:"pay_params"`
+}
+
+// 取消待支付订单
+type CancelOrderArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+}
+type CancelOrderReply struct {
+    Status              int         `json:"status"`
+}
+
+// 我的订单
+type MyOrderListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type MyOrderListReply struct {
+    OrderList           []OrderInfoJson     `json:"order_list"`
+    TotalNum		    int 			    `json:"total_num"`
+}
+
+// 订单详情，已支付的订单返回发放的门票
+type OrderDetailArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+}
+type OrderDetailReply struct {
+    Order               OrderInfoJson       `json:"order"`
+    TicketList          []TicketInfoJson    `json:"ticket_list"`
+    RefundList          []RefundInfoJson    `json:"refund_list"`
+}
+
+// 模拟支付成功，只在使用本地模拟支付时可用
+type FakePayConfirmArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+}
+type FakePayConfirmReply struct {
+    Status              int         `json:"status"`
+}
+
+// 用户申请退款，ticket_nos为空时退全部可退的门票
+type ApplyRefundArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+    TicketNos           []string    `json:"ticket_nos" mapstructure:"ticket_nos"`
+    Reason              string      `json:"reason"`
+}
+type ApplyRefundReply struct {
+    Refund              RefundInfoJson      `json:"refund"`
+}
+
+// 管理员退款，不受退款截止时间限制
+type AdminRefundArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+    TicketNos           []string    `json:"ticket_nos" mapstructure:"ticket_nos"`
+    Reason              string      `json:"reason"`
+}
+type AdminRefundReply struct {
+    Refund              RefundInfoJson      `json:"refund"`
+}
+
+// 订单流水和退款记录，用于财务对账
+type OrderLogListArgs struct {
+    local.TraceParam
+
+    OrderNo             string      `json:"order_no" mapstructure:"order_no"`
+}
+type OrderLogListReply struct {
+    Order               OrderInfoJson       `json:"order"`
+    LogList             []OrderLogJson      `json:"log_list"`
+    RefundList          []RefundInfoJson    `json:"refund_list"`
+}
+
+// 模拟退款结果通知，只在使用本地模拟支付时可用
+type FakeRefundConfirmArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    RefundNo            string      `json:"refund_no" mapstructure:"refund_no"`
+    Success             bool        `json:"success"`
+}
+type FakeRefundConfirmReply struct {
+    Status              int         `json:"status"`
+}
@@ -0,0 +1,171 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/10 21:30
+ */
+package protocol
+
+import "third/go-local"
+
+type PetInfoJson struct {
+    Id              int64           `json:"id"`
+    Name            string          `json:"name"`
+    Species         int             `json:"species"`        // 种类，1: 狗 2: 猫 3: 小宠 4: 鸟 5: 爬宠 6: 水族
+    SpeciesName     string          `json:"species_name"`
+    BreedId         int64           `json:"breed_id"`       // 品种，0: 未知
+    BreedName       string          `json:"breed_name"`
+    Birthday        string          `json:"birthday"`       // 2006-01-02，未填写时为空
+    Gender          int             `json:"gender"`         // 性别，0: 未知 1: 公 2: 母
+    Weight          int             `json:"weight"`         // 体重，单位克，0: 未知
+    Cover           string          `json:"cover"`          // 封面，第一张照片
+}
+
+type PetVaccinationJson struct {
+    Id              int64           `json:"id"`
+    Vaccine         string          `json:"vaccine"`        // 疫苗名称
+    VaccinateDate   string          `json:"vaccinate_date"` // 接种日期，2006-01-02
+    ExpireDate      string          `json:"expire_date"`    // 有效期至，未填写时为空
+    Hospital        string          `json:"hospital"`       // 接种机构
+}
+
+type PetPhotoJson struct {
+    Id              int64           `json:"id"`
+    Url             string          `json:"url"`
+}
+
+type PetBreedJson struct {
+    Id              int64           `json:"id"`
+    Species         int             `json:"species"`
+    Name            string          `json:"name"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 品种列表
+type PetBreedListArgs struct {
+    local.TraceParam
+
+    Species             int         `json:"species"`        // 种类，不传返回所有种类
+}
+type PetBreedListReply struct {
+    BreedList           []PetBreedJson      `json:"breed_list"`
+}
+
+// 添加宠物
+type CreatePetArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    Name                string      `json:"name"`
+    Species             int         `json:"species"`
+    BreedId             int64       `json:"breed_id" mapstructure:"breed_id"`
+    Birthday            string      `json:"birthday"`       // 2006-01-02
+    Gender              int         `json:"gender"`
+    Weight              int         `json:"weight"`         // 单位克
+}
+type CreatePetReply struct {
+    Pet                 PetInfoJson         `json:"pet"`
+}
+
+// 修改宠物资料，所有字段都会修改
+type UpdatePetArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    Id                  int64       `json:"id"`
+    Name                string      `json:"name"`
+    Species             int         `json:"species"`
+    BreedId             int64       `json:"breed_id" mapstructure:"breed_id"`
+    Birthday            string      `json:"birthday"`
+    Gender              int         `json:"gender"`
+    Weight              int         `json:"weight"`
+}
+type UpdatePetReply struct {
+    Pet                 PetInfoJson         `json:"pet"`
+}
+
+// 删除宠物
+type DeletePetArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    Id                  int64       `json:"id"`
+}
+type DeletePetReply struct {
+
+}
+
+// 我的宠物列表
+type MyPetListArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+}
+type MyPetListReply struct {
+    PetList             []PetInfoJson       `json:"pet_list"`
+}
+
+// 宠物详情
+type PetDetailArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    Id                  int64       `json:"id"`
+}
+type PetDetailReply struct {
+    Pet                 PetInfoJson             `json:"pet"`
+    Vaccinations        []PetVaccinationJson    `json:"vaccinations"`
+    Photos              []PetPhotoJson          `json:"photos"`
+}
+
+// 添加疫苗记录
+type AddPetVaccinationArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+    Vaccine             string      `json:"vaccine"`
+    VaccinateDate       string      `json:"vaccinate_date" mapstructure:"vaccinate_date"`
+    ExpireDate          string      `json:"expire_date" mapstructure:"expire_date"`
+    Hospital            string      `json:"hospital"`
+}
+type AddPetVaccinationReply struct {
+    Vaccination         PetVaccinationJson  `json:"vaccination"`
+}
+
+// 删除疫苗记录
+type DeletePetVaccinationArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+    Id                  int64       `json:"id"`
+}
+type DeletePetVaccinationReply struct {
+
+}
+
+// 上传宠物照片
+type UploadPetPhotoArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+    Filename            string      `json:"-" mapstructure:"-"`
+    Data                []byte      `json:"-" mapstructure:"-"`
+}
+type UploadPetPhotoReply struct {
+    Photo               PetPhotoJson        `json:"photo"`
+}
+
+// 删除宠物照片
+type DeletePetPhotoArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+    Id                  int64       `json:"id"`
+}
+type DeletePetPhotoReply struct {
+
+}
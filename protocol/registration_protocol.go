@@ -0,0 +1,91 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/20 20:50
+ */
+package protocol
+
+import "third/go-local"
+
+type RegistrationJobJson struct {
+    JobNo           string          `json:"job_no"`
+    EditionId       int64           `json:"edition_id"`     // 发放门票的届次
+    Filename        string          `json:"filename"`
+    Status          int             `json:"status"`         // 状态，1: 处理中 2: 已完成 3: 异常中断
+    TotalNum        int             `json:"total_num"`      // 数据行数
+    ProcessedNum    int             `json:"processed_num"`  // 已处理行数
+    CreatedNum      int             `json:"created_num"`
+    DuplicateNum    int             `json:"duplicate_num"`
+    FailNum         int             `json:"fail_num"`
+    ErrMsg          string          `json:"err_msg"`
+    CreateTime      string          `json:"create_time"`
+    FinishTime      string          `json:"finish_time"`
+}
+
+type RegistrationRowJson struct {
+    Row             int             `json:"row"`            // 表格中的行号，从1开始
+    Name            string          `json:"name"`
+    Phone           string          `json:"phone"`
+    Email           string          `json:"email"`
+    Status          int             `json:"status"`         // 结果，1: 已注册 2: 重复 3: 失败
+    UserId          int64           `json:"user_id"`
+    TicketNo        string          `json:"ticket_no"`
+    Desc            string          `json:"desc"`           // 重复或失败的原因
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 上传表格团体登记观众
+type ImportRegistrationArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    Filename            string      `json:"-" mapstructure:"-"`
+    Data                []byte      `json:"-" mapstructure:"-"`
+}
+type ImportRegistrationReply struct {
+    Job                 RegistrationJobJson     `json:"job"`
+    RowList             []RegistrationRowJson   `json:"row_list"`   // 同步处理完成时返回每行结果，后台处理时为空
+}
+
+// 登记任务的进度
+type RegistrationJobArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    IsAdmin             bool        `json:"-" mapstructure:"-"`
+    JobNo               string      `json:"job_no" mapstructure:"job_no"`
+}
+type RegistrationJobReply struct {
+    Job                 RegistrationJobJson     `json:"job"`
+}
+
+// 登记任务每行的结果
+type RegistrationRowListArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    IsAdmin             bool        `json:"-" mapstructure:"-"`
+    JobNo               string      `json:"job_no" mapstructure:"job_no"`
+    Status              int         `json:"status"`     // 结果，为0时返回全部
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type RegistrationRowListReply struct {
+    RowList             []RegistrationRowJson   `json:"row_list"`
+    TotalNum		    int 			        `json:"total_num"`
+}
+
+// 登记任务列表，管理员返回所有人的任务
+type RegistrationJobListArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    IsAdmin             bool        `json:"-" mapstructure:"-"`
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type RegistrationJobListReply struct {
+    JobList             []RegistrationJobJson   `json:"job_list"`
+    TotalNum		    int 			        `json:"total_num"`
+}
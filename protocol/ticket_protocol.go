@@ -0,0 +1,54 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/3 21:20
+ */
+package protocol
+
+import "third/go-local"
+
+type TicketInfoJson struct {
+    TicketNo        string          `json:"ticket_no"`
+    EditionId       int64           `json:"edition_id"`     // 展会届次
+    ProductId       int64           `json:"product_id"`     // 付费门票商品，0: 免费门票
+    Status          int             `json:"status"`         // 状态，1: 有效 2: 作废
+    ValidFrom       string          `json:"valid_from"`     // 生效时间，2006-01-02 15:04:05
+    ValidTo         string          `json:"valid_to"`       // 失效时间
+    Payload         string          `json:"payload"`        // 二维码内容
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 我的门票
+type MyTicketListArgs struct {
+    local.TraceParam
+
+    UserId          int64           `json:"-" mapstructure:"-"`
+    EditionId       int64           `json:"edition_id" mapstructure:"edition_id"`  // 补发免费门票的届次，为0时按域名确定或使用当前届次
+    Host            string          `json:"-" mapstructure:"-"`
+}
+type MyTicketListReply struct {
+    TicketList      []TicketInfoJson    `json:"ticket_list"`
+}
+
+// 门票二维码图片
+type TicketQrcodeArgs struct {
+    local.TraceParam
+
+    UserId          int64           `json:"-" mapstructure:"-"`
+    TicketNo        string          `json:"ticket_no" mapstructure:"ticket_no"`
+    Size            int             `json:"size"`           // 图片边长，默认256
+}
+type TicketQrcodeReply struct {
+    Png             []byte          `json:"-"`
+}
+
+// 门票签名公钥，扫码设备离线校验使用
+type TicketPublicKeyArgs struct {
+    local.TraceParam
+}
+type TicketPublicKeyReply struct {
+    Algorithm       string          `json:"algorithm"`
+    KeyId           string          `json:"key_id"`
+    PublicKey       string          `json:"public_key"`     // base64
+}
@@ -17,11 +17,18 @@ type UserInfoJson struct {
     Nickname        string          `json:"nickname"`   // 微信昵称
     Avatar          string          `json:"avatar"`     // 微信头像
     Gender          string          `json:"gender"`     // 性别，0: 无性别 1: 男 2: 女
-    Phone           string          `json:"phone"`
+    Phone           string          `json:"phone"`      // E.164格式，如 +8613800000000
     Email           string          `json:"email"`
     Openid          string          `json:"openid"`     // 微信用户凭证
 }
 
+// 登录凭证
+type TokenInfoJson struct {
+    AccessToken     string          `json:"access_token"`
+    RefreshToken    string          `json:"refresh_token"`
+    ExpiresIn       int             `json:"expires_in"`  // access_token有效期，单位秒
+}
+
 // ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
 // 电话注册参数
 type UserPhoneRegistArgs struct {
@@ -30,21 +37,25 @@ type UserPhoneRegistArgs struct {
     Name            string              `json:"name"`
     Gender          string              `json:"gender"`         // 性别，0: 无性别 1: 男 2: 女
     Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
     Email           string              `json:"email"`
 
     RegistType      int                 `json:"regist_type" mapstructure:"regist_type"`    // 1: 微信   2：官网
     Nickname        string              `json:"nickname"`       // 微信昵称
     Avatar          string              `json:"avatar"`         // 微信头像
-    Openid          string              `json:"openid"`         // 微信用户凭证
+    Ticket          string              `json:"ticket"`         // 微信授权回调签发的一次性登录票据，微信注册时必填
 }
+// 用户信息字段直接放在返回数据中，兼容原来的返回格式
 type UserPhoneRegistReply struct {
-    User            UserInfoJson        `json:"user_info"`
+    UserInfoJson
+    Token           TokenInfoJson       `json:"token"`
 }
 
 // openid获取用户信息
 type GetUserByOpenidArgs struct {
     local.TraceParam
 
+    UserId          int64               `json:"-" mapstructure:"-"`   // 当前登录用户
     Openid          string              `json:"openid"`
 }
 type GetUserByOpenidReply struct {
@@ -56,6 +67,12 @@ type SendVerifyCodeArgs struct {
     local.TraceParam
 
     Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
+    Purpose         string              `json:"purpose"`            // 验证码用途，register, login, reset_password, bind_phone
+    CaptchaId       string              `json:"captcha_id" mapstructure:"captcha_id"`
+    CaptchaCode     string              `json:"captcha_code" mapstructure:"captcha_code"`
+    ClientIp        string              `json:"-" mapstructure:"-"`
+    DeviceId        string              `json:"-" mapstructure:"-"`
 }
 type SendVerifyCodeReply struct {
 
@@ -66,9 +83,143 @@ type CheckVerifyCodeArgs struct {
     local.TraceParam
 
     Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
     VerifyCode      string              `json:"verify_code" mapstructure:"verify_code"`
+    Purpose         string              `json:"purpose"`            // 验证码用途，register, login
 }
 type CheckVerifyCodeReply struct {
     State           int                 `json:"state"`
     User            UserInfoJson        `json:"user_info"`
-}
\ No newline at end of file
+    Token           TokenInfoJson       `json:"token"`          // 已注册用户返回登录凭证
+}
+
+// 刷新登录凭证
+type RefreshTokenArgs struct {
+    local.TraceParam
+
+    RefreshToken    string              `json:"refresh_token" mapstructure:"refresh_token"`
+}
+type RefreshTokenReply struct {
+    Token           TokenInfoJson       `json:"token"`
+}
+
+// 获取当前登录用户信息
+type GetUserInfoArgs struct {
+    local.TraceParam
+
+    UserId          int64               `json:"-" mapstructure:"-"`
+}
+type GetUserInfoReply struct {
+    User            UserInfoJson        `json:"user_info"`
+}
+
+// 退出登录
+type UserLogoutArgs struct {
+    local.TraceParam
+
+    AccessToken     string              `json:"-" mapstructure:"-"`
+    RefreshToken    string              `json:"refresh_token" mapstructure:"refresh_token"`
+}
+type UserLogoutReply struct {
+
+}
+
+// 设置密码
+type SetPasswordArgs struct {
+    local.TraceParam
+
+    UserId          int64               `json:"-" mapstructure:"-"`
+    OldPassword     string              `json:"old_password" mapstructure:"old_password"`  // 已设置过密码时必填
+    Password        string              `json:"password"`
+}
+type SetPasswordReply struct {
+
+}
+
+// 密码登录
+type PasswordLoginArgs struct {
+    local.TraceParam
+
+    Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
+    Password        string              `json:"password"`
+}
+type PasswordLoginReply struct {
+    User            UserInfoJson        `json:"user_info"`
+    Token           TokenInfoJson       `json:"token"`
+}
+
+// 重置密码
+type ResetPasswordArgs struct {
+    local.TraceParam
+
+    Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
+    VerifyCode      string              `json:"verify_code" mapstructure:"verify_code"`
+    Password        string              `json:"password"`
+}
+type ResetPasswordReply struct {
+
+}
+
+
+// 微信授权登录票据换取登录凭证
+type ExchangeLoginTicketArgs struct {
+    local.TraceParam
+
+    Ticket          string              `json:"ticket"`
+}
+type ExchangeLoginTicketReply struct {
+    User            UserInfoJson        `json:"user_info"`
+    Token           TokenInfoJson       `json:"token"`
+}
+
+
+// 绑定电话号码
+type BindPhoneArgs struct {
+    local.TraceParam
+
+    UserId          int64               `json:"-" mapstructure:"-"`
+    Phone           string              `json:"phone"`
+    CountryCode     string              `json:"country_code" mapstructure:"country_code"`   // 国际区号，如 86、852，默认86
+    VerifyCode      string              `json:"verify_code" mapstructure:"verify_code"`
+}
+type BindPhoneReply struct {
+    Merged          bool                `json:"merged"`         // 是否合并了账号
+    User            UserInfoJson        `json:"user_info"`
+    Token           TokenInfoJson       `json:"token"`          // 合并账号后返回新的登录凭证
+}
+
+
+// 更新个人资料，不传的字段不修改
+type UpdateProfileArgs struct {
+    local.TraceParam
+
+    UserId          int64               `json:"-" mapstructure:"-"`
+    Name            string              `json:"name"`
+    Gender          string              `json:"gender"`         // 性别，0: 无性别 1: 男 2: 女
+    Email           string              `json:"email"`
+}
+type UpdateProfileReply struct {
+    User            UserInfoJson        `json:"user_info"`
+}
+
+// 上传头像
+type UploadAvatarArgs struct {
+    local.TraceParam
+
+    UserId          int64               `json:"-" mapstructure:"-"`
+    Filename        string              `json:"-" mapstructure:"-"`
+    Data            []byte              `json:"-" mapstructure:"-"`
+}
+type UploadAvatarReply struct {
+    Avatar          string              `json:"avatar"`
+}
+
+type NewCaptchaArgs struct {
+    local.TraceParam
+}
+type NewCaptchaReply struct {
+    CaptchaId       string              `json:"captcha_id"`
+    Image           string              `json:"image"`              // base64 png, data url
+}
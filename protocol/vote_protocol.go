@@ -0,0 +1,100 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/15 21:00
+ */
+package protocol
+
+import "third/go-local"
+
+// 人气排行
+type PetVoteRankJson struct {
+    Ranking         int             `json:"ranking"`        // 名次，从1开始
+    PetId           int64           `json:"pet_id"`
+    PetName         string          `json:"pet_name"`
+    Cover           string          `json:"cover"`
+    Votes           int             `json:"votes"`          // 票数
+}
+
+// 投票记录
+type PetVoteJson struct {
+    Id              int64           `json:"id"`
+    UserId          int64           `json:"user_id"`
+    PetId           int64           `json:"pet_id"`
+    Ip              string          `json:"ip"`
+    DeviceId        string          `json:"device_id"`
+    FraudFlags      string          `json:"fraud_flags"`    // 可疑标记，ip_burst, device_burst, new_account，逗号分隔
+    CreateTime      string          `json:"create_time"`
+}
+
+// ++++++++++++++++++++ 请求参数的数据格式 ++++++++++++++++++++++
+
+// 人气排行榜
+type PetVoteRankListArgs struct {
+    local.TraceParam
+
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type PetVoteRankListReply struct {
+    RankList            []PetVoteRankJson   `json:"rank_list"`
+    TotalNum		    int 			    `json:"total_num"`
+}
+
+// 投票
+type VotePetArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+    PetId               int64       `json:"pet_id" mapstructure:"pet_id"`
+    ClientIp            string      `json:"-" mapstructure:"-"`
+    DeviceId            string      `json:"-" mapstructure:"-"`
+}
+type VotePetReply struct {
+    RemainVotes         int         `json:"remain_votes"`   // 今天剩余票数
+    Rank                PetVoteRankJson `json:"rank"`       // 投票后宠物的排名
+}
+
+// 我今天的剩余票数
+type MyVoteInfoArgs struct {
+    local.TraceParam
+
+    UserId              int64       `json:"-" mapstructure:"-"`
+}
+type MyVoteInfoReply struct {
+    DailyVotes          int         `json:"daily_votes"`    // 每天票数
+    RemainVotes         int         `json:"remain_votes"`   // 今天剩余票数
+}
+
+// 可疑投票列表
+type SuspiciousVoteListArgs struct {
+    local.TraceParam
+
+    PageNum     		int			`json:"page_num" mapstructure:"page_num"`
+    PageSize    		int         `json:"page_size" mapstructure:"page_size"`
+}
+type SuspiciousVoteListReply struct {
+    VoteList            []PetVoteJson       `json:"vote_list"`
+    TotalNum		    int 			    `json:"total_num"`
+}
+
+// 作废投票
+type VoidPetVoteArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+    VoteIds             []int64     `json:"vote_ids" mapstructure:"vote_ids"`
+}
+type VoidPetVoteReply struct {
+    VoidNum             int64       `json:"void_num"`       // 作废的票数
+}
+
+// 重新计算排行榜
+type RebuildVoteRankArgs struct {
+    local.TraceParam
+
+    OperatorId          int64       `json:"-" mapstructure:"-"`
+}
+type RebuildVoteRankReply struct {
+    PetNum              int         `json:"pet_num"`        // 有票的宠物数
+}
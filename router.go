@@ -8,6 +8,7 @@ package main
 import (
     "third/gin"
     "pet/utils"
+    "pet/model"
     "os"
     "time"
     "third/go-local"
@@ -33,9 +34,35 @@ func StartHttpServer() {
     // user
     user_router := router.Group("/api/users")
     user_router.POST("/phone_regist", UserPhoneRegist)
-    user_router.GET("/get_by_openid", GetUserByOpenid)
     user_router.POST("/send_verify_code", SendVerifyCode)
     user_router.POST("/check_verify_code", CheckVerifyCode)
+    user_router.POST("/refresh_token", RefreshUserToken)
+    user_router.POST("/password_login", PasswordLogin)
+    user_router.POST("/reset_password", ResetPassword)
+    user_router.POST("/exchange_login_ticket", ExchangeLoginTicket)
+
+    // user, 需要登录
+    auth_user_router := router.Group("/api/users", GinAuthRequired())
+    auth_user_router.GET("/get_by_openid", GetUserByOpenid)
+    auth_user_router.GET("/get_user_info", GetUserInfo)
+    auth_user_router.POST("/logout", UserLogout)
+    auth_user_router.POST("/set_password", SetPassword)
+    auth_user_router.POST("/bind_phone", BindPhone)
+    auth_user_router.POST("/update_profile", UpdateProfile)
+    auth_user_router.POST("/upload_avatar", UploadAvatar)
+
+    // captcha
+    captcha_router := router.Group("/api/captcha")
+    captcha_router.GET("/new", NewCaptcha)
+
+    // edition
+    edition_router := router.Group("/api/edition")
+    edition_router.GET("/get_edition_list", GetEditionList)
+    edition_router.GET("/get_current_edition", GetCurrentEdition)
+
+    // edition, 管理员
+    admin_edition_router := router.Group("/api/edition", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_ADMIN))
+    admin_edition_router.POST("/save", SaveEdition)
 
     // banner
     banner_router := router.Group("/api/banner")
@@ -45,6 +72,147 @@ func StartHttpServer() {
     article_router := router.Group("/api/article")
     article_router.GET("get_article_list", GetArticleListByPage)
 
+    // activity
+    activity_router := router.Group("/api/activity")
+    activity_router.GET("/get_activity_list", GetActivityListByPage)
+    activity_router.GET("/get_activity_detail", GetActivityDetail)
+
+    // activity, 需要登录
+    auth_activity_router := router.Group("/api/activity", GinAuthRequired())
+    auth_activity_router.POST("/signup", SignupActivity)
+    auth_activity_router.POST("/cancel_signup", CancelActivitySignup)
+    auth_activity_router.GET("/get_my_signup_list", GetMySignupList)
+
+    // ticket
+    ticket_router := router.Group("/api/ticket")
+    ticket_router.GET("/get_public_key", GetTicketPublicKey)
+
+    // ticket, 需要登录
+    auth_ticket_router := router.Group("/api/ticket", GinAuthRequired())
+    auth_ticket_router.GET("/get_my_ticket_list", GetMyTicketList)
+    auth_ticket_router.GET("/get_ticket_qrcode", GetTicketQrcode)
+
+    // checkin, 检票员
+    checkin_router := router.Group("/api/checkin", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_GATE_STAFF))
+    checkin_router.POST("/scan", TicketCheckin)
+    checkin_router.POST("/batch_sync", CheckinBatchSync)
+
+    // exhibitor
+    exhibitor_router := router.Group("/api/exhibitor")
+    exhibitor_router.GET("/get_category_list", GetExhibitorCategoryList)
+    exhibitor_router.GET("/get_exhibitor_list", GetExhibitorListByPage)
+    exhibitor_router.GET("/get_exhibitor_detail", GetExhibitorDetail)
+
+    // exhibitor, 管理员
+    admin_exhibitor_router := router.Group("/api/exhibitor", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_ADMIN))
+    admin_exhibitor_router.POST("/import", ImportExhibitor)
+    admin_exhibitor_router.POST("/add_staff", AddExhibitorStaff)
+    admin_exhibitor_router.POST("/remove_staff", RemoveExhibitorStaff)
+    admin_exhibitor_router.GET("/get_staff_list", GetExhibitorStaffList)
+
+    // pet
+    pet_router := router.Group("/api/pets")
+    pet_router.GET("/get_breed_list", GetPetBreedList)
+
+    // pet, 需要登录，只能操作自己的宠物
+    auth_pet_router := router.Group("/api/pets", GinAuthRequired())
+    auth_pet_router.POST("/create", CreatePet)
+    auth_pet_router.POST("/update", UpdatePet)
+    auth_pet_router.POST("/delete", DeletePet)
+    auth_pet_router.GET("/get_my_pet_list", GetMyPetList)
+    auth_pet_router.GET("/get_pet_detail", GetPetDetail)
+    auth_pet_router.POST("/add_vaccination", AddPetVaccination)
+    auth_pet_router.POST("/delete_vaccination", DeletePetVaccination)
+    auth_pet_router.POST("/upload_photo", UploadPetPhoto)
+    auth_pet_router.POST("/delete_photo", DeletePetPhoto)
+
+    // competition
+    competition_router := router.Group("/api/competition")
+    competition_router.GET("/get_class_list", GetCompetitionClassList)
+    competition_router.GET("/get_result", GetCompetitionResult)
+
+    // competition, 需要登录
+    auth_competition_router := router.Group("/api/competition", GinAuthRequired())
+    auth_competition_router.POST("/enter", EnterCompetition)
+    auth_competition_router.POST("/withdraw", WithdrawCompetition)
+    auth_competition_router.GET("/get_my_entry_list", GetMyCompetitionEntryList)
+
+    // competition, 裁判
+    judge_competition_router := router.Group("/api/competition", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_JUDGE))
+    judge_competition_router.GET("/get_judge_entry_list", GetJudgeEntryList)
+    judge_competition_router.POST("/submit_score", SubmitCompetitionScore)
+
+    // competition, 管理员
+    admin_competition_router := router.Group("/api/competition", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_ADMIN))
+    admin_competition_router.POST("/create_class", CreateCompetitionClass)
+    admin_competition_router.POST("/update_status", UpdateCompetitionStatus)
+
+    // vote
+    vote_router := router.Group("/api/vote")
+    vote_router.GET("/get_rank_list", GetPetVoteRankList)
+
+    // vote, 需要登录
+    auth_vote_router := router.Group("/api/vote", GinAuthRequired())
+    auth_vote_router.POST("/vote", VotePet)
+    auth_vote_router.GET("/get_my_vote_info", GetMyVoteInfo)
+
+    // vote, 管理员
+    admin_vote_router := router.Group("/api/vote", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_ADMIN))
+    admin_vote_router.GET("/get_suspicious_list", GetSuspiciousVoteList)
+    admin_vote_router.POST("/void", VoidPetVote)
+    admin_vote_router.POST("/rebuild_rank", RebuildVoteRank)
+
+    // favorite
+    favorite_router := router.Group("/api/favorite")
+    favorite_router.GET("/get_count_list", GetFavoriteCountList)
+
+    // favorite, 需要登录
+    auth_favorite_router := router.Group("/api/favorite", GinAuthRequired())
+    auth_favorite_router.POST("/add", AddFavorite)
+    auth_favorite_router.POST("/remove", RemoveFavorite)
+    auth_favorite_router.GET("/get_my_list", GetMyFavoriteList)
+
+    // order
+    order_router := router.Group("/api/order")
+    order_router.GET("/get_product_list", GetTicketProductList)
+    order_router.POST("/pay_notify", PayNotify)
+    order_router.POST("/refund_notify", RefundNotify)
+
+    // order, 需要登录
+    auth_order_router := router.Group("/api/order", GinAuthRequired())
+    auth_order_router.POST("/create", CreateOrder)
+    auth_order_router.GET("/get_pay_params", GetOrderPayParams)
+    auth_order_router.POST("/cancel", CancelOrder)
+    auth_order_router.GET("/get_my_list", GetMyOrderList)
+    auth_order_router.GET("/get_detail", GetOrderDetail)
+    auth_order_router.POST("/apply_refund", ApplyRefund)
+    // 只在使用本地模拟支付时可用
+    auth_order_router.POST("/fake_confirm", FakePayConfirm)
+    auth_order_router.POST("/fake_refund_confirm", FakeRefundConfirm)
+
+    // order, 管理员
+    admin_order_router := router.Group("/api/order", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_ADMIN))
+    admin_order_router.POST("/refund", AdminRefundOrder)
+    admin_order_router.GET("/get_log_list", GetOrderLogList)
+
+    // registration, 合作机构
+    partner_registration_router := router.Group("/api/registration", GinAuthRequired(), GinRoleRequired(model.USER_ROLE_PARTNER))
+    partner_registration_router.POST("/import", ImportRegistration)
+    partner_registration_router.GET("/get_job", GetRegistrationJob)
+    partner_registration_router.GET("/get_row_list", GetRegistrationRowList)
+    partner_registration_router.GET("/get_job_list", GetRegistrationJobList)
+
+    // lead, 展商工作人员只能操作所属展商的潜在客户，观众可以查看、撤回和重新授权
+    lead_router := router.Group("/api/lead", GinAuthRequired())
+    lead_router.GET("/get_my_exhibitor_list", GetStaffExhibitorList)
+    lead_router.POST("/scan", ScanLead)
+    lead_router.POST("/update", UpdateLead)
+    lead_router.GET("/get_list", GetLeadList)
+    lead_router.GET("/export", ExportLead)
+    lead_router.GET("/get_my_list", GetMyLeadList)
+    lead_router.POST("/revoke_consent", RevokeLeadConsent)
+    lead_router.POST("/grant_consent", GrantLeadConsent)
+
 
     // weixin homepage
     router.GET("/api/vistor_center_auth", VistorCenterAuth)
@@ -52,6 +220,11 @@ func StartHttpServer() {
 
     router.GET("/MP_verify_nzlgoroX2jUMUlfT.txt", DownloadWinxinValidFile)
 
+    // 本地存储的上传文件，未配置oss时使用
+    if utils.Config.OssSetting.Bucket == "" {
+        router.Static("/upload", utils.LocalUploadDir())
+    }
+
     router.Run(utils.Config.Listen)
 }
 
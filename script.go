@@ -8,6 +8,9 @@ package main
 import (
     "github.com/chanxuehong/wechat.v2/mp/menu"
     "fmt"
+    "pet/controller"
+    "pet/model"
+    "pet/utils"
 )
 
 // 初始化微信菜单
@@ -35,4 +38,72 @@ func InitWinxinMenuList() {
     if nil != err {
         fmt.Printf("create menu err: %v \n", err)
     }
-}
\ No newline at end of file
+}
+
+// 用户电话号码转换成E.164格式，旧数据都是中国大陆号码
+// /var/www/go_workspace/bin/pet -a migrate_user_phone
+func MigrateUserPhone() {
+    var last_user_id int64
+    var migrated, failed int
+    for {
+        user_list, err := model.GetLegacyPhoneUsers(last_user_id, 500)
+        if nil != err {
+            fmt.Printf("get legacy phone users err: %v \n", err)
+            return
+        }
+        if len(user_list) == 0 {
+            break
+        }
+
+        for _, user := range user_list {
+            last_user_id = user.UserId
+
+            phone, err := utils.NormalizePhone(user.Phone, utils.DEFAULT_COUNTRY_CODE)
+            if nil != err {
+                failed++
+                fmt.Printf("normalize phone failed, user_id: %d, phone: %s, err: %v \n", user.UserId, user.Phone, err)
+                continue
+            }
+            // 转换后的号码已被其他账号使用，需要人工合并
+            err, exist, phone_user := model.CheckPhoneExist(phone)
+            if nil != err {
+                failed++
+                fmt.Printf("check phone exist failed, user_id: %d, err: %v \n", user.UserId, err)
+                continue
+            }
+            if exist {
+                failed++
+                fmt.Printf("phone conflict, user_id: %d, phone: %s, other user_id: %d \n", user.UserId, phone, phone_user.UserId)
+                continue
+            }
+
+            if err = user.UpdatePhone(phone); nil != err {
+                failed++
+                fmt.Printf("update phone failed, user_id: %d, err: %v \n", user.UserId, err)
+                continue
+            }
+            migrated++
+        }
+    }
+    fmt.Printf("migrate user phone finished, migrated: %d, failed: %d \n", migrated, failed)
+}
+
+// 关闭超时未支付的订单，释放库存，由crontab定时执行
+// /var/www/go_workspace/bin/pet -a close_expired_order
+func CloseExpiredOrder() {
+    closed_num, err := controller.CloseExpiredOrders()
+    if nil != err {
+        fmt.Printf("close expired order err: %v \n", err)
+    }
+    fmt.Printf("close expired order finished, closed: %d \n", closed_num)
+}
+
+// 重试申请时网络超时等结果未知的退款，由crontab定时执行
+// /var/www/go_workspace/bin/pet -a retry_refund
+func RetryRefund() {
+    sent_num, err := controller.RetryUnsentRefunds()
+    if nil != err {
+        fmt.Printf("retry refund err: %v \n", err)
+    }
+    fmt.Printf("retry refund finished, sent: %d \n", sent_num)
+}
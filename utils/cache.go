@@ -100,6 +100,17 @@ func (cache *Cache) Incr(key string) (int, error) {
 	return res, err
 }
 
+func (cache *Cache) Decr(key string) (int, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+	res, err := redis.Int(conn.Do("DECR", key))
+
+	if nil != err && !strings.Contains(err.Error(), "nil returned") {
+		err = NewInternalError(CacheErrCode, err)
+	}
+	return res, err
+}
+
 func (cache *Cache) MGet(key []interface{}) (interface{}, error) {
 	conn := cache.RedisPool().Get()
 	defer conn.Close()
@@ -274,6 +285,118 @@ func (cache *Cache) Del(key string) error {
 	return err
 }
 
+// 重命名key，new_key已存在时覆盖
+func (cache *Cache) Rename(key, new_key string) error {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+	_, err := conn.Do("RENAME", key, new_key)
+
+	if nil != err {
+		err = NewInternalError(CacheErrCode, err)
+	}
+	return err
+}
+
+var getDelScript = redis.NewScript(1, `
+local value = redis.call("GET", KEYS[1])
+if value then
+	redis.call("DEL", KEYS[1])
+end
+return value
+`)
+
+// 获取并删除key，保证只能被读取一次
+func (cache *Cache) GetDel(key string) ([]byte, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	res, err := redis.Bytes(getDelScript.Do(conn, key))
+	if nil != err && !strings.Contains(err.Error(), "nil returned") {
+		err = NewInternalError(CacheErrCode, err)
+	}
+	return res, err
+}
+
+var delIfEqualScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// key的值等于value时删除，返回是否删除成功
+func (cache *Cache) DelIfEqual(key string, value interface{}) (bool, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	res, err := redis.Int(delIfEqualScript.Do(conn, key, value))
+	if nil != err {
+		err = NewInternalError(CacheErrCode, err)
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// key不存在时才设置，返回是否设置成功
+func (cache *Cache) SetNx(key string, value interface{}, timeout int) (bool, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", key, value, "EX", timeout, "NX"))
+	if nil != err {
+		if strings.Contains(err.Error(), "nil returned") {
+			return false, nil
+		}
+		return false, NewInternalError(CacheErrCode, err)
+	}
+	return true, nil
+}
+
+var zincrbyUnlessLockedScript = redis.NewScript(3, `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("RPUSH", KEYS[2], ARGV[3])
+	return false
+end
+return redis.call("ZINCRBY", KEYS[3], ARGV[1], ARGV[2])
+`)
+
+// lock_key存在时把item放入list_key等待加锁方处理，否则直接ZINCRBY，返回是否已加分
+func (cache *Cache) ZincrbyUnlessLocked(lock_key, list_key, key string, value float64, member, item interface{}) (float64, bool, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	res, err := redis.Float64(zincrbyUnlessLockedScript.Do(conn, lock_key, list_key, key, value, member, item))
+	if nil != err {
+		if strings.Contains(err.Error(), "nil returned") {
+			return 0, false, nil
+		}
+		return 0, false, NewInternalError(CacheErrCode, err)
+	}
+	return res, true, nil
+}
+
+var unlockAndDrainScript = redis.NewScript(2, `
+local items = redis.call("LRANGE", KEYS[2], 0, -1)
+redis.call("DEL", KEYS[2])
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+end
+return items
+`)
+
+// 释放lock_key（值等于value时），并取出加锁期间放入list_key的所有元素
+func (cache *Cache) UnlockAndDrain(lock_key, list_key string, value interface{}) ([]string, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	res, err := redis.Strings(unlockAndDrainScript.Do(conn, lock_key, list_key, value))
+	if nil != err && !strings.Contains(err.Error(), "nil returned") {
+		err = NewInternalError(CacheErrCode, err)
+		return nil, err
+	}
+	return res, nil
+}
+
 func (cache *Cache) Exists(key string) (bool, error) {
 	conn := cache.RedisPool().Get()
 	defer conn.Close()
@@ -438,6 +561,17 @@ func (cache *Cache) Zincrby(key string, value, member interface{}) (interface{},
 	return res, err
 }
 
+func (cache *Cache) Zrem(key string, member interface{}) (int, error) {
+	conn := cache.RedisPool().Get()
+	defer conn.Close()
+
+	res, err := redis.Int(conn.Do("ZREM", key, member))
+	if nil != err {
+		err = NewInternalError(CacheErrCode, err)
+	}
+	return res, err
+}
+
 func (cache *Cache) ZincrbyFloat(key string, value float64, member interface{}) (float64, error) {
 	conn := cache.RedisPool().Get()
 	defer conn.Close()
@@ -0,0 +1,43 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/24 14:10
+ */
+package utils
+
+// 图形验证码redis存储，实现 captcha.Store，支持多实例部署
+type CaptchaStore struct {
+    cache       *Cache
+    prefix      string
+    expire      int     // 过期时间，单位秒
+}
+
+func NewCaptchaStore(cache *Cache, prefix string, expire int) *CaptchaStore {
+    return &CaptchaStore{
+        cache:  cache,
+        prefix: prefix,
+        expire: expire,
+    }
+}
+
+func (store *CaptchaStore) Set(id string, digits []byte) {
+    err := store.cache.Set(store.prefix+id, digits, store.expire)
+    if nil != err {
+        Logger.Error("set captcha cache err: %v", err)
+    }
+}
+
+// clear为true时读取后删除，保证图形验证码只能校验一次
+func (store *CaptchaStore) Get(id string, clear bool) []byte {
+    var digits []byte
+    var err error
+    if clear {
+        digits, err = store.cache.GetDel(store.prefix + id)
+    } else {
+        digits, err = store.cache.Get(store.prefix + id)
+    }
+    if nil != err && CheckRedisReturnValue(err) != KeyNotFound {
+        Logger.Error("get captcha cache err: %v", err)
+    }
+    return digits
+}
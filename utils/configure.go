@@ -66,6 +66,100 @@ type OssConfig struct {
     AccessKeySecret string
     Region          string
     Bucket          string
+    Domain          string  // 访问域名，为空时使用bucket默认域名
+}
+
+// 短信服务商配置
+type SmsProviderConfig struct {
+    ApiKey          string
+    ApiSecret       string
+    SignName        string      // 短信签名
+}
+
+// 短信配置
+type SmsConfig struct {
+    Providers       []string    // 短信服务商，按顺序失败切换，可选: yunpian, aliyun, log
+    Yunpian         SmsProviderConfig
+    Aliyun          SmsProviderConfig
+    LogFile         string      // log 发送方式写入的文件，为空时写入日志
+    Templates       map[string]map[string]string    // 短信用途 -> 服务商 -> 模板id
+}
+
+// 短信发送限流配置，数值为0时使用默认值
+type SmsThrottleConfig struct {
+    IpHourMax               int         // 单个ip每小时最多发送次数
+    IpDayMax                int         // 单个ip每天最多发送次数
+    DeviceHourMax           int         // 单个设备每小时最多发送次数
+    DeviceDayMax            int         // 单个设备每天最多发送次数
+    GlobalHourMax           int         // 全部用户每小时最多发送条数，超过后停止发送
+    DeviceHeader            string      // 设备标识请求头，默认 X-Device-Id
+    CaptchaEnable           bool        // 是否启用图形验证码
+    CaptchaIpThreshold      int         // 单个ip每小时发送超过此次数后需要图形验证码
+    CaptchaDeviceThreshold  int         // 单个设备每小时发送超过此次数后需要图形验证码
+}
+
+// 人气投票配置
+type VoteConfig struct {
+    DailyMax                int         // 每个用户每天投票次数
+    BurstSeconds            int         // 突发投票的统计时长，单位秒
+    IpBurstMax              int         // 单个ip在统计时长内超过此票数后标记为可疑
+    DeviceBurstMax          int         // 单个设备在统计时长内超过此票数后标记为可疑
+    NewAccountHours         int         // 注册不满此小时数的账号投票标记为可疑
+}
+
+// 未配置的项使用默认值
+func (config VoteConfig) WithDefaults() VoteConfig {
+    if config.DailyMax <= 0 {
+        config.DailyMax = 3
+    }
+    if config.BurstSeconds <= 0 {
+        config.BurstSeconds = 600
+    }
+    if config.IpBurstMax <= 0 {
+        config.IpBurstMax = 30
+    }
+    if config.DeviceBurstMax <= 0 {
+        config.DeviceBurstMax = 10
+    }
+    if config.NewAccountHours <= 0 {
+        config.NewAccountHours = 24
+    }
+    return config
+}
+
+// 展会届次配置
+type EditionConfig struct {
+    CurrentId       int64       // 当前届次，请求没有指定届次、域名也没有绑定届次时使用
+}
+
+// 电子门票配置
+type TicketConfig struct {
+    EditionId       int64       // 发放门票的届次，为0时使用当前届次
+    ValidFrom       string      // 门票生效日期，2006-01-02
+    ValidTo         string      // 门票失效日期，包含当天，2006-01-02
+    KeyId           string      // 签名密钥版本，扫码设备按版本选择公钥
+    SignKey         string      // Ed25519 私钥种子，32字节，base64编码
+}
+
+// 支付配置
+type PayConfig struct {
+    Provider            string      // 支付渠道，wxpay: 微信支付 fake: 本地模拟支付，未配置时线上为wxpay，其他环境为fake
+    AppId               string      // 公众号appid，为空时使用 External.AppId
+    MchId               string      // 商户号
+    ApiKey              string      // 商户API密钥，用于签名
+    NotifyUrl           string      // 支付结果通知地址
+    OrderExpireMinutes  int         // 未支付订单多少分钟后关闭，默认30
+    CertFile            string      // 商户证书 apiclient_cert.pem，退款时使用
+    KeyFile             string      // 商户证书私钥 apiclient_key.pem
+    RefundNotifyUrl     string      // 退款结果通知地址
+    RefundDeadline      string      // 用户申请退款的截止日期，2006-01-02，为空时开展前都可以申请
+}
+
+// 验证码测试账号，非线上环境生效，审核账号线上环境也生效
+type TestAccountConfig struct {
+    Phone           string
+    Code            string      // 固定验证码
+    Reviewer        bool        // 应用商店、微信审核账号
 }
 
 // statsd, circuit
@@ -116,6 +210,14 @@ type Configure struct {
     GRpcSettings   map[string][]RPCSetting
     CelerySetting  map[string]CeleryQueue
     OssSetting     OssConfig
+    SmsSetting     SmsConfig
+    SmsThrottle    SmsThrottleConfig
+    TestAccounts   []TestAccountConfig
+    WeixinTemplates map[string]string   // 微信模板消息，通知用途 -> 模板id
+    EditionSetting EditionConfig
+    TicketSetting  TicketConfig
+    VoteSetting    VoteConfig
+    PaySetting     PayConfig
     HystrixSetting HystrixConfig
     ConsulSetting  ConsulConfig
     SentryUrl      string
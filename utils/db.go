@@ -8,9 +8,15 @@ package utils
 import (
     "database/sql"
     "fmt"
+    "strings"
     "third/gorm"
 )
 
+// 是否是唯一索引冲突，mysql错误码1062
+func IsDuplicateKeyError(err error) bool {
+    return nil != err && strings.Contains(err.Error(), "Error 1062")
+}
+
 func InitDbPool(config *MysqlConfig) (*sql.DB, error) {
 
     dbPool, err := sql.Open("mysql", config.MysqlConn)
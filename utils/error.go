@@ -32,6 +32,28 @@ const (
     DayMaxTimeErrCode       ErrCode = 504   // 验证码每天次数
     VerifyCodeWrong         ErrCode = 505   // 验证码错误
 	VerifyCodeSendErrCode	ErrCode = 506	// 验证码发送失败
+    TokenInvalidErrCode     ErrCode = 507   // 登录已失效
+    PermissionDeniedErrCode ErrCode = 508   // 无权限
+    PasswordWrongErrCode    ErrCode = 509   // 电话号码或密码错误
+    LoginLockedErrCode      ErrCode = 510   // 密码错误次数过多，暂时锁定
+    AccountBindErrCode      ErrCode = 511   // 账号绑定冲突
+    VerifyCodeLockedErrCode ErrCode = 512   // 验证码错误次数过多
+    CaptchaRequiredErrCode  ErrCode = 513   // 需要图形验证码
+    CaptchaWrongErrCode     ErrCode = 514   // 图形验证码错误
+    SmsLimitErrCode         ErrCode = 515   // 短信发送量超过限制
+    NotFoundErrCode         ErrCode = 516   // 数据不存在
+    ActivityClosedErrCode   ErrCode = 517   // 活动已开始或已取消
+    TicketInvalidErrCode    ErrCode = 518   // 门票无效
+    TicketExpiredErrCode    ErrCode = 519   // 门票不在有效期内
+    TicketCheckedInErrCode  ErrCode = 520   // 门票已入场
+    CompetitionStatusErrCode ErrCode = 521  // 比赛当前阶段不允许该操作
+    VoteLimitErrCode        ErrCode = 522   // 今日投票次数已用完
+    OrderStatusErrCode      ErrCode = 523   // 订单当前状态不允许该操作
+    SoldOutErrCode          ErrCode = 524   // 门票已售罄
+    PayErrCode              ErrCode = 525   // 支付下单失败
+    RefundDeadlineErrCode   ErrCode = 526   // 已过退款截止时间
+    RefundErrCode           ErrCode = 527   // 退款申请失败
+    PayDisabledErrCode      ErrCode = 528   // 支付未配置
 
     MaxUserError 			ErrCode = 9999
 )
@@ -295,7 +295,17 @@ func SendResponse(c *gin.Context, http_code int, data interface{}, err error) er
 
 // modified by linagbo on 2017-08-10, 定义不被转化成int的key
 var string_key map[string]int = map[string]int{
-
+    "country_code": 1,
+    "hall":         1,
+    "ticket_no":    1,
+    "gate_id":      1,
+    "keyword":      1,
+    "target_ids":   1,
+    "order_no":     1,
+    "refund_no":    1,
+    "job_no":       1,
+    "phone":        1,
+    "notes":        1,
 }
 
 //func ForwardHttpToRpc(c *gin.Context, client *RpcClient, method string, args map[string]interface{}, reply interface{}, http_code *int) error {
@@ -488,6 +498,26 @@ func GetTokenFromHeader(r *http.Request) string {
     return token
 }
 
+// 读取上传的文件，返回文件内容和文件名
+func ReadFormFile(r *http.Request, field string, max_size int64) ([]byte, string, error) {
+    file, header, err := r.FormFile(field)
+    if nil != err {
+        Logger.Error("read form file err, field: %s, err: %v", field, err)
+        return nil, "", NewInternalErrorByStr(ParameterErrCode, "请选择上传文件")
+    }
+    defer file.Close()
+
+    data, err := ioutil.ReadAll(io.LimitReader(file, max_size+1))
+    if nil != err {
+        Logger.Error("read form file err, field: %s, err: %v", field, err)
+        return nil, "", NewInternalError(InternalErrorCode, err)
+    }
+    if int64(len(data)) > max_size {
+        return nil, "", NewInternalErrorByStr(ParameterErrCode, "文件大小超过限制")
+    }
+    return data, header.Filename, nil
+}
+
 func GetGinRawPath(c *gin.Context) string {
     path := c.Request.URL.Path
     for i := range c.Params {
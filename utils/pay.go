@@ -0,0 +1,263 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 20:10
+ */
+package utils
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+)
+
+// 统一下单参数
+type PayOrder struct {
+    OrderNo         string      // 商户订单号
+    Body            string      // 商品描述
+    TotalFee        int         // 金额，单位分
+    ClientIp        string
+    Openid          string      // JSAPI支付的用户openid
+    ExpireTime      time.Time   // 订单失效时间
+}
+
+// 支付结果通知
+type PayNotify struct {
+    OrderNo         string
+    TransactionId   string      // 支付平台的交易号
+    TotalFee        int
+    Success         bool
+    PaidTime        time.Time
+}
+
+// 退款申请参数
+type PayRefund struct {
+    OrderNo         string      // 商户订单号
+    RefundNo        string      // 商户退款单号，同一退款单号重复申请只退一次
+    TotalFee        int         // 订单金额，单位分
+    RefundFee       int         // 退款金额，单位分
+    Reason          string
+}
+
+// 退款结果通知
+type RefundNotify struct {
+    OrderNo         string
+    RefundNo        string
+    RefundId        string      // 支付平台的退款单号
+    RefundFee       int
+    Success         bool
+    Status          string      // 支付平台的退款状态，SUCCESS、CHANGE、REFUNDCLOSE
+    SuccessTime     time.Time
+}
+
+// 支付平台明确返回的业务失败，区别于网络超时等结果未知的错误
+type PayBizError struct {
+    ErrCode         string
+    ErrCodeDes      string
+}
+
+func (err *PayBizError) Error() string {
+    return fmt.Sprintf("pay biz failed, err_code: %s, err_code_des: %s", err.ErrCode, err.ErrCodeDes)
+}
+
+// 是否是支付平台明确返回的业务失败
+func IsPayBizError(err error) bool {
+    _, ok := err.(*PayBizError)
+    return ok
+}
+
+// 支付渠道
+type PayGateway interface {
+    Name() string
+    // 统一下单，返回预支付交易会话标识
+    UnifiedOrder(order *PayOrder) (prepay_id string, err error)
+    // H5页面调起支付的参数
+    JsapiParams(prepay_id string) map[string]string
+    // 校验签名并解析支付结果通知
+    ParseNotify(body []byte) (*PayNotify, error)
+    // 关闭未支付的订单
+    CloseOrder(order_no string) error
+    // 申请退款，返回支付平台的退款单号，退款结果通过退款通知确认
+    // 明确失败时返回 *PayBizError，其他错误结果未知，可以用同一退款单号重试
+    Refund(refund *PayRefund) (refund_id string, err error)
+    // 解密并解析退款结果通知
+    ParseRefundNotify(body []byte) (*RefundNotify, error)
+}
+
+// 支付渠道，未配置支付时为nil，不能下单和退款
+var PayClient PayGateway
+
+/**
+ * 按配置初始化支付渠道
+ *
+ * Provider 未配置时线上在商户号和密钥都配置后使用微信支付，否则不启用支付，
+ * 其他环境使用本地模拟支付；明确配置了 Provider 但配置不完整时返回错误
+ */
+func InitPayGateway() error {
+    config := &Config.PaySetting
+
+    provider := config.Provider
+    if provider == "" {
+        if !IsOnline() {
+            provider = "fake"
+        } else if config.MchId != "" && config.ApiKey != "" {
+            provider = "wxpay"
+        } else {
+            PayClient = nil
+            Logger.Warning("pay not configured, order and refund are disabled")
+            return nil
+        }
+    }
+    app_id := config.AppId
+    if app_id == "" {
+        app_id = Config.External["AppId"]
+    }
+
+    switch provider {
+    case "wxpay":
+        if config.MchId == "" || config.ApiKey == "" {
+            return fmt.Errorf("wxpay mch_id or api_key not configured")
+        }
+        gateway := NewWxpayGateway(app_id, config.MchId, config.ApiKey, config.NotifyUrl)
+        // 退款需要商户证书，未配置时不能退款，不影响支付
+        if config.CertFile != "" {
+            if err := gateway.LoadCert(config.CertFile, config.KeyFile, config.RefundNotifyUrl); nil != err {
+                return err
+            }
+        }
+        PayClient = gateway
+    case "fake":
+        if IsOnline() {
+            return fmt.Errorf("fake pay gateway is not allowed online")
+        }
+        PayClient = NewFakePayGateway(app_id, config.MchId, config.ApiKey)
+    default:
+        return fmt.Errorf("unknown pay provider: %s", provider)
+    }
+    return nil
+}
+
+// 未支付订单的关闭时间
+func OrderExpireDuration() time.Duration {
+    minutes := Config.PaySetting.OrderExpireMinutes
+    if minutes <= 0 {
+        minutes = 30
+    }
+    return time.Duration(minutes) * time.Minute
+}
+
+// 用户申请退款的截止时间，包含截止日期当天，未配置时返回零值表示不限
+func RefundDeadline() (time.Time, error) {
+    if Config.PaySetting.RefundDeadline == "" {
+        return time.Time{}, nil
+    }
+    deadline, err := time.ParseInLocation(DATE_FORMAT, Config.PaySetting.RefundDeadline, time.Local)
+    if nil != err {
+        return time.Time{}, err
+    }
+    return deadline.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+/**
+ * 本地模拟支付，用于开发和测试环境
+ *
+ * 下单和关单不访问网络，支付结果通知与微信支付格式、签名方式相同，
+ * 通过 Notify 生成已签名的通知，走和微信支付一样的回调处理流程
+ */
+type FakePayGateway struct {
+    app_id      string
+    mch_id      string
+    api_key     string
+}
+
+func NewFakePayGateway(app_id, mch_id, api_key string) *FakePayGateway {
+    if mch_id == "" {
+        mch_id = "fake_mch"
+    }
+    if api_key == "" {
+        api_key = "fake_api_key"
+    }
+    return &FakePayGateway{app_id: app_id, mch_id: mch_id, api_key: api_key}
+}
+
+func (gateway *FakePayGateway) Name() string {
+    return "fake"
+}
+
+func (gateway *FakePayGateway) UnifiedOrder(order *PayOrder) (string, error) {
+    if order.TotalFee <= 0 {
+        return "", fmt.Errorf("fake unified order failed, total_fee: %d", order.TotalFee)
+    }
+    Logger.Notice("[pay] fake unified order, order_no: %s, total_fee: %d, openid: %s", order.OrderNo, order.TotalFee, order.Openid)
+    return "fake_prepay_" + order.OrderNo, nil
+}
+
+func (gateway *FakePayGateway) JsapiParams(prepay_id string) map[string]string {
+    return wxpayJsapiParams(gateway.app_id, gateway.api_key, prepay_id)
+}
+
+func (gateway *FakePayGateway) ParseNotify(body []byte) (*PayNotify, error) {
+    return parseWxpayNotify(body, gateway.app_id, gateway.mch_id, gateway.api_key)
+}
+
+func (gateway *FakePayGateway) CloseOrder(order_no string) error {
+    Logger.Notice("[pay] fake close order, order_no: %s", order_no)
+    return nil
+}
+
+// 生成支付成功的通知
+func (gateway *FakePayGateway) Notify(order_no string, total_fee int) []byte {
+    nonce_str, _ := RandomHex(16)
+    params := map[string]string{
+        "return_code":      "SUCCESS",
+        "result_code":      "SUCCESS",
+        "appid":            gateway.app_id,
+        "mch_id":           gateway.mch_id,
+        "nonce_str":        nonce_str,
+        "out_trade_no":     order_no,
+        "transaction_id":   "fake_" + order_no,
+        "total_fee":        strconv.Itoa(total_fee),
+        "trade_type":       "JSAPI",
+        "time_end":         time.Now().Format(WXPAY_TIME_FORMAT),
+    }
+    params["sign"] = WxpaySign(params, gateway.api_key)
+    return wxpayXml(params)
+}
+
+func (gateway *FakePayGateway) Refund(refund *PayRefund) (string, error) {
+    if refund.RefundFee <= 0 || refund.RefundFee > refund.TotalFee {
+        return "", &PayBizError{ErrCode: "PARAM_ERROR",
+            ErrCodeDes: fmt.Sprintf("refund_fee: %d, total_fee: %d", refund.RefundFee, refund.TotalFee)}
+    }
+    Logger.Notice("[pay] fake refund, order_no: %s, refund_no: %s, refund_fee: %d", refund.OrderNo, refund.RefundNo, refund.RefundFee)
+    return "fake_" + refund.RefundNo, nil
+}
+
+func (gateway *FakePayGateway) ParseRefundNotify(body []byte) (*RefundNotify, error) {
+    return parseWxpayRefundNotify(body, gateway.app_id, gateway.mch_id, gateway.api_key)
+}
+
+// 生成退款结果的通知，和微信支付一样加密
+func (gateway *FakePayGateway) RefundNotify(order_no, refund_no string, refund_fee int, success bool) []byte {
+    status := "SUCCESS"
+    if !success {
+        status = "REFUNDCLOSE"
+    }
+    info := map[string]string{
+        "out_trade_no":         order_no,
+        "out_refund_no":        refund_no,
+        "refund_id":            "fake_" + refund_no,
+        "refund_fee":           strconv.Itoa(refund_fee),
+        "refund_status":        status,
+        "success_time":         time.Now().Format(TIME_FORMAT),
+    }
+    req_info, _ := wxpayEncryptReqInfo(wxpayXml(info), gateway.api_key)
+    nonce_str, _ := RandomHex(16)
+    return wxpayXml(map[string]string{
+        "return_code":  "SUCCESS",
+        "appid":        gateway.app_id,
+        "mch_id":       gateway.mch_id,
+        "nonce_str":    nonce_str,
+        "req_info":     req_info,
+    })
+}
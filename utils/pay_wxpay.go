@@ -0,0 +1,389 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/18 20:40
+ */
+package utils
+
+import (
+    "bytes"
+    "crypto/aes"
+    "crypto/md5"
+    "crypto/tls"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    WXPAY_API_HOST      = "https://api.mch.weixin.qq.com"
+    WXPAY_TIME_FORMAT   = "20060102150405"
+    WXPAY_API_TIMEOUT   = 10 * time.Second
+)
+
+// 退款接口需要用原退款单号重试的错误码，退款结果未知
+var wxpayRefundRetryCodes = map[string]bool{
+    "SYSTEMERROR":          true,
+    "BIZERR_NEED_RETRY":    true,
+    "FREQUENCY_LIMITED":    true,
+}
+
+// 微信支付，JSAPI方式
+type WxpayGateway struct {
+    app_id      string
+    mch_id      string
+    api_key     string
+    notify_url  string
+    client      *http.Client
+    refund_notify_url   string
+    cert_client         *http.Client    // 带商户证书，退款等接口使用
+}
+
+func NewWxpayGateway(app_id, mch_id, api_key, notify_url string) *WxpayGateway {
+    return &WxpayGateway{
+        app_id:     app_id,
+        mch_id:     mch_id,
+        api_key:    api_key,
+        notify_url: notify_url,
+        client:     &http.Client{Timeout: WXPAY_API_TIMEOUT},
+    }
+}
+
+// 加载商户证书，退款接口需要双向证书
+func (gateway *WxpayGateway) LoadCert(cert_file, key_file, refund_notify_url string) error {
+    cert, err := tls.LoadX509KeyPair(cert_file, key_file)
+    if nil != err {
+        return fmt.Errorf("load wxpay cert failed, err: %v", err)
+    }
+    gateway.refund_notify_url = refund_notify_url
+    gateway.cert_client = &http.Client{
+        Timeout:    WXPAY_API_TIMEOUT,
+        Transport:  &http.Transport{TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}}},
+    }
+    return nil
+}
+
+func (gateway *WxpayGateway) Name() string {
+    return "wxpay"
+}
+
+/**
+ * 微信支付签名，MD5方式
+ *
+ * 参数按key排序后拼接为 key1=value1&key2=value2，空值和sign不参与签名，最后拼接 &key=API密钥
+ */
+func WxpaySign(params map[string]string, api_key string) string {
+    keys := make([]string, 0, len(params))
+    for key, value := range params {
+        if key == "sign" || value == "" {
+            continue
+        }
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var buf bytes.Buffer
+    for _, key := range keys {
+        buf.WriteString(key)
+        buf.WriteByte('=')
+        buf.WriteString(params[key])
+        buf.WriteByte('&')
+    }
+    buf.WriteString("key=")
+    buf.WriteString(api_key)
+
+    sum := md5.Sum(buf.Bytes())
+    return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// 参数转为微信支付的xml格式
+func wxpayXml(params map[string]string) []byte {
+    var buf bytes.Buffer
+    buf.WriteString("<xml>")
+    for key, value := range params {
+        buf.WriteString("<" + key + "><![CDATA[")
+        buf.WriteString(strings.Replace(value, "]]>", "]]]]><![CDATA[>", -1))
+        buf.WriteString("]]></" + key + ">")
+    }
+    buf.WriteString("</xml>")
+    return buf.Bytes()
+}
+
+// 解析微信支付的xml，只支持一层
+func parseWxpayXml(data []byte) (map[string]string, error) {
+    params := make(map[string]string)
+    decoder := xml.NewDecoder(bytes.NewReader(data))
+    var key string
+    depth := 0
+    for {
+        token, err := decoder.Token()
+        if err == io.EOF {
+            break
+        } else if nil != err {
+            return nil, err
+        }
+        switch t := token.(type) {
+        case xml.StartElement:
+            depth++
+            key = t.Name.Local
+        case xml.CharData:
+            if depth == 2 && key != "" {
+                params[key] += string(t)
+            }
+        case xml.EndElement:
+            depth--
+            key = ""
+        }
+    }
+    if len(params) == 0 {
+        return nil, fmt.Errorf("empty wxpay xml")
+    }
+    return params, nil
+}
+
+// 微信支付的应答回复
+func WxpayNotifyReply(success bool, msg string) []byte {
+    code := "SUCCESS"
+    if !success {
+        code = "FAIL"
+    }
+    return []byte("<xml><return_code><![CDATA[" + code + "]]></return_code><return_msg><![CDATA[" + msg + "]]></return_msg></xml>")
+}
+
+// H5页面调起支付的参数
+func wxpayJsapiParams(app_id, api_key, prepay_id string) map[string]string {
+    nonce_str, _ := RandomHex(16)
+    params := map[string]string{
+        "appId":        app_id,
+        "timeStamp":    strconv.FormatInt(time.Now().Unix(), 10),
+        "nonceStr":     nonce_str,
+        "package":      "prepay_id=" + prepay_id,
+        "signType":     "MD5",
+    }
+    params["paySign"] = WxpaySign(params, api_key)
+    return params
+}
+
+// 校验签名并解析支付结果通知
+func parseWxpayNotify(body []byte, app_id, mch_id, api_key string) (*PayNotify, error) {
+    params, err := parseWxpayXml(body)
+    if nil != err {
+        return nil, err
+    }
+    if params["return_code"] != "SUCCESS" {
+        return nil, fmt.Errorf("wxpay notify return_code: %s, return_msg: %s", params["return_code"], params["return_msg"])
+    }
+    if params["sign"] == "" || WxpaySign(params, api_key) != params["sign"] {
+        return nil, fmt.Errorf("wxpay notify sign invalid, out_trade_no: %s", params["out_trade_no"])
+    }
+    if params["appid"] != app_id || params["mch_id"] != mch_id {
+        return nil, fmt.Errorf("wxpay notify appid or mch_id mismatch, appid: %s, mch_id: %s", params["appid"], params["mch_id"])
+    }
+
+    notify := new(PayNotify)
+    notify.OrderNo = params["out_trade_no"]
+    notify.TransactionId = params["transaction_id"]
+    notify.TotalFee, err = strconv.Atoi(params["total_fee"])
+    if nil != err {
+        return nil, fmt.Errorf("wxpay notify total_fee invalid: %s", params["total_fee"])
+    }
+    notify.Success = params["result_code"] == "SUCCESS"
+    notify.PaidTime, err = time.ParseInLocation(WXPAY_TIME_FORMAT, params["time_end"], time.Local)
+    if nil != err {
+        notify.PaidTime = time.Now()
+    }
+    return notify, nil
+}
+
+// 退款通知 req_info 的密钥，商户API密钥的md5小写
+func wxpayReqInfoKey(api_key string) []byte {
+    sum := md5.Sum([]byte(api_key))
+    return []byte(hex.EncodeToString(sum[:]))
+}
+
+// 解密退款通知的 req_info，AES-256-ECB，PKCS7填充
+func wxpayDecryptReqInfo(req_info, api_key string) ([]byte, error) {
+    data, err := base64.StdEncoding.DecodeString(req_info)
+    if nil != err {
+        return nil, err
+    }
+    block, err := aes.NewCipher(wxpayReqInfoKey(api_key))
+    if nil != err {
+        return nil, err
+    }
+    size := block.BlockSize()
+    if len(data) == 0 || len(data)%size != 0 {
+        return nil, fmt.Errorf("wxpay req_info length invalid: %d", len(data))
+    }
+    plain := make([]byte, len(data))
+    for i := 0; i < len(data); i += size {
+        block.Decrypt(plain[i:i+size], data[i:i+size])
+    }
+    padding := int(plain[len(plain)-1])
+    if padding == 0 || padding > size {
+        return nil, fmt.Errorf("wxpay req_info padding invalid")
+    }
+    return plain[:len(plain)-padding], nil
+}
+
+// 加密退款通知的 req_info，本地模拟退款通知时使用
+func wxpayEncryptReqInfo(plain []byte, api_key string) (string, error) {
+    block, err := aes.NewCipher(wxpayReqInfoKey(api_key))
+    if nil != err {
+        return "", err
+    }
+    size := block.BlockSize()
+    padding := size - len(plain)%size
+    data := append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+    for i := 0; i < len(data); i += size {
+        block.Encrypt(data[i:i+size], data[i:i+size])
+    }
+    return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// 解析退款结果通知，退款通知没有签名，能用API密钥解密即为可信
+func parseWxpayRefundNotify(body []byte, app_id, mch_id, api_key string) (*RefundNotify, error) {
+    params, err := parseWxpayXml(body)
+    if nil != err {
+        return nil, err
+    }
+    if params["return_code"] != "SUCCESS" {
+        return nil, fmt.Errorf("wxpay refund notify return_code: %s, return_msg: %s", params["return_code"], params["return_msg"])
+    }
+    if params["appid"] != app_id || params["mch_id"] != mch_id {
+        return nil, fmt.Errorf("wxpay refund notify appid or mch_id mismatch, appid: %s, mch_id: %s", params["appid"], params["mch_id"])
+    }
+    plain, err := wxpayDecryptReqInfo(params["req_info"], api_key)
+    if nil != err {
+        return nil, fmt.Errorf("wxpay refund notify decrypt failed, err: %v", err)
+    }
+    info, err := parseWxpayXml(plain)
+    if nil != err {
+        return nil, err
+    }
+
+    notify := new(RefundNotify)
+    notify.OrderNo = info["out_trade_no"]
+    notify.RefundNo = info["out_refund_no"]
+    notify.RefundId = info["refund_id"]
+    notify.RefundFee, err = strconv.Atoi(info["refund_fee"])
+    if nil != err {
+        return nil, fmt.Errorf("wxpay refund notify refund_fee invalid: %s", info["refund_fee"])
+    }
+    notify.Status = info["refund_status"]
+    notify.Success = notify.Status == "SUCCESS"
+    notify.SuccessTime, err = time.ParseInLocation(TIME_FORMAT, info["success_time"], time.Local)
+    if nil != err {
+        notify.SuccessTime = time.Now()
+    }
+    return notify, nil
+}
+
+// 调用微信支付接口，校验返回结果的签名
+func (gateway *WxpayGateway) request(client *http.Client, path string, params map[string]string) (map[string]string, error) {
+    params["appid"] = gateway.app_id
+    params["mch_id"] = gateway.mch_id
+    params["nonce_str"], _ = RandomHex(16)
+    params["sign"] = WxpaySign(params, gateway.api_key)
+
+    resp, err := client.Post(WXPAY_API_HOST+path, "application/xml", bytes.NewReader(wxpayXml(params)))
+    if nil != err {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    body, err := ioutil.ReadAll(resp.Body)
+    if nil != err {
+        return nil, err
+    }
+
+    result, err := parseWxpayXml(body)
+    if nil != err {
+        return nil, err
+    }
+    if result["return_code"] != "SUCCESS" {
+        return nil, fmt.Errorf("wxpay %s failed, return_msg: %s", path, result["return_msg"])
+    }
+    if WxpaySign(result, gateway.api_key) != result["sign"] {
+        return nil, fmt.Errorf("wxpay %s response sign invalid", path)
+    }
+    if result["result_code"] != "SUCCESS" {
+        return result, fmt.Errorf("wxpay %s failed, err_code: %s, err_code_des: %s", path, result["err_code"], result["err_code_des"])
+    }
+    return result, nil
+}
+
+func (gateway *WxpayGateway) UnifiedOrder(order *PayOrder) (string, error) {
+    params := map[string]string{
+        "body":             order.Body,
+        "out_trade_no":     order.OrderNo,
+        "total_fee":        strconv.Itoa(order.TotalFee),
+        "spbill_create_ip": order.ClientIp,
+        "notify_url":       gateway.notify_url,
+        "trade_type":       "JSAPI",
+        "openid":           order.Openid,
+    }
+    if !order.ExpireTime.IsZero() {
+        params["time_expire"] = order.ExpireTime.Format(WXPAY_TIME_FORMAT)
+    }
+    result, err := gateway.request(gateway.client, "/pay/unifiedorder", params)
+    if nil != err {
+        return "", err
+    }
+    return result["prepay_id"], nil
+}
+
+func (gateway *WxpayGateway) JsapiParams(prepay_id string) map[string]string {
+    return wxpayJsapiParams(gateway.app_id, gateway.api_key, prepay_id)
+}
+
+func (gateway *WxpayGateway) ParseNotify(body []byte) (*PayNotify, error) {
+    return parseWxpayNotify(body, gateway.app_id, gateway.mch_id, gateway.api_key)
+}
+
+func (gateway *WxpayGateway) CloseOrder(order_no string) error {
+    result, err := gateway.request(gateway.client, "/pay/closeorder", map[string]string{"out_trade_no": order_no})
+    // 订单已关闭不算失败
+    if nil != err && result != nil && result["err_code"] == "ORDERCLOSED" {
+        return nil
+    }
+    return err
+}
+
+/**
+ * 申请退款，同一退款单号重复申请只退一次
+ *
+ * 业务结果失败时返回 *PayBizError，系统繁忙等需要重试的错误码和网络错误一样按结果未知处理
+ */
+func (gateway *WxpayGateway) Refund(refund *PayRefund) (string, error) {
+    if gateway.cert_client == nil {
+        return "", &PayBizError{ErrCode: "CERT_NOT_CONFIGURED", ErrCodeDes: "wxpay cert not configured"}
+    }
+    params := map[string]string{
+        "out_trade_no":     refund.OrderNo,
+        "out_refund_no":    refund.RefundNo,
+        "total_fee":        strconv.Itoa(refund.TotalFee),
+        "refund_fee":       strconv.Itoa(refund.RefundFee),
+        "refund_desc":      refund.Reason,
+        "notify_url":       gateway.refund_notify_url,
+    }
+    result, err := gateway.request(gateway.cert_client, "/secapi/pay/refund", params)
+    if nil != err {
+        if result != nil && !wxpayRefundRetryCodes[result["err_code"]] {
+            return "", &PayBizError{ErrCode: result["err_code"], ErrCodeDes: result["err_code_des"]}
+        }
+        return "", err
+    }
+    return result["refund_id"], nil
+}
+
+func (gateway *WxpayGateway) ParseRefundNotify(body []byte) (*RefundNotify, error) {
+    return parseWxpayRefundNotify(body, gateway.app_id, gateway.mch_id, gateway.api_key)
+}
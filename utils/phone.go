@@ -0,0 +1,77 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/26 21:15
+ */
+package utils
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "github.com/nyaruka/phonenumbers"
+)
+
+const (
+    DEFAULT_COUNTRY_CODE    = "86"      // 默认中国大陆
+    MAINLAND_PHONE_PREFIX   = "+86"
+)
+
+// 国际区号(86、+852)或地区代码(CN、HK)转换成地区代码
+func phoneRegion(country_code string) (string, error) {
+    country_code = strings.TrimPrefix(strings.TrimSpace(country_code), "+")
+    if country_code == "" {
+        country_code = DEFAULT_COUNTRY_CODE
+    }
+    if code, err := strconv.Atoi(country_code); nil == err {
+        region := phonenumbers.GetRegionCodeForCountryCode(code)
+        if region == "" || region == phonenumbers.UNKNOWN_REGION {
+            return "", fmt.Errorf("unknown country code: %s", country_code)
+        }
+        return region, nil
+    }
+    region := strings.ToUpper(country_code)
+    if phonenumbers.GetCountryCodeForRegion(region) == 0 {
+        return "", fmt.Errorf("unknown region: %s", country_code)
+    }
+    return region, nil
+}
+
+/**
+ * 电话号码转换成E.164格式，如 +8613800000000
+ *
+ * 按地区规则校验，只接受手机号码；phone 以+开头时忽略 country_code
+ */
+func NormalizePhone(phone, country_code string) (string, error) {
+    phone = strings.TrimSpace(phone)
+    if phone == "" {
+        return "", fmt.Errorf("phone is empty")
+    }
+    region, err := phoneRegion(country_code)
+    if nil != err {
+        return "", err
+    }
+
+    number, err := phonenumbers.Parse(phone, region)
+    if nil != err {
+        return "", err
+    }
+    if !phonenumbers.IsValidNumber(number) {
+        return "", fmt.Errorf("invalid phone: %s, region: %s", phone, region)
+    }
+    number_type := phonenumbers.GetNumberType(number)
+    if number_type != phonenumbers.MOBILE && number_type != phonenumbers.FIXED_LINE_OR_MOBILE {
+        return "", fmt.Errorf("not mobile phone: %s, region: %s", phone, region)
+    }
+    return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// 是否中国大陆号码，兼容未转换的旧号码
+func IsMainlandPhone(phone string) bool {
+    return !strings.HasPrefix(phone, "+") || strings.HasPrefix(phone, MAINLAND_PHONE_PREFIX)
+}
+
+// 中国大陆号码去掉国际区号
+func MainlandPhone(phone string) string {
+    return strings.TrimPrefix(phone, MAINLAND_PHONE_PREFIX)
+}
@@ -0,0 +1,105 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/2 20:36
+ */
+package utils
+
+import (
+    "encoding/json"
+    "errors"
+    "github.com/chanxuehong/session"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// session存储，value会序列化成json保存
+type SessionStore interface {
+    Add(sid string, value interface{}) error
+    // value必须是指针，session不存在或已过期返回ErrSessionNotFound
+    Get(sid string, value interface{}) error
+    Delete(sid string) error
+}
+
+// redis session存储，支持多实例部署
+type RedisSessionStore struct {
+    cache       *Cache
+    prefix      string
+    expire      int     // 过期时间，单位秒
+}
+
+func NewRedisSessionStore(cache *Cache, prefix string, expire int) *RedisSessionStore {
+    return &RedisSessionStore{
+        cache:  cache,
+        prefix: prefix,
+        expire: expire,
+    }
+}
+
+func (store *RedisSessionStore) key(sid string) string {
+    return store.prefix + sid
+}
+
+func (store *RedisSessionStore) Add(sid string, value interface{}) error {
+    bytes, err := json.Marshal(value)
+    if nil != err {
+        return NewInternalError(DecodeErrCode, err)
+    }
+    return store.cache.Set(store.key(sid), bytes, store.expire)
+}
+
+func (store *RedisSessionStore) Get(sid string, value interface{}) error {
+    bytes, err := store.cache.Get(store.key(sid))
+    if nil != err && CheckRedisReturnValue(err) != KeyNotFound {
+        return err
+    }
+    if bytes == nil {
+        return ErrSessionNotFound
+    }
+    if err = json.Unmarshal(bytes, value); nil != err {
+        return NewInternalError(DecodeErrCode, err)
+    }
+    return nil
+}
+
+func (store *RedisSessionStore) Delete(sid string) error {
+    return store.cache.Del(store.key(sid))
+}
+
+// 内存session存储，只能单实例使用，用于本地开发
+type MemorySessionStore struct {
+    storage     *session.Storage
+}
+
+func NewMemorySessionStore(max_age, limit int) *MemorySessionStore {
+    return &MemorySessionStore{
+        storage: session.New(max_age, limit),
+    }
+}
+
+func (store *MemorySessionStore) Add(sid string, value interface{}) error {
+    bytes, err := json.Marshal(value)
+    if nil != err {
+        return NewInternalError(DecodeErrCode, err)
+    }
+    return store.storage.Add(sid, bytes)
+}
+
+func (store *MemorySessionStore) Get(sid string, value interface{}) error {
+    data, err := store.storage.Get(sid)
+    if nil != err {
+        return ErrSessionNotFound
+    }
+    bytes, ok := data.([]byte)
+    if !ok {
+        return ErrSessionNotFound
+    }
+    if err = json.Unmarshal(bytes, value); nil != err {
+        return NewInternalError(DecodeErrCode, err)
+    }
+    return nil
+}
+
+func (store *MemorySessionStore) Delete(sid string) error {
+    return store.storage.Delete(sid)
+}
@@ -0,0 +1,103 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/8 20:10
+ */
+package utils
+
+import (
+    "bytes"
+    "encoding/csv"
+    "path/filepath"
+    "strings"
+    "unicode/utf8"
+    "github.com/tealeg/xlsx"
+)
+
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+/**
+ * 读取表格文件，按扩展名支持 csv 和 xlsx，xlsx 只读取第一个工作表
+ *
+ * 单元格内容去掉首尾空白，csv 需要 UTF-8 编码
+ */
+func ReadSheetRows(filename string, data []byte) ([][]string, error) {
+    var rows [][]string
+    switch strings.ToLower(filepath.Ext(filename)) {
+    case ".csv":
+        data = bytes.TrimPrefix(data, utf8Bom)
+        if !utf8.Valid(data) {
+            return nil, NewInternalErrorByStr(ParameterErrCode, "csv文件需要使用UTF-8编码")
+        }
+        reader := csv.NewReader(bytes.NewReader(data))
+        reader.FieldsPerRecord = -1
+        reader.LazyQuotes = true
+        records, err := reader.ReadAll()
+        if nil != err {
+            Logger.Error("read csv err, filename: %s, err: %v", filename, err)
+            return nil, NewInternalErrorByStr(ParameterErrCode, "csv文件格式错误")
+        }
+        rows = records
+    case ".xlsx":
+        file, err := xlsx.OpenBinary(data)
+        if nil != err {
+            Logger.Error("read xlsx err, filename: %s, err: %v", filename, err)
+            return nil, NewInternalErrorByStr(ParameterErrCode, "xlsx文件格式错误")
+        }
+        if len(file.Sheets) == 0 {
+            return nil, NewInternalErrorByStr(ParameterErrCode, "xlsx文件没有工作表")
+        }
+        for _, row := range file.Sheets[0].Rows {
+            cells := make([]string, 0, len(row.Cells))
+            for _, cell := range row.Cells {
+                cells = append(cells, cell.String())
+            }
+            rows = append(rows, cells)
+        }
+    default:
+        return nil, NewInternalErrorByStr(ParameterErrCode, "只支持csv、xlsx格式的文件")
+    }
+
+    for i := range rows {
+        for j := range rows[i] {
+            rows[i][j] = strings.TrimSpace(rows[i][j])
+        }
+    }
+    return rows, nil
+}
+
+// 以 = + - @ 开头的单元格在表格软件中会被当作公式，纯数字除外
+func csvCellSafe(value string) string {
+    if value == "" || !strings.ContainsRune("=+-@", rune(value[0])) {
+        return value
+    }
+    if len(value) > 1 && strings.Trim(value[1:], "0123456789") == "" {
+        return value
+    }
+    return "'" + value
+}
+
+/**
+ * 生成csv文件，带UTF-8 BOM，Excel打开时不会乱码
+ *
+ * 可能被当作公式的单元格前面加单引号
+ */
+func WriteCsvRows(rows [][]string) ([]byte, error) {
+    var buf bytes.Buffer
+    buf.Write(utf8Bom)
+    writer := csv.NewWriter(&buf)
+    for _, row := range rows {
+        cells := make([]string, len(row))
+        for i, value := range row {
+            cells[i] = csvCellSafe(value)
+        }
+        if err := writer.Write(cells); nil != err {
+            return nil, err
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); nil != err {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
@@ -0,0 +1,163 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/16 14:30
+ */
+package utils
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sync"
+    "time"
+)
+
+// 短信用途，每种用途在配置中对应各服务商的模板id
+const (
+    SMS_PURPOSE_VERIFY_CODE         = "verify_code"         // 验证码，参数: code
+    SMS_PURPOSE_ACTIVITY_PROMOTED   = "activity_promoted"   // 活动候补递补成功，参数: title, start_time
+)
+
+// 国际短信模板的用途后缀，如 verify_code_intl
+const SMS_INTL_SUFFIX = "_intl"
+
+// 云片未配置模板时使用的短信内容，和升级前发送的内容一致
+var yunpianDefaultTexts = map[string]string{
+    SMS_PURPOSE_VERIFY_CODE:    "您的验证码是#code#。如非本人操作，请忽略本短信",
+}
+
+// 短信发送
+type SmsSender interface {
+    Name() string
+    // 按用途对应的模板发送短信，phone为E.164格式，params为模板参数
+    Send(phone, purpose string, params map[string]string) error
+}
+
+var SmsClient SmsSender
+
+// 按配置初始化短信发送，配置多个服务商时按顺序失败切换
+func InitSmsSender() error {
+    config := &Config.SmsSetting
+
+    providers := config.Providers
+    if len(providers) == 0 {
+        if IsOnline() {
+            providers = []string{"yunpian"}
+        } else {
+            providers = []string{"log"}
+        }
+    }
+
+    senders := make([]SmsSender, 0, len(providers))
+    for _, provider := range providers {
+        var sender SmsSender
+        switch provider {
+        case "yunpian":
+            api_key := config.Yunpian.ApiKey
+            if api_key == "" {
+                api_key = Config.External["ypApiKey"]
+            }
+            sender = NewYunpianSmsSender(api_key, config.Templates)
+        case "aliyun":
+            // 阿里云只能按模板发送，验证码模板未配置时启动失败，不在用户请求时才报错
+            if config.Templates[SMS_PURPOSE_VERIFY_CODE]["aliyun"] == "" {
+                return fmt.Errorf("aliyun sms template not configured, purpose: %s", SMS_PURPOSE_VERIFY_CODE)
+            }
+            sender = NewAliyunSmsSender(&config.Aliyun, config.Templates)
+        case "log":
+            log_sender, err := NewLogSmsSender(config.LogFile)
+            if nil != err {
+                return err
+            }
+            sender = log_sender
+        default:
+            return fmt.Errorf("unknown sms provider: %s", provider)
+        }
+        senders = append(senders, sender)
+    }
+
+    if len(senders) == 1 {
+        SmsClient = senders[0]
+    } else {
+        SmsClient = NewFailoverSmsSender(senders...)
+    }
+    return nil
+}
+
+// 发送短信
+func SendSms(phone, purpose string, params map[string]string) error {
+    return SmsClient.Send(phone, purpose, params)
+}
+
+// 获取用途对应服务商的模板id，非中国大陆号码使用国际短信模板
+func smsTemplate(templates map[string]map[string]string, phone, purpose, provider string) (string, error) {
+    if !IsMainlandPhone(phone) {
+        purpose = purpose + SMS_INTL_SUFFIX
+    }
+    tpl_id, ok := templates[purpose][provider]
+    if !ok || tpl_id == "" {
+        return "", fmt.Errorf("%s sms template not configured, purpose: %s", provider, purpose)
+    }
+    return tpl_id, nil
+}
+
+// 失败切换，按顺序尝试，前一个服务商发送失败时使用下一个
+type FailoverSmsSender struct {
+    senders     []SmsSender
+}
+
+func NewFailoverSmsSender(senders ...SmsSender) *FailoverSmsSender {
+    return &FailoverSmsSender{senders: senders}
+}
+
+func (sender *FailoverSmsSender) Name() string {
+    return "failover"
+}
+
+func (sender *FailoverSmsSender) Send(phone, purpose string, params map[string]string) error {
+    var err error
+    for _, s := range sender.senders {
+        err = s.Send(phone, purpose, params)
+        if nil == err {
+            return nil
+        }
+        Logger.Error("%s send sms failed, phone: %s, purpose: %s, err: %v", s.Name(), phone, purpose, err)
+    }
+    return err
+}
+
+// 短信写入文件或日志，用于开发和测试环境
+type LogSmsSender struct {
+    lock        sync.Mutex
+    file        *os.File
+}
+
+func NewLogSmsSender(filename string) (*LogSmsSender, error) {
+    sender := new(LogSmsSender)
+    if filename != "" {
+        file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+        if nil != err {
+            return nil, err
+        }
+        sender.file = file
+    }
+    return sender, nil
+}
+
+func (sender *LogSmsSender) Name() string {
+    return "log"
+}
+
+func (sender *LogSmsSender) Send(phone, purpose string, params map[string]string) error {
+    bytes, _ := json.Marshal(params)
+    Logger.Notice("[sms] phone: %s, purpose: %s, params: %s", phone, purpose, string(bytes))
+
+    if nil == sender.file {
+        return nil
+    }
+    sender.lock.Lock()
+    defer sender.lock.Unlock()
+    _, err := fmt.Fprintf(sender.file, "%s\t%s\t%s\t%s\n", time.Now().Format("2006-01-02 15:04:05"), phone, purpose, string(bytes))
+    return err
+}
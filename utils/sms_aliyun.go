@@ -0,0 +1,132 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/16 16:10
+ */
+package utils
+
+import (
+    "crypto/hmac"
+    "crypto/sha1"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+)
+
+const aliyunSmsApi = "https://dysmsapi.aliyuncs.com/"
+
+// 阿里云短信
+type AliyunSmsSender struct {
+    config      *SmsProviderConfig
+    templates   map[string]map[string]string
+    client      *http.Client
+}
+
+func NewAliyunSmsSender(config *SmsProviderConfig, templates map[string]map[string]string) *AliyunSmsSender {
+    return &AliyunSmsSender{
+        config:     config,
+        templates:  templates,
+        client:     &http.Client{Timeout: 5 * time.Second},
+    }
+}
+
+func (sender *AliyunSmsSender) Name() string {
+    return "aliyun"
+}
+
+// 阿里云签名要求的url编码
+func aliyunPercentEncode(s string) string {
+    s = url.QueryEscape(s)
+    s = strings.Replace(s, "+", "%20", -1)
+    s = strings.Replace(s, "*", "%2A", -1)
+    s = strings.Replace(s, "%7E", "~", -1)
+    return s
+}
+
+// 阿里云 pop 签名
+func aliyunSign(secret string, params map[string]string) string {
+    keys := make([]string, 0, len(params))
+    for key := range params {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    pairs := make([]string, 0, len(keys))
+    for _, key := range keys {
+        pairs = append(pairs, aliyunPercentEncode(key)+"="+aliyunPercentEncode(params[key]))
+    }
+    query := strings.Join(pairs, "&")
+
+    string_to_sign := "GET&" + aliyunPercentEncode("/") + "&" + aliyunPercentEncode(query)
+    mac := hmac.New(sha1.New, []byte(secret+"&"))
+    mac.Write([]byte(string_to_sign))
+    return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (sender *AliyunSmsSender) Send(phone, purpose string, params map[string]string) error {
+    tpl_id, err := smsTemplate(sender.templates, phone, purpose, sender.Name())
+    if nil != err {
+        return err
+    }
+    tpl_param, _ := json.Marshal(params)
+    nonce, err := RandomHex(16)
+    if nil != err {
+        return err
+    }
+
+    // 国内号码不带区号，国际号码为区号加号码，不带+
+    mobile := strings.TrimPrefix(phone, "+")
+    if IsMainlandPhone(phone) {
+        mobile = MainlandPhone(phone)
+    }
+
+    query := map[string]string{
+        "AccessKeyId":      sender.config.ApiKey,
+        "Action":           "SendSms",
+        "Format":           "JSON",
+        "PhoneNumbers":     mobile,
+        "RegionId":         "cn-hangzhou",
+        "SignName":         sender.config.SignName,
+        "SignatureMethod":  "HMAC-SHA1",
+        "SignatureNonce":   nonce,
+        "SignatureVersion": "1.0",
+        "TemplateCode":     tpl_id,
+        "TemplateParam":    string(tpl_param),
+        "Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+        "Version":          "2017-05-25",
+    }
+    values := url.Values{}
+    for key, value := range query {
+        values.Set(key, value)
+    }
+    values.Set("Signature", aliyunSign(sender.config.ApiSecret, query))
+
+    resp, err := sender.client.Get(aliyunSmsApi + "?" + values.Encode())
+    if nil != err {
+        return err
+    }
+    defer resp.Body.Close()
+
+    body, err := ioutil.ReadAll(resp.Body)
+    if nil != err {
+        return err
+    }
+    var result struct {
+        Code        string
+        Message     string
+        RequestId   string
+    }
+    if err = json.Unmarshal(body, &result); nil != err {
+        return fmt.Errorf("aliyun send sms failed, status: %d, body: %s", resp.StatusCode, string(body))
+    }
+    if result.Code != "OK" {
+        return fmt.Errorf("aliyun send sms failed, code: %s, msg: %s, request_id: %s", result.Code, result.Message, result.RequestId)
+    }
+    return nil
+}
@@ -0,0 +1,93 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/16 15:02
+ */
+package utils
+
+import (
+    "fmt"
+    "net/url"
+    "sort"
+    "strings"
+    ypclnt "github.com/yunpian/yunpian-go-sdk/sdk"
+)
+
+// 云片短信
+type YunpianSmsSender struct {
+    client      ypclnt.YunpianClient
+    templates   map[string]map[string]string
+}
+
+func NewYunpianSmsSender(api_key string, templates map[string]map[string]string) *YunpianSmsSender {
+    return &YunpianSmsSender{
+        client:     ypclnt.New(api_key),
+        templates:  templates,
+    }
+}
+
+func (sender *YunpianSmsSender) Name() string {
+    return "yunpian"
+}
+
+// 模板参数格式: urlencode(#key#)=urlencode(value)&...
+func yunpianTplValue(params map[string]string) string {
+    keys := make([]string, 0, len(params))
+    for key := range params {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    values := make([]string, 0, len(keys))
+    for _, key := range keys {
+        values = append(values, url.QueryEscape("#"+key+"#")+"="+url.QueryEscape(params[key]))
+    }
+    return strings.Join(values, "&")
+}
+
+// 未配置模板时按默认内容发送，没有默认内容的用途返回错误
+func yunpianDefaultText(purpose string, params map[string]string) (string, bool) {
+    text, ok := yunpianDefaultTexts[purpose]
+    if !ok {
+        return "", false
+    }
+    for key, value := range params {
+        text = strings.Replace(text, "#"+key+"#", value, -1)
+    }
+    return text, true
+}
+
+func (sender *YunpianSmsSender) Send(phone, purpose string, params map[string]string) error {
+    // 国内号码不带区号，国际号码使用E.164格式
+    mobile := phone
+    if IsMainlandPhone(phone) {
+        mobile = MainlandPhone(phone)
+    }
+
+    var result *ypclnt.Result
+    tpl_id, err := smsTemplate(sender.templates, phone, purpose, sender.Name())
+    if nil == err {
+        param := ypclnt.NewParam(3)
+        param[ypclnt.MOBILE] = mobile
+        param[ypclnt.TPL_ID] = tpl_id
+        param[ypclnt.TPL_VALUE] = yunpianTplValue(params)
+        result = sender.client.Sms().TplSingleSend(param)
+    } else {
+        text, ok := yunpianDefaultText(purpose, params)
+        if !ok {
+            return err
+        }
+        param := ypclnt.NewParam(2)
+        param[ypclnt.MOBILE] = mobile
+        param[ypclnt.TEXT] = text
+        result = sender.client.Sms().SingleSend(param)
+    }
+
+    if result == nil {
+        return fmt.Errorf("yunpian send sms failed, call client error")
+    }
+    if result.Code != 0 {
+        return fmt.Errorf("yunpian send sms failed, code: %d, msg: %s, detail: %s", result.Code, result.Msg, result.Detail)
+    }
+    return nil
+}
@@ -0,0 +1,112 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/12/9 21:18
+ */
+package utils
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// 文件存储，返回文件访问地址
+type FileStorage interface {
+    Save(key string, data []byte, content_type string) (string, error)
+}
+
+var Storage FileStorage
+
+// 配置了oss bucket时使用oss，否则保存到本地磁盘
+func InitFileStorage() error {
+    var err error
+    if Config.OssSetting.Bucket != "" {
+        Storage, err = NewOssFileStorage(&Config.OssSetting)
+    } else {
+        Storage, err = NewLocalFileStorage(LocalUploadDir(), Config.External["UploadUrlPrefix"])
+    }
+    return err
+}
+
+// 本地文件保存目录
+func LocalUploadDir() string {
+    if dir := Config.External["UploadDir"]; dir != "" {
+        return dir
+    }
+    return "/var/www/upload"
+}
+
+// 阿里云oss存储
+type OssFileStorage struct {
+    bucket      *oss.Bucket
+    domain      string
+}
+
+func NewOssFileStorage(config *OssConfig) (*OssFileStorage, error) {
+    client, err := oss.New(fmt.Sprintf("http://%s.aliyuncs.com", config.Region), config.AccessKeyId, config.AccessKeySecret)
+    if nil != err {
+        Logger.Error("new oss client err: %v", err)
+        return nil, err
+    }
+    bucket, err := client.Bucket(config.Bucket)
+    if nil != err {
+        Logger.Error("get oss bucket err: %v", err)
+        return nil, err
+    }
+
+    domain := config.Domain
+    if domain == "" {
+        domain = fmt.Sprintf("http://%s.%s.aliyuncs.com", config.Bucket, config.Region)
+    }
+    return &OssFileStorage{
+        bucket: bucket,
+        domain: strings.TrimRight(domain, "/"),
+    }, nil
+}
+
+func (storage *OssFileStorage) Save(key string, data []byte, content_type string) (string, error) {
+    err := storage.bucket.PutObject(key, bytes.NewReader(data), oss.ContentType(content_type))
+    if nil != err {
+        Logger.Error("put oss object err, key: %s, err: %v", key, err)
+        return "", err
+    }
+    return storage.domain + "/" + key, nil
+}
+
+// 本地磁盘存储，用于开发环境
+type LocalFileStorage struct {
+    dir         string
+    url_prefix  string
+}
+
+func NewLocalFileStorage(dir, url_prefix string) (*LocalFileStorage, error) {
+    if err := os.MkdirAll(dir, 0755); nil != err {
+        Logger.Error("make upload dir err, dir: %s, err: %v", dir, err)
+        return nil, err
+    }
+    if url_prefix == "" {
+        url_prefix = "/upload"
+    }
+    return &LocalFileStorage{
+        dir:        dir,
+        url_prefix: strings.TrimRight(url_prefix, "/"),
+    }, nil
+}
+
+func (storage *LocalFileStorage) Save(key string, data []byte, content_type string) (string, error) {
+    filename := filepath.Join(storage.dir, filepath.FromSlash(key))
+    if err := os.MkdirAll(filepath.Dir(filename), 0755); nil != err {
+        Logger.Error("make upload dir err, key: %s, err: %v", key, err)
+        return "", err
+    }
+    if err := ioutil.WriteFile(filename, data, 0644); nil != err {
+        Logger.Error("write upload file err, key: %s, err: %v", key, err)
+        return "", err
+    }
+    return storage.url_prefix + "/" + key, nil
+}
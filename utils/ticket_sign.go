@@ -0,0 +1,140 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2018/1/3 20:10
+ */
+package utils
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "golang.org/x/crypto/ed25519"
+)
+
+/**
+ * 门票二维码内容: PT1.{key_id}.{ticket_no}.{edition_id}.{valid_from}.{valid_to}.{sign}
+ *
+ * valid_from、valid_to 为unix时间戳，sign 为前面内容的 Ed25519 签名，base64url 编码
+ * 扫码设备只需要公钥即可离线校验
+ */
+const TICKET_PAYLOAD_VERSION = "PT1"
+
+var ErrTicketPayloadInvalid = errors.New("ticket payload invalid")
+
+type TicketClaims struct {
+    KeyId           string
+    TicketNo        string
+    EditionId       int64
+    ValidFrom       int64
+    ValidTo         int64
+}
+
+// 是否在有效期内
+func (claims *TicketClaims) ValidAt(t time.Time) bool {
+    return t.Unix() >= claims.ValidFrom && t.Unix() <= claims.ValidTo
+}
+
+func (claims *TicketClaims) content() string {
+    return strings.Join([]string{TICKET_PAYLOAD_VERSION, claims.KeyId, claims.TicketNo,
+        strconv.FormatInt(claims.EditionId, 10), strconv.FormatInt(claims.ValidFrom, 10),
+        strconv.FormatInt(claims.ValidTo, 10)}, ".")
+}
+
+// 门票签名
+type TicketSigner struct {
+    keyId           string
+    privateKey      ed25519.PrivateKey
+    publicKey       ed25519.PublicKey
+}
+
+var TicketSign *TicketSigner
+
+func NewTicketSigner(key_id string, seed []byte) (*TicketSigner, error) {
+    if len(seed) != ed25519.SeedSize {
+        return nil, fmt.Errorf("ticket sign key must be %d bytes", ed25519.SeedSize)
+    }
+    if key_id == "" || strings.Contains(key_id, ".") {
+        return nil, fmt.Errorf("ticket key id invalid: %s", key_id)
+    }
+    private_key := ed25519.NewKeyFromSeed(seed)
+    return &TicketSigner{
+        keyId:      key_id,
+        privateKey: private_key,
+        publicKey:  private_key.Public().(ed25519.PublicKey),
+    }, nil
+}
+
+// 按配置初始化门票签名，非线上环境未配置密钥时使用临时密钥
+func InitTicketSigner() error {
+    config := &Config.TicketSetting
+    key_id := config.KeyId
+    if key_id == "" {
+        key_id = "1"
+    }
+
+    var seed []byte
+    var err error
+    if config.SignKey == "" {
+        if IsOnline() {
+            return fmt.Errorf("ticket sign key not configured")
+        }
+        seed = make([]byte, ed25519.SeedSize)
+        if _, err = rand.Read(seed); nil != err {
+            return err
+        }
+        Logger.Warning("ticket sign key not configured, use temporary key, env: %s", Env)
+    } else {
+        seed, err = base64.StdEncoding.DecodeString(config.SignKey)
+        if nil != err {
+            return fmt.Errorf("ticket sign key decode err: %v", err)
+        }
+    }
+
+    TicketSign, err = NewTicketSigner(key_id, seed)
+    return err
+}
+
+func (signer *TicketSigner) KeyId() string {
+    return signer.keyId
+}
+
+// 公钥，base64编码
+func (signer *TicketSigner) PublicKey() string {
+    return base64.StdEncoding.EncodeToString(signer.publicKey)
+}
+
+// 生成门票二维码内容
+func (signer *TicketSigner) Sign(claims *TicketClaims) string {
+    claims.KeyId = signer.keyId
+    content := claims.content()
+    sign := ed25519.Sign(signer.privateKey, []byte(content))
+    return content + "." + base64.RawURLEncoding.EncodeToString(sign)
+}
+
+// 校验门票二维码内容的签名，不校验有效期
+func (signer *TicketSigner) Verify(payload string) (*TicketClaims, error) {
+    parts := strings.Split(strings.TrimSpace(payload), ".")
+    if len(parts) != 7 || parts[0] != TICKET_PAYLOAD_VERSION || parts[1] != signer.keyId || parts[2] == "" {
+        return nil, ErrTicketPayloadInvalid
+    }
+
+    claims := &TicketClaims{KeyId: parts[1], TicketNo: parts[2]}
+    var err1, err2, err3 error
+    claims.EditionId, err1 = strconv.ParseInt(parts[3], 10, 64)
+    claims.ValidFrom, err2 = strconv.ParseInt(parts[4], 10, 64)
+    claims.ValidTo, err3 = strconv.ParseInt(parts[5], 10, 64)
+    if nil != err1 || nil != err2 || nil != err3 {
+        return nil, ErrTicketPayloadInvalid
+    }
+
+    sign, err := base64.RawURLEncoding.DecodeString(parts[6])
+    if nil != err || !ed25519.Verify(signer.publicKey, []byte(claims.content()), sign) {
+        return nil, ErrTicketPayloadInvalid
+    }
+    return claims, nil
+}
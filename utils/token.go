@@ -0,0 +1,96 @@
+/**
+ * @author liangbo
+ * @email  liangbogopher87@gmail.com
+ * @date   2017/11/12 16:20
+ */
+package utils
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const TOKEN_SECRET_MIN_LEN = 32
+
+// 检查token签名密钥，线上必须配置，默认值在代码中公开，只能用于开发和测试环境
+func InitTokenSecret() error {
+    secret := ""
+    if nil != Config {
+        secret = Config.External["TokenSecret"]
+    }
+    if len(secret) >= TOKEN_SECRET_MIN_LEN {
+        return nil
+    }
+    if IsOnline() {
+        return fmt.Errorf("token secret not configured or shorter than %d bytes", TOKEN_SECRET_MIN_LEN)
+    }
+    if secret == "" {
+        Logger.Warning("token secret not configured, use default secret, env: %s", Env)
+    }
+    return nil
+}
+
+// token签名密钥，未配置时使用默认值，线上启动时已检查必须配置
+func TokenSecret() string {
+    if nil != Config {
+        if secret, ok := Config.External["TokenSecret"]; ok && secret != "" {
+            return secret
+        }
+    }
+    return AESTable
+}
+
+// 生成随机的16进制字符串，n为字节数
+func RandomHex(n int) (string, error) {
+    buf := make([]byte, n)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf), nil
+}
+
+// hmac-sha256 签名
+func HmacSha256(secret, content string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(content))
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 生成签名token, 格式: user_id.expire_time.nonce.sign
+func GenerateSignedToken(user_id int64, expire_time int64) (string, error) {
+    nonce, err := RandomHex(16)
+    if nil != err {
+        return "", err
+    }
+    content := fmt.Sprintf("%d.%d.%s", user_id, expire_time, nonce)
+    return content + "." + HmacSha256(TokenSecret(), content), nil
+}
+
+// 校验token签名和过期时间，返回token中的user_id
+func ParseSignedToken(token string) (user_id int64, ok bool) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 4 {
+        return 0, false
+    }
+
+    content := strings.Join(parts[:3], ".")
+    if !hmac.Equal([]byte(parts[3]), []byte(HmacSha256(TokenSecret(), content))) {
+        return 0, false
+    }
+
+    user_id, err := strconv.ParseInt(parts[0], 10, 64)
+    if nil != err || user_id <= 0 {
+        return 0, false
+    }
+    expire_time, err := strconv.ParseInt(parts[1], 10, 64)
+    if nil != err || expire_time < time.Now().Unix() {
+        return 0, false
+    }
+    return user_id, true
+}
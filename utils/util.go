@@ -15,7 +15,6 @@ import (
     "reflect"
     "encoding/hex"
     "regexp"
-    ypclnt "github.com/yunpian/yunpian-go-sdk/sdk"
 )
 
 var (
@@ -29,10 +28,6 @@ var (
     AESBlock       cipher.Block
     ErrAESTextSize = errors.New("ciphertext is not a multiple of the block size")
     ErrAESPadding  = errors.New("cipher padding size err")
-
-    // 云片sms
-    ypApiKey            string
-    ypClient            ypclnt.YunpianClient
 )
 
 var ses_res_chan chan struct{} = make(chan struct{}, 10) // aws ses send limit is 14 per second
@@ -41,6 +36,12 @@ const (
     AESTable = "shanghaipet1464345----1577808000"
 )
 
+// 接口返回的时间格式
+const (
+    TIME_FORMAT = "2006-01-02 15:04:05"
+    DATE_FORMAT = "2006-01-02"
+)
+
 func init() {
     var err error
     AESBlock, err = aes.NewCipher([]byte(AESTable))
@@ -55,12 +56,6 @@ func init() {
     }
 }
 
-func InitYpClient() {
-    // 云片client
-    ypApiKey            = Config.External["ypApiKey"]
-    ypClient            = ypclnt.New(ypApiKey)
-}
-
 // aes 加密
 func AesEncrypt(srcStr string) string {
     src := []byte(srcStr)
@@ -137,31 +132,23 @@ func DumpStruct(to interface{}, from interface{}) {
     }
 }
 
-// 校验电话号码
+// 校验中国大陆手机号码，不带国际区号
 func PhoneValid(phone string) bool {
     var ret bool
 
-    reg := `^1(3|4|5|7|8)[0-9]\d{8}$`
+    reg := `^1[3-9]\d{9}$`
     rgx := regexp.MustCompile(reg)
     ret = rgx.MatchString(phone)
 
     return ret
 }
 
-// 云片发送验证码
-func YpSendSms(phone, code string) int {
-    param := ypclnt.NewParam(2)
-    param[ypclnt.MOBILE] = phone
-    param[ypclnt.TEXT] = "您的验证码是" + code +"。如非本人操作，请忽略本短信"
-    result := ypClient.Sms().SingleSend(param)
-
-    if result == nil || result.Code != 0 {
-    	if result != nil {
-			Logger.Error("Yunpian send sms failed, result: %+v", result)
-		} else {
-			Logger.Error("Yunpian send sms failed, call ypClient error")
-		}
-        return 1
+// 校验邮箱
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9\-]+(\.[a-zA-Z0-9\-]+)*\.[a-zA-Z]{2,}$`)
+
+func EmailValid(email string) bool {
+    if len(email) > 128 {
+        return false
     }
-    return 0
-}
\ No newline at end of file
+    return emailRegexp.MatchString(email)
+}
@@ -10,18 +10,21 @@ import (
     "github.com/chanxuehong/wechat.v2/mp/message/callback/request"
     "github.com/chanxuehong/wechat.v2/mp/menu"
     "github.com/chanxuehong/wechat.v2/mp/message/callback/response"
+    "github.com/chanxuehong/wechat.v2/mp/message/template"
     "third/gin"
     "pet/utils"
+    "pet/controller"
 
     mpoauth2 "github.com/chanxuehong/wechat.v2/mp/oauth2"
     "github.com/chanxuehong/wechat.v2/oauth2"
-    "github.com/chanxuehong/session"
     "net/http"
     "github.com/chanxuehong/sid"
     "io"
     "github.com/chanxuehong/rand"
     "fmt"
     "net/url"
+    "time"
+    "encoding/json"
 )
 
 const (
@@ -46,9 +49,15 @@ var (
     wechatClient      *core.Client
 
     oauth2Endpoint      oauth2.Endpoint
-    sessionStorage      = session.New(20*60, 60*60)
+    sessionStorage      utils.SessionStore
 )
 
+// 授权页面保存的session
+type oauthSession struct {
+    State           string      `json:"state"`
+    CreateTime      int64       `json:"create_time"`
+}
+
 func InitWeixinServer() {
     mux := core.NewServeMux()
     // 默认处理
@@ -71,6 +80,36 @@ func InitWeixinServer() {
     wechatClient        = core.NewClient(accessTokenServer, nil)
 
     oauth2Endpoint      = mpoauth2.NewEndpoint(wxAppId, wxAppSecret)
+
+    // 默认使用redis保存授权session，支持多实例部署，本地开发可配置为memory
+    if utils.Config.External["SessionStore"] == "memory" {
+        sessionStorage  = utils.NewMemorySessionStore(20*60, 60*60)
+    } else {
+        sessionStorage  = utils.NewRedisSessionStore(controller.GetCache(), "wx_oauth_session:", 20*60)
+    }
+
+    controller.WeixinTemplateSender = sendWeixinTemplate
+}
+
+// 发送微信模板消息
+func sendWeixinTemplate(openid, template_id, url string, data map[string]string) error {
+    items := make(map[string]template.DataItem, len(data))
+    for key, value := range data {
+        items[key] = template.DataItem{Value: value}
+    }
+    bytes, err := json.Marshal(items)
+    if nil != err {
+        return err
+    }
+
+    msg := &template.TemplateMessage{
+        ToUser:     openid,
+        TemplateId: template_id,
+        URL:        url,
+        Data:       bytes,
+    }
+    _, err = template.Send(wechatClient, msg)
+    return err
 }
 
 func defaultMsgHandler(ctx *core.Context) {
@@ -116,7 +155,11 @@ func AuthPage(w http.ResponseWriter, r *http.Request) {
     sid := sid.New()
     state := string(rand.NewHex())
 
-    if err := sessionStorage.Add(sid, state); err != nil {
+    oauth_session := oauthSession{
+        State:      state,
+        CreateTime: time.Now().Unix(),
+    }
+    if err := sessionStorage.Add(sid, &oauth_session); err != nil {
         io.WriteString(w, err.Error())
         utils.Logger.Error("add session err: %v", err)
         return
@@ -145,14 +188,15 @@ func AuthCallback(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    session, err := sessionStorage.Get(cookie.Value)
+    var oauth_session oauthSession
+    err = sessionStorage.Get(cookie.Value, &oauth_session)
     if err != nil {
         io.WriteString(w, err.Error())
         utils.Logger.Error("get session err: %v", err)
         return
     }
 
-    savedState := session.(string) // 一般是要序列化的, 这里保存在内存所以可以这么做
+    savedState := oauth_session.State
 
     queryValues, err := url.ParseQuery(r.URL.RawQuery)
     if err != nil {
@@ -179,6 +223,11 @@ func AuthCallback(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // state 只能使用一次
+    if err = sessionStorage.Delete(cookie.Value); err != nil {
+        utils.Logger.Error("delete session err: %v", err)
+    }
+
     oauth2Client := oauth2.Client{
         Endpoint: oauth2Endpoint,
     }
@@ -196,10 +245,24 @@ func AuthCallback(w http.ResponseWriter, r *http.Request) {
         utils.Logger.Error("get weixin user info err: %v", err)
         return
     }
-    vistorCenterURL := vistorCenterHomeURI + "?openid=" + url.QueryEscape(userinfo.OpenId) +
-        "&nickname=" + url.QueryEscape(userinfo.Nickname) + "&headurl=" + url.QueryEscape(userinfo.HeadImageURL)
+    unionid := userinfo.UnionId
+    if unionid == "" {
+        unionid = token.UnionId
+    }
+
+    // 保存微信用户，跳转时只带一次性登录票据，H5页面用票据换取用户信息
+    ticket, need_phone, err := controller.WeixinOAuthLogin(userinfo.OpenId, unionid, userinfo.Nickname, userinfo.HeadImageURL)
+    if err != nil {
+        io.WriteString(w, err.Error())
+        utils.Logger.Error("weixin oauth login err: %v", err)
+        return
+    }
+    vistorCenterURL := vistorCenterHomeURI + "?ticket=" + url.QueryEscape(ticket)
+    if need_phone {
+        vistorCenterURL += "&need_phone=1"
+    }
 
-	utils.Logger.Info("userinfo: %+v, redirect_url: %s \r\n", userinfo, vistorCenterURL)
+    utils.Logger.Info("userinfo: %+v, redirect_url: %s \r\n", userinfo, vistorCenterURL)
 
     http.Redirect(w, r, vistorCenterURL, http.StatusFound)
     return